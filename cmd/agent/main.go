@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -33,6 +34,13 @@ func run() error {
 
 	log.Printf("Starting agent service")
 
+	if hostRoot := os.Getenv("HOST_ROOT"); hostRoot != "" {
+		if err := agent.SetHostRoot(hostRoot); err != nil {
+			return err
+		}
+		log.Printf("Reading host metrics from %s", hostRoot)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -106,7 +114,42 @@ func run() error {
 func runClient(ctx context.Context, collectorAddr string) error {
 	log.Printf("Connecting to collector at: %s", collectorAddr)
 
-	client, err := agent.NewClient(collectorAddr)
+	hostname, err := agent.Hostname()
+	if err != nil {
+		return err
+	}
+
+	collectors := []agent.Collector{agent.NewInventoryCollector(hostname)}
+	if agent.CgroupV2Available() {
+		collectors = append(collectors, agent.NewCgroupCollector(hostname))
+	}
+	if agent.HwmonAvailable() {
+		collectors = append(collectors, agent.NewSensorCollector(hostname, ""))
+	}
+	if socket := getEnv("DOCKER_SOCKET", agent.DefaultDockerSocket); fileExists(socket) {
+		collectors = append(collectors, agent.NewDockerCollector(hostname, socket))
+	}
+	certPaths := splitList(os.Getenv("CERT_PATHS"))
+	scanPorts := os.Getenv("CERT_SCAN_PORTS") == "true"
+	if len(certPaths) > 0 || scanPorts {
+		collectors = append(collectors, agent.NewCertificateCollector(hostname, certPaths, scanPorts))
+	}
+	if units := splitList(os.Getenv("SYSTEMD_UNITS")); len(units) > 0 {
+		collectors = append(collectors, agent.NewSystemdCollector(hostname, units))
+	}
+	if path := os.Getenv("LOG_RULES_FILE"); path != "" {
+		rules, err := agent.LoadLogRules(path)
+		if err != nil {
+			return err
+		}
+		logs, err := agent.NewLogCollector(hostname, rules)
+		if err != nil {
+			return err
+		}
+		collectors = append(collectors, logs)
+	}
+
+	client, err := agent.NewClient(collectorAddr, agent.WithCollectors(collectors...))
 	if err != nil {
 		return err
 	}
@@ -116,6 +159,22 @@ func runClient(ctx context.Context, collectorAddr string) error {
 	return client.Start(ctx, defaultReportInterval)
 }
 
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// splitList splits a comma separated list, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func getEnv(key, fallback string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -23,9 +23,9 @@ const (
 	defaultPort            = "9090"
 	defaultHTTPPort        = "8080"
 	defaultDBPath          = "/data/metrics.db"
-	defaultInactiveTimeout = 60 * time.Second
 	defaultCleanupInterval = 5 * time.Minute
 	defaultRetentionPeriod = 7 * 24 * time.Hour
+	defaultSkewThreshold   = "30s"
 )
 
 func main() {
@@ -50,8 +50,17 @@ func run() error {
 		return fmt.Errorf("listen: %w", err)
 	}
 
+	skewThreshold, err := time.ParseDuration(getEnv("CLOCK_SKEW_THRESHOLD", defaultSkewThreshold))
+	if err != nil {
+		return fmt.Errorf("parse CLOCK_SKEW_THRESHOLD: %w", err)
+	}
+	skewAction, err := commander.ParseSkewAction(os.Getenv("CLOCK_SKEW_ACTION"))
+	if err != nil {
+		return err
+	}
+
 	grpcServer := grpc.NewServer()
-	server := commander.NewServer(db)
+	server := commander.NewServer(db, commander.WithClockSkew(skewThreshold, skewAction))
 	pb.RegisterMetricsCollectorServer(grpcServer, server)
 
 	healthServer := health.NewServer()
@@ -70,7 +79,14 @@ func run() error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	go startMaintenanceTasks(ctx, db)
+	var decommissionAfter time.Duration
+	if value := os.Getenv("DECOMMISSION_AFTER"); value != "" {
+		if decommissionAfter, err = time.ParseDuration(value); err != nil {
+			return fmt.Errorf("parse DECOMMISSION_AFTER: %w", err)
+		}
+	}
+
+	go startMaintenanceTasks(ctx, db, server, decommissionAfter)
 
 	if err := registerConsul(port, httpPort); err != nil {
 		log.Printf("Warning: failed to register with Consul: %v", err)
@@ -102,24 +118,35 @@ func run() error {
 	}
 }
 
-func startMaintenanceTasks(ctx context.Context, db *commander.DB) {
-	inactiveTicker := time.NewTicker(10 * time.Second)
+// startMaintenanceTasks updates host presence, removes old data and, if
+// decommissionAfter is set, decommissions hosts offline for that long.
+func startMaintenanceTasks(ctx context.Context, db *commander.DB, server *commander.Server, decommissionAfter time.Duration) {
+	presenceTicker := time.NewTicker(10 * time.Second)
 	cleanupTicker := time.NewTicker(defaultCleanupInterval)
-	defer inactiveTicker.Stop()
+	defer presenceTicker.Stop()
 	defer cleanupTicker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-inactiveTicker.C:
-			if err := db.MarkInactive(defaultInactiveTimeout); err != nil {
-				log.Printf("Error marking inactive hosts: %v", err)
+		case <-presenceTicker.C:
+			if err := server.SweepPresence(time.Now()); err != nil {
+				log.Printf("Error updating host presence: %v", err)
 			}
 		case <-cleanupTicker.C:
 			if err := db.CleanupOldUsage(defaultRetentionPeriod); err != nil {
 				log.Printf("Error cleaning up old usage data: %v", err)
 			}
+			if decommissionAfter > 0 {
+				hostnames, err := db.DecommissionOfflineHosts(decommissionAfter)
+				if err != nil {
+					log.Printf("Error decommissioning offline hosts: %v", err)
+				}
+				for _, hostname := range hostnames {
+					log.Printf("Decommissioned %s after being offline for %s", hostname, decommissionAfter)
+				}
+			}
 		}
 	}
 }
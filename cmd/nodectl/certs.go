@@ -0,0 +1,38 @@
+package main
+
+import (
+	"github.com/metorial/sentinel/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+var certsCmd = &cobra.Command{
+	Use:   "certs",
+	Short: "List TLS certificates across the fleet, soonest expiry first",
+	Long: `List the TLS certificates reported by agents, soonest expiry first.
+
+Agents scan the PEM files matching CERT_PATHS (comma separated globs) and, with
+CERT_SCAN_PORTS=true, the certificates served on local listening ports.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hostname, _ := cmd.Flags().GetString("host")
+		days, _ := cmd.Flags().GetInt("days")
+
+		client := cli.NewClient(serverURL)
+		data, err := client.ListCertificates(hostname, days)
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(data, "certificates")
+		}
+
+		return cli.FormatCertificatesTable(data)
+	},
+}
+
+func init() {
+	certsCmd.Flags().String("host", "", "Only show certificates of this host")
+	certsCmd.Flags().Int("days", 0, "Only show certificates expiring within this many days")
+
+	rootCmd.AddCommand(certsCmd)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/metorial/sentinel/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+var checksCmd = &cobra.Command{
+	Use:   "checks",
+	Short: "Manage synthetic checks",
+	Long: `Synthetic checks are run by the agents of every host matching the check
+selector (a tag name, or empty for all hosts). Supported types:
+
+  http   GET the target URL; expects a status below 400 unless --status is
+         set, and optionally a body matching --body
+  tcp    connect to the target host:port
+  tls    connect to the target host:port and check the certificate is valid
+         for at least --min-days days
+  dns    resolve the target name, optionally to --address
+
+A failing check raises an alert on the host until it passes again.`,
+}
+
+var listChecksCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all checks",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := cli.NewClient(serverURL)
+		data, err := client.ListChecks()
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(data, "checks")
+		}
+
+		return cli.FormatChecksTable(data)
+	},
+}
+
+var getCheckCmd = &cobra.Command{
+	Use:   "get [name]",
+	Short: "Show a check and its latest result on each host",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := cli.NewClient(serverURL)
+		data, err := client.GetCheck(args[0])
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(data, "status")
+		}
+
+		return cli.FormatCheckDetail(data)
+	},
+}
+
+var setCheckCmd = &cobra.Command{
+	Use:   "set [name]",
+	Short: "Create or replace a check",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		checkType, _ := cmd.Flags().GetString("type")
+		target, _ := cmd.Flags().GetString("target")
+		selector, _ := cmd.Flags().GetString("selector")
+		interval, _ := cmd.Flags().GetDuration("interval")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		status, _ := cmd.Flags().GetInt("status")
+		body, _ := cmd.Flags().GetString("body")
+		minDays, _ := cmd.Flags().GetInt("min-days")
+		address, _ := cmd.Flags().GetString("address")
+
+		client := cli.NewClient(serverURL)
+		data, err := client.SetCheck(args[0], map[string]interface{}{
+			"type":             checkType,
+			"target":           target,
+			"selector":         selector,
+			"interval_seconds": int(interval.Seconds()),
+			"timeout_seconds":  int(timeout.Seconds()),
+			"expected_status":  status,
+			"body_regex":       body,
+			"min_days_valid":   minDays,
+			"expected_address": address,
+		})
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(data, "")
+		}
+
+		fmt.Printf("Check %s saved\n", args[0])
+		return nil
+	},
+}
+
+var deleteCheckCmd = &cobra.Command{
+	Use:   "delete [name]",
+	Short: "Delete a check and its results",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := cli.NewClient(serverURL)
+		data, err := client.DeleteCheck(args[0])
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(data, "")
+		}
+
+		fmt.Printf("Check %s deleted\n", args[0])
+		return nil
+	},
+}
+
+var checkResultsCmd = &cobra.Command{
+	Use:   "results [name]",
+	Short: "Show the result history of a check",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hostname, _ := cmd.Flags().GetString("host")
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		client := cli.NewClient(serverURL)
+		data, err := client.GetCheckResults(args[0], hostname, limit)
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(data, "results")
+		}
+
+		return cli.FormatCheckResultsTable(data)
+	},
+}
+
+var alertsCmd = &cobra.Command{
+	Use:   "alerts",
+	Short: "List firing alerts",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		state, _ := cmd.Flags().GetString("state")
+
+		client := cli.NewClient(serverURL)
+		data, err := client.ListAlerts(state)
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(data, "alerts")
+		}
+
+		return cli.FormatAlertsTable(data)
+	},
+}
+
+func init() {
+	setCheckCmd.Flags().String("type", "", "Check type: http, tcp, tls or dns")
+	setCheckCmd.Flags().String("target", "", "URL, host:port or name to check")
+	setCheckCmd.Flags().String("selector", "", "Selector of the hosts that run the check, e.g. 'web AND env=prod' (empty matches all hosts)")
+	setCheckCmd.Flags().Duration("interval", 0, "Time between runs (default 1m)")
+	setCheckCmd.Flags().Duration("timeout", 0, "Time before a run fails (default 10s)")
+	setCheckCmd.Flags().Int("status", 0, "Expected HTTP status (default: any below 400)")
+	setCheckCmd.Flags().String("body", "", "Regular expression the HTTP response body must match")
+	setCheckCmd.Flags().Int("min-days", 0, "Minimum days the TLS certificate must remain valid")
+	setCheckCmd.Flags().String("address", "", "Address the DNS name must resolve to")
+	setCheckCmd.MarkFlagRequired("type")
+	setCheckCmd.MarkFlagRequired("target")
+	checkResultsCmd.Flags().String("host", "", "Only show results from this host")
+	checkResultsCmd.Flags().IntP("limit", "l", 100, "Number of results to retrieve (max: 1000)")
+	alertsCmd.Flags().String("state", "", "Alert state: firing, resolved or all (default: firing)")
+
+	checksCmd.AddCommand(listChecksCmd)
+	checksCmd.AddCommand(getCheckCmd)
+	checksCmd.AddCommand(setCheckCmd)
+	checksCmd.AddCommand(deleteCheckCmd)
+	checksCmd.AddCommand(checkResultsCmd)
+
+	rootCmd.AddCommand(checksCmd)
+	rootCmd.AddCommand(alertsCmd)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/metorial/sentinel/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+var graphHostCmd = &cobra.Command{
+	Use:   "graph [hostname]",
+	Short: "Chart the CPU, memory and storage usage history of a host",
+	Long: `Chart the CPU, memory and storage usage of a host over --range as sparklines,
+or as braille line charts with --style braille, with the lowest, average and
+highest value of each. Charts are as wide as the terminal unless --width is set.`,
+	Example: `  nodectl hosts graph web-1
+  nodectl hosts graph web-1 --range 24h --style braille --height 6`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		usageRange, _ := cmd.Flags().GetDuration("range")
+		opts := cli.GraphOptions{}
+		opts.Style, _ = cmd.Flags().GetString("style")
+		opts.Width, _ = cmd.Flags().GetInt("width")
+		opts.Height, _ = cmd.Flags().GetInt("height")
+
+		if opts.Style != cli.GraphSparkline && opts.Style != cli.GraphBraille {
+			return fmt.Errorf("invalid --style %q, expected spark or braille", opts.Style)
+		}
+		if usageRange <= 0 {
+			return fmt.Errorf("--range must be positive")
+		}
+		if opts.Height < 2 {
+			return fmt.Errorf("--height must be at least 2")
+		}
+		if opts.Width <= 0 {
+			if _, cols := terminalSize(); cols > 0 {
+				opts.Width = cols
+			} else {
+				opts.Width = 80
+			}
+		}
+
+		client := cli.NewClient(serverURL)
+		data, err := client.GetHostUsageHistory(args[0], usageRange, min(opts.Points(), 2000))
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(data, "points")
+		}
+
+		return cli.FormatUsageGraphs(os.Stdout, data, opts)
+	},
+}
+
+func init() {
+	graphHostCmd.Flags().Duration("range", time.Hour, "How far back to chart usage")
+	graphHostCmd.Flags().String("style", cli.GraphSparkline, "Chart style: spark or braille")
+	graphHostCmd.Flags().Int("width", 0, "Width of the charts in columns (default: terminal width)")
+	graphHostCmd.Flags().Int("height", 8, "Height of braille charts in lines")
+
+	hostsCmd.AddCommand(graphHostCmd)
+}
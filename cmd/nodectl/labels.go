@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/metorial/sentinel/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+var labelsCmd = &cobra.Command{
+	Use:   "labels",
+	Short: "Manage host labels",
+	Long: `Manage host labels. A label is a key with one value per host, such as
+env=prod. Selectors match labels with env=prod, and tag filters and the tag
+endpoints see them as the tag env:prod.`,
+}
+
+var listLabelsCmd = &cobra.Command{
+	Use:   "list [hostname]",
+	Short: "List the labels in use, or those of one host",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := cli.NewClient(serverURL)
+
+		if len(args) == 1 {
+			data, err := client.GetHostLabels(args[0])
+			if err != nil {
+				return err
+			}
+			if output.Structured() {
+				return output.Print(data, "labels")
+			}
+			return cli.FormatHostLabelsTable(data)
+		}
+
+		data, err := client.ListLabels()
+		if err != nil {
+			return err
+		}
+		if output.Structured() {
+			return output.Print(data, "labels")
+		}
+		return cli.FormatLabelsTable(data)
+	},
+}
+
+var setLabelsCmd = &cobra.Command{
+	Use:     "set [hostname] [key=value...]",
+	Short:   "Set labels on a host, replacing the current value of each key",
+	Example: `  nodectl labels set web-1 env=prod team=platform`,
+	Args:    cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hostname := args[0]
+		labels := make([][2]string, 0, len(args)-1)
+		for _, arg := range args[1:] {
+			key, value, ok := strings.Cut(arg, "=")
+			if !ok || key == "" {
+				return fmt.Errorf("invalid label %q, expected key=value", arg)
+			}
+			labels = append(labels, [2]string{key, value})
+		}
+
+		client := cli.NewClient(serverURL)
+		results := make([]interface{}, 0, len(labels))
+		for _, label := range labels {
+			data, err := client.SetHostLabel(hostname, label[0], label[1])
+			if err != nil {
+				return fmt.Errorf("set %s: %w", label[0], err)
+			}
+			results = append(results, map[string]interface{}{
+				"key": label[0], "value": label[1], "message": data["message"],
+			})
+			if !output.Structured() {
+				fmt.Printf("Set %s=%s on %s\n", label[0], label[1], hostname)
+			}
+		}
+
+		if output.Structured() {
+			return output.Print(map[string]interface{}{"hostname": hostname, "results": results}, "results")
+		}
+		return nil
+	},
+}
+
+var unsetLabelsCmd = &cobra.Command{
+	Use:     "unset [hostname] [key...]",
+	Short:   "Remove labels from a host",
+	Example: `  nodectl labels unset web-1 team`,
+	Args:    cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hostname := args[0]
+
+		client := cli.NewClient(serverURL)
+		results := make([]interface{}, 0, len(args)-1)
+		for _, key := range args[1:] {
+			data, err := client.RemoveHostLabel(hostname, key)
+			if err != nil {
+				return fmt.Errorf("unset %s: %w", key, err)
+			}
+			results = append(results, map[string]interface{}{"key": key, "message": data["message"]})
+			if !output.Structured() {
+				fmt.Printf("Removed %s from %s\n", key, hostname)
+			}
+		}
+
+		if output.Structured() {
+			return output.Print(map[string]interface{}{"hostname": hostname, "results": results}, "results")
+		}
+		return nil
+	},
+}
+
+func init() {
+	labelsCmd.AddCommand(listLabelsCmd)
+	labelsCmd.AddCommand(setLabelsCmd)
+	labelsCmd.AddCommand(unsetLabelsCmd)
+
+	rootCmd.AddCommand(labelsCmd)
+}
@@ -3,14 +3,18 @@ package main
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/metorial/sentinel/internal/cli"
 	"github.com/spf13/cobra"
 )
 
 var (
-	serverURL  string
-	outputJSON bool
+	serverURL    string
+	outputJSON   bool
+	outputFormat string
+	// output is parsed from --output, or --json, before any command runs
+	output *cli.Output
 )
 
 func main() {
@@ -25,9 +29,38 @@ var rootCmd = &cobra.Command{
 	Short: "CLI for Node Metrics Collector",
 	Long: `nodectl is a command-line interface for interacting with the Node Metrics Collector API.
 
-It provides commands to query host information, usage statistics, and cluster-wide metrics.`,
+It provides commands to query host information, usage statistics, and cluster-wide metrics.
+
+Results are printed as tables unless --output selects another format: wide for
+tables with extra columns (hosts list, hosts cgroups and hosts containers only),
+json, yaml, csv, jsonpath=EXPR such as jsonpath='{.hosts[*].hostname}', or
+template=TEMPLATE, a Go template such as
+template='{{range .hosts}}{{.hostname}} {{.ip}}{{"\n"}}{{end}}'.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		format := outputFormat
+		if outputJSON {
+			if cmd.Flags().Changed("output") && format != cli.OutputJSON {
+				return fmt.Errorf("--json conflicts with --output %s", format)
+			}
+			format = cli.OutputJSON
+		}
+
+		var err error
+		output, err = cli.ParseOutput(format)
+		if err != nil {
+			return err
+		}
+		if output.Wide() && cmd.Annotations[wideAnnotation] == "" {
+			return fmt.Errorf("%s has no wide output; use table or a structured format", cmd.CommandPath())
+		}
+		return nil
+	},
 }
 
+// wideAnnotation marks the commands whose tables have extra columns for
+// --output wide.
+const wideAnnotation = "wide"
+
 var healthCmd = &cobra.Command{
 	Use:   "health",
 	Short: "Check collector service health",
@@ -38,8 +71,8 @@ var healthCmd = &cobra.Command{
 			return err
 		}
 
-		if outputJSON {
-			return cli.FormatJSON(data)
+		if output.Structured() {
+			return output.Print(data, "")
 		}
 
 		status := data["status"].(string)
@@ -59,17 +92,34 @@ var listHostsCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all hosts",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := cli.HostListOptions{}
+		opts.Tags, _ = cmd.Flags().GetStringSlice("tag")
+		opts.Selector, _ = cmd.Flags().GetString("selector")
+		opts.Status, _ = cmd.Flags().GetString("status")
+		opts.Outdated, _ = cmd.Flags().GetBool("outdated")
+		opts.Query, _ = cmd.Flags().GetString("search")
+		opts.Sort, _ = cmd.Flags().GetString("sort")
+		opts.Limit, _ = cmd.Flags().GetInt("limit")
+		opts.Cursor, _ = cmd.Flags().GetString("cursor")
+		opts.IncludeDecommissioned, _ = cmd.Flags().GetBool("all")
+
 		client := cli.NewClient(serverURL)
-		data, err := client.ListHosts()
+		data, err := client.ListHosts(opts)
 		if err != nil {
 			return err
 		}
 
-		if outputJSON {
-			return cli.FormatJSON(data)
+		if output.Structured() {
+			return output.Print(data, "hosts")
 		}
 
-		return cli.FormatHostsTable(data)
+		if err := cli.FormatHostsTable(data, output.Wide()); err != nil {
+			return err
+		}
+		if next, ok := data["next_cursor"].(string); ok && next != "" {
+			fmt.Printf("\nMore hosts available, continue with --cursor %s\n", next)
+		}
+		return nil
 	},
 }
 
@@ -87,28 +137,286 @@ var getHostCmd = &cobra.Command{
 			return err
 		}
 
-		if outputJSON {
-			return cli.FormatJSON(data)
+		if output.Structured() {
+			return output.Print(data, "usage")
 		}
 
 		return cli.FormatHostDetailTable(data)
 	},
 }
 
+var inventoryHostCmd = &cobra.Command{
+	Use:   "inventory [hostname]",
+	Short: "Show the OS and hardware inventory of a host",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		showHistory, _ := cmd.Flags().GetBool("history")
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		client := cli.NewClient(serverURL)
+		data, err := client.GetInventory(args[0], limit)
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			rows := "inventory"
+			if showHistory {
+				rows = "history"
+			}
+			return output.Print(data, rows)
+		}
+
+		return cli.FormatInventory(data, showHistory)
+	},
+}
+
+var cgroupsHostCmd = &cobra.Command{
+	Use:   "cgroups [hostname]",
+	Short: "List container cgroups running on a host",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		all, _ := cmd.Flags().GetBool("all")
+
+		client := cli.NewClient(serverURL)
+		data, err := client.ListCgroups(args[0], all)
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(data, "cgroups")
+		}
+
+		return cli.FormatCgroupsTable(data, output.Wide())
+	},
+}
+
+var containersHostCmd = &cobra.Command{
+	Use:   "containers [hostname]",
+	Short: "List Docker containers on a host",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := cli.NewClient(serverURL)
+		data, err := client.ListContainers(args[0])
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(data, "containers")
+		}
+
+		return cli.FormatContainersTable(data, output.Wide())
+	},
+}
+
+var sensorsHostCmd = &cobra.Command{
+	Use:   "sensors [hostname]",
+	Short: "List hardware temperature and fan sensors of a host",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := cli.NewClient(serverURL)
+		data, err := client.ListSensors(args[0])
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(data, "sensors")
+		}
+
+		return cli.FormatSensorsTable(data)
+	},
+}
+
+var deleteHostCmd = &cobra.Command{
+	Use:   "delete [hostname]",
+	Short: "Delete a host and all of its data",
+	Long:  "Delete a host and all of its data. Hosts with a connected agent can't be deleted; decommission them instead.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := cli.NewClient(serverURL)
+		data, err := client.DeleteHost(args[0])
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(data, "")
+		}
+
+		fmt.Printf("Host %s deleted\n", args[0])
+		return nil
+	},
+}
+
+var decommissionHostCmd = &cobra.Command{
+	Use:   "decommission [hostname]",
+	Short: "Retire a host, hiding it from listings and stats",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := cli.NewClient(serverURL)
+		data, err := client.DecommissionHost(args[0])
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(data, "")
+		}
+
+		fmt.Println(data["message"])
+		return nil
+	},
+}
+
+var recommissionHostCmd = &cobra.Command{
+	Use:   "recommission [hostname]",
+	Short: "Return a decommissioned host to the fleet",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := cli.NewClient(serverURL)
+		data, err := client.RecommissionHost(args[0])
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(data, "")
+		}
+
+		fmt.Println(data["message"])
+		return nil
+	},
+}
+
+var presenceHostCmd = &cobra.Command{
+	Use:   "presence [hostname]",
+	Short: "Show the presence state of a host and why it changed",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		client := cli.NewClient(serverURL)
+		data, err := client.GetHostPresence(args[0], limit)
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(data, "transitions")
+		}
+
+		return cli.FormatHostPresence(data)
+	},
+}
+
+var logsHostCmd = &cobra.Command{
+	Use:   "logs [hostname]",
+	Short: "Show log rule match counts of a host",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rule, _ := cmd.Flags().GetString("rule")
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		client := cli.NewClient(serverURL)
+		data, err := client.GetLogSamples(args[0], rule, limit)
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(data, "samples")
+		}
+
+		return cli.FormatLogSamplesTable(data)
+	},
+}
+
+var execHostCmd = &cobra.Command{
+	Use:   "exec [hostname] [command] [argument]",
+	Short: "Run a command on a connected agent",
+	Long: `Run a command on a connected agent and wait for its result.
+
+Available commands:
+  collect-now                 Collect and report metrics immediately
+  set-interval <duration>     Change the metrics report interval (e.g. 30s)
+  diagnostic <name>           Run a built-in diagnostic (uptime, load, memory, disk, network)`,
+	Args: cobra.RangeArgs(2, 3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hostname, command := args[0], args[1]
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+
+		params := map[string]interface{}{
+			"timeout_seconds": int(timeout.Seconds()),
+		}
+
+		var apiCommand string
+		switch command {
+		case "collect-now":
+			apiCommand = "collect_now"
+		case "set-interval":
+			if len(args) != 3 {
+				return fmt.Errorf("set-interval requires a duration argument")
+			}
+			interval, err := time.ParseDuration(args[2])
+			if err != nil {
+				return fmt.Errorf("invalid interval: %w", err)
+			}
+			apiCommand = "set_interval"
+			params["interval_seconds"] = int(interval.Seconds())
+		case "diagnostic":
+			if len(args) != 3 {
+				return fmt.Errorf("diagnostic requires a diagnostic name")
+			}
+			apiCommand = "run_diagnostic"
+			params["diagnostic"] = args[2]
+		default:
+			return fmt.Errorf("unknown command %q", command)
+		}
+
+		client := cli.NewClient(serverURL)
+		data, err := client.ExecCommand(hostname, apiCommand, params)
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(data, "")
+		}
+
+		return cli.FormatCommandResult(data)
+	},
+}
+
 var statsCmd = &cobra.Command{
 	Use:   "stats",
 	Short: "Get cluster-wide statistics",
+	Example: `  nodectl stats --selector 'env=prod'
+  nodectl stats --group-by env --window 1h
+  nodectl stats --group-by tag`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := cli.StatsOptions{}
+		opts.Selector, _ = cmd.Flags().GetString("selector")
+		opts.GroupBy, _ = cmd.Flags().GetString("group-by")
+		opts.Window, _ = cmd.Flags().GetDuration("window")
+
 		client := cli.NewClient(serverURL)
-		data, err := client.GetStats()
+		data, err := client.GetStats(opts)
 		if err != nil {
 			return err
 		}
 
-		if outputJSON {
-			return cli.FormatJSON(data)
+		if opts.GroupBy != "" {
+			if output.Structured() {
+				return output.Print(data, "groups")
+			}
+			return cli.FormatStatsGroupsTable(data)
+		}
+		if output.Structured() {
+			return output.Print(data, "")
 		}
-
 		return cli.FormatStatsTable(data)
 	},
 }
@@ -121,12 +429,46 @@ func init() {
 	}
 
 	rootCmd.PersistentFlags().StringVarP(&serverURL, "server", "s", defaultServerURL, "Collector server URL")
-	rootCmd.PersistentFlags().BoolVarP(&outputJSON, "json", "j", false, "Output in JSON format")
+	rootCmd.PersistentFlags().BoolVarP(&outputJSON, "json", "j", false, "Output in JSON format (same as --output json)")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", cli.OutputTable, "Output format: table, wide, json, yaml, csv, jsonpath=EXPR or template=TEMPLATE")
+	for _, cmd := range []*cobra.Command{listHostsCmd, cgroupsHostCmd, containersHostCmd} {
+		cmd.Annotations = map[string]string{wideAnnotation: "true"}
+	}
 
+	listHostsCmd.Flags().Bool("outdated", false, "Only show hosts running an agent older than the controller")
+	listHostsCmd.Flags().BoolP("all", "a", false, "Include decommissioned hosts")
+	listHostsCmd.Flags().StringSliceP("tag", "t", nil, "Only show hosts with any of these tags")
+	listHostsCmd.Flags().String("selector", "", "Only show hosts matching this selector, e.g. 'env=prod AND NOT canary'")
+	listHostsCmd.Flags().String("status", "", "Only show hosts in this state (connecting, online, stale, offline or decommissioned)")
+	listHostsCmd.Flags().StringP("search", "q", "", "Only show hosts whose hostname or IP contains this")
+	listHostsCmd.Flags().String("sort", "", "Sort by hostname, cpu, memory, storage or last_seen; prefix with - for descending")
+	listHostsCmd.Flags().IntP("limit", "l", 0, "Number of hosts per page (max: 1000, default: all)")
+	listHostsCmd.Flags().String("cursor", "", "Continue a paged listing from the cursor printed by the previous page")
 	getHostCmd.Flags().IntP("limit", "l", 100, "Number of usage records to retrieve (max: 1000)")
+	inventoryHostCmd.Flags().Bool("history", false, "Show the inventory change history")
+	inventoryHostCmd.Flags().IntP("limit", "l", 20, "Number of history entries to retrieve (max: 500)")
+	cgroupsHostCmd.Flags().BoolP("all", "a", false, "Include cgroups that are no longer running")
+	presenceHostCmd.Flags().IntP("limit", "l", 20, "Number of state changes to retrieve (max: 1000)")
+	logsHostCmd.Flags().String("rule", "", "Only show samples of this log rule")
+	logsHostCmd.Flags().IntP("limit", "l", 100, "Number of samples to retrieve (max: 1000)")
+	execHostCmd.Flags().Duration("timeout", 10*time.Second, "Time to wait for the agent to respond (max: 60s)")
+
+	statsCmd.Flags().String("selector", "", "Only count hosts matching this selector")
+	statsCmd.Flags().StringP("group-by", "g", "", "Break the statistics down by tag, or by the values of a label key")
+	statsCmd.Flags().Duration("window", 0, "Compute CPU and memory usage over this much recent data (default: 5m)")
 
 	hostsCmd.AddCommand(listHostsCmd)
 	hostsCmd.AddCommand(getHostCmd)
+	hostsCmd.AddCommand(inventoryHostCmd)
+	hostsCmd.AddCommand(cgroupsHostCmd)
+	hostsCmd.AddCommand(containersHostCmd)
+	hostsCmd.AddCommand(sensorsHostCmd)
+	hostsCmd.AddCommand(logsHostCmd)
+	hostsCmd.AddCommand(presenceHostCmd)
+	hostsCmd.AddCommand(deleteHostCmd)
+	hostsCmd.AddCommand(decommissionHostCmd)
+	hostsCmd.AddCommand(recommissionHostCmd)
+	hostsCmd.AddCommand(execHostCmd)
 
 	rootCmd.AddCommand(healthCmd)
 	rootCmd.AddCommand(hostsCmd)
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/metorial/sentinel/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+var maintenanceCmd = &cobra.Command{
+	Use:   "maintenance",
+	Short: "Manage maintenance windows",
+	Long: `Manage maintenance windows. While a window covers a host, no new alerts are
+opened for it and its presence changes aren't announced.`,
+}
+
+var listMaintenanceCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List active and scheduled maintenance windows",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		all, _ := cmd.Flags().GetBool("all")
+
+		client := cli.NewClient(serverURL)
+		data, err := client.ListMaintenance(all)
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(data, "windows")
+		}
+
+		return cli.FormatMaintenanceTable(data)
+	},
+}
+
+var createMaintenanceCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Open a maintenance window for a host or tag",
+	Example: `  nodectl maintenance create --host web-1 --duration 2h --reason "kernel upgrade"
+  nodectl maintenance create --tag db --start 2025-12-01T22:00:00Z --end 2025-12-02T02:00:00Z --reason patching`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		window := cli.MaintenanceWindow{}
+		window.Hostname, _ = cmd.Flags().GetString("host")
+		window.Tag, _ = cmd.Flags().GetString("tag")
+		window.Reason, _ = cmd.Flags().GetString("reason")
+		window.Author, _ = cmd.Flags().GetString("author")
+		if duration, _ := cmd.Flags().GetDuration("duration"); duration > 0 {
+			window.Duration = duration.String()
+		}
+
+		for flag, dest := range map[string]**time.Time{"start": &window.StartsAt, "end": &window.EndsAt} {
+			value, _ := cmd.Flags().GetString(flag)
+			if value == "" {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return fmt.Errorf("invalid --%s %q, expected RFC 3339 time such as 2025-12-01T22:00:00Z", flag, value)
+			}
+			*dest = &t
+		}
+
+		client := cli.NewClient(serverURL)
+		data, err := client.CreateMaintenance(window)
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(data, "")
+		}
+
+		return cli.FormatMaintenanceTable(map[string]interface{}{"windows": []interface{}{data}})
+	},
+}
+
+var deleteMaintenanceCmd = &cobra.Command{
+	Use:   "delete [id]",
+	Short: "End a maintenance window early or cancel a scheduled one",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid maintenance window id %q", args[0])
+		}
+
+		client := cli.NewClient(serverURL)
+		data, err := client.DeleteMaintenance(id)
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(data, "")
+		}
+
+		fmt.Printf("Maintenance window %d deleted\n", id)
+		return nil
+	},
+}
+
+func init() {
+	listMaintenanceCmd.Flags().BoolP("all", "a", false, "Include windows that have ended")
+	createMaintenanceCmd.Flags().String("host", "", "Hostname to put under maintenance")
+	createMaintenanceCmd.Flags().String("tag", "", "Put every host matching this selector under maintenance, e.g. 'env=staging'")
+	createMaintenanceCmd.Flags().Duration("duration", 0, "Length of the window, e.g. 2h")
+	createMaintenanceCmd.Flags().String("start", "", "Start of the window (RFC 3339, default: now)")
+	createMaintenanceCmd.Flags().String("end", "", "End of the window (RFC 3339), instead of --duration")
+	createMaintenanceCmd.Flags().StringP("reason", "r", "", "Why the hosts are under maintenance")
+	createMaintenanceCmd.Flags().String("author", os.Getenv("USER"), "Who opened the window")
+
+	maintenanceCmd.AddCommand(listMaintenanceCmd)
+	maintenanceCmd.AddCommand(createMaintenanceCmd)
+	maintenanceCmd.AddCommand(deleteMaintenanceCmd)
+
+	rootCmd.AddCommand(maintenanceCmd)
+}
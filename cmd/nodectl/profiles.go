@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/metorial/sentinel/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+var profilesCmd = &cobra.Command{
+	Use:   "profiles",
+	Short: "Manage agent config profiles",
+	Long: `Agent config profiles are pushed by the controller to every connected agent
+whose host matches the profile selector (a tag name, or empty for all hosts).
+When several profiles match, the one with the highest priority wins.`,
+}
+
+var listProfilesCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all profiles",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := cli.NewClient(serverURL)
+		data, err := client.ListProfiles()
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(data, "profiles")
+		}
+
+		return cli.FormatProfilesTable(data)
+	},
+}
+
+var getProfileCmd = &cobra.Command{
+	Use:   "get [name]",
+	Short: "Show a profile",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := cli.NewClient(serverURL)
+		data, err := client.GetProfile(args[0])
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(data, "")
+		}
+
+		return cli.FormatProfilesTable(map[string]interface{}{
+			"profiles": []interface{}{data},
+		})
+	},
+}
+
+var setProfileCmd = &cobra.Command{
+	Use:   "set [name]",
+	Short: "Create or replace a profile",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		selector, _ := cmd.Flags().GetString("selector")
+		priority, _ := cmd.Flags().GetInt("priority")
+		interval, _ := cmd.Flags().GetDuration("interval")
+		collectors, _ := cmd.Flags().GetStringSlice("collectors")
+		mountInclude, _ := cmd.Flags().GetStringSlice("mount-include")
+		mountExclude, _ := cmd.Flags().GetStringSlice("mount-exclude")
+
+		client := cli.NewClient(serverURL)
+		data, err := client.SetProfile(args[0], map[string]interface{}{
+			"selector":                selector,
+			"priority":                priority,
+			"report_interval_seconds": int(interval.Seconds()),
+			"enabled_collectors":      collectors,
+			"mount_include":           mountInclude,
+			"mount_exclude":           mountExclude,
+		})
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(data, "")
+		}
+
+		fmt.Printf("Profile %s saved (version %v)\n", args[0], data["version"])
+		return nil
+	},
+}
+
+var deleteProfileCmd = &cobra.Command{
+	Use:   "delete [name]",
+	Short: "Delete a profile",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := cli.NewClient(serverURL)
+		data, err := client.DeleteProfile(args[0])
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(data, "")
+		}
+
+		fmt.Printf("Profile %s deleted\n", args[0])
+		return nil
+	},
+}
+
+func init() {
+	setProfileCmd.Flags().String("selector", "", "Selector of the hosts the profile applies to, e.g. 'env=prod' (empty matches all hosts)")
+	setProfileCmd.Flags().Int("priority", 0, "Priority when several profiles match a host")
+	setProfileCmd.Flags().Duration("interval", 0, "Metrics report interval (0 keeps the agent default)")
+	setProfileCmd.Flags().StringSlice("collectors", nil, "Optional collectors to enable (default: all)")
+	setProfileCmd.Flags().StringSlice("mount-include", nil, "Mountpoint globs counted towards storage (default: /)")
+	setProfileCmd.Flags().StringSlice("mount-exclude", nil, "Mountpoint globs excluded from storage")
+
+	profilesCmd.AddCommand(listProfilesCmd)
+	profilesCmd.AddCommand(getProfileCmd)
+	profilesCmd.AddCommand(setProfileCmd)
+	profilesCmd.AddCommand(deleteProfileCmd)
+
+	rootCmd.AddCommand(profilesCmd)
+}
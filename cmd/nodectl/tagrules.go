@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/metorial/sentinel/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+var tagRulesCmd = &cobra.Command{
+	Use:   "tag-rules",
+	Short: "Manage tagging rules",
+	Long: `Tagging rules tag every host that meets all of their conditions: a hostname
+regex, an IP range, a range of CPU cores and the OS. The controller applies
+them when a host registers, when its inventory changes and to all hosts when
+a rule is saved or deleted. A rule only removes the tags it added itself.`,
+}
+
+var listTagRulesCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all tagging rules",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := cli.NewClient(serverURL)
+		data, err := client.ListTagRules()
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(data, "rules")
+		}
+
+		return cli.FormatTagRulesTable(data)
+	},
+}
+
+var getTagRuleCmd = &cobra.Command{
+	Use:   "get [name]",
+	Short: "Show a tagging rule",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := cli.NewClient(serverURL)
+		data, err := client.GetTagRule(args[0])
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(data, "")
+		}
+
+		return cli.FormatTagRulesTable(map[string]interface{}{
+			"rules": []interface{}{data},
+		})
+	},
+}
+
+var setTagRuleCmd = &cobra.Command{
+	Use:   "set [name]",
+	Short: "Create or replace a tagging rule",
+	Example: `  nodectl tag-rules set web --tag web --hostname-regex '^web-'
+  nodectl tag-rules set big-ubuntu --tag big --min-cores 16 --os ubuntu --dry-run`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tag, _ := cmd.Flags().GetString("tag")
+		hostnameRegex, _ := cmd.Flags().GetString("hostname-regex")
+		cidr, _ := cmd.Flags().GetString("cidr")
+		minCores, _ := cmd.Flags().GetInt("min-cores")
+		maxCores, _ := cmd.Flags().GetInt("max-cores")
+		osName, _ := cmd.Flags().GetString("os")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		client := cli.NewClient(serverURL)
+		data, err := client.SetTagRule(args[0], map[string]interface{}{
+			"tag":            tag,
+			"hostname_regex": hostnameRegex,
+			"cidr":           cidr,
+			"min_cpu_cores":  minCores,
+			"max_cpu_cores":  maxCores,
+			"os":             osName,
+		}, dryRun)
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(data, "")
+		}
+
+		if dryRun {
+			return cli.FormatTagRulePreview(data)
+		}
+
+		fmt.Printf("Tagging rule %s saved\n", args[0])
+		return nil
+	},
+}
+
+var deleteTagRuleCmd = &cobra.Command{
+	Use:   "delete [name]",
+	Short: "Delete a tagging rule and remove the tags it added",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := cli.NewClient(serverURL)
+		data, err := client.DeleteTagRule(args[0])
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(data, "")
+		}
+
+		fmt.Printf("Tagging rule %s deleted\n", args[0])
+		return nil
+	},
+}
+
+func init() {
+	setTagRuleCmd.Flags().String("tag", "", "Tag to add to matching hosts (required)")
+	setTagRuleCmd.Flags().String("hostname-regex", "", "Regular expression the hostname must match")
+	setTagRuleCmd.Flags().String("cidr", "", "IP range the host address must be in, e.g. 10.0.0.0/16")
+	setTagRuleCmd.Flags().Int("min-cores", 0, "Minimum number of CPU cores")
+	setTagRuleCmd.Flags().Int("max-cores", 0, "Maximum number of CPU cores")
+	setTagRuleCmd.Flags().String("os", "", "OS (e.g. linux) or platform (e.g. ubuntu) of the host")
+	setTagRuleCmd.Flags().Bool("dry-run", false, "Show the hosts the rule would tag and untag without saving it")
+	setTagRuleCmd.MarkFlagRequired("tag")
+
+	tagRulesCmd.AddCommand(listTagRulesCmd)
+	tagRulesCmd.AddCommand(getTagRuleCmd)
+	tagRulesCmd.AddCommand(setTagRuleCmd)
+	tagRulesCmd.AddCommand(deleteTagRuleCmd)
+
+	rootCmd.AddCommand(tagRulesCmd)
+}
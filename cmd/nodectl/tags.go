@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/metorial/sentinel/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+var tagsCmd = &cobra.Command{
+	Use:   "tags",
+	Short: "Manage host tags",
+}
+
+var listTagsCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all tags with the number of hosts with each",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := cli.NewClient(serverURL)
+		data, err := client.ListTags()
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(data, "tags")
+		}
+
+		return cli.FormatTagsTable(data)
+	},
+}
+
+var deleteTagCmd = &cobra.Command{
+	Use:   "delete [tag]",
+	Short: "Delete a tag no host has, or with --force one that hosts have",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		force, _ := cmd.Flags().GetBool("force")
+
+		client := cli.NewClient(serverURL)
+		data, err := client.DeleteTag(args[0], force)
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(data, "")
+		}
+
+		hosts, _ := data["hosts"].([]interface{})
+		if len(hosts) > 0 {
+			fmt.Printf("Tag %s deleted and removed from %d hosts\n", args[0], len(hosts))
+		} else {
+			fmt.Printf("Tag %s deleted\n", args[0])
+		}
+		return nil
+	},
+}
+
+var renameTagCmd = &cobra.Command{
+	Use:   "rename [tag] [new-name]",
+	Short: "Rename a tag, merging it into new-name if that tag exists",
+	Long: `Rename a tag on every host that has it, merging it into new-name if that tag
+exists. Tagging rules that add the tag are updated, and so are the selectors of
+profiles, checks and maintenance windows wherever they mention it.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := cli.NewClient(serverURL)
+		data, err := client.RenameTag(args[0], args[1])
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(data, "")
+		}
+
+		hosts, _ := data["hosts"].([]interface{})
+		if merged, _ := data["merged"].(bool); merged {
+			fmt.Printf("Tag %s merged into %s (%d hosts)\n", args[0], args[1], len(hosts))
+		} else {
+			fmt.Printf("Tag %s renamed to %s (%d hosts)\n", args[0], args[1], len(hosts))
+		}
+		return nil
+	},
+}
+
+var tagHostCmd = &cobra.Command{
+	Use:   "tag [hostname] [tag...]",
+	Short: "Add tags to a host",
+	Long: `Add tags to a host. A tag of the form key:value sets the label key instead,
+replacing the value the host had for it.`,
+	Example: `  nodectl hosts tag web-1 web frontend`,
+	Args:    cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hostname := args[0]
+
+		client := cli.NewClient(serverURL)
+		results := make([]interface{}, 0, len(args)-1)
+		for _, tag := range args[1:] {
+			data, err := client.AddHostTag(hostname, tag)
+			if err != nil {
+				return fmt.Errorf("add %s: %w", tag, err)
+			}
+			results = append(results, map[string]interface{}{"tag": tag, "message": data["message"]})
+			if !output.Structured() {
+				fmt.Printf("Added %s to %s\n", tag, hostname)
+			}
+		}
+
+		if output.Structured() {
+			return output.Print(map[string]interface{}{"hostname": hostname, "results": results}, "results")
+		}
+		return nil
+	},
+}
+
+var untagHostCmd = &cobra.Command{
+	Use:     "untag [hostname] [tag...]",
+	Short:   "Remove tags from a host",
+	Example: `  nodectl hosts untag web-1 frontend`,
+	Args:    cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hostname := args[0]
+
+		client := cli.NewClient(serverURL)
+		results := make([]interface{}, 0, len(args)-1)
+		for _, tag := range args[1:] {
+			data, err := client.RemoveHostTag(hostname, tag)
+			if err != nil {
+				return fmt.Errorf("remove %s: %w", tag, err)
+			}
+			results = append(results, map[string]interface{}{"tag": tag, "message": data["message"]})
+			if !output.Structured() {
+				fmt.Printf("Removed %s from %s\n", tag, hostname)
+			}
+		}
+
+		if output.Structured() {
+			return output.Print(map[string]interface{}{"hostname": hostname, "results": results}, "results")
+		}
+		return nil
+	},
+}
+
+// tagHostsArgs reads the tags and hosts of tags add and tags remove: a comma
+// separated list of tags followed by hostnames, or --selector.
+func tagHostsArgs(cmd *cobra.Command, args []string) (cli.BulkTags, []string, error) {
+	var req cli.BulkTags
+	req.Selector, _ = cmd.Flags().GetString("selector")
+
+	tags := strings.Split(args[0], ",")
+	for _, tag := range tags {
+		if tag == "" {
+			return req, nil, fmt.Errorf("invalid tags %q", args[0])
+		}
+	}
+
+	req.Hostnames = args[1:]
+	if (len(req.Hostnames) == 0) == (req.Selector == "") {
+		return req, nil, fmt.Errorf("give either hostnames or --selector")
+	}
+	return req, tags, nil
+}
+
+func runTagHosts(req cli.BulkTags) error {
+	client := cli.NewClient(serverURL)
+	data, err := client.UpdateTags(req)
+	if err != nil {
+		return err
+	}
+
+	if output.Structured() {
+		return output.Print(data, "results")
+	}
+
+	return cli.FormatTagResults(data)
+}
+
+var addTagsCmd = &cobra.Command{
+	Use:   "add [tag,...] [hostname...]",
+	Short: "Add tags to hosts",
+	Long: `Add tags to the given hosts, or to every host matching --selector. All hosts
+are updated or none is. A tag of the form key:value sets the label key instead,
+replacing the value a host had for it.`,
+	Example: `  nodectl tags add web,frontend web-1 web-2
+  nodectl tags add patched --selector 'env=prod AND role=db'`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		req, tags, err := tagHostsArgs(cmd, args)
+		if err != nil {
+			return err
+		}
+		req.Add = tags
+		return runTagHosts(req)
+	},
+}
+
+var removeTagsCmd = &cobra.Command{
+	Use:   "remove [tag,...] [hostname...]",
+	Short: "Remove tags from hosts",
+	Long: `Remove tags from the given hosts, or from every host matching --selector. All
+hosts are updated or none is.`,
+	Example: `  nodectl tags remove frontend web-1 web-2
+  nodectl tags remove patched --selector patched`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		req, tags, err := tagHostsArgs(cmd, args)
+		if err != nil {
+			return err
+		}
+		req.Remove = tags
+		return runTagHosts(req)
+	},
+}
+
+func init() {
+	deleteTagCmd.Flags().Bool("force", false, "Delete the tag even if hosts have it, removing it from them")
+
+	tagsCmd.AddCommand(listTagsCmd)
+	tagsCmd.AddCommand(deleteTagCmd)
+	tagsCmd.AddCommand(renameTagCmd)
+	for _, c := range []*cobra.Command{addTagsCmd, removeTagsCmd} {
+		c.Flags().String("selector", "", "Change the hosts matching this selector instead of hostnames, e.g. 'env=prod AND NOT canary'")
+		tagsCmd.AddCommand(c)
+	}
+
+	hostsCmd.AddCommand(tagHostCmd)
+	hostsCmd.AddCommand(untagHostCmd)
+
+	rootCmd.AddCommand(tagsCmd)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/metorial/sentinel/internal/cli"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var topCmd = &cobra.Command{
+	Use:   "top",
+	Short: "Live view of host usage across the fleet",
+	Long: `Show a live view of the hosts with their status and CPU, memory and storage
+usage, refreshed every --interval. Hosts with usage at or above a threshold are
+highlighted.
+
+Keys: c, m, d, n and s sort by CPU, memory, disk, name and status (pressing
+the current one again reverses the order), r reverses the order and q quits.`,
+	Example: `  nodectl top
+  nodectl top --tag web --sort memory --cpu-threshold 75
+  nodectl top --selector 'env=prod AND NOT canary' --interval 5s`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := cli.HostListOptions{}
+		opts.Tags, _ = cmd.Flags().GetStringSlice("tag")
+		opts.Selector, _ = cmd.Flags().GetString("selector")
+		interval, _ := cmd.Flags().GetDuration("interval")
+		once, _ := cmd.Flags().GetBool("once")
+		noColor, _ := cmd.Flags().GetBool("no-color")
+
+		stat, err := os.Stdout.Stat()
+		terminal := err == nil && stat.Mode()&os.ModeCharDevice != 0
+
+		view := &cli.TopView{Color: terminal && !noColor}
+		view.Sort, _ = cmd.Flags().GetString("sort")
+		view.CPUThreshold, _ = cmd.Flags().GetFloat64("cpu-threshold")
+		view.MemoryThreshold, _ = cmd.Flags().GetFloat64("memory-threshold")
+		view.StorageThreshold, _ = cmd.Flags().GetFloat64("storage-threshold")
+		if !cli.ValidTopSort(view.Sort) {
+			return fmt.Errorf("invalid --sort %q, expected cpu, memory, storage, name or status", view.Sort)
+		}
+		if interval < time.Second {
+			return fmt.Errorf("--interval must be at least 1s")
+		}
+
+		if output.Structured() && !once {
+			return fmt.Errorf("--output %s needs --once", output.Format)
+		}
+
+		client := cli.NewClient(serverURL)
+		if once {
+			data, err := client.ListHosts(opts)
+			if err != nil {
+				return err
+			}
+			if output.Structured() {
+				return output.Print(data, "hosts")
+			}
+			return view.Render(os.Stdout, data, 0)
+		}
+
+		restore, err := rawTerminal()
+		if err != nil {
+			return fmt.Errorf("top needs a terminal (use --once otherwise): %w", err)
+		}
+		defer restore()
+
+		// Restore the terminal on Ctrl-C too
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(signals)
+
+		keys := make(chan byte)
+		go func() {
+			buf := make([]byte, 1)
+			for {
+				if n, err := os.Stdin.Read(buf); err != nil {
+					close(keys)
+					return
+				} else if n == 1 {
+					keys <- buf[0]
+				}
+			}
+		}()
+
+		fmt.Print("\033[?25l")
+		defer fmt.Print("\033[?25h")
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var data map[string]interface{}
+		var fetchErr error
+		refresh := true
+		for {
+			if refresh {
+				data, fetchErr = client.ListHosts(opts)
+			}
+			refresh = false
+
+			var frame bytes.Buffer
+			frame.WriteString("\033[H\033[2J")
+			if fetchErr != nil {
+				fmt.Fprintf(&frame, "Error fetching hosts: %v\n", fetchErr)
+			} else if data != nil {
+				height, _ := terminalSize()
+				if err := view.Render(&frame, data, height); err != nil {
+					return err
+				}
+			}
+			// The terminal doesn't translate newlines while raw
+			os.Stdout.Write(bytes.ReplaceAll(frame.Bytes(), []byte("\n"), []byte("\r\n")))
+
+			select {
+			case <-ticker.C:
+				refresh = true
+			case key, ok := <-keys:
+				if !ok || key == 'q' || key == 3 {
+					fmt.Print("\033[H\033[2J")
+					return nil
+				}
+				view.HandleKey(key)
+			case <-signals:
+				fmt.Print("\033[H\033[2J")
+				return nil
+			}
+		}
+	},
+}
+
+// rawTerminal switches the terminal nodectl reads from to reading single key
+// presses without echoing them, and returns a function restoring its settings.
+func rawTerminal() (func(), error) {
+	fd := int(os.Stdin.Fd())
+	saved, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, err
+	}
+	return func() { term.Restore(fd, saved) }, nil
+}
+
+// terminalSize returns the number of lines and columns of the terminal nodectl
+// writes to, or zeros if they are unknown
+func terminalSize() (rows, cols int) {
+	cols, rows, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		return 0, 0
+	}
+	return rows, cols
+}
+
+func init() {
+	topCmd.Flags().StringSliceP("tag", "t", nil, "Only show hosts with any of these tags")
+	topCmd.Flags().String("selector", "", "Only show hosts matching this selector, e.g. 'env=prod AND NOT canary'")
+	topCmd.Flags().String("sort", "cpu", "Column to sort by: cpu, memory, storage, name or status")
+	topCmd.Flags().Duration("interval", 2*time.Second, "Refresh interval")
+	topCmd.Flags().Float64("cpu-threshold", 90, "Highlight hosts with CPU usage at or above this percentage (0 disables)")
+	topCmd.Flags().Float64("memory-threshold", 90, "Highlight hosts with memory usage at or above this percentage (0 disables)")
+	topCmd.Flags().Float64("storage-threshold", 90, "Highlight hosts with storage usage at or above this percentage (0 disables)")
+	topCmd.Flags().Bool("once", false, "Print a single frame and exit, without a terminal")
+	topCmd.Flags().Bool("no-color", false, "Don't highlight with colors")
+
+	rootCmd.AddCommand(topCmd)
+}
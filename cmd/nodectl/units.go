@@ -0,0 +1,65 @@
+package main
+
+import (
+	"github.com/metorial/sentinel/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+var unitsCmd = &cobra.Command{
+	Use:   "units",
+	Short: "List watched systemd units across the fleet",
+	Long: `List the state of the systemd units agents watch, failed units first.
+
+Agents watch the units listed in SYSTEMD_UNITS (comma separated). A failed unit
+raises an alert on its host until it recovers.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hostname, _ := cmd.Flags().GetString("host")
+		state, _ := cmd.Flags().GetString("state")
+		if failed, _ := cmd.Flags().GetBool("failed"); failed {
+			state = "failed"
+		}
+
+		client := cli.NewClient(serverURL)
+		data, err := client.ListUnits(hostname, state)
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(data, "units")
+		}
+
+		return cli.FormatUnitsTable(data)
+	},
+}
+
+var unitsHostCmd = &cobra.Command{
+	Use:   "units [hostname]",
+	Short: "Show watched systemd units of a host and their state changes",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		client := cli.NewClient(serverURL)
+		data, err := client.GetHostUnits(args[0], limit)
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(data, "units")
+		}
+
+		return cli.FormatHostUnits(data)
+	},
+}
+
+func init() {
+	unitsCmd.Flags().String("host", "", "Only show units of this host")
+	unitsCmd.Flags().String("state", "", "Only show units in this active state (e.g. failed, active)")
+	unitsCmd.Flags().Bool("failed", false, "Only show failed units")
+	unitsHostCmd.Flags().IntP("limit", "l", 100, "Number of state changes to retrieve (max: 1000)")
+
+	hostsCmd.AddCommand(unitsHostCmd)
+	rootCmd.AddCommand(unitsCmd)
+}
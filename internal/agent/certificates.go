@@ -0,0 +1,297 @@
+package agent
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/metorial/sentinel/proto"
+	psnet "github.com/shirou/gopsutil/v3/net"
+)
+
+const (
+	// certScanInterval is how often certificates are rescanned; they rarely
+	// change and probing ports on every tick would be wasteful.
+	certScanInterval = 5 * time.Minute
+	// certDialTimeout bounds the TLS handshake with each listening port, most
+	// of which don't speak TLS at all.
+	certDialTimeout = 2 * time.Second
+	// certProbeWorkers is how many listening ports are probed at once, and
+	// certProbeDeadline how long probing them all may take.
+	certProbeWorkers  = 8
+	certProbeDeadline = 20 * time.Second
+	// certReprobeInterval is how long the result of probing a port is reused,
+	// unless the certificate it served has expired since.
+	certReprobeInterval = time.Hour
+)
+
+// CertificateCollector reports the TLS certificates found in PEM files
+// matching a set of globs and, optionally, served on local listening ports.
+type CertificateCollector struct {
+	hostname  string
+	globs     []string
+	scanPorts bool
+	// listeners returns the local addresses to probe for certificates.
+	listeners func() ([]string, error)
+	lastScan  time.Time
+	// probes holds the last probe of each listening port.
+	probes map[string]certProbe
+}
+
+// certProbe is the certificate served on a port, nil if it didn't complete a
+// TLS handshake.
+type certProbe struct {
+	cert *x509.Certificate
+	at   time.Time
+}
+
+func NewCertificateCollector(hostname string, globs []string, scanPorts bool) *CertificateCollector {
+	return &CertificateCollector{
+		hostname:  hostname,
+		globs:     globs,
+		scanPorts: scanPorts,
+		listeners: listeningAddrs,
+	}
+}
+
+func (cc *CertificateCollector) Name() string { return "certificates" }
+
+func (cc *CertificateCollector) Collect(ctx context.Context) ([]*pb.AgentMessage, error) {
+	if !cc.lastScan.IsZero() && time.Since(cc.lastScan) < certScanInterval {
+		return nil, nil
+	}
+
+	list := &pb.CertificateList{
+		Hostname:  cc.hostname,
+		Timestamp: time.Now().Unix(),
+	}
+
+	for _, glob := range cc.globs {
+		matches, err := filepath.Glob(hostPath(glob))
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate glob %q: %w", glob, err)
+		}
+		for _, match := range matches {
+			certs, err := readCertificateFile(match)
+			if err != nil {
+				log.Printf("Skipping certificate file %s: %v", match, err)
+				continue
+			}
+			source := match
+			if hostRoot != "/" {
+				source = "/" + strings.TrimPrefix(match, hostRoot+string(filepath.Separator))
+			}
+			for _, cert := range certs {
+				list.Certificates = append(list.Certificates, certificateToProto(source, cert))
+			}
+		}
+	}
+
+	if cc.scanPorts {
+		addrs, err := cc.listeners()
+		if err != nil {
+			return nil, fmt.Errorf("list listening ports: %w", err)
+		}
+		cc.probes = cc.probePorts(ctx, addrs, time.Now())
+		for addr, probe := range cc.probes {
+			if probe.cert != nil {
+				list.Certificates = append(list.Certificates, certificateToProto("tcp://"+addr, probe.cert))
+			}
+		}
+	}
+
+	sort.SliceStable(list.Certificates, func(i, j int) bool {
+		return list.Certificates[i].Source < list.Certificates[j].Source
+	})
+
+	cc.lastScan = time.Now()
+
+	return []*pb.AgentMessage{{
+		Payload: &pb.AgentMessage_Certificates{Certificates: list},
+	}}, nil
+}
+
+// probePorts returns the probe of each of addrs, reusing earlier probes that
+// are recent enough. Ports are probed by a few workers at once; those not
+// probed by certProbeDeadline keep their earlier probe, if any.
+func (cc *CertificateCollector) probePorts(ctx context.Context, addrs []string, now time.Time) map[string]certProbe {
+	probes := make(map[string]certProbe, len(addrs))
+	var pending []string
+	for _, addr := range addrs {
+		probe, ok := cc.probes[addr]
+		if ok && now.Sub(probe.at) < certReprobeInterval && (probe.cert == nil || now.Before(probe.cert.NotAfter)) {
+			probes[addr] = probe
+			continue
+		}
+		if ok {
+			probes[addr] = probe
+		}
+		pending = append(pending, addr)
+	}
+	if len(pending) == 0 {
+		return probes
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, certProbeDeadline)
+	defer cancel()
+
+	type result struct {
+		addr  string
+		probe certProbe
+	}
+	work := make(chan string)
+	results := make(chan result)
+	var wg sync.WaitGroup
+	for i := 0; i < min(certProbeWorkers, len(pending)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for addr := range work {
+				cert, err := fetchCertificate(ctx, addr)
+				if ctx.Err() != nil {
+					// Out of time rather than not speaking TLS
+					continue
+				}
+				if err != nil {
+					cert = nil
+				}
+				results <- result{addr, certProbe{cert: cert, at: now}}
+			}
+		}()
+	}
+	go func() {
+		defer close(work)
+		for _, addr := range pending {
+			select {
+			case work <- addr:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for r := range results {
+		probes[r.addr] = r.probe
+	}
+	return probes
+}
+
+// readCertificateFile parses every certificate in a PEM file, e.g. a leaf
+// followed by its chain. Other blocks such as private keys are skipped.
+func readCertificateFile(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no PEM certificates found")
+	}
+	return certs, nil
+}
+
+// fetchCertificate returns the leaf certificate served at addr.
+func fetchCertificate(ctx context.Context, addr string) (*x509.Certificate, error) {
+	ctx, cancel := context.WithTimeout(ctx, certDialTimeout)
+	defer cancel()
+
+	dialer := &tls.Dialer{Config: &tls.Config{InsecureSkipVerify: true}}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate presented")
+	}
+	return certs[0], nil
+}
+
+// listeningAddrs returns a loopback address for every local TCP port in the
+// LISTEN state.
+func listeningAddrs() ([]string, error) {
+	conns, err := psnet.Connections("tcp")
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var addrs []string
+	for _, c := range conns {
+		if c.Status != "LISTEN" {
+			continue
+		}
+		ip := c.Laddr.IP
+		switch ip {
+		case "", "0.0.0.0", "::":
+			ip = "127.0.0.1"
+		}
+		addr := net.JoinHostPort(ip, strconv.FormatUint(uint64(c.Laddr.Port), 10))
+		if !seen[addr] {
+			seen[addr] = true
+			addrs = append(addrs, addr)
+		}
+	}
+	sort.Strings(addrs)
+	return addrs, nil
+}
+
+func certificateToProto(source string, cert *x509.Certificate) *pb.Certificate {
+	sans := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+
+	fingerprint := sha256.Sum256(cert.Raw)
+
+	return &pb.Certificate{
+		Source:      source,
+		Subject:     cert.Subject.String(),
+		Issuer:      cert.Issuer.String(),
+		Sans:        sans,
+		NotBefore:   cert.NotBefore.Unix(),
+		NotAfter:    cert.NotAfter.Unix(),
+		Serial:      cert.SerialNumber.Text(16),
+		Fingerprint: hex.EncodeToString(fingerprint[:]),
+	}
+}
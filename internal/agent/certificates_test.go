@@ -0,0 +1,171 @@
+package agent
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func writeTestCertificate(t *testing.T, path, commonName string, notAfter time.Time) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{commonName, "www." + commonName},
+		IPAddresses:  []net.IP{net.ParseIP("10.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+}
+
+func TestCertificateCollectorFiles(t *testing.T) {
+	dir := t.TempDir()
+	notAfter := time.Now().Add(30 * 24 * time.Hour).Truncate(time.Second)
+	writeTestCertificate(t, filepath.Join(dir, "example.pem"), "example.com", notAfter)
+	if err := os.WriteFile(filepath.Join(dir, "broken.pem"), []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	collector := NewCertificateCollector("test-host", []string{filepath.Join(dir, "*.pem")}, false)
+	msgs, err := collector.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(msgs))
+	}
+
+	list := msgs[0].GetCertificates()
+	if list == nil || list.Hostname != "test-host" {
+		t.Fatalf("Unexpected message: %v", msgs[0])
+	}
+	if len(list.Certificates) != 1 {
+		t.Fatalf("Expected 1 certificate, got %d", len(list.Certificates))
+	}
+
+	cert := list.Certificates[0]
+	if cert.Source != filepath.Join(dir, "example.pem") {
+		t.Errorf("Expected source %s, got %s", filepath.Join(dir, "example.pem"), cert.Source)
+	}
+	if cert.Subject != "CN=example.com" || cert.Issuer != "CN=example.com" {
+		t.Errorf("Unexpected subject %q or issuer %q", cert.Subject, cert.Issuer)
+	}
+	if strings.Join(cert.Sans, ",") != "example.com,www.example.com,10.0.0.1" {
+		t.Errorf("Unexpected SANs: %v", cert.Sans)
+	}
+	if cert.NotAfter != notAfter.Unix() {
+		t.Errorf("Expected not_after %d, got %d", notAfter.Unix(), cert.NotAfter)
+	}
+	if cert.Serial != "2a" || len(cert.Fingerprint) != 64 {
+		t.Errorf("Unexpected serial %q or fingerprint %q", cert.Serial, cert.Fingerprint)
+	}
+
+	// Certificates are rescanned only every certScanInterval
+	msgs, err = collector.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	if len(msgs) != 0 {
+		t.Errorf("Expected no messages before the next scan, got %d", len(msgs))
+	}
+}
+
+func TestCertificateCollectorPorts(t *testing.T) {
+	var dials atomic.Int32
+	countDials := func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			dials.Add(1)
+		}
+	}
+	server := httptest.NewUnstartedServer(http.NotFoundHandler())
+	server.Config.ConnState = countDials
+	server.StartTLS()
+	defer server.Close()
+	plain := httptest.NewUnstartedServer(http.NotFoundHandler())
+	plain.Config.ConnState = countDials
+	plain.Start()
+	defer plain.Close()
+
+	tlsAddr := strings.TrimPrefix(server.URL, "https://")
+	plainAddr := strings.TrimPrefix(plain.URL, "http://")
+
+	collector := NewCertificateCollector("test-host", nil, true)
+	collector.listeners = func() ([]string, error) {
+		return []string{tlsAddr, plainAddr}, nil
+	}
+
+	msgs, err := collector.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+
+	certs := msgs[0].GetCertificates().Certificates
+	if len(certs) != 1 {
+		t.Fatalf("Expected 1 certificate, got %d", len(certs))
+	}
+	if certs[0].Source != "tcp://"+tlsAddr {
+		t.Errorf("Expected source tcp://%s, got %s", tlsAddr, certs[0].Source)
+	}
+
+	// The next scan reuses the probes rather than dialing again
+	probed := dials.Load()
+	collector.lastScan = time.Time{}
+	msgs, err = collector.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	if len(msgs[0].GetCertificates().Certificates) != 1 {
+		t.Errorf("Expected the cached certificate, got %+v", msgs[0].GetCertificates().Certificates)
+	}
+	if dials.Load() != probed {
+		t.Errorf("Expected no new connections, got %d", dials.Load()-probed)
+	}
+
+	// A port whose certificate expired since is probed again
+	probe := collector.probes[tlsAddr]
+	expired := *probe.cert
+	expired.NotAfter = time.Now().Add(-time.Minute)
+	collector.probes[tlsAddr] = certProbe{cert: &expired, at: probe.at}
+	collector.lastScan = time.Time{}
+	if _, err := collector.Collect(context.Background()); err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	if dials.Load() != probed+1 {
+		t.Errorf("Expected the expired port probed again, got %d new connections", dials.Load()-probed)
+	}
+}
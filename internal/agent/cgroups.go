@@ -0,0 +1,248 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/metorial/sentinel/proto"
+)
+
+// maxCgroupDepth bounds how deep below the cgroup2 mount containers are
+// looked for; runtimes nest them at most a few levels deep.
+const maxCgroupDepth = 5
+
+// cgroupRuntimes recognizes container cgroups by their directory name (and
+// parent for runtimes using the cgroupfs driver) and extracts their id.
+var cgroupRuntimes = []struct {
+	runtime string
+	parent  string
+	name    *regexp.Regexp
+}{
+	{"docker", "", regexp.MustCompile(`^docker-([0-9a-f]{12,64})\.scope$`)},
+	{"docker", "docker", regexp.MustCompile(`^([0-9a-f]{12,64})$`)},
+	{"podman", "", regexp.MustCompile(`^libpod-([0-9a-f]{12,64})\.scope$`)},
+	{"containerd", "", regexp.MustCompile(`^cri-containerd-([0-9a-f]{12,64})\.scope$`)},
+	{"cri-o", "", regexp.MustCompile(`^crio-([0-9a-f]{12,64})\.scope$`)},
+	// Nomad places tasks in nomad.slice, or below its share.slice and
+	// reserve.slice children since 1.7, named <alloc id>.<task>.scope.
+	{"nomad", "nomad.slice", regexp.MustCompile(`^(.+)\.scope$`)},
+	{"nomad", "share.slice", regexp.MustCompile(`^(.+)\.scope$`)},
+	{"nomad", "reserve.slice", regexp.MustCompile(`^(.+)\.scope$`)},
+}
+
+// CgroupCollector reports CPU, memory and IO usage of the container cgroups
+// found in the host's cgroup v2 hierarchy.
+type CgroupCollector struct {
+	hostname string
+	root     string
+
+	// CPU usage per cgroup path at the previous collection, for computing
+	// cpu_percent.
+	prevCPU  map[string]int64
+	prevTime time.Time
+}
+
+func NewCgroupCollector(hostname string) *CgroupCollector {
+	return &CgroupCollector{
+		hostname: hostname,
+		root:     hostSysPath("fs", "cgroup"),
+	}
+}
+
+// CgroupV2Available reports whether the host uses the unified cgroup v2
+// hierarchy the CgroupCollector reads.
+func CgroupV2Available() bool {
+	_, err := os.Stat(hostSysPath("fs", "cgroup", "cgroup.controllers"))
+	return err == nil
+}
+
+func (cc *CgroupCollector) Name() string { return "cgroups" }
+
+func (cc *CgroupCollector) Collect(ctx context.Context) ([]*pb.AgentMessage, error) {
+	now := time.Now()
+	elapsed := now.Sub(cc.prevTime)
+
+	metrics := &pb.CgroupMetrics{
+		Hostname:  cc.hostname,
+		Timestamp: now.Unix(),
+	}
+	cpu := make(map[string]int64)
+
+	err := filepath.WalkDir(cc.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Cgroups disappear while we walk the tree.
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() || path == cc.root {
+			return nil
+		}
+
+		rel, err := filepath.Rel(cc.root, path)
+		if err != nil {
+			return err
+		}
+
+		runtime, id := classifyCgroup(rel)
+		if runtime == "" {
+			if strings.Count(rel, string(filepath.Separator)) >= maxCgroupDepth-1 {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		usage, usec, err := readCgroupUsage(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return fmt.Errorf("read cgroup %s: %w", rel, err)
+		}
+		usage.Path = filepath.ToSlash(rel)
+		usage.Runtime = runtime
+		usage.Id = id
+
+		cpu[usage.Path] = usec
+		if prev, ok := cc.prevCPU[usage.Path]; ok && elapsed > 0 && usec >= prev {
+			usage.CpuPercent = float64(usec-prev) / float64(elapsed.Microseconds()) * 100
+		}
+
+		metrics.Cgroups = append(metrics.Cgroups, usage)
+		// Container cgroups may have children of their own; they are
+		// accounted to the container.
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cc.prevCPU = cpu
+	cc.prevTime = now
+
+	return []*pb.AgentMessage{{
+		Payload: &pb.AgentMessage_Cgroups{Cgroups: metrics},
+	}}, nil
+}
+
+// classifyCgroup returns the runtime and id of the container whose cgroup is
+// at rel, or empty strings if rel is not a container cgroup.
+func classifyCgroup(rel string) (runtime, id string) {
+	dir, name := filepath.Split(filepath.ToSlash(rel))
+	parent := filepath.Base(dir)
+	if dir == "" {
+		parent = ""
+	}
+
+	for _, r := range cgroupRuntimes {
+		if r.parent != "" && r.parent != parent {
+			continue
+		}
+		if m := r.name.FindStringSubmatch(name); m != nil {
+			return r.runtime, m[1]
+		}
+	}
+	return "", ""
+}
+
+// readCgroupUsage reads the usage of the cgroup at dir, returning the
+// cumulative CPU time in microseconds alongside it.
+func readCgroupUsage(dir string) (*pb.CgroupUsage, int64, error) {
+	usage := &pb.CgroupUsage{}
+
+	cpuStat, err := readKeyValues(filepath.Join(dir, "cpu.stat"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, 0, err
+	}
+	usec := cpuStat["usage_usec"]
+
+	if usage.MemoryBytes, err = readCgroupInt(filepath.Join(dir, "memory.current")); err != nil && !os.IsNotExist(err) {
+		return nil, 0, err
+	}
+	if usage.MemoryLimitBytes, err = readCgroupInt(filepath.Join(dir, "memory.max")); err != nil && !os.IsNotExist(err) {
+		return nil, 0, err
+	}
+
+	usage.IoReadBytes, usage.IoWriteBytes, err = readIOStat(filepath.Join(dir, "io.stat"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, 0, err
+	}
+
+	return usage, usec, nil
+}
+
+// readCgroupInt reads a single-value cgroup file; "max" reads as 0.
+func readCgroupInt(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "max" {
+		return 0, nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// readKeyValues parses flat keyed files such as cpu.stat ("key value" lines).
+func readKeyValues(path string) (map[string]int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]int64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), " ")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64); err == nil {
+			values[key] = n
+		}
+	}
+	return values, scanner.Err()
+}
+
+// readIOStat sums rbytes and wbytes over all devices in io.stat, whose lines
+// look like "8:0 rbytes=1024 wbytes=2048 rios=1 wios=2 dbytes=0 dios=0".
+func readIOStat(path string) (read, write int64, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		for _, field := range fields[min(1, len(fields)):] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			switch key {
+			case "rbytes":
+				read += n
+			case "wbytes":
+				write += n
+			}
+		}
+	}
+	return read, write, scanner.Err()
+}
@@ -0,0 +1,108 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeCgroupFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create %s: %v", dir, err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+}
+
+func TestClassifyCgroup(t *testing.T) {
+	id := "3f4e8c2d1b0a99887766554433221100ffeeddccbbaa99887766554433221100"
+
+	tests := []struct {
+		path        string
+		wantRuntime string
+		wantID      string
+	}{
+		{"system.slice/docker-" + id + ".scope", "docker", id},
+		{"docker/" + id, "docker", id},
+		{"machine.slice/libpod-" + id + ".scope", "podman", id},
+		{"kubepods.slice/kubepods-pod1.slice/cri-containerd-" + id + ".scope", "containerd", id},
+		{"nomad.slice/share.slice/8c1f0f9a.redis.scope", "nomad", "8c1f0f9a.redis"},
+		{"nomad.slice/8c1f0f9a.redis.scope", "nomad", "8c1f0f9a.redis"},
+		{"nomad.slice/share.slice", "", ""},
+		{"system.slice/sshd.service", "", ""},
+		{"docker", "", ""},
+	}
+
+	for _, tt := range tests {
+		runtime, id := classifyCgroup(tt.path)
+		if runtime != tt.wantRuntime || id != tt.wantID {
+			t.Errorf("classifyCgroup(%q) = %q, %q, want %q, %q", tt.path, runtime, id, tt.wantRuntime, tt.wantID)
+		}
+	}
+}
+
+func TestCgroupCollector(t *testing.T) {
+	root := t.TempDir()
+	id := "3f4e8c2d1b0a99887766554433221100ffeeddccbbaa99887766554433221100"
+	container := filepath.Join(root, "system.slice", "docker-"+id+".scope")
+
+	writeCgroupFiles(t, root, map[string]string{"cgroup.controllers": "cpu io memory pids\n"})
+	writeCgroupFiles(t, filepath.Join(root, "system.slice", "sshd.service"), map[string]string{
+		"cpu.stat": "usage_usec 100\n",
+	})
+	writeCgroupFiles(t, container, map[string]string{
+		"cpu.stat":       "usage_usec 1000000\nuser_usec 800000\nsystem_usec 200000\n",
+		"memory.current": "52428800\n",
+		"memory.max":     "max\n",
+		"io.stat":        "8:0 rbytes=4096 wbytes=8192 rios=1 wios=2 dbytes=0 dios=0\n8:16 rbytes=1024 wbytes=0 rios=1 wios=0 dbytes=0 dios=0\n",
+	})
+
+	collector := &CgroupCollector{hostname: "web-1", root: root}
+
+	msgs, err := collector.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect() error: %v", err)
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(msgs))
+	}
+
+	cgroups := msgs[0].GetCgroups().Cgroups
+	if len(cgroups) != 1 {
+		t.Fatalf("Expected 1 container cgroup, got %d", len(cgroups))
+	}
+
+	cg := cgroups[0]
+	if cg.Runtime != "docker" || cg.Id != id || cg.Path != "system.slice/docker-"+id+".scope" {
+		t.Errorf("Unexpected cgroup identity: %+v", cg)
+	}
+	if cg.MemoryBytes != 52428800 || cg.MemoryLimitBytes != 0 {
+		t.Errorf("Expected memory 52428800 without limit, got %d (limit %d)", cg.MemoryBytes, cg.MemoryLimitBytes)
+	}
+	if cg.IoReadBytes != 5120 || cg.IoWriteBytes != 8192 {
+		t.Errorf("Expected io 5120/8192, got %d/%d", cg.IoReadBytes, cg.IoWriteBytes)
+	}
+	if cg.CpuPercent != 0 {
+		t.Errorf("Expected no cpu percent on first collect, got %f", cg.CpuPercent)
+	}
+
+	// Pretend a second passed during which the container used half a core.
+	collector.prevTime = time.Now().Add(-time.Second)
+	writeCgroupFiles(t, container, map[string]string{"cpu.stat": "usage_usec 1500000\n"})
+
+	msgs, err = collector.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect() error: %v", err)
+	}
+
+	cpu := msgs[0].GetCgroups().Cgroups[0].CpuPercent
+	if cpu < 40 || cpu > 51 {
+		t.Errorf("Expected cpu percent around 50, got %f", cpu)
+	}
+}
@@ -0,0 +1,250 @@
+package agent
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	pb "github.com/metorial/sentinel/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	defaultCheckInterval = 60 * time.Second
+	defaultCheckTimeout  = 10 * time.Second
+	// maxCheckBodyBytes bounds how much of an HTTP response is matched
+	// against body_regex.
+	maxCheckBodyBytes = 1 << 20
+)
+
+// checkRunner runs the checks assigned by the controller, each on its own
+// interval, and reports results through report.
+type checkRunner struct {
+	report func(*pb.CheckResult)
+
+	mu      sync.Mutex
+	running map[int64]*runningCheck
+}
+
+type runningCheck struct {
+	def    *pb.CheckDefinition
+	cancel context.CancelFunc
+}
+
+func newCheckRunner(report func(*pb.CheckResult)) *checkRunner {
+	return &checkRunner{
+		report:  report,
+		running: make(map[int64]*runningCheck),
+	}
+}
+
+// Update replaces the running checks with defs. Checks whose definition is
+// unchanged keep running on their current schedule.
+func (r *checkRunner) Update(defs []*pb.CheckDefinition) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	wanted := make(map[int64]*pb.CheckDefinition, len(defs))
+	for _, def := range defs {
+		wanted[def.Id] = def
+	}
+
+	for id, rc := range r.running {
+		if def, ok := wanted[id]; !ok || !proto.Equal(def, rc.def) {
+			rc.cancel()
+			delete(r.running, id)
+		}
+	}
+
+	for id, def := range wanted {
+		if _, ok := r.running[id]; ok {
+			continue
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		r.running[id] = &runningCheck{def: def, cancel: cancel}
+		go r.loop(ctx, def)
+	}
+
+	log.Printf("Running %d checks", len(r.running))
+}
+
+// Stop stops all checks.
+func (r *checkRunner) Stop() {
+	r.Update(nil)
+}
+
+func (r *checkRunner) loop(ctx context.Context, def *pb.CheckDefinition) {
+	interval := time.Duration(def.IntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = defaultCheckInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		result := RunCheck(ctx, def)
+		if ctx.Err() != nil {
+			return
+		}
+		r.report(result)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunCheck runs a check once and returns its result.
+func RunCheck(ctx context.Context, def *pb.CheckDefinition) *pb.CheckResult {
+	timeout := time.Duration(def.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = defaultCheckTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result := &pb.CheckResult{
+		CheckId:   def.Id,
+		Name:      def.Name,
+		Timestamp: time.Now().Unix(),
+	}
+
+	start := time.Now()
+	var message string
+	var err error
+	switch def.Type {
+	case "http":
+		message, err = checkHTTP(ctx, def)
+	case "tcp":
+		message, err = checkTCP(ctx, def)
+	case "tls":
+		message, err = checkTLS(ctx, def)
+	case "dns":
+		message, err = checkDNS(ctx, def)
+	default:
+		err = fmt.Errorf("unsupported check type %q", def.Type)
+	}
+	result.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+
+	if err != nil {
+		result.Message = err.Error()
+	} else {
+		result.Success = true
+		result.Message = message
+	}
+	return result
+}
+
+func checkHTTP(ctx context.Context, def *pb.CheckDefinition) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, def.Target, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if def.ExpectedStatus != 0 {
+		if resp.StatusCode != int(def.ExpectedStatus) {
+			return "", fmt.Errorf("status %d, expected %d", resp.StatusCode, def.ExpectedStatus)
+		}
+	} else if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	if def.BodyRegex != "" {
+		re, err := regexp.Compile(def.BodyRegex)
+		if err != nil {
+			return "", fmt.Errorf("invalid body regex: %w", err)
+		}
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxCheckBodyBytes))
+		if err != nil {
+			return "", fmt.Errorf("read body: %w", err)
+		}
+		if !re.Match(body) {
+			return "", fmt.Errorf("body does not match %q", def.BodyRegex)
+		}
+	}
+
+	return fmt.Sprintf("status %d", resp.StatusCode), nil
+}
+
+func checkTCP(ctx context.Context, def *pb.CheckDefinition) (string, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", def.Target)
+	if err != nil {
+		return "", err
+	}
+	conn.Close()
+	return "connected", nil
+}
+
+// checkTLS checks how long the certificate served at target remains valid.
+// The chain isn't verified, so certificates from internal CAs can be checked.
+func checkTLS(ctx context.Context, def *pb.CheckDefinition) (string, error) {
+	host, _, err := net.SplitHostPort(def.Target)
+	if err != nil {
+		return "", err
+	}
+
+	dialer := &tls.Dialer{Config: &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true,
+	}}
+	conn, err := dialer.DialContext(ctx, "tcp", def.Target)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return "", fmt.Errorf("no certificate presented")
+	}
+
+	notAfter := certs[0].NotAfter
+	days := int(time.Until(notAfter).Hours() / 24)
+	if time.Now().After(notAfter) {
+		return "", fmt.Errorf("certificate expired on %s", notAfter.Format(time.RFC3339))
+	}
+	if days < int(def.MinDaysValid) {
+		return "", fmt.Errorf("certificate expires in %d days on %s (minimum %d)",
+			days, notAfter.Format(time.RFC3339), def.MinDaysValid)
+	}
+	return fmt.Sprintf("certificate valid for %d days", days), nil
+}
+
+func checkDNS(ctx context.Context, def *pb.CheckDefinition) (string, error) {
+	addrs, err := net.DefaultResolver.LookupHost(ctx, def.Target)
+	if err != nil {
+		return "", err
+	}
+
+	if def.ExpectedAddress != "" {
+		found := false
+		for _, addr := range addrs {
+			if addr == def.ExpectedAddress {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("resolved to %v, expected %s", addrs, def.ExpectedAddress)
+		}
+	}
+
+	return fmt.Sprintf("resolved to %v", addrs), nil
+}
@@ -0,0 +1,130 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	pb "github.com/metorial/sentinel/proto"
+)
+
+func TestRunCheckHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"status": "ok"}`)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		def     *pb.CheckDefinition
+		success bool
+	}{
+		{"ok", &pb.CheckDefinition{Target: server.URL}, true},
+		{"expected status", &pb.CheckDefinition{Target: server.URL + "/missing", ExpectedStatus: 404}, true},
+		{"error status", &pb.CheckDefinition{Target: server.URL + "/missing"}, false},
+		{"unexpected status", &pb.CheckDefinition{Target: server.URL, ExpectedStatus: 204}, false},
+		{"body matches", &pb.CheckDefinition{Target: server.URL, BodyRegex: `"status":\s*"ok"`}, true},
+		{"body mismatch", &pb.CheckDefinition{Target: server.URL, BodyRegex: `degraded`}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.def.Type = "http"
+			result := RunCheck(context.Background(), tt.def)
+			if result.Success != tt.success {
+				t.Errorf("Expected success %v, got %v (%s)", tt.success, result.Success, result.Message)
+			}
+			if result.LatencyMs <= 0 {
+				t.Errorf("Expected positive latency, got %f", result.LatencyMs)
+			}
+		})
+	}
+}
+
+func TestRunCheckTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	addr := listener.Addr().String()
+
+	result := RunCheck(context.Background(), &pb.CheckDefinition{Type: "tcp", Target: addr})
+	if !result.Success {
+		t.Errorf("Expected success, got %s", result.Message)
+	}
+
+	listener.Close()
+
+	result = RunCheck(context.Background(), &pb.CheckDefinition{Type: "tcp", Target: addr})
+	if result.Success {
+		t.Error("Expected failure after listener closed")
+	}
+}
+
+func TestRunCheckTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+
+	target := strings.TrimPrefix(server.URL, "https://")
+
+	result := RunCheck(context.Background(), &pb.CheckDefinition{Type: "tls", Target: target, MinDaysValid: 1})
+	if !result.Success {
+		t.Errorf("Expected success, got %s", result.Message)
+	}
+
+	// The test certificate is valid for a long time, but not this long
+	result = RunCheck(context.Background(), &pb.CheckDefinition{Type: "tls", Target: target, MinDaysValid: 1000000})
+	if result.Success {
+		t.Error("Expected failure for certificate expiring before min_days_valid")
+	}
+}
+
+func TestRunCheckDNS(t *testing.T) {
+	result := RunCheck(context.Background(), &pb.CheckDefinition{Type: "dns", Target: "localhost"})
+	if !result.Success {
+		t.Skipf("localhost does not resolve here: %s", result.Message)
+	}
+
+	result = RunCheck(context.Background(), &pb.CheckDefinition{Type: "dns", Target: "localhost", ExpectedAddress: "192.0.2.1"})
+	if result.Success {
+		t.Error("Expected failure for unexpected address")
+	}
+}
+
+func TestCheckRunnerUpdate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	results := make(chan *pb.CheckResult, 10)
+	runner := newCheckRunner(func(r *pb.CheckResult) { results <- r })
+	defer runner.Stop()
+
+	def := &pb.CheckDefinition{Id: 1, Name: "web", Type: "http", Target: server.URL, IntervalSeconds: 3600}
+	runner.Update([]*pb.CheckDefinition{def})
+
+	result := <-results
+	if result.CheckId != 1 || result.Name != "web" || !result.Success {
+		t.Errorf("Unexpected result: %v", result)
+	}
+
+	// An unchanged definition keeps its schedule instead of running again
+	runner.Update([]*pb.CheckDefinition{def})
+	select {
+	case r := <-results:
+		t.Errorf("Expected no rerun of unchanged check, got %v", r)
+	default:
+	}
+
+	runner.Update(nil)
+	if len(runner.running) != 0 {
+		t.Errorf("Expected no running checks, got %d", len(runner.running))
+	}
+}
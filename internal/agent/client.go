@@ -5,21 +5,51 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"runtime"
+	"sync"
 	"time"
 
+	"github.com/metorial/sentinel/internal/version"
 	pb "github.com/metorial/sentinel/proto"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 )
 
+// Capabilities are the optional protocol features this agent supports and
+// offers to the controller in its Hello.
+var Capabilities = []string{"commands", "config", "inventory", "cgroups", "docker", "checks", "certificates", "systemd", "logs", "sensors"}
+
+// collectorTimeout bounds a single run of a collector, so that a stuck one
+// can't hold back the others or its own next run.
+const collectorTimeout = 30 * time.Second
+
 type Client struct {
 	collector *MetricsCollector
 	conn      *grpc.ClientConn
 	stream    pb.MetricsCollector_StreamMetricsClient
 	hostname  string
+
+	// sendMu serializes writes to the stream, which is shared between the
+	// reporting loop and command handlers.
+	sendMu     sync.Mutex
+	intervalCh chan time.Duration
+	configCh   chan *pb.AgentConfig
+
+	collectors []Collector
+	// enabled holds the collectors enabled by the current config; nil
+	// enables all of them.
+	enabled map[string]bool
+	// resend holds the collectors the controller asked to send their data
+	// again, handed from the receiving goroutine to the collector goroutine.
+	resendMu sync.Mutex
+	resend   map[string]bool
+
+	checks *checkRunner
 }
 
-func NewClient(collectorAddr string) (*Client, error) {
+func NewClient(collectorAddr string, opts ...Option) (*Client, error) {
 	collector, err := NewMetricsCollector()
 	if err != nil {
 		return nil, fmt.Errorf("create metrics collector: %w", err)
@@ -41,10 +71,23 @@ func NewClient(collectorAddr string) (*Client, error) {
 	}
 
 	c := &Client{
-		collector: collector,
-		conn:      conn,
-		stream:    stream,
-		hostname:  collector.hostname,
+		collector:  collector,
+		conn:       conn,
+		stream:     stream,
+		hostname:   collector.hostname,
+		intervalCh: make(chan time.Duration, 1),
+		configCh:   make(chan *pb.AgentConfig, 1),
+	}
+
+	c.checks = newCheckRunner(c.sendCheckResult)
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if err := c.sendHello(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("send hello: %w", err)
 	}
 
 	go c.receiveMessages()
@@ -52,10 +95,23 @@ func NewClient(collectorAddr string) (*Client, error) {
 	return c, nil
 }
 
+// Start reports metrics every interval until ctx is done. Configs pushed by the
+// collector are applied here, between ticks; a config without a report
+// interval restores the interval passed to Start. The optional collectors are
+// run right away, so the inventory reaches the collector as soon as the stream
+// is up, and then on the same ticks, in a goroutine of their own so that they
+// never delay the metrics.
 func (c *Client) Start(ctx context.Context, interval time.Duration) error {
+	defaultInterval := interval
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	collectCtx, stopCollectors := context.WithCancel(ctx)
+	defer stopCollectors()
+	runs := make(chan map[string]bool, 1)
+	runs <- c.enabled
+	go c.collectLoop(collectCtx, runs)
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -64,6 +120,31 @@ func (c *Client) Start(ctx context.Context, interval time.Duration) error {
 			if err := c.sendMetrics(); err != nil {
 				return fmt.Errorf("send metrics: %w", err)
 			}
+			// Skip the collectors this tick if their last run is still going
+			select {
+			case runs <- c.enabled:
+			default:
+			}
+		case config := <-c.configCh:
+			newInterval := defaultInterval
+			if config.ReportIntervalSeconds > 0 {
+				newInterval = time.Duration(config.ReportIntervalSeconds) * time.Second
+			}
+			if err := c.applyConfig(config); err != nil {
+				log.Printf("Error applying config version %d: %v", config.Version, err)
+				c.sendConfigApplied(config.Version, err)
+				continue
+			}
+			if newInterval != interval {
+				log.Printf("Changing report interval from %s to %s", interval, newInterval)
+				interval = newInterval
+				ticker.Reset(interval)
+			}
+			c.sendConfigApplied(config.Version, nil)
+		case newInterval := <-c.intervalCh:
+			log.Printf("Changing report interval from %s to %s", interval, newInterval)
+			interval = newInterval
+			ticker.Reset(interval)
 		}
 	}
 }
@@ -80,6 +161,138 @@ func (c *Client) sendMetrics() error {
 		},
 	}
 
+	return c.send(msg)
+}
+
+func (c *Client) sendHello() error {
+	return c.send(&pb.AgentMessage{
+		Payload: &pb.AgentMessage_Hello{
+			Hello: &pb.Hello{
+				Hostname:        c.hostname,
+				AgentVersion:    version.Version,
+				ProtocolVersion: version.Protocol,
+				Os:              runtime.GOOS,
+				Arch:            runtime.GOARCH,
+				Capabilities:    Capabilities,
+			},
+		},
+	})
+}
+
+// collectLoop runs the collectors enabled at each tick of Start until ctx is
+// done.
+func (c *Client) collectLoop(ctx context.Context, runs <-chan map[string]bool) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case enabled := <-runs:
+			c.runCollectors(ctx, enabled)
+		}
+	}
+}
+
+// runCollectors runs the enabled collectors, all of them if enabled is nil,
+// giving each collectorTimeout to finish.
+func (c *Client) runCollectors(ctx context.Context, enabled map[string]bool) {
+	for _, collector := range c.collectors {
+		if ctx.Err() != nil {
+			return
+		}
+		if enabled != nil && !enabled[collector.Name()] {
+			continue
+		}
+		if r, ok := collector.(resender); ok && c.takeResend(collector.Name()) {
+			r.Resend()
+		}
+
+		collectCtx, cancel := context.WithTimeout(ctx, collectorTimeout)
+		msgs, err := collector.Collect(collectCtx)
+		timedOut := collectCtx.Err() == context.DeadlineExceeded
+		cancel()
+		if err != nil {
+			if timedOut {
+				log.Printf("Collector %s timed out after %s: %v", collector.Name(), collectorTimeout, err)
+			} else {
+				log.Printf("Collector %s failed: %v", collector.Name(), err)
+			}
+			continue
+		}
+
+		for _, msg := range msgs {
+			if err := c.send(msg); err != nil {
+				log.Printf("Error sending %s data: %v", collector.Name(), err)
+			}
+		}
+	}
+}
+
+// requestResend has the collector named name send its data again on its next
+// run.
+func (c *Client) requestResend(name string) {
+	c.resendMu.Lock()
+	defer c.resendMu.Unlock()
+	if c.resend == nil {
+		c.resend = make(map[string]bool)
+	}
+	c.resend[name] = true
+}
+
+// takeResend reports whether the collector named name was asked to send its
+// data again, clearing the request.
+func (c *Client) takeResend(name string) bool {
+	c.resendMu.Lock()
+	defer c.resendMu.Unlock()
+	requested := c.resend[name]
+	delete(c.resend, name)
+	return requested
+}
+
+func (c *Client) applyConfig(config *pb.AgentConfig) error {
+	if err := c.collector.SetMountFilters(config.MountInclude, config.MountExclude); err != nil {
+		return err
+	}
+
+	// enabled is handed to the collector goroutine, so it is replaced rather
+	// than modified
+	if len(config.EnabledCollectors) == 0 {
+		c.enabled = nil
+	} else {
+		c.enabled = make(map[string]bool, len(config.EnabledCollectors))
+		for _, name := range config.EnabledCollectors {
+			c.enabled[name] = true
+		}
+	}
+
+	log.Printf("Applied config version %d (profile %q)", config.Version, config.Profile)
+	return nil
+}
+
+func (c *Client) sendConfigApplied(version int64, applyErr error) {
+	applied := &pb.ConfigApplied{Version: version, Success: applyErr == nil}
+	if applyErr != nil {
+		applied.Error = applyErr.Error()
+	}
+
+	if err := c.send(&pb.AgentMessage{
+		Payload: &pb.AgentMessage_ConfigApplied{ConfigApplied: applied},
+	}); err != nil {
+		log.Printf("Error acknowledging config version %d: %v", version, err)
+	}
+}
+
+func (c *Client) sendCheckResult(result *pb.CheckResult) {
+	if err := c.send(&pb.AgentMessage{
+		Payload: &pb.AgentMessage_CheckResult{CheckResult: result},
+	}); err != nil {
+		log.Printf("Error sending result of check %s: %v", result.Name, err)
+	}
+}
+
+func (c *Client) send(msg *pb.AgentMessage) error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
 	if err := c.stream.Send(msg); err != nil {
 		return fmt.Errorf("send to stream: %w", err)
 	}
@@ -94,17 +307,47 @@ func (c *Client) receiveMessages() {
 			log.Println("Stream closed by server")
 			return
 		}
+		if status.Code(err) == codes.FailedPrecondition {
+			log.Printf("Controller rejected this agent: %s", status.Convert(err).Message())
+			return
+		}
 		if err != nil {
 			log.Printf("Error receiving message: %v", err)
 			return
 		}
 
 		switch payload := msg.Payload.(type) {
+		case *pb.CollectorMessage_Welcome:
+			welcome := payload.Welcome
+			log.Printf("Connected to controller %s (protocol %d), accepted capabilities: %v",
+				welcome.ControllerVersion, welcome.ProtocolVersion, welcome.AcceptedCapabilities)
+
 		case *pb.CollectorMessage_Ack:
 			ack := payload.Ack
 			if !ack.Success {
 				log.Printf("Server reported error: %s", ack.Message)
 			}
+			if ack.Resend != "" {
+				c.requestResend(ack.Resend)
+			}
+
+		case *pb.CollectorMessage_Command:
+			go c.handleCommand(payload.Command)
+
+		case *pb.CollectorMessage_Checks:
+			c.checks.Update(payload.Checks.Checks)
+
+		case *pb.CollectorMessage_Config:
+			// Only the newest config matters; drop one Start hasn't picked up yet.
+			select {
+			case <-c.configCh:
+			default:
+			}
+			select {
+			case c.configCh <- payload.Config:
+			default:
+				log.Printf("Dropping config version %d: agent is not accepting configs", payload.Config.Version)
+			}
 
 		default:
 			log.Printf("Unknown message type: %T", payload)
@@ -112,7 +355,62 @@ func (c *Client) receiveMessages() {
 	}
 }
 
+func (c *Client) handleCommand(cmd *pb.Command) {
+	result := &pb.CommandResult{Id: cmd.Id}
+
+	output, err := c.executeCommand(cmd)
+	if err != nil {
+		log.Printf("Command %s failed: %v", cmd.Id, err)
+		result.Error = err.Error()
+	} else {
+		result.Success = true
+		result.Output = output
+	}
+
+	if err := c.send(&pb.AgentMessage{
+		Payload: &pb.AgentMessage_CommandResult{CommandResult: result},
+	}); err != nil {
+		log.Printf("Error sending result for command %s: %v", cmd.Id, err)
+	}
+}
+
+func (c *Client) executeCommand(cmd *pb.Command) (string, error) {
+	switch action := cmd.Action.(type) {
+	case *pb.Command_CollectNow:
+		if err := c.sendMetrics(); err != nil {
+			return "", err
+		}
+		return "metrics sent", nil
+
+	case *pb.Command_SetInterval:
+		interval := time.Duration(action.SetInterval.IntervalSeconds) * time.Second
+		if interval <= 0 {
+			return "", fmt.Errorf("interval must be positive, got %ds", action.SetInterval.IntervalSeconds)
+		}
+		// Replace any interval change that Start hasn't picked up yet.
+		select {
+		case <-c.intervalCh:
+		default:
+		}
+		select {
+		case c.intervalCh <- interval:
+		default:
+			return "", fmt.Errorf("agent is not accepting interval changes")
+		}
+		return fmt.Sprintf("report interval set to %s", interval), nil
+
+	case *pb.Command_RunDiagnostic:
+		return RunDiagnostic(action.RunDiagnostic.Name)
+
+	default:
+		return "", fmt.Errorf("unsupported command: %T", action)
+	}
+}
+
 func (c *Client) Close() error {
+	if c.checks != nil {
+		c.checks.Stop()
+	}
 	if c.stream != nil {
 		c.stream.CloseSend()
 	}
@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/metorial/sentinel/internal/version"
 	pb "github.com/metorial/sentinel/proto"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
@@ -19,6 +20,7 @@ const bufSize = 1024 * 1024
 type mockServer struct {
 	pb.UnimplementedMetricsCollectorServer
 	receivedMetrics []*pb.HostMetrics
+	receivedHellos  []*pb.Hello
 	mu              sync.Mutex
 }
 
@@ -35,6 +37,12 @@ func (m *mockServer) StreamMetrics(stream pb.MetricsCollector_StreamMetricsServe
 			m.mu.Unlock()
 		}
 
+		if hello := msg.GetHello(); hello != nil {
+			m.mu.Lock()
+			m.receivedHellos = append(m.receivedHellos, hello)
+			m.mu.Unlock()
+		}
+
 		if err := stream.Send(&pb.CollectorMessage{
 			Payload: &pb.CollectorMessage_Ack{
 				Ack: &pb.Acknowledgment{
@@ -253,3 +261,253 @@ func TestClientStart(t *testing.T) {
 
 	t.Logf("Received %d metrics", len(received))
 }
+
+func TestClientSetIntervalCommand(t *testing.T) {
+	client := &Client{intervalCh: make(chan time.Duration, 1)}
+
+	output, err := client.executeCommand(&pb.Command{
+		Action: &pb.Command_SetInterval{SetInterval: &pb.SetInterval{IntervalSeconds: 30}},
+	})
+	if err != nil {
+		t.Fatalf("executeCommand() error: %v", err)
+	}
+
+	if !strings.Contains(output, "30s") {
+		t.Errorf("Expected output to mention new interval, got %q", output)
+	}
+
+	select {
+	case interval := <-client.intervalCh:
+		if interval != 30*time.Second {
+			t.Errorf("Expected 30s interval, got %s", interval)
+		}
+	default:
+		t.Error("Expected interval change to be queued")
+	}
+
+	if _, err := client.executeCommand(&pb.Command{
+		Action: &pb.Command_SetInterval{SetInterval: &pb.SetInterval{IntervalSeconds: 0}},
+	}); err == nil {
+		t.Error("Expected error for non-positive interval")
+	}
+}
+
+type fakeCollector struct {
+	name    string
+	calls   int
+	resends int
+}
+
+func (f *fakeCollector) Name() string { return f.name }
+
+func (f *fakeCollector) Resend() { f.resends++ }
+
+func (f *fakeCollector) Collect(ctx context.Context) ([]*pb.AgentMessage, error) {
+	f.calls++
+	return nil, nil
+}
+
+func TestClientApplyConfig(t *testing.T) {
+	collector, err := NewMetricsCollector()
+	if err != nil {
+		t.Fatalf("Failed to create metrics collector: %v", err)
+	}
+
+	docker := &fakeCollector{name: "docker"}
+	certs := &fakeCollector{name: "certs"}
+	client := &Client{collector: collector}
+	WithCollectors(docker, certs)(client)
+
+	if err := client.applyConfig(&pb.AgentConfig{Version: 3, EnabledCollectors: []string{"certs"}}); err != nil {
+		t.Fatalf("applyConfig() error: %v", err)
+	}
+
+	client.runCollectors(context.Background(), client.enabled)
+	if docker.calls != 0 || certs.calls != 1 {
+		t.Errorf("Expected only certs to run, got docker=%d certs=%d", docker.calls, certs.calls)
+	}
+
+	if err := client.applyConfig(&pb.AgentConfig{Version: 4}); err != nil {
+		t.Fatalf("applyConfig() error: %v", err)
+	}
+
+	client.runCollectors(context.Background(), client.enabled)
+	if docker.calls != 1 || certs.calls != 2 {
+		t.Errorf("Expected all collectors to run, got docker=%d certs=%d", docker.calls, certs.calls)
+	}
+
+	if err := client.applyConfig(&pb.AgentConfig{Version: 5, MountInclude: []string{"["}}); err == nil {
+		t.Error("Expected error for invalid mount pattern")
+	}
+
+	// A resend asked for by the controller is passed on before the next run
+	client.requestResend("docker")
+	client.runCollectors(context.Background(), nil)
+	client.runCollectors(context.Background(), nil)
+	if docker.resends != 1 || certs.resends != 0 {
+		t.Errorf("Expected docker to resend once, got docker=%d certs=%d", docker.resends, certs.resends)
+	}
+}
+
+// blockingCollector doesn't return until its context is done.
+type blockingCollector struct {
+	started chan struct{}
+	done    chan struct{}
+}
+
+func (b *blockingCollector) Name() string { return "blocking" }
+
+func (b *blockingCollector) Collect(ctx context.Context) ([]*pb.AgentMessage, error) {
+	select {
+	case b.started <- struct{}{}:
+	default:
+	}
+	<-ctx.Done()
+	close(b.done)
+	return nil, ctx.Err()
+}
+
+func TestClientSlowCollectorDoesNotDelayMetrics(t *testing.T) {
+	mock, listener, cleanup := setupMockServer(t)
+	defer cleanup()
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return listener.Dial()
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	collector, err := NewMetricsCollector()
+	if err != nil {
+		t.Fatalf("Failed to create metrics collector: %v", err)
+	}
+	stream, err := pb.NewMetricsCollectorClient(conn).StreamMetrics(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to create stream: %v", err)
+	}
+
+	blocking := &blockingCollector{started: make(chan struct{}, 1), done: make(chan struct{})}
+	client := &Client{collector: collector, conn: conn, stream: stream, hostname: collector.hostname}
+	WithCollectors(blocking)(client)
+	go client.receiveMessages()
+
+	// Collecting metrics takes a second to sample the CPU
+	ctx, cancel := context.WithTimeout(context.Background(), 2500*time.Millisecond)
+	defer cancel()
+	if err := client.Start(ctx, 50*time.Millisecond); err != context.DeadlineExceeded {
+		if err != nil && strings.Contains(err.Error(), "not implemented yet") {
+			t.Skip("Skipping test: CPU metrics not available without CGO")
+		}
+		t.Fatalf("Expected Start to run until the deadline, got %v", err)
+	}
+
+	select {
+	case <-blocking.started:
+	default:
+		t.Fatal("Expected the collector to run")
+	}
+	// Stopping the client cancels the collector still running
+	select {
+	case <-blocking.done:
+	case <-time.After(time.Second):
+		t.Error("Expected the running collector to be cancelled")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if received := mock.getReceivedMetrics(); len(received) < 2 {
+		t.Errorf("Expected metrics on every tick despite the stuck collector, got %d", len(received))
+	}
+}
+
+func TestClientRunsCollectorsOnStart(t *testing.T) {
+	_, listener, cleanup := setupMockServer(t)
+	defer cleanup()
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return listener.Dial()
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	collector, err := NewMetricsCollector()
+	if err != nil {
+		t.Fatalf("Failed to create metrics collector: %v", err)
+	}
+	stream, err := pb.NewMetricsCollectorClient(conn).StreamMetrics(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to create stream: %v", err)
+	}
+
+	blocking := &blockingCollector{started: make(chan struct{}, 1), done: make(chan struct{})}
+	client := &Client{collector: collector, conn: conn, stream: stream, hostname: collector.hostname}
+	WithCollectors(blocking)(client)
+	go client.receiveMessages()
+
+	// The first tick is an hour away
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if err := client.Start(ctx, time.Hour); err != context.DeadlineExceeded {
+		t.Fatalf("Expected Start to run until the deadline, got %v", err)
+	}
+
+	select {
+	case <-blocking.started:
+	default:
+		t.Error("Expected the collectors to run before the first tick")
+	}
+}
+
+func TestClientSendHello(t *testing.T) {
+	mock, listener, cleanup := setupMockServer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	conn, err := grpc.DialContext(ctx, "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return listener.Dial()
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	stream, err := pb.NewMetricsCollectorClient(conn).StreamMetrics(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create stream: %v", err)
+	}
+
+	client := &Client{conn: conn, stream: stream, hostname: "test-host"}
+	if err := client.sendHello(); err != nil {
+		t.Fatalf("sendHello() error: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+
+	if len(mock.receivedHellos) != 1 {
+		t.Fatalf("Expected 1 hello, got %d", len(mock.receivedHellos))
+	}
+
+	hello := mock.receivedHellos[0]
+	if hello.Hostname != "test-host" || hello.ProtocolVersion != version.Protocol {
+		t.Errorf("Unexpected hello: %v", hello)
+	}
+
+	if hello.Os == "" || hello.Arch == "" || len(hello.Capabilities) == 0 {
+		t.Errorf("Expected platform and capabilities in hello, got %v", hello)
+	}
+}
@@ -0,0 +1,37 @@
+package agent
+
+import (
+	"context"
+
+	pb "github.com/metorial/sentinel/proto"
+)
+
+// Collector is an optional data source the agent runs on every report tick in
+// addition to the core host metrics. Collectors run apart from the metrics, so
+// a slow one never delays a report. Collectors can be switched on and off
+// remotely through the enabled_collectors list of the agent config.
+type Collector interface {
+	// Name identifies the collector in agent config profiles.
+	Name() string
+	// Collect returns the messages to send for this tick. Returning no
+	// messages is fine, e.g. when nothing changed since the last tick. It
+	// should give up once ctx is done.
+	Collect(ctx context.Context) ([]*pb.AgentMessage, error)
+}
+
+// resender is implemented by collectors that only send what changed since
+// their last run. Resend makes the next run send everything again, for when
+// the controller couldn't store what was sent.
+type resender interface {
+	Resend()
+}
+
+// Option configures optional behavior of a Client.
+type Option func(*Client)
+
+// WithCollectors registers optional collectors with the client.
+func WithCollectors(collectors ...Collector) Option {
+	return func(c *Client) {
+		c.collectors = append(c.collectors, collectors...)
+	}
+}
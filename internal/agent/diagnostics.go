@@ -0,0 +1,124 @@
+package agent
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/host"
+	"github.com/shirou/gopsutil/v3/load"
+	"github.com/shirou/gopsutil/v3/mem"
+	"github.com/shirou/gopsutil/v3/net"
+)
+
+// diagnostics holds the named diagnostics that can be run remotely through a
+// RunDiagnostic command. Only these built-ins are runnable; the controller can
+// never make the agent execute arbitrary commands.
+var diagnostics = map[string]func() (string, error){
+	"uptime":  diagnoseUptime,
+	"load":    diagnoseLoad,
+	"memory":  diagnoseMemory,
+	"disk":    diagnoseDisk,
+	"network": diagnoseNetwork,
+}
+
+// DiagnosticNames returns the names of all available diagnostics, sorted.
+func DiagnosticNames() []string {
+	names := make([]string, 0, len(diagnostics))
+	for name := range diagnostics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RunDiagnostic runs the named diagnostic and returns its human-readable output.
+func RunDiagnostic(name string) (string, error) {
+	fn, ok := diagnostics[name]
+	if !ok {
+		return "", fmt.Errorf("unknown diagnostic %q (available: %s)", name, strings.Join(DiagnosticNames(), ", "))
+	}
+	return fn()
+}
+
+func diagnoseUptime() (string, error) {
+	uptime, err := host.Uptime()
+	if err != nil {
+		return "", fmt.Errorf("get uptime: %w", err)
+	}
+
+	bootTime, err := host.BootTime()
+	if err != nil {
+		return "", fmt.Errorf("get boot time: %w", err)
+	}
+
+	return fmt.Sprintf("uptime: %s\nbooted: %s\n",
+		time.Duration(uptime)*time.Second,
+		time.Unix(int64(bootTime), 0).UTC().Format(time.RFC3339)), nil
+}
+
+func diagnoseLoad() (string, error) {
+	avg, err := load.Avg()
+	if err != nil {
+		return "", fmt.Errorf("get load average: %w", err)
+	}
+	return fmt.Sprintf("load average: %.2f %.2f %.2f\n", avg.Load1, avg.Load5, avg.Load15), nil
+}
+
+func diagnoseMemory() (string, error) {
+	vm, err := mem.VirtualMemory()
+	if err != nil {
+		return "", fmt.Errorf("get memory info: %w", err)
+	}
+
+	swap, err := mem.SwapMemory()
+	if err != nil {
+		return "", fmt.Errorf("get swap info: %w", err)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "total:     %d\n", vm.Total)
+	fmt.Fprintf(&b, "used:      %d (%.1f%%)\n", vm.Used, vm.UsedPercent)
+	fmt.Fprintf(&b, "available: %d\n", vm.Available)
+	fmt.Fprintf(&b, "swap:      %d/%d\n", swap.Used, swap.Total)
+	return b.String(), nil
+}
+
+func diagnoseDisk() (string, error) {
+	partitions, err := disk.Partitions(false)
+	if err != nil {
+		return "", fmt.Errorf("list partitions: %w", err)
+	}
+
+	var b strings.Builder
+	for _, p := range partitions {
+		usage, err := disk.Usage(p.Mountpoint)
+		if err != nil {
+			fmt.Fprintf(&b, "%s (%s): %v\n", p.Mountpoint, p.Fstype, err)
+			continue
+		}
+		fmt.Fprintf(&b, "%s (%s): %d/%d bytes used (%.1f%%)\n",
+			p.Mountpoint, p.Fstype, usage.Used, usage.Total, usage.UsedPercent)
+	}
+	return b.String(), nil
+}
+
+func diagnoseNetwork() (string, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return "", fmt.Errorf("list interfaces: %w", err)
+	}
+
+	var b strings.Builder
+	for _, iface := range interfaces {
+		addrs := make([]string, 0, len(iface.Addrs))
+		for _, addr := range iface.Addrs {
+			addrs = append(addrs, addr.Addr)
+		}
+		fmt.Fprintf(&b, "%s mtu=%d mac=%s addrs=[%s]\n",
+			iface.Name, iface.MTU, iface.HardwareAddr, strings.Join(addrs, ", "))
+	}
+	return b.String(), nil
+}
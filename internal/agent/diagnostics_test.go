@@ -0,0 +1,44 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRunDiagnostic(t *testing.T) {
+	output, err := RunDiagnostic("uptime")
+	if err != nil {
+		if strings.Contains(err.Error(), "not implemented yet") {
+			t.Skip("Skipping test: uptime not available on this platform")
+		}
+		t.Fatalf("RunDiagnostic() error: %v", err)
+	}
+
+	if !strings.Contains(output, "uptime:") {
+		t.Errorf("Expected uptime output, got %q", output)
+	}
+}
+
+func TestRunDiagnosticUnknown(t *testing.T) {
+	_, err := RunDiagnostic("rm -rf /")
+	if err == nil {
+		t.Fatal("Expected error for unknown diagnostic")
+	}
+
+	if !strings.Contains(err.Error(), "available:") {
+		t.Errorf("Expected error to list available diagnostics, got %v", err)
+	}
+}
+
+func TestDiagnosticNames(t *testing.T) {
+	names := DiagnosticNames()
+	if len(names) != len(diagnostics) {
+		t.Errorf("Expected %d names, got %d", len(diagnostics), len(names))
+	}
+
+	for i := 1; i < len(names); i++ {
+		if names[i-1] > names[i] {
+			t.Errorf("Expected sorted names, got %v", names)
+		}
+	}
+}
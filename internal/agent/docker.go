@@ -0,0 +1,264 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	pb "github.com/metorial/sentinel/proto"
+)
+
+// DefaultDockerSocket is the Docker Engine API socket used unless configured
+// otherwise.
+const DefaultDockerSocket = "/var/run/docker.sock"
+
+// dockerAPIVersion is the oldest Engine API version providing every field the
+// collector reads (Docker 20.10).
+const dockerAPIVersion = "v1.41"
+
+// dockerInspectInterval is how long the inspected details of a container are
+// reused while its state doesn't change.
+const dockerInspectInterval = time.Minute
+
+// DockerCollector reports the containers of the local Docker Engine, talking
+// to its API over a Unix socket.
+type DockerCollector struct {
+	hostname string
+	client   *http.Client
+
+	// CPU counters per container id at the previous collection, for computing
+	// cpu_percent.
+	prevCPU map[string]dockerCPUSample
+	// Inspected details per container id.
+	inspected map[string]dockerInspect
+}
+
+type dockerCPUSample struct {
+	total, system uint64
+}
+
+type dockerInspect struct {
+	state        string
+	restartCount int32
+	at           time.Time
+}
+
+func NewDockerCollector(hostname, socket string) *DockerCollector {
+	return &DockerCollector{
+		hostname: hostname,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+	}
+}
+
+func (dc *DockerCollector) Name() string { return "docker" }
+
+func (dc *DockerCollector) Collect(ctx context.Context) ([]*pb.AgentMessage, error) {
+	var summaries []struct {
+		ID      string   `json:"Id"`
+		Names   []string `json:"Names"`
+		Image   string   `json:"Image"`
+		State   string   `json:"State"`
+		Status  string   `json:"Status"`
+		Created int64    `json:"Created"`
+	}
+	if err := dc.get(ctx, "/containers/json?all=true", &summaries); err != nil {
+		return nil, fmt.Errorf("list containers: %w", err)
+	}
+
+	now := time.Now()
+	list := &pb.ContainerList{
+		Hostname:  dc.hostname,
+		Timestamp: now.Unix(),
+	}
+	cpu := make(map[string]dockerCPUSample)
+	inspected := make(map[string]dockerInspect)
+
+	// A container that can't be read is reported with the error rather than
+	// failing the whole list
+	for _, s := range summaries {
+		container := &pb.Container{
+			Id:      s.ID,
+			Image:   s.Image,
+			State:   s.State,
+			Status:  s.Status,
+			Created: s.Created,
+		}
+		if len(s.Names) > 0 {
+			container.Name = strings.TrimPrefix(s.Names[0], "/")
+		}
+
+		inspect, ok := dc.inspected[s.ID]
+		if !ok || inspect.state != s.State || now.Sub(inspect.at) >= dockerInspectInterval {
+			fresh, err := dc.inspect(ctx, s.ID, s.State, now)
+			switch {
+			case isDockerNotFound(err):
+				// The container was removed since it was listed
+				continue
+			case err != nil:
+				container.Error = fmt.Sprintf("inspect: %v", err)
+			default:
+				inspect, ok = fresh, true
+			}
+		}
+		if ok {
+			container.RestartCount = inspect.restartCount
+			inspected[s.ID] = inspect
+		}
+
+		if s.State == "running" {
+			sample, err := dc.collectStats(ctx, container)
+			switch {
+			case err == nil:
+				cpu[s.ID] = sample
+			case !isDockerNotFound(err) && container.Error == "":
+				container.Error = fmt.Sprintf("stats: %v", err)
+			}
+		}
+
+		list.Containers = append(list.Containers, container)
+	}
+
+	dc.prevCPU = cpu
+	dc.inspected = inspected
+
+	return []*pb.AgentMessage{{
+		Payload: &pb.AgentMessage_Containers{Containers: list},
+	}}, nil
+}
+
+// inspect reads the details of a container not included in the container
+// list.
+func (dc *DockerCollector) inspect(ctx context.Context, id, state string, now time.Time) (dockerInspect, error) {
+	var inspect struct {
+		RestartCount int32 `json:"RestartCount"`
+	}
+	if err := dc.get(ctx, "/containers/"+url.PathEscape(id)+"/json", &inspect); err != nil {
+		return dockerInspect{}, err
+	}
+	return dockerInspect{state: state, restartCount: inspect.RestartCount, at: now}, nil
+}
+
+// dockerStats is the subset of the Engine's container stats used here.
+type dockerStats struct {
+	CPUStats struct {
+		CPUUsage struct {
+			TotalUsage uint64 `json:"total_usage"`
+		} `json:"cpu_usage"`
+		SystemUsage uint64 `json:"system_cpu_usage"`
+		OnlineCPUs  uint32 `json:"online_cpus"`
+	} `json:"cpu_stats"`
+	MemoryStats struct {
+		Usage uint64            `json:"usage"`
+		Limit uint64            `json:"limit"`
+		Stats map[string]uint64 `json:"stats"`
+	} `json:"memory_stats"`
+	Networks map[string]struct {
+		RxBytes uint64 `json:"rx_bytes"`
+		TxBytes uint64 `json:"tx_bytes"`
+	} `json:"networks"`
+	BlkioStats struct {
+		IOServiceBytesRecursive []struct {
+			Op    string `json:"op"`
+			Value uint64 `json:"value"`
+		} `json:"io_service_bytes_recursive"`
+	} `json:"blkio_stats"`
+}
+
+// collectStats fills in the resource usage of a running container and
+// returns its CPU counters.
+func (dc *DockerCollector) collectStats(ctx context.Context, container *pb.Container) (dockerCPUSample, error) {
+	var stats dockerStats
+	if err := dc.get(ctx, "/containers/"+url.PathEscape(container.Id)+"/stats?stream=false&one-shot=true", &stats); err != nil {
+		return dockerCPUSample{}, err
+	}
+
+	sample := dockerCPUSample{
+		total:  stats.CPUStats.CPUUsage.TotalUsage,
+		system: stats.CPUStats.SystemUsage,
+	}
+	if prev, ok := dc.prevCPU[container.Id]; ok && sample.system > prev.system && sample.total >= prev.total {
+		cpus := float64(stats.CPUStats.OnlineCPUs)
+		if cpus == 0 {
+			cpus = 1
+		}
+		container.CpuPercent = float64(sample.total-prev.total) / float64(sample.system-prev.system) * cpus * 100
+	}
+
+	// Like `docker stats`, don't count reclaimable page cache as used.
+	memory := stats.MemoryStats.Usage
+	inactive := stats.MemoryStats.Stats["inactive_file"]
+	if inactive == 0 {
+		inactive = stats.MemoryStats.Stats["total_inactive_file"]
+	}
+	if inactive < memory {
+		memory -= inactive
+	}
+	container.MemoryBytes = int64(memory)
+	container.MemoryLimitBytes = int64(stats.MemoryStats.Limit)
+
+	for _, network := range stats.Networks {
+		container.NetworkRxBytes += int64(network.RxBytes)
+		container.NetworkTxBytes += int64(network.TxBytes)
+	}
+
+	for _, entry := range stats.BlkioStats.IOServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			container.BlockReadBytes += int64(entry.Value)
+		case "write":
+			container.BlockWriteBytes += int64(entry.Value)
+		}
+	}
+
+	return sample, nil
+}
+
+type dockerError struct {
+	status  int
+	message string
+}
+
+func (e *dockerError) Error() string {
+	return fmt.Sprintf("docker API returned %d: %s", e.status, e.message)
+}
+
+func isDockerNotFound(err error) bool {
+	de, ok := err.(*dockerError)
+	return ok && de.status == http.StatusNotFound
+}
+
+func (dc *DockerCollector) get(ctx context.Context, path string, v interface{}) error {
+	// The host is ignored by the Unix socket dialer.
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker/"+dockerAPIVersion+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := dc.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var body struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return &dockerError{status: resp.StatusCode, message: body.Message}
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,192 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeDocker serves a minimal Docker Engine API on a Unix socket.
+type fakeDocker struct {
+	mu       sync.Mutex
+	cpuTotal uint64
+	system   uint64
+	inspects int
+}
+
+func (f *fakeDocker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if strings.HasSuffix(r.URL.Path, "/json") && r.URL.Path != "/"+dockerAPIVersion+"/containers/json" {
+		f.inspects++
+	}
+
+	switch r.URL.Path {
+	case "/" + dockerAPIVersion + "/containers/json":
+		if r.URL.Query().Get("all") != "true" {
+			http.Error(w, "expected all=true", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode([]map[string]interface{}{
+			{"Id": "aaa111", "Names": []string{"/web"}, "Image": "nginx:1.27", "State": "running", "Status": "Up 2 hours", "Created": 1733000000},
+			{"Id": "bbb222", "Names": []string{"/worker"}, "Image": "app:latest", "State": "exited", "Status": "Exited (1) 5 minutes ago", "Created": 1733000100},
+			{"Id": "ccc333", "Names": []string{"/gone"}, "Image": "busybox", "State": "running", "Status": "Up 1 second", "Created": 1733000200},
+			{"Id": "ddd444", "Names": []string{"/stuck"}, "Image": "busybox", "State": "running", "Status": "Up 1 hour", "Created": 1733000300},
+		})
+	case "/" + dockerAPIVersion + "/containers/aaa111/json":
+		json.NewEncoder(w).Encode(map[string]interface{}{"RestartCount": 0})
+	case "/" + dockerAPIVersion + "/containers/bbb222/json":
+		json.NewEncoder(w).Encode(map[string]interface{}{"RestartCount": 3})
+	case "/" + dockerAPIVersion + "/containers/ddd444/json", "/" + dockerAPIVersion + "/containers/ddd444/stats":
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"message": "container is stuck"})
+	case "/" + dockerAPIVersion + "/containers/aaa111/stats":
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"cpu_stats": map[string]interface{}{
+				"cpu_usage":        map[string]interface{}{"total_usage": f.cpuTotal},
+				"system_cpu_usage": f.system,
+				"online_cpus":      4,
+			},
+			"memory_stats": map[string]interface{}{
+				"usage": 104857600,
+				"limit": 536870912,
+				"stats": map[string]interface{}{"inactive_file": 4857600},
+			},
+			"networks": map[string]interface{}{
+				"eth0": map[string]interface{}{"rx_bytes": 1000, "tx_bytes": 2000},
+				"eth1": map[string]interface{}{"rx_bytes": 10, "tx_bytes": 20},
+			},
+			"blkio_stats": map[string]interface{}{
+				"io_service_bytes_recursive": []map[string]interface{}{
+					{"op": "read", "value": 4096},
+					{"op": "write", "value": 8192},
+				},
+			},
+		})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"message": "No such container"})
+	}
+}
+
+func startFakeDocker(t *testing.T, handler http.Handler) string {
+	t.Helper()
+
+	// Unix socket paths are limited to ~100 bytes, which t.TempDir() can exceed.
+	dir, err := os.MkdirTemp("", "docker")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	socket := filepath.Join(dir, "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Failed to listen on %s: %v", socket, err)
+	}
+
+	server := httptest.NewUnstartedServer(handler)
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+
+	return socket
+}
+
+func TestDockerCollector(t *testing.T) {
+	fake := &fakeDocker{cpuTotal: 1000, system: 100000}
+	socket := startFakeDocker(t, fake)
+
+	collector := NewDockerCollector("web-1", socket)
+
+	msgs, err := collector.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect() error: %v", err)
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(msgs))
+	}
+
+	list := msgs[0].GetContainers()
+	if list.Hostname != "web-1" {
+		t.Errorf("Expected hostname web-1, got %s", list.Hostname)
+	}
+	// ccc333 disappeared between listing and inspecting it.
+	if len(list.Containers) != 3 {
+		t.Fatalf("Expected 3 containers, got %d", len(list.Containers))
+	}
+
+	web, worker, stuck := list.Containers[0], list.Containers[1], list.Containers[2]
+	if web.Name != "web" || web.Image != "nginx:1.27" || web.State != "running" {
+		t.Errorf("Unexpected container: %+v", web)
+	}
+	if web.MemoryBytes != 100000000 || web.MemoryLimitBytes != 536870912 {
+		t.Errorf("Expected memory 100000000/536870912, got %d/%d", web.MemoryBytes, web.MemoryLimitBytes)
+	}
+	if web.NetworkRxBytes != 1010 || web.NetworkTxBytes != 2020 {
+		t.Errorf("Expected network 1010/2020, got %d/%d", web.NetworkRxBytes, web.NetworkTxBytes)
+	}
+	if web.BlockReadBytes != 4096 || web.BlockWriteBytes != 8192 {
+		t.Errorf("Expected block io 4096/8192, got %d/%d", web.BlockReadBytes, web.BlockWriteBytes)
+	}
+	if web.CpuPercent != 0 {
+		t.Errorf("Expected no cpu percent on first collect, got %f", web.CpuPercent)
+	}
+
+	if worker.RestartCount != 3 || worker.State != "exited" || worker.MemoryBytes != 0 {
+		t.Errorf("Unexpected stopped container: %+v", worker)
+	}
+	if web.Error != "" || worker.Error != "" {
+		t.Errorf("Expected no errors on readable containers, got %q and %q", web.Error, worker.Error)
+	}
+	// A container that can't be read is reported with its error
+	if stuck.Name != "stuck" || !strings.Contains(stuck.Error, "container is stuck") {
+		t.Errorf("Expected the error of the stuck container, got %+v", stuck)
+	}
+
+	fake.mu.Lock()
+	fake.cpuTotal += 5000
+	fake.system += 20000
+	fake.mu.Unlock()
+
+	msgs, err = collector.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect() error: %v", err)
+	}
+
+	// 5000/20000 of the system's time on 4 CPUs is one full core.
+	if cpu := msgs[0].GetContainers().Containers[0].CpuPercent; cpu != 100 {
+		t.Errorf("Expected cpu percent 100, got %f", cpu)
+	}
+	if worker := msgs[0].GetContainers().Containers[1]; worker.RestartCount != 3 {
+		t.Errorf("Expected the restart count kept from the first inspect, got %d", worker.RestartCount)
+	}
+
+	// Only the containers that couldn't be inspected are inspected again
+	fake.mu.Lock()
+	inspects := fake.inspects
+	fake.mu.Unlock()
+	if inspects != 6 {
+		t.Errorf("Expected 4 inspects on the first collect and 2 on the second, got %d", inspects)
+	}
+}
+
+func TestDockerCollectorUnavailable(t *testing.T) {
+	socket := startFakeDocker(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"message": "daemon is shutting down"})
+	}))
+
+	collector := NewDockerCollector("web-1", socket)
+	if _, err := collector.Collect(context.Background()); err == nil {
+		t.Error("Expected error when the Docker API fails")
+	}
+}
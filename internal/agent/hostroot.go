@@ -0,0 +1,84 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// hostRoot is the path the host's root filesystem is mounted at when the agent
+// runs in a container, or "/" when it reads the host directly.
+var hostRoot = "/"
+
+// SetHostRoot makes the agent report the host it runs on rather than its own
+// container, reading procfs, sysfs and filesystems below root (e.g. /host,
+// with the host's / bind-mounted there). Paths already set through gopsutil's
+// HOST_* environment variables take precedence.
+func SetHostRoot(root string) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		return fmt.Errorf("host root: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("host root %s is not a directory", root)
+	}
+
+	hostRoot = filepath.Clean(root)
+
+	for env, dir := range map[string]string{
+		"HOST_ROOT": "",
+		"HOST_PROC": "proc",
+		"HOST_SYS":  "sys",
+		"HOST_ETC":  "etc",
+		"HOST_VAR":  "var",
+		"HOST_RUN":  "run",
+		"HOST_DEV":  "dev",
+	} {
+		if os.Getenv(env) != "" {
+			continue
+		}
+		if err := os.Setenv(env, filepath.Join(hostRoot, dir)); err != nil {
+			return fmt.Errorf("set %s: %w", env, err)
+		}
+	}
+
+	return nil
+}
+
+// hostPath returns the path at which the host path p can be read.
+func hostPath(p string) string {
+	return filepath.Join(hostRoot, p)
+}
+
+// hostProcPath returns the path of a file below the host's /proc.
+func hostProcPath(elem ...string) string {
+	return hostSpecialPath("HOST_PROC", "/proc", elem)
+}
+
+// hostSysPath returns the path of a file below the host's /sys.
+func hostSysPath(elem ...string) string {
+	return hostSpecialPath("HOST_SYS", "/sys", elem)
+}
+
+func hostSpecialPath(env, dir string, elem []string) string {
+	base := os.Getenv(env)
+	if base == "" {
+		base = hostPath(dir)
+	}
+	return filepath.Join(append([]string{base}, elem...)...)
+}
+
+// Hostname returns the hostname of the host. Inside a container os.Hostname
+// reports the container's hostname, so the host's /etc/hostname is preferred
+// when a host root is set.
+func Hostname() (string, error) {
+	if hostRoot != "/" {
+		if data, err := os.ReadFile(hostPath("/etc/hostname")); err == nil {
+			if name := strings.TrimSpace(string(data)); name != "" {
+				return name, nil
+			}
+		}
+	}
+	return os.Hostname()
+}
@@ -0,0 +1,186 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	pb "github.com/metorial/sentinel/proto"
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/host"
+	"google.golang.org/protobuf/proto"
+)
+
+// InventoryCollector reports the OS and hardware inventory of the host. The
+// inventory is sent when the agent connects and afterwards only when it
+// changes.
+type InventoryCollector struct {
+	hostname string
+	gather   func() (*pb.Inventory, error)
+	last     *pb.Inventory
+}
+
+func NewInventoryCollector(hostname string) *InventoryCollector {
+	return &InventoryCollector{
+		hostname: hostname,
+		gather:   gatherInventory,
+	}
+}
+
+func (ic *InventoryCollector) Name() string { return "inventory" }
+
+func (ic *InventoryCollector) Resend() { ic.last = nil }
+
+func (ic *InventoryCollector) Collect(ctx context.Context) ([]*pb.AgentMessage, error) {
+	inventory, err := ic.gather()
+	if err != nil {
+		return nil, err
+	}
+	inventory.Hostname = ic.hostname
+
+	if ic.last != nil && proto.Equal(ic.last, inventory) {
+		return nil, nil
+	}
+	ic.last = inventory
+
+	// Timestamp is not part of the comparison above.
+	sent := proto.Clone(inventory).(*pb.Inventory)
+	sent.Timestamp = time.Now().Unix()
+
+	return []*pb.AgentMessage{{
+		Payload: &pb.AgentMessage_Inventory{Inventory: sent},
+	}}, nil
+}
+
+func gatherInventory() (*pb.Inventory, error) {
+	info, err := host.Info()
+	if err != nil {
+		return nil, fmt.Errorf("get host info: %w", err)
+	}
+
+	inventory := &pb.Inventory{
+		OsName:        info.Platform,
+		OsVersion:     info.PlatformVersion,
+		KernelVersion: info.KernelVersion,
+		Arch:          info.KernelArch,
+		BootTime:      int64(info.BootTime),
+		Timezone:      localTimezone(),
+		Container:     detectContainer(),
+	}
+	if inventory.OsName == "" {
+		inventory.OsName = info.OS
+	}
+	if inventory.Arch == "" {
+		inventory.Arch = runtime.GOARCH
+	}
+	if info.VirtualizationRole == "guest" && info.VirtualizationSystem != inventory.Container {
+		inventory.Virtualization = info.VirtualizationSystem
+	}
+
+	cpus, err := cpu.Info()
+	if err != nil {
+		return nil, fmt.Errorf("get cpu info: %w", err)
+	}
+	if len(cpus) > 0 {
+		inventory.CpuModel = strings.TrimSpace(cpus[0].ModelName)
+	}
+
+	inventory.Interfaces, err = networkInterfaces()
+	if err != nil {
+		return nil, fmt.Errorf("get network interfaces: %w", err)
+	}
+
+	return inventory, nil
+}
+
+// networkInterfaces lists the non-loopback interfaces, sorted by name.
+func networkInterfaces() ([]*pb.NetworkInterface, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*pb.NetworkInterface
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+
+		ni := &pb.NetworkInterface{
+			Name: iface.Name,
+			Mac:  iface.HardwareAddr.String(),
+		}
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, fmt.Errorf("addresses of %s: %w", iface.Name, err)
+		}
+		for _, addr := range addrs {
+			ni.Addresses = append(ni.Addresses, addr.String())
+		}
+		sort.Strings(ni.Addresses)
+
+		result = append(result, ni)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// localTimezone returns the IANA name of the local timezone if it can be
+// determined, otherwise the zone abbreviation.
+func localTimezone() string {
+	if tz := os.Getenv("TZ"); tz != "" {
+		return strings.TrimPrefix(tz, ":")
+	}
+
+	if target, err := os.Readlink(hostPath("/etc/localtime")); err == nil {
+		if _, name, ok := strings.Cut(target, "zoneinfo/"); ok {
+			return name
+		}
+	}
+
+	if data, err := os.ReadFile(hostPath("/etc/timezone")); err == nil {
+		if name := strings.TrimSpace(string(data)); name != "" {
+			return name
+		}
+	}
+
+	name, _ := time.Now().Zone()
+	return name
+}
+
+// detectContainer returns the container runtime the host runs in, if any.
+// With a host root set this inspects the host, not the agent's own container.
+func detectContainer() string {
+	if _, err := os.Stat(hostPath("/.dockerenv")); err == nil {
+		return "docker"
+	}
+	if _, err := os.Stat(hostPath("/run/.containerenv")); err == nil {
+		return "podman"
+	}
+	if data, err := os.ReadFile(hostProcPath("1", "environ")); err == nil {
+		for _, kv := range strings.Split(string(data), "\x00") {
+			if name, ok := strings.CutPrefix(kv, "container="); ok && name != "" {
+				return name
+			}
+		}
+	}
+	if data, err := os.ReadFile(hostProcPath("1", "cgroup")); err == nil {
+		cgroup := string(data)
+		switch {
+		case strings.Contains(cgroup, "/docker/"):
+			return "docker"
+		case strings.Contains(cgroup, "/kubepods"):
+			return "kubernetes"
+		case strings.Contains(cgroup, "/lxc/"):
+			return "lxc"
+		}
+	}
+	return ""
+}
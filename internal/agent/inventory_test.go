@@ -0,0 +1,70 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/metorial/sentinel/proto"
+)
+
+func TestInventoryCollectorSendsOnChange(t *testing.T) {
+	kernel := "6.8.0-45-generic"
+	collector := NewInventoryCollector("web-1")
+	collector.gather = func() (*pb.Inventory, error) {
+		return &pb.Inventory{OsName: "ubuntu", KernelVersion: kernel}, nil
+	}
+
+	msgs, err := collector.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect() error: %v", err)
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("Expected inventory on first collect, got %d messages", len(msgs))
+	}
+
+	inventory := msgs[0].GetInventory()
+	if inventory.Hostname != "web-1" || inventory.Timestamp == 0 {
+		t.Errorf("Expected hostname and timestamp to be set, got %+v", inventory)
+	}
+
+	msgs, err = collector.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect() error: %v", err)
+	}
+	if len(msgs) != 0 {
+		t.Errorf("Expected no messages for unchanged inventory, got %d", len(msgs))
+	}
+
+	// Asked to resend, e.g. because the controller couldn't store it
+	collector.Resend()
+	msgs, err = collector.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect() error: %v", err)
+	}
+	if len(msgs) != 1 {
+		t.Errorf("Expected the inventory sent again after Resend, got %d messages", len(msgs))
+	}
+
+	kernel = "6.8.0-49-generic"
+	msgs, err = collector.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect() error: %v", err)
+	}
+	if len(msgs) != 1 || msgs[0].GetInventory().KernelVersion != kernel {
+		t.Errorf("Expected changed inventory to be sent, got %v", msgs)
+	}
+}
+
+func TestGatherInventory(t *testing.T) {
+	inventory, err := gatherInventory()
+	if err != nil {
+		t.Fatalf("gatherInventory() error: %v", err)
+	}
+
+	if inventory.OsName == "" || inventory.Arch == "" {
+		t.Errorf("Expected OS name and arch, got %+v", inventory)
+	}
+	if inventory.BootTime == 0 {
+		t.Error("Expected boot time to be set")
+	}
+}
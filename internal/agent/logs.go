@@ -0,0 +1,238 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"time"
+
+	pb "github.com/metorial/sentinel/proto"
+)
+
+const (
+	// maxLogLines is how many of the last matching lines are sent per rule
+	// and interval.
+	maxLogLines = 10
+	// maxLogLineBytes truncates long matching lines before they are sent.
+	maxLogLineBytes = 1024
+	// maxLogReadBytes bounds how much of a file is read per tick; anything
+	// beyond is read on the following ticks.
+	maxLogReadBytes = 4 << 20
+	// maxLogPartialBytes is the longest line buffered while waiting for its
+	// newline.
+	maxLogPartialBytes = 64 << 10
+)
+
+// LogRule counts the lines appended to a file that match a regular
+// expression.
+type LogRule struct {
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	Pattern string `json:"pattern"`
+}
+
+// LoadLogRules reads a JSON array of log rules from path.
+func LoadLogRules(path string) ([]LogRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []LogRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("parse log rules %s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// LogCollector follows log files and reports, every tick, how many new lines
+// matched each rule along with the last matching lines. Files are followed
+// across rotation (a new file at the path) and truncation.
+type LogCollector struct {
+	hostname    string
+	tailers     []*logTailer
+	lastCollect time.Time
+}
+
+type logTailer struct {
+	path  string
+	rules []logMatcher
+
+	file    *os.File
+	offset  int64
+	partial []byte
+	// started is set once the path was first looked at; files present then
+	// are followed from their end, files appearing later from their start.
+	started bool
+}
+
+type logMatcher struct {
+	name string
+	re   *regexp.Regexp
+}
+
+func NewLogCollector(hostname string, rules []LogRule) (*LogCollector, error) {
+	lc := &LogCollector{hostname: hostname}
+	byPath := make(map[string]*logTailer)
+
+	for _, rule := range rules {
+		if rule.Name == "" || rule.Path == "" {
+			return nil, fmt.Errorf("log rule requires a name and path")
+		}
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("log rule %s: %w", rule.Name, err)
+		}
+
+		tailer, ok := byPath[rule.Path]
+		if !ok {
+			tailer = &logTailer{path: rule.Path}
+			byPath[rule.Path] = tailer
+			lc.tailers = append(lc.tailers, tailer)
+		}
+		tailer.rules = append(tailer.rules, logMatcher{name: rule.Name, re: re})
+	}
+
+	return lc, nil
+}
+
+func (lc *LogCollector) Name() string { return "logs" }
+
+func (lc *LogCollector) Collect(ctx context.Context) ([]*pb.AgentMessage, error) {
+	now := time.Now()
+	counts := &pb.LogCounts{
+		Hostname:  lc.hostname,
+		Timestamp: now.Unix(),
+	}
+	if !lc.lastCollect.IsZero() {
+		counts.IntervalSeconds = int32(now.Sub(lc.lastCollect).Round(time.Second).Seconds())
+	}
+	lc.lastCollect = now
+
+	for _, t := range lc.tailers {
+		lines, err := t.readLines()
+		if err != nil {
+			log.Printf("Error reading %s: %v", t.path, err)
+		}
+
+		for _, rule := range t.rules {
+			count := &pb.LogRuleCount{
+				Rule:   rule.name,
+				Labels: map[string]string{"path": t.path},
+			}
+			for _, line := range lines {
+				if !rule.re.MatchString(line) {
+					continue
+				}
+				count.Count++
+				if len(line) > maxLogLineBytes {
+					line = line[:maxLogLineBytes]
+				}
+				count.Lines = append(count.Lines, line)
+				if len(count.Lines) > maxLogLines {
+					count.Lines = count.Lines[1:]
+				}
+			}
+			counts.Counts = append(counts.Counts, count)
+		}
+	}
+
+	return []*pb.AgentMessage{{
+		Payload: &pb.AgentMessage_LogCounts{LogCounts: counts},
+	}}, nil
+}
+
+// readLines returns the complete lines appended to the file since the last
+// call.
+func (t *logTailer) readLines() ([]string, error) {
+	first := !t.started
+	t.started = true
+
+	info, err := os.Stat(hostPath(t.path))
+	if os.IsNotExist(err) {
+		// Rotated away and not recreated yet: finish the old file.
+		if t.file == nil {
+			return nil, nil
+		}
+		lines, err := t.read()
+		t.close()
+		return lines, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	if t.file != nil {
+		current, err := t.file.Stat()
+		if err != nil {
+			return nil, err
+		}
+		if !os.SameFile(info, current) {
+			// Rotated: finish the old file, then follow the new one.
+			lines, err = t.read()
+			t.close()
+			if err != nil {
+				return lines, err
+			}
+		} else if info.Size() < t.offset {
+			// Truncated: start over.
+			t.offset = 0
+			t.partial = nil
+		}
+	}
+
+	if t.file == nil {
+		f, err := os.Open(hostPath(t.path))
+		if err != nil {
+			return lines, err
+		}
+		t.file = f
+		t.offset = 0
+		if first {
+			t.offset = info.Size()
+		}
+	}
+
+	more, err := t.read()
+	return append(lines, more...), err
+}
+
+// read returns the complete lines available in the open file past offset.
+func (t *logTailer) read() ([]string, error) {
+	data, err := io.ReadAll(io.NewSectionReader(t.file, t.offset, maxLogReadBytes))
+	if err != nil {
+		return nil, err
+	}
+	t.offset += int64(len(data))
+
+	data = append(t.partial, data...)
+	var lines []string
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		lines = append(lines, string(bytes.TrimSuffix(data[:i], []byte("\r"))))
+		data = data[i+1:]
+	}
+
+	if len(data) > maxLogPartialBytes {
+		lines = append(lines, string(data))
+		data = nil
+	}
+	t.partial = append([]byte(nil), data...)
+	return lines, nil
+}
+
+func (t *logTailer) close() {
+	t.file.Close()
+	t.file = nil
+	t.offset = 0
+	t.partial = nil
+}
@@ -0,0 +1,143 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pb "github.com/metorial/sentinel/proto"
+)
+
+func appendLog(t *testing.T, path string, lines ...string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("Failed to open log: %v", err)
+	}
+	defer f.Close()
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(f, line); err != nil {
+			t.Fatalf("Failed to write log: %v", err)
+		}
+	}
+}
+
+func collectLogCounts(t *testing.T, lc *LogCollector) map[string]*pb.LogRuleCount {
+	t.Helper()
+	msgs, err := lc.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(msgs))
+	}
+	counts := make(map[string]*pb.LogRuleCount)
+	for _, c := range msgs[0].GetLogCounts().Counts {
+		counts[c.Rule] = c
+	}
+	return counts
+}
+
+func TestLogCollector(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendLog(t, path, "ERROR before the agent started")
+
+	lc, err := NewLogCollector("test-host", []LogRule{
+		{Name: "errors", Path: path, Pattern: `ERROR`},
+		{Name: "timeouts", Path: path, Pattern: `(?i)timed? ?out`},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create collector: %v", err)
+	}
+
+	// Existing content is skipped
+	counts := collectLogCounts(t, lc)
+	if counts["errors"].Count != 0 {
+		t.Errorf("Expected 0 errors, got %d", counts["errors"].Count)
+	}
+	if counts["errors"].Labels["path"] != path {
+		t.Errorf("Expected path label %s, got %v", path, counts["errors"].Labels)
+	}
+
+	appendLog(t, path, "ERROR one", "INFO fine", "ERROR request timed out")
+	// An incomplete line is counted once finished
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	f.WriteString("ERROR par")
+	f.Close()
+
+	counts = collectLogCounts(t, lc)
+	if counts["errors"].Count != 2 || counts["timeouts"].Count != 1 {
+		t.Errorf("Expected 2 errors and 1 timeout, got %d and %d", counts["errors"].Count, counts["timeouts"].Count)
+	}
+	if strings.Join(counts["errors"].Lines, "|") != "ERROR one|ERROR request timed out" {
+		t.Errorf("Unexpected lines: %v", counts["errors"].Lines)
+	}
+
+	appendLog(t, path, "tial")
+	counts = collectLogCounts(t, lc)
+	if counts["errors"].Count != 1 || counts["errors"].Lines[0] != "ERROR partial" {
+		t.Errorf("Expected the completed line, got %v", counts["errors"])
+	}
+
+	// Rotation: the old file is finished, the new one followed from its start
+	appendLog(t, path, "ERROR last in old file")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatalf("Failed to rotate: %v", err)
+	}
+	appendLog(t, path, "ERROR first in new file")
+
+	counts = collectLogCounts(t, lc)
+	if counts["errors"].Count != 2 {
+		t.Errorf("Expected 2 errors across rotation, got %d (%v)", counts["errors"].Count, counts["errors"].Lines)
+	}
+
+	// Truncation: counting restarts from the beginning
+	appendLog(t, path, "padding so the file shrinks when truncated", "more padding")
+	collectLogCounts(t, lc)
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatalf("Failed to truncate: %v", err)
+	}
+	appendLog(t, path, "ERROR after truncate")
+
+	counts = collectLogCounts(t, lc)
+	if counts["errors"].Count != 1 {
+		t.Errorf("Expected 1 error after truncation, got %d", counts["errors"].Count)
+	}
+}
+
+func TestLogCollectorKeepsLastLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+
+	lc, err := NewLogCollector("test-host", []LogRule{{Name: "errors", Path: path, Pattern: `ERROR`}})
+	if err != nil {
+		t.Fatalf("Failed to create collector: %v", err)
+	}
+
+	// The file doesn't exist yet; once created it is read from the start
+	collectLogCounts(t, lc)
+	for i := 0; i < maxLogLines+5; i++ {
+		appendLog(t, path, fmt.Sprintf("ERROR %d", i))
+	}
+
+	counts := collectLogCounts(t, lc)
+	if counts["errors"].Count != maxLogLines+5 {
+		t.Errorf("Expected %d errors, got %d", maxLogLines+5, counts["errors"].Count)
+	}
+	lines := counts["errors"].Lines
+	if len(lines) != maxLogLines || lines[len(lines)-1] != fmt.Sprintf("ERROR %d", maxLogLines+4) {
+		t.Errorf("Expected the last %d lines, got %v", maxLogLines, lines)
+	}
+}
+
+func TestNewLogCollectorInvalidRule(t *testing.T) {
+	if _, err := NewLogCollector("test-host", []LogRule{{Name: "bad", Path: "/var/log/x", Pattern: "("}}); err == nil {
+		t.Error("Expected error for invalid pattern")
+	}
+	if _, err := NewLogCollector("test-host", []LogRule{{Pattern: "x"}}); err == nil {
+		t.Error("Expected error for rule without name and path")
+	}
+}
@@ -3,7 +3,8 @@ package agent
 import (
 	"fmt"
 	"net"
-	"os"
+	"path/filepath"
+	"sync"
 	"time"
 
 	pb "github.com/metorial/sentinel/proto"
@@ -16,10 +17,14 @@ import (
 type MetricsCollector struct {
 	hostname string
 	ip       string
+
+	mu           sync.RWMutex
+	mountInclude []string
+	mountExclude []string
 }
 
 func NewMetricsCollector() (*MetricsCollector, error) {
-	hostname, err := os.Hostname()
+	hostname, err := Hostname()
 	if err != nil {
 		return nil, fmt.Errorf("get hostname: %w", err)
 	}
@@ -71,7 +76,7 @@ func (mc *MetricsCollector) collectInfo() (*pb.HostInfo, error) {
 		return nil, fmt.Errorf("get memory info: %w", err)
 	}
 
-	diskInfo, err := disk.Usage("/")
+	storageTotal, _, err := mc.storageUsage()
 	if err != nil {
 		return nil, fmt.Errorf("get disk info: %w", err)
 	}
@@ -80,7 +85,7 @@ func (mc *MetricsCollector) collectInfo() (*pb.HostInfo, error) {
 		UptimeSeconds:     int64(uptime),
 		CpuCores:          int32(cpuCores),
 		TotalMemoryBytes:  int64(memInfo.Total),
-		TotalStorageBytes: int64(diskInfo.Total),
+		TotalStorageBytes: int64(storageTotal),
 	}, nil
 }
 
@@ -95,7 +100,7 @@ func (mc *MetricsCollector) collectUsage() (*pb.ResourceUsage, error) {
 		return nil, fmt.Errorf("get memory usage: %w", err)
 	}
 
-	diskInfo, err := disk.Usage("/")
+	_, storageUsed, err := mc.storageUsage()
 	if err != nil {
 		return nil, fmt.Errorf("get disk usage: %w", err)
 	}
@@ -108,10 +113,77 @@ func (mc *MetricsCollector) collectUsage() (*pb.ResourceUsage, error) {
 	return &pb.ResourceUsage{
 		CpuPercent:       cpuPct,
 		UsedMemoryBytes:  int64(memInfo.Used),
-		UsedStorageBytes: int64(diskInfo.Used),
+		UsedStorageBytes: int64(storageUsed),
 	}, nil
 }
 
+// SetMountFilters sets the mountpoint globs counted towards storage totals.
+// With no include patterns only "/" is counted.
+func (mc *MetricsCollector) SetMountFilters(include, exclude []string) error {
+	for _, pattern := range append(append([]string{}, include...), exclude...) {
+		if _, err := filepath.Match(pattern, "/"); err != nil {
+			return fmt.Errorf("invalid mount pattern %q: %w", pattern, err)
+		}
+	}
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.mountInclude = include
+	mc.mountExclude = exclude
+	return nil
+}
+
+// storageUsage returns total and used bytes summed over the mounts selected
+// by the current mount filters.
+func (mc *MetricsCollector) storageUsage() (total, used uint64, err error) {
+	mc.mu.RLock()
+	include, exclude := mc.mountInclude, mc.mountExclude
+	mc.mu.RUnlock()
+
+	if len(include) == 0 && len(exclude) == 0 {
+		usage, err := disk.Usage(hostRoot)
+		if err != nil {
+			return 0, 0, err
+		}
+		return usage.Total, usage.Used, nil
+	}
+
+	if len(include) == 0 {
+		include = []string{"/"}
+	}
+
+	partitions, err := disk.Partitions(false)
+	if err != nil {
+		return 0, 0, fmt.Errorf("list partitions: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range partitions {
+		if seen[p.Mountpoint] || !matchMount(p.Mountpoint, include) || matchMount(p.Mountpoint, exclude) {
+			continue
+		}
+		seen[p.Mountpoint] = true
+
+		usage, err := disk.Usage(hostPath(p.Mountpoint))
+		if err != nil {
+			return 0, 0, fmt.Errorf("usage of %s: %w", p.Mountpoint, err)
+		}
+		total += usage.Total
+		used += usage.Used
+	}
+
+	return total, used, nil
+}
+
+func matchMount(mountpoint string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, mountpoint); ok {
+			return true
+		}
+	}
+	return false
+}
+
 func getLocalIP() (string, error) {
 	// Get all network interfaces
 	addrs, err := net.InterfaceAddrs()
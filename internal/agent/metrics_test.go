@@ -139,3 +139,47 @@ func TestCollectUsage(t *testing.T) {
 		t.Error("Expected non-negative used storage")
 	}
 }
+
+func TestSetMountFilters(t *testing.T) {
+	mc, err := NewMetricsCollector()
+	if err != nil {
+		t.Fatalf("Failed to create metrics collector: %v", err)
+	}
+
+	if err := mc.SetMountFilters([]string{"["}, nil); err == nil {
+		t.Error("Expected error for invalid pattern")
+	}
+
+	defaultTotal, _, err := mc.storageUsage()
+	if err != nil {
+		t.Fatalf("Failed to get storage usage: %v", err)
+	}
+
+	if err := mc.SetMountFilters([]string{"/"}, nil); err != nil {
+		t.Fatalf("Failed to set mount filters: %v", err)
+	}
+
+	total, _, err := mc.storageUsage()
+	if err != nil {
+		t.Fatalf("Failed to get storage usage: %v", err)
+	}
+
+	// "/" may not show up as a partition in every container, in which case
+	// nothing matches the include filter.
+	if total != 0 && total != defaultTotal {
+		t.Errorf("Expected %d bytes for \"/\", got %d", defaultTotal, total)
+	}
+
+	if err := mc.SetMountFilters([]string{"/"}, []string{"/"}); err != nil {
+		t.Fatalf("Failed to set mount filters: %v", err)
+	}
+
+	total, _, err = mc.storageUsage()
+	if err != nil {
+		t.Fatalf("Failed to get storage usage: %v", err)
+	}
+
+	if total != 0 {
+		t.Errorf("Expected 0 bytes with \"/\" excluded, got %d", total)
+	}
+}
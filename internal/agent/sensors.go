@@ -0,0 +1,172 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/metorial/sentinel/proto"
+)
+
+// hwmonInput matches the input files of the sensor kinds reported, e.g.
+// temp1_input or fan2_input.
+var hwmonInput = regexp.MustCompile(`^(temp|fan)(\d+)_input$`)
+
+// SensorCollector reports temperature and fan sensors exposed through the
+// Linux hwmon sysfs interface.
+type SensorCollector struct {
+	hostname string
+	root     string
+}
+
+// NewSensorCollector returns a collector reading the hwmon devices below
+// root, or the host's /sys/class/hwmon if root is empty.
+func NewSensorCollector(hostname, root string) *SensorCollector {
+	if root == "" {
+		root = hostSysPath("class", "hwmon")
+	}
+	return &SensorCollector{hostname: hostname, root: root}
+}
+
+// HwmonAvailable reports whether the host exposes any hwmon devices.
+func HwmonAvailable() bool {
+	entries, err := os.ReadDir(hostSysPath("class", "hwmon"))
+	return err == nil && len(entries) > 0
+}
+
+func (sc *SensorCollector) Name() string { return "sensors" }
+
+func (sc *SensorCollector) Collect(ctx context.Context) ([]*pb.AgentMessage, error) {
+	entries, err := os.ReadDir(sc.root)
+	if err != nil {
+		return nil, err
+	}
+
+	// hwmonN numbering isn't stable across boots; order by it only to
+	// disambiguate chips sharing a name.
+	sort.Slice(entries, func(i, j int) bool {
+		return hwmonIndex(entries[i].Name()) < hwmonIndex(entries[j].Name())
+	})
+
+	readings := &pb.SensorReadings{
+		Hostname:  sc.hostname,
+		Timestamp: time.Now().Unix(),
+	}
+	chips := make(map[string]int)
+
+	for _, entry := range entries {
+		dir := filepath.Join(sc.root, entry.Name())
+		name, err := readSysString(filepath.Join(dir, "name"))
+		if err != nil {
+			continue
+		}
+
+		chip := name
+		if n := chips[name]; n > 0 {
+			chip = fmt.Sprintf("%s-%d", name, n)
+		}
+		chips[name]++
+
+		sensors, err := readHwmonChip(dir)
+		if err != nil {
+			return nil, fmt.Errorf("read hwmon %s: %w", entry.Name(), err)
+		}
+		for _, sensor := range sensors {
+			sensor.Chip = chip
+			readings.Sensors = append(readings.Sensors, sensor)
+		}
+	}
+
+	return []*pb.AgentMessage{{
+		Payload: &pb.AgentMessage_Sensors{Sensors: readings},
+	}}, nil
+}
+
+// readHwmonChip reads the temperature and fan sensors of one hwmon device.
+// Sensors whose input can't be read (e.g. a powered down disk) are skipped.
+func readHwmonChip(dir string) ([]*pb.SensorReading, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var sensors []*pb.SensorReading
+	for _, f := range files {
+		m := hwmonInput.FindStringSubmatch(f.Name())
+		if m == nil {
+			continue
+		}
+		prefix := m[1] + m[2]
+
+		value, err := readSysFloat(filepath.Join(dir, f.Name()))
+		if err != nil {
+			continue
+		}
+
+		sensor := &pb.SensorReading{Sensor: prefix}
+		sensor.Label, _ = readSysString(filepath.Join(dir, prefix+"_label"))
+
+		switch m[1] {
+		case "temp":
+			// Temperatures are reported in millidegrees Celsius.
+			sensor.Kind = "temperature"
+			sensor.Value = value / 1000
+			if crit, err := readSysFloat(filepath.Join(dir, prefix+"_crit")); err == nil {
+				sensor.Critical = crit / 1000
+			}
+			if max, err := readSysFloat(filepath.Join(dir, prefix+"_max")); err == nil {
+				sensor.Max = max / 1000
+			}
+		case "fan":
+			sensor.Kind = "fan"
+			sensor.Value = value
+			if max, err := readSysFloat(filepath.Join(dir, prefix+"_max")); err == nil {
+				sensor.Max = max
+			}
+		}
+
+		sensors = append(sensors, sensor)
+	}
+
+	// Temperatures first, then fans, each in numeric order.
+	sort.Slice(sensors, func(i, j int) bool {
+		a, b := sensors[i].Sensor, sensors[j].Sensor
+		if ka, kb := strings.TrimRight(a, "0123456789"), strings.TrimRight(b, "0123456789"); ka != kb {
+			return ka > kb
+		}
+		return hwmonIndex(a) < hwmonIndex(b)
+	})
+	return sensors, nil
+}
+
+// hwmonIndex returns the number at the end of names like hwmon3 or temp12.
+func hwmonIndex(name string) int {
+	i := len(name)
+	for i > 0 && name[i-1] >= '0' && name[i-1] <= '9' {
+		i--
+	}
+	n, _ := strconv.Atoi(name[i:])
+	return n
+}
+
+func readSysString(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func readSysFloat(path string) (float64, error) {
+	s, err := readSysString(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(s, 64)
+}
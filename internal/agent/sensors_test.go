@@ -0,0 +1,74 @@
+package agent
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestSensorCollector(t *testing.T) {
+	root := t.TempDir()
+
+	writeCgroupFiles(t, filepath.Join(root, "hwmon0"), map[string]string{
+		"name":        "coretemp\n",
+		"temp1_input": "45000\n",
+		"temp1_label": "Package id 0\n",
+		"temp1_crit":  "100000\n",
+		"temp1_max":   "80000\n",
+		"temp2_input": "43500\n",
+	})
+	writeCgroupFiles(t, filepath.Join(root, "hwmon1"), map[string]string{
+		"name":        "nct6775\n",
+		"fan1_input":  "1200\n",
+		"temp1_input": "38000\n",
+	})
+	writeCgroupFiles(t, filepath.Join(root, "hwmon2"), map[string]string{
+		"name":        "coretemp\n",
+		"temp1_input": "50000\n",
+	})
+
+	collector := NewSensorCollector("test-host", root)
+	msgs, err := collector.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(msgs))
+	}
+
+	readings := msgs[0].GetSensors()
+	if readings == nil || readings.Hostname != "test-host" {
+		t.Fatalf("Expected sensor readings for test-host, got %v", msgs[0])
+	}
+	if len(readings.Sensors) != 5 {
+		t.Fatalf("Expected 5 sensors, got %d: %v", len(readings.Sensors), readings.Sensors)
+	}
+
+	pkg := readings.Sensors[0]
+	if pkg.Chip != "coretemp" || pkg.Sensor != "temp1" || pkg.Label != "Package id 0" || pkg.Kind != "temperature" {
+		t.Errorf("Unexpected first sensor: %v", pkg)
+	}
+	if pkg.Value != 45 || pkg.Critical != 100 || pkg.Max != 80 {
+		t.Errorf("Expected 45/100/80 degrees, got %v/%v/%v", pkg.Value, pkg.Critical, pkg.Max)
+	}
+	if s := readings.Sensors[1]; s.Sensor != "temp2" || s.Value != 43.5 || s.Critical != 0 {
+		t.Errorf("Unexpected second sensor: %v", s)
+	}
+
+	if s := readings.Sensors[2]; s.Chip != "nct6775" || s.Sensor != "temp1" {
+		t.Errorf("Expected temperatures before fans, got %v", s)
+	}
+	if s := readings.Sensors[3]; s.Sensor != "fan1" || s.Kind != "fan" || s.Value != 1200 {
+		t.Errorf("Unexpected fan sensor: %v", s)
+	}
+	if s := readings.Sensors[4]; s.Chip != "coretemp-1" || s.Value != 50 {
+		t.Errorf("Expected second coretemp chip to be suffixed, got %v", s)
+	}
+}
+
+func TestSensorCollectorMissingRoot(t *testing.T) {
+	collector := NewSensorCollector("test-host", filepath.Join(t.TempDir(), "missing"))
+	if _, err := collector.Collect(context.Background()); err == nil {
+		t.Error("Expected error for missing hwmon directory")
+	}
+}
@@ -0,0 +1,150 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/metorial/sentinel/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+// systemdProperties are the unit properties requested from systemctl show.
+var systemdProperties = []string{
+	"Id", "Description", "LoadState", "ActiveState", "SubState", "StateChangeTimestamp",
+}
+
+// systemctlTimeout bounds a systemctl invocation.
+const systemctlTimeout = 10 * time.Second
+
+// SystemdCollector reports the state of a configured set of systemd units.
+// The list is sent when the agent connects and afterwards only when a unit
+// changes state.
+type SystemdCollector struct {
+	hostname string
+	units    []string
+	// show returns the output of `systemctl show` for units.
+	show func(ctx context.Context, units []string) ([]byte, error)
+	last []*pb.UnitStatus
+}
+
+func NewSystemdCollector(hostname string, units []string) *SystemdCollector {
+	return &SystemdCollector{
+		hostname: hostname,
+		units:    units,
+		show:     systemctlShow,
+	}
+}
+
+func (sc *SystemdCollector) Name() string { return "systemd" }
+
+func (sc *SystemdCollector) Resend() { sc.last = nil }
+
+func (sc *SystemdCollector) Collect(ctx context.Context) ([]*pb.AgentMessage, error) {
+	output, err := sc.show(ctx, sc.units)
+	if err != nil {
+		return nil, err
+	}
+
+	units := parseSystemctlShow(output)
+	if sc.last != nil && unitsEqual(sc.last, units) {
+		return nil, nil
+	}
+	sc.last = units
+
+	return []*pb.AgentMessage{{
+		Payload: &pb.AgentMessage_Units{Units: &pb.UnitStatusList{
+			Hostname:  sc.hostname,
+			Timestamp: time.Now().Unix(),
+			Units:     units,
+		}},
+	}}, nil
+}
+
+func unitsEqual(a, b []*pb.UnitStatus) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !proto.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func systemctlShow(ctx context.Context, units []string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, systemctlTimeout)
+	defer cancel()
+
+	// Unix timestamps, as the formatted ones name the zone by an abbreviation
+	// that doesn't give its offset
+	args := append([]string{"show", "--no-pager", "--timestamp=unix", "--property=" + strings.Join(systemdProperties, ",")}, units...)
+	output, err := exec.CommandContext(ctx, "systemctl", args...).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("systemctl show: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("systemctl show: %w", err)
+	}
+	return output, nil
+}
+
+// parseSystemctlShow parses the output of `systemctl show` for several
+// units: blocks of Key=Value lines separated by blank lines, one per unit.
+func parseSystemctlShow(output []byte) []*pb.UnitStatus {
+	var units []*pb.UnitStatus
+	var current *pb.UnitStatus
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			current = nil
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		if current == nil {
+			current = &pb.UnitStatus{}
+			units = append(units, current)
+		}
+
+		switch key {
+		case "Id":
+			current.Name = value
+		case "Description":
+			current.Description = value
+		case "LoadState":
+			current.LoadState = value
+		case "ActiveState":
+			current.ActiveState = value
+		case "SubState":
+			current.SubState = value
+		case "StateChangeTimestamp":
+			current.Since = parseSystemdTimestamp(value)
+		}
+	}
+	return units
+}
+
+// parseSystemdTimestamp parses timestamps printed with --timestamp=unix, like
+// "@1764583200", returning 0 for empty or unparseable values.
+func parseSystemdTimestamp(value string) int64 {
+	seconds, ok := strings.CutPrefix(value, "@")
+	if !ok {
+		return 0
+	}
+	t, err := strconv.ParseInt(seconds, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return t
+}
@@ -0,0 +1,113 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+const systemctlShowFixture = `Id=nginx.service
+Description=A high performance web server and a reverse proxy server
+LoadState=loaded
+ActiveState=active
+SubState=running
+StateChangeTimestamp=@1764583200
+
+Id=postgresql.service
+Description=PostgreSQL RDBMS
+LoadState=loaded
+ActiveState=failed
+SubState=failed
+StateChangeTimestamp=@1764588600
+
+Id=missing.service
+Description=missing.service
+LoadState=not-found
+ActiveState=inactive
+SubState=dead
+StateChangeTimestamp=
+`
+
+func TestParseSystemctlShow(t *testing.T) {
+	units := parseSystemctlShow([]byte(systemctlShowFixture))
+	if len(units) != 3 {
+		t.Fatalf("Expected 3 units, got %d", len(units))
+	}
+
+	nginx := units[0]
+	if nginx.Name != "nginx.service" || nginx.LoadState != "loaded" ||
+		nginx.ActiveState != "active" || nginx.SubState != "running" {
+		t.Errorf("Unexpected unit: %v", nginx)
+	}
+	want := time.Date(2025, 12, 1, 10, 0, 0, 0, time.UTC).Unix()
+	if nginx.Since != want {
+		t.Errorf("Expected since %d, got %d", want, nginx.Since)
+	}
+
+	if units[1].ActiveState != "failed" {
+		t.Errorf("Expected postgresql.service failed, got %s", units[1].ActiveState)
+	}
+
+	if units[2].LoadState != "not-found" || units[2].Since != 0 {
+		t.Errorf("Unexpected unit: %v", units[2])
+	}
+}
+
+func TestSystemdCollectorSendsChanges(t *testing.T) {
+	output := systemctlShowFixture
+	var requested []string
+
+	collector := NewSystemdCollector("test-host", []string{"nginx.service", "postgresql.service", "missing.service"})
+	collector.show = func(ctx context.Context, units []string) ([]byte, error) {
+		requested = units
+		return []byte(output), nil
+	}
+
+	msgs, err := collector.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(msgs))
+	}
+	list := msgs[0].GetUnits()
+	if list == nil || list.Hostname != "test-host" || len(list.Units) != 3 {
+		t.Fatalf("Unexpected message: %v", msgs[0])
+	}
+	if len(requested) != 3 {
+		t.Errorf("Expected 3 units requested, got %v", requested)
+	}
+
+	msgs, err = collector.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	if len(msgs) != 0 {
+		t.Errorf("Expected no messages without changes, got %d", len(msgs))
+	}
+
+	output = strings.Replace(output, "ActiveState=failed\nSubState=failed", "ActiveState=active\nSubState=running", 1)
+	msgs, err = collector.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("Expected 1 message after a state change, got %d", len(msgs))
+	}
+	if state := msgs[0].GetUnits().Units[1].ActiveState; state != "active" {
+		t.Errorf("Expected postgresql.service active, got %s", state)
+	}
+}
+
+func TestSystemdCollectorError(t *testing.T) {
+	collector := NewSystemdCollector("test-host", []string{"nginx.service"})
+	collector.show = func(ctx context.Context, units []string) ([]byte, error) {
+		return nil, fmt.Errorf("systemctl show: System has not been booted with systemd")
+	}
+
+	if _, err := collector.Collect(context.Background()); err == nil {
+		t.Error("Expected error")
+	}
+}
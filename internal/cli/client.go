@@ -1,10 +1,12 @@
 package cli
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"time"
 )
 
@@ -26,8 +28,137 @@ func (c *Client) Health() (map[string]interface{}, error) {
 	return c.get("/api/v1/health")
 }
 
-func (c *Client) ListHosts() (map[string]interface{}, error) {
-	return c.get("/api/v1/hosts")
+// HostListOptions filter, sort and page the hosts returned by ListHosts.
+type HostListOptions struct {
+	Tags                  []string
+	Selector              string
+	Status                string
+	Outdated              bool
+	Query                 string
+	Sort                  string
+	Limit                 int
+	Cursor                string
+	IncludeDecommissioned bool
+}
+
+func (c *Client) ListHosts(opts HostListOptions) (map[string]interface{}, error) {
+	query := url.Values{}
+	for _, tag := range opts.Tags {
+		query.Add("tag", tag)
+	}
+	if opts.Selector != "" {
+		query.Set("selector", opts.Selector)
+	}
+	if opts.Status != "" {
+		query.Set("status", opts.Status)
+	}
+	if opts.Outdated {
+		query.Set("outdated", "true")
+	}
+	if opts.Query != "" {
+		query.Set("q", opts.Query)
+	}
+	if opts.Sort != "" {
+		query.Set("sort", opts.Sort)
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", fmt.Sprintf("%d", opts.Limit))
+	}
+	if opts.Cursor != "" {
+		query.Set("cursor", opts.Cursor)
+	}
+	if opts.IncludeDecommissioned {
+		query.Set("include_decommissioned", "true")
+	}
+
+	path := "/api/v1/hosts"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return c.get(path)
+}
+
+// MaintenanceWindow is the request to open a maintenance window; exactly one
+// of Hostname and Tag, and of EndsAt and Duration, must be set.
+type MaintenanceWindow struct {
+	Hostname string     `json:"hostname,omitempty"`
+	Tag      string     `json:"tag,omitempty"`
+	Reason   string     `json:"reason"`
+	Author   string     `json:"author"`
+	StartsAt *time.Time `json:"starts_at,omitempty"`
+	EndsAt   *time.Time `json:"ends_at,omitempty"`
+	Duration string     `json:"duration,omitempty"`
+}
+
+func (c *Client) ListMaintenance(all bool) (map[string]interface{}, error) {
+	path := "/api/v1/maintenance"
+	if all {
+		path += "?all=true"
+	}
+	return c.get(path)
+}
+
+func (c *Client) CreateMaintenance(window MaintenanceWindow) (map[string]interface{}, error) {
+	return c.do(http.MethodPost, "/api/v1/maintenance", window)
+}
+
+func (c *Client) DeleteMaintenance(id int64) (map[string]interface{}, error) {
+	return c.do(http.MethodDelete, fmt.Sprintf("/api/v1/maintenance/%d", id), nil)
+}
+
+func (c *Client) ListTags() (map[string]interface{}, error) {
+	return c.get("/api/v1/tags")
+}
+
+func (c *Client) AddHostTag(hostname, tag string) (map[string]interface{}, error) {
+	return c.post("/api/v1/hosts/tags", map[string]string{"hostname": hostname, "tag": tag})
+}
+
+func (c *Client) RemoveHostTag(hostname, tag string) (map[string]interface{}, error) {
+	return c.do(http.MethodDelete, "/api/v1/hosts/tags", map[string]string{"hostname": hostname, "tag": tag})
+}
+
+// DeleteTag deletes a tag. Tags that hosts still have are only deleted with
+// force, which removes them from the hosts.
+func (c *Client) DeleteTag(name string, force bool) (map[string]interface{}, error) {
+	path := "/api/v1/tags/" + url.PathEscape(name)
+	if force {
+		path += "?force=true"
+	}
+	return c.do(http.MethodDelete, path, nil)
+}
+
+// RenameTag renames a tag, merging it into newName if that tag exists.
+func (c *Client) RenameTag(name, newName string) (map[string]interface{}, error) {
+	return c.post("/api/v1/tags/"+url.PathEscape(name)+"/rename", map[string]string{"name": newName})
+}
+
+// BulkTags is a request to add and remove tags on several hosts at once;
+// exactly one of Hostnames and Selector must be set.
+type BulkTags struct {
+	Hostnames []string `json:"hostnames,omitempty"`
+	Selector  string   `json:"selector,omitempty"`
+	Add       []string `json:"add,omitempty"`
+	Remove    []string `json:"remove,omitempty"`
+}
+
+// UpdateTags applies a bulk tag operation, all hosts or none, and returns
+// what it did to each host.
+func (c *Client) UpdateTags(req BulkTags) (map[string]interface{}, error) {
+	return c.do(http.MethodPost, "/api/v1/hosts/tags/bulk", req)
+}
+
+// DeleteHost deletes hostname and everything stored about it.
+func (c *Client) DeleteHost(hostname string) (map[string]interface{}, error) {
+	return c.do(http.MethodDelete, "/api/v1/hosts/"+url.PathEscape(hostname), nil)
+}
+
+func (c *Client) DecommissionHost(hostname string) (map[string]interface{}, error) {
+	return c.do(http.MethodPost, fmt.Sprintf("/api/v1/hosts/%s/decommission", url.PathEscape(hostname)), nil)
+}
+
+func (c *Client) RecommissionHost(hostname string) (map[string]interface{}, error) {
+	return c.do(http.MethodPost, fmt.Sprintf("/api/v1/hosts/%s/recommission", url.PathEscape(hostname)), nil)
 }
 
 func (c *Client) GetHost(hostname string, limit int) (map[string]interface{}, error) {
@@ -38,14 +169,286 @@ func (c *Client) GetHost(hostname string, limit int) (map[string]interface{}, er
 	return c.get(url)
 }
 
-func (c *Client) GetStats() (map[string]interface{}, error) {
-	return c.get("/api/v1/stats")
+// ListLabels returns every label key in use with the number of hosts with
+// each of its values.
+// GetHostUsageHistory returns the usage of a host over the last usageRange,
+// averaged into about points steps, with the lowest, average and highest
+// value of each metric.
+func (c *Client) GetHostUsageHistory(hostname string, usageRange time.Duration, points int) (map[string]interface{}, error) {
+	query := url.Values{}
+	query.Set("range", usageRange.String())
+	query.Set("points", fmt.Sprintf("%d", points))
+	return c.get(fmt.Sprintf("/api/v1/hosts/%s/usage?%s", url.PathEscape(hostname), query.Encode()))
+}
+
+func (c *Client) ListLabels() (map[string]interface{}, error) {
+	return c.get("/api/v1/labels")
+}
+
+func (c *Client) GetHostLabels(hostname string) (map[string]interface{}, error) {
+	return c.get(fmt.Sprintf("/api/v1/hosts/%s/labels", url.PathEscape(hostname)))
+}
+
+func (c *Client) SetHostLabel(hostname, key, value string) (map[string]interface{}, error) {
+	path := fmt.Sprintf("/api/v1/hosts/%s/labels/%s", url.PathEscape(hostname), url.PathEscape(key))
+	return c.do(http.MethodPut, path, map[string]string{"value": value})
+}
+
+func (c *Client) RemoveHostLabel(hostname, key string) (map[string]interface{}, error) {
+	path := fmt.Sprintf("/api/v1/hosts/%s/labels/%s", url.PathEscape(hostname), url.PathEscape(key))
+	return c.do(http.MethodDelete, path, nil)
+}
+
+// StatsOptions narrow the hosts GetStats aggregates over, group them by tag
+// or label and set how far back usage is averaged.
+type StatsOptions struct {
+	Selector string
+	GroupBy  string
+	Window   time.Duration
+}
+
+func (c *Client) GetStats(opts StatsOptions) (map[string]interface{}, error) {
+	query := url.Values{}
+	if opts.Selector != "" {
+		query.Set("selector", opts.Selector)
+	}
+	if opts.GroupBy != "" {
+		query.Set("group_by", opts.GroupBy)
+	}
+	if opts.Window > 0 {
+		query.Set("window", opts.Window.String())
+	}
+
+	path := "/api/v1/stats"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return c.get(path)
+}
+
+// ExecCommand sends a command to the agent on hostname and returns its result.
+// params carries command-specific fields such as interval_seconds or diagnostic.
+func (c *Client) ExecCommand(hostname, command string, params map[string]interface{}) (map[string]interface{}, error) {
+	body := map[string]interface{}{"command": command}
+	for k, v := range params {
+		body[k] = v
+	}
+	return c.post(fmt.Sprintf("/api/v1/hosts/%s/commands", url.PathEscape(hostname)), body)
+}
+
+// GetInventory returns the latest inventory of hostname and up to history
+// earlier versions (0 uses the server default).
+func (c *Client) GetInventory(hostname string, history int) (map[string]interface{}, error) {
+	path := fmt.Sprintf("/api/v1/hosts/%s/inventory", url.PathEscape(hostname))
+	if history > 0 {
+		path = fmt.Sprintf("%s?history=%d", path, history)
+	}
+	return c.get(path)
+}
+
+// ListCgroups returns the container cgroups of hostname with their latest
+// usage; all includes cgroups that are no longer running.
+func (c *Client) ListCgroups(hostname string, all bool) (map[string]interface{}, error) {
+	path := fmt.Sprintf("/api/v1/hosts/%s/cgroups", url.PathEscape(hostname))
+	if all {
+		path += "?all=true"
+	}
+	return c.get(path)
+}
+
+// ListSensors returns the hardware sensors of hostname with their latest
+// reading.
+func (c *Client) ListSensors(hostname string) (map[string]interface{}, error) {
+	return c.get(fmt.Sprintf("/api/v1/hosts/%s/sensors", url.PathEscape(hostname)))
+}
+
+func (c *Client) ListContainers(hostname string) (map[string]interface{}, error) {
+	return c.get(fmt.Sprintf("/api/v1/hosts/%s/containers", url.PathEscape(hostname)))
+}
+
+func (c *Client) ListProfiles() (map[string]interface{}, error) {
+	return c.get("/api/v1/profiles")
+}
+
+func (c *Client) GetProfile(name string) (map[string]interface{}, error) {
+	return c.get("/api/v1/profiles/" + url.PathEscape(name))
+}
+
+// SetProfile creates or replaces the agent config profile with the given name.
+func (c *Client) SetProfile(name string, profile map[string]interface{}) (map[string]interface{}, error) {
+	return c.do(http.MethodPut, "/api/v1/profiles/"+url.PathEscape(name), profile)
+}
+
+func (c *Client) DeleteProfile(name string) (map[string]interface{}, error) {
+	return c.do(http.MethodDelete, "/api/v1/profiles/"+url.PathEscape(name), nil)
+}
+
+func (c *Client) ListTagRules() (map[string]interface{}, error) {
+	return c.get("/api/v1/tag-rules")
+}
+
+func (c *Client) GetTagRule(name string) (map[string]interface{}, error) {
+	return c.get("/api/v1/tag-rules/" + url.PathEscape(name))
+}
+
+// SetTagRule creates or replaces the tagging rule with the given name. With
+// dryRun it returns the hosts the rule would tag and untag instead.
+func (c *Client) SetTagRule(name string, rule map[string]interface{}, dryRun bool) (map[string]interface{}, error) {
+	path := "/api/v1/tag-rules/" + url.PathEscape(name)
+	if dryRun {
+		path += "?dry_run=true"
+	}
+	return c.do(http.MethodPut, path, rule)
+}
+
+func (c *Client) DeleteTagRule(name string) (map[string]interface{}, error) {
+	return c.do(http.MethodDelete, "/api/v1/tag-rules/"+url.PathEscape(name), nil)
+}
+
+func (c *Client) ListChecks() (map[string]interface{}, error) {
+	return c.get("/api/v1/checks")
+}
+
+// GetCheck returns a check and its latest result on every host running it.
+func (c *Client) GetCheck(name string) (map[string]interface{}, error) {
+	return c.get("/api/v1/checks/" + url.PathEscape(name))
+}
+
+// SetCheck creates or replaces the synthetic check with the given name.
+func (c *Client) SetCheck(name string, check map[string]interface{}) (map[string]interface{}, error) {
+	return c.do(http.MethodPut, "/api/v1/checks/"+url.PathEscape(name), check)
+}
+
+func (c *Client) DeleteCheck(name string) (map[string]interface{}, error) {
+	return c.do(http.MethodDelete, "/api/v1/checks/"+url.PathEscape(name), nil)
+}
+
+// GetCheckResults returns the most recent results of a check, optionally only
+// those of hostname.
+func (c *Client) GetCheckResults(name, hostname string, limit int) (map[string]interface{}, error) {
+	query := url.Values{}
+	if hostname != "" {
+		query.Set("host", hostname)
+	}
+	if limit > 0 {
+		query.Set("limit", fmt.Sprint(limit))
+	}
+	path := "/api/v1/checks/" + url.PathEscape(name) + "/results"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return c.get(path)
+}
+
+// ListAlerts returns the alerts in state (firing, resolved or all; empty for
+// firing).
+func (c *Client) ListAlerts(state string) (map[string]interface{}, error) {
+	path := "/api/v1/alerts"
+	if state != "" {
+		path += "?state=" + url.QueryEscape(state)
+	}
+	return c.get(path)
+}
+
+// ListCertificates returns the TLS certificates found across the fleet,
+// soonest expiry first. hostname and withinDays (when positive) narrow the
+// result to one host and to certificates expiring within that many days.
+func (c *Client) ListCertificates(hostname string, withinDays int) (map[string]interface{}, error) {
+	query := url.Values{}
+	if hostname != "" {
+		query.Set("host", hostname)
+	}
+	if withinDays > 0 {
+		query.Set("within_days", fmt.Sprint(withinDays))
+	}
+	path := "/api/v1/certificates"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return c.get(path)
+}
+
+// ListUnits returns the watched systemd units across the fleet, optionally
+// only those of hostname or in the given active state (e.g. "failed").
+func (c *Client) ListUnits(hostname, state string) (map[string]interface{}, error) {
+	query := url.Values{}
+	if hostname != "" {
+		query.Set("host", hostname)
+	}
+	if state != "" {
+		query.Set("state", state)
+	}
+	path := "/api/v1/units"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return c.get(path)
+}
+
+// GetHostUnits returns the watched units of hostname and up to limit recent
+// state transitions (0 uses the server default).
+func (c *Client) GetHostUnits(hostname string, limit int) (map[string]interface{}, error) {
+	path := fmt.Sprintf("/api/v1/hosts/%s/units", url.PathEscape(hostname))
+	if limit > 0 {
+		path = fmt.Sprintf("%s?limit=%d", path, limit)
+	}
+	return c.get(path)
+}
+
+// GetHostPresence returns the presence state of hostname with its most
+// recent transitions.
+func (c *Client) GetHostPresence(hostname string, limit int) (map[string]interface{}, error) {
+	path := fmt.Sprintf("/api/v1/hosts/%s/presence", url.PathEscape(hostname))
+	if limit > 0 {
+		path = fmt.Sprintf("%s?limit=%d", path, limit)
+	}
+	return c.get(path)
+}
+
+// GetLogSamples returns the most recent log rule samples of hostname,
+// optionally only those of rule.
+func (c *Client) GetLogSamples(hostname, rule string, limit int) (map[string]interface{}, error) {
+	query := url.Values{}
+	if rule != "" {
+		query.Set("rule", rule)
+	}
+	if limit > 0 {
+		query.Set("limit", fmt.Sprint(limit))
+	}
+	path := fmt.Sprintf("/api/v1/hosts/%s/logs", url.PathEscape(hostname))
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return c.get(path)
 }
 
 func (c *Client) get(path string) (map[string]interface{}, error) {
-	url := c.baseURL + path
+	return c.do(http.MethodGet, path, nil)
+}
+
+func (c *Client) post(path string, body interface{}) (map[string]interface{}, error) {
+	return c.do(http.MethodPost, path, body)
+}
+
+func (c *Client) do(method, path string, body interface{}) (map[string]interface{}, error) {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("encode request: %w", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
-	resp, err := c.httpClient.Get(url)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestClientHealth(t *testing.T) {
@@ -49,7 +50,7 @@ func TestClientListHosts(t *testing.T) {
 	defer server.Close()
 
 	client := NewClient(server.URL)
-	data, err := client.ListHosts()
+	data, err := client.ListHosts(HostListOptions{})
 	if err != nil {
 		t.Fatalf("ListHosts() error: %v", err)
 	}
@@ -109,7 +110,7 @@ func TestClientGetStats(t *testing.T) {
 	defer server.Close()
 
 	client := NewClient(server.URL)
-	data, err := client.GetStats()
+	data, err := client.GetStats(StatsOptions{})
 	if err != nil {
 		t.Fatalf("GetStats() error: %v", err)
 	}
@@ -120,6 +121,24 @@ func TestClientGetStats(t *testing.T) {
 	}
 }
 
+func TestClientGetStatsGrouped(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("group_by") != "env" || query.Get("window") != "15m0s" || query.Get("selector") != "web" {
+			t.Errorf("Unexpected query %s", r.URL.RawQuery)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"groups": []interface{}{}})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	if _, err := client.GetStats(StatsOptions{Selector: "web", GroupBy: "env", Window: 15 * time.Minute}); err != nil {
+		t.Fatalf("GetStats() error: %v", err)
+	}
+}
+
 func TestClientErrorHandling(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNotFound)
@@ -133,3 +152,76 @@ func TestClientErrorHandling(t *testing.T) {
 		t.Error("Expected error for 404 response")
 	}
 }
+
+func TestClientExecCommand(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("Expected POST, got %s", r.Method)
+		}
+
+		if r.URL.Path != "/api/v1/hosts/test-host/commands" {
+			t.Errorf("Expected path /api/v1/hosts/test-host/commands, got %s", r.URL.Path)
+		}
+
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode body: %v", err)
+		}
+
+		if body["command"] != "run_diagnostic" || body["diagnostic"] != "disk" {
+			t.Errorf("Unexpected request body: %v", body)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"output":  "/: ok",
+		})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	data, err := client.ExecCommand("test-host", "run_diagnostic", map[string]interface{}{"diagnostic": "disk"})
+	if err != nil {
+		t.Fatalf("ExecCommand() error: %v", err)
+	}
+
+	if data["output"] != "/: ok" {
+		t.Errorf("Expected output '/: ok', got %v", data["output"])
+	}
+}
+
+func TestClientSetProfile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Errorf("Expected PUT, got %s", r.Method)
+		}
+
+		if r.URL.Path != "/api/v1/profiles/web" {
+			t.Errorf("Expected path /api/v1/profiles/web, got %s", r.URL.Path)
+		}
+
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode body: %v", err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"name":     "web",
+			"selector": body["selector"],
+			"version":  7,
+		})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	data, err := client.SetProfile("web", map[string]interface{}{"selector": "web"})
+	if err != nil {
+		t.Fatalf("SetProfile() error: %v", err)
+	}
+
+	if data["selector"] != "web" {
+		t.Errorf("Expected selector web, got %v", data["selector"])
+	}
+}
@@ -1,51 +1,76 @@
 package cli
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
 	"text/tabwriter"
 	"time"
 )
 
-func FormatJSON(data interface{}) error {
-	encoder := json.NewEncoder(os.Stdout)
-	encoder.SetIndent("", "  ")
-	return encoder.Encode(data)
-}
-
-func FormatHostsTable(data map[string]interface{}) error {
+// FormatHostsTable lists hosts, adding their platform, uptime and latest
+// usage when wide.
+func FormatHostsTable(data map[string]interface{}, wide bool) error {
 	hosts, ok := data["hosts"].([]interface{})
 	if !ok {
 		return fmt.Errorf("invalid hosts data")
 	}
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "HOSTNAME\tIP\tSTATUS\tCPU CORES\tMEMORY\tSTORAGE\tLAST SEEN")
+	header := "HOSTNAME\tIP\tSTATUS\tCPU CORES\tMEMORY\tSTORAGE\tAGENT\tCLOCK SKEW\tLAST SEEN\tTAGS"
+	if wide {
+		header += "\tPLATFORM\tUPTIME\tCPU %\tMEMORY USED\tSTORAGE USED"
+	}
+	fmt.Fprintln(w, header)
 
 	for _, h := range hosts {
 		host := h.(map[string]interface{})
 
-		status := "offline"
-		if online, ok := host["online"].(bool); ok && online {
-			status = "online"
-		}
+		status := formatStatus(host)
 
 		cpuCores := formatNumber(host["cpu_cores"])
 		memory := formatBytes(host["total_memory_bytes"])
 		storage := formatBytes(host["total_storage_bytes"])
 		lastSeen := formatTime(host["last_seen"])
 
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s",
 			getString(host["hostname"]),
 			getString(host["ip"]),
 			status,
 			cpuCores,
 			memory,
 			storage,
+			formatAgentVersion(host),
+			formatSkew(host["clock_skew_seconds"]),
 			lastSeen,
+			orNone(joinStrings(host["tags"])),
 		)
+		if wide {
+			platform := "-"
+			if osName := getString(host["os"]); osName != "" {
+				platform = osName + "/" + getString(host["arch"])
+			}
+			cpu, memoryUsed, storageUsed := "-", "-", "-"
+			if _, ok := host["cpu_percent"]; ok {
+				cpu = formatFloat(host["cpu_percent"]) + "%"
+			}
+			if _, ok := host["used_memory_bytes"]; ok {
+				memoryUsed = formatBytes(host["used_memory_bytes"])
+			}
+			if _, ok := host["used_storage_bytes"]; ok {
+				storageUsed = formatBytes(host["used_storage_bytes"])
+			}
+			fmt.Fprintf(w, "\t%s\t%s\t%s\t%s\t%s",
+				platform,
+				formatUptime(host["uptime_seconds"]),
+				cpu,
+				memoryUsed,
+				storageUsed,
+			)
+		}
+		fmt.Fprintln(w)
 	}
 
 	return w.Flush()
@@ -59,14 +84,58 @@ func FormatHostDetailTable(data map[string]interface{}) error {
 
 	fmt.Printf("Host: %s\n", getString(host["hostname"]))
 	fmt.Printf("IP: %s\n", getString(host["ip"]))
-	fmt.Printf("Status: %s\n", formatOnline(host["online"]))
+	status := formatStatus(host)
+	if reason := getString(host["status_reason"]); reason != "" {
+		status += " (" + reason + ")"
+	}
+	fmt.Printf("Status: %s\n", status)
 	fmt.Printf("CPU Cores: %s\n", formatNumber(host["cpu_cores"]))
 	fmt.Printf("Total Memory: %s\n", formatBytes(host["total_memory_bytes"]))
 	fmt.Printf("Total Storage: %s\n", formatBytes(host["total_storage_bytes"]))
 	fmt.Printf("Uptime: %s\n", formatUptime(host["uptime_seconds"]))
+	fmt.Printf("Agent: %s\n", formatAgentVersion(host))
+	if osName := getString(host["os"]); osName != "" {
+		fmt.Printf("Platform: %s/%s\n", osName, getString(host["arch"]))
+	}
 	fmt.Printf("Last Seen: %s\n", formatTime(host["last_seen"]))
+	fmt.Printf("Clock Skew: %s\n", formatSkew(host["clock_skew_seconds"]))
+	labels, _ := data["labels"].(map[string]interface{})
+	if tags := plainTags(data["tags"], labels); len(tags) > 0 {
+		fmt.Printf("Tags: %s\n", strings.Join(tags, ", "))
+	}
+	if len(labels) > 0 {
+		fmt.Printf("Labels: %s\n", formatLabels(labels))
+	}
+	if config, ok := data["config"].(map[string]interface{}); ok {
+		fmt.Printf("Config: %s\n", formatConfigStatus(config))
+	}
 	fmt.Printf("\n")
 
+	if windows, ok := data["maintenance"].([]interface{}); ok && len(windows) > 0 {
+		fmt.Println("Maintenance:")
+		fmt.Println()
+		if err := formatMaintenanceWindows(windows); err != nil {
+			return err
+		}
+		fmt.Printf("\n")
+	}
+
+	if sensors, ok := data["sensors"].([]interface{}); ok && len(sensors) > 0 {
+		fmt.Println("Sensors:")
+		fmt.Println()
+		if err := formatSensors(sensors); err != nil {
+			return err
+		}
+		fmt.Printf("\n")
+	}
+
+	if logs, ok := data["logs"].([]interface{}); ok && len(logs) > 0 {
+		if err := formatLogSummaries(logs); err != nil {
+			return err
+		}
+		fmt.Printf("\n")
+	}
+
 	usage, ok := data["usage"].([]interface{})
 	if !ok || len(usage) == 0 {
 		fmt.Println("No usage data available")
@@ -100,14 +169,1001 @@ func FormatStatsTable(data map[string]interface{}) error {
 	fmt.Fprintf(w, "Total Hosts:\t%s\n", formatNumber(data["total_hosts"]))
 	fmt.Fprintf(w, "Online Hosts:\t%s\n", formatNumber(data["online_hosts"]))
 	fmt.Fprintf(w, "Offline Hosts:\t%s\n", formatNumber(data["offline_hosts"]))
+	if n, ok := data["maintenance_hosts"].(float64); ok && n > 0 {
+		fmt.Fprintf(w, "In Maintenance:\t%s\n", formatNumber(data["maintenance_hosts"]))
+	}
+	if n, ok := data["decommissioned_hosts"].(float64); ok && n > 0 {
+		fmt.Fprintf(w, "Decommissioned Hosts:\t%s\n", formatNumber(data["decommissioned_hosts"]))
+	}
 	fmt.Fprintf(w, "Total CPU Cores:\t%s\n", formatNumber(data["total_cpu_cores"]))
 	fmt.Fprintf(w, "Total Memory:\t%s\n", formatBytes(data["total_memory_bytes"]))
 	fmt.Fprintf(w, "Total Storage:\t%s\n", formatBytes(data["total_storage_bytes"]))
 	fmt.Fprintf(w, "Avg CPU Usage:\t%s%%\n", formatFloat(data["avg_cpu_percent"]))
+	if _, ok := data["max_cpu_percent"]; ok {
+		fmt.Fprintf(w, "Max CPU Usage:\t%s%%\n", formatFloat(data["max_cpu_percent"]))
+		fmt.Fprintf(w, "Avg Memory Usage:\t%s%%\n", formatFloat(data["avg_memory_percent"]))
+		fmt.Fprintf(w, "Max Memory Usage:\t%s%%\n", formatFloat(data["max_memory_percent"]))
+	}
+
+	return w.Flush()
+}
+
+// FormatStatsGroupsTable prints cluster statistics grouped by tag or label,
+// one group per row.
+func FormatStatsGroupsTable(data map[string]interface{}) error {
+	groups, ok := data["groups"].([]interface{})
+	if !ok {
+		return fmt.Errorf("invalid stats data")
+	}
+	if len(groups) == 0 {
+		fmt.Println("No hosts")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "GROUP\tHOSTS\tONLINE\tOFFLINE\tCPU CORES\tMEMORY\tSTORAGE\tAVG CPU\tMAX CPU\tAVG MEM\tMAX MEM")
+
+	for _, g := range groups {
+		group := g.(map[string]interface{})
+
+		name := getString(group["group"])
+		if name == "" {
+			name = "(none)"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s%%\t%s%%\t%s%%\t%s%%\n",
+			name,
+			formatNumber(group["total_hosts"]),
+			formatNumber(group["online_hosts"]),
+			formatNumber(group["offline_hosts"]),
+			formatNumber(group["total_cpu_cores"]),
+			formatBytes(group["total_memory_bytes"]),
+			formatBytes(group["total_storage_bytes"]),
+			formatFloat(group["avg_cpu_percent"]),
+			formatFloat(group["max_cpu_percent"]),
+			formatFloat(group["avg_memory_percent"]),
+			formatFloat(group["max_memory_percent"]),
+		)
+	}
+
+	return w.Flush()
+}
+
+// FormatCgroupsTable lists cgroups, with full container IDs when wide.
+func FormatCgroupsTable(data map[string]interface{}, wide bool) error {
+	cgroups, ok := data["cgroups"].([]interface{})
+	if !ok {
+		return fmt.Errorf("invalid cgroups data")
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tRUNTIME\tCONTAINER\tSTATUS\tCPU %\tMEMORY\tLIMIT\tIO READ\tIO WRITE")
+
+	for _, c := range cgroups {
+		cgroup := c.(map[string]interface{})
+
+		status := "stopped"
+		if active, ok := cgroup["active"].(bool); ok && active {
+			status = "running"
+		}
+
+		cpu, memory, limit, ioRead, ioWrite := "-", "-", "-", "-", "-"
+		if usage, ok := cgroup["usage"].(map[string]interface{}); ok {
+			cpu = formatFloat(usage["cpu_percent"])
+			memory = formatBytes(usage["memory_bytes"])
+			limit = "none"
+			if l, ok := usage["memory_limit_bytes"].(float64); ok && l > 0 {
+				limit = formatBytes(l)
+			}
+			ioRead = formatBytes(usage["io_read_bytes"])
+			ioWrite = formatBytes(usage["io_write_bytes"])
+		}
+
+		containerID := getString(cgroup["container_id"])
+		if !wide {
+			containerID = shortID(containerID)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			formatNumber(cgroup["id"]),
+			getString(cgroup["runtime"]),
+			containerID,
+			status,
+			cpu,
+			memory,
+			limit,
+			ioRead,
+			ioWrite,
+		)
+	}
+
+	return w.Flush()
+}
+
+func FormatSensorsTable(data map[string]interface{}) error {
+	sensors, ok := data["sensors"].([]interface{})
+	if !ok {
+		return fmt.Errorf("invalid sensors data")
+	}
+	return formatSensors(sensors)
+}
+
+func FormatLabelsTable(data map[string]interface{}) error {
+	labels, ok := data["labels"].([]interface{})
+	if !ok {
+		return fmt.Errorf("invalid labels data")
+	}
+	if len(labels) == 0 {
+		fmt.Println("No labels")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tHOSTS")
+
+	for _, l := range labels {
+		label := l.(map[string]interface{})
+		values, _ := label["values"].(map[string]interface{})
+		for _, value := range sortedKeys(values) {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", getString(label["key"]), value, formatNumber(values[value]))
+		}
+	}
+
+	return w.Flush()
+}
+
+func FormatHostLabelsTable(data map[string]interface{}) error {
+	labels, ok := data["labels"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("invalid labels data")
+	}
+	if len(labels) == 0 {
+		fmt.Println("No labels")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE")
+	for _, key := range sortedKeys(labels) {
+		fmt.Fprintf(w, "%s\t%s\n", key, getString(labels[key]))
+	}
+	return w.Flush()
+}
+
+func FormatTagsTable(data map[string]interface{}) error {
+	tags, ok := data["tags"].([]interface{})
+	if !ok {
+		return fmt.Errorf("invalid tags data")
+	}
+	if len(tags) == 0 {
+		fmt.Println("No tags")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TAG\tHOSTS\tCREATED")
+
+	for _, t := range tags {
+		tag := t.(map[string]interface{})
+		fmt.Fprintf(w, "%s\t%s\t%s\n",
+			getString(tag["name"]),
+			formatNumber(tag["hosts"]),
+			formatTime(tag["created_at"]),
+		)
+	}
+
+	return w.Flush()
+}
+
+// FormatTagResults prints what a bulk tag operation did to each host.
+func FormatTagResults(data map[string]interface{}) error {
+	results, ok := data["results"].([]interface{})
+	if !ok {
+		return fmt.Errorf("invalid tag results data")
+	}
+	if len(results) == 0 {
+		fmt.Println("No matching hosts")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOSTNAME\tADDED\tREMOVED\tERROR")
+
+	for _, r := range results {
+		result := r.(map[string]interface{})
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+			getString(result["hostname"]),
+			orNone(joinStrings(result["added"])),
+			orNone(joinStrings(result["removed"])),
+			orNone(getString(result["error"])),
+		)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\n%s of %s hosts changed\n", formatNumber(data["changed"]), formatNumber(data["count"]))
+	return nil
+}
+
+func FormatMaintenanceTable(data map[string]interface{}) error {
+	windows, ok := data["windows"].([]interface{})
+	if !ok {
+		return fmt.Errorf("invalid maintenance data")
+	}
+	if len(windows) == 0 {
+		fmt.Println("No maintenance windows")
+		return nil
+	}
+	return formatMaintenanceWindows(windows)
+}
+
+func formatMaintenanceWindows(windows []interface{}) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSCOPE\tSTARTS\tENDS\tSTATE\tAUTHOR\tREASON")
+
+	for _, mw := range windows {
+		window := mw.(map[string]interface{})
+
+		scope := "host " + getString(window["hostname"])
+		if tag := getString(window["tag"]); tag != "" {
+			scope = "tag " + tag
+		}
+		state := "scheduled"
+		if active, _ := window["active"].(bool); active {
+			state = "active"
+		} else if t, err := time.Parse(time.RFC3339, getString(window["ends_at"])); err == nil && !t.After(time.Now()) {
+			state = "ended"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			formatNumber(window["id"]),
+			scope,
+			formatTime(window["starts_at"]),
+			formatTime(window["ends_at"]),
+			state,
+			orNone(getString(window["author"])),
+			orNone(getString(window["reason"])),
+		)
+	}
+
+	return w.Flush()
+}
+
+func formatSensors(sensors []interface{}) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCHIP\tSENSOR\tLABEL\tVALUE\tCRITICAL\tSTATUS")
+
+	for _, s := range sensors {
+		sensor := s.(map[string]interface{})
+
+		unit := "°C"
+		if getString(sensor["kind"]) == "fan" {
+			unit = " RPM"
+		}
+		critical := "-"
+		if c, ok := sensor["critical"].(float64); ok && c > 0 {
+			critical = formatFloat(c) + unit
+		}
+		status := "ok"
+		if over, ok := sensor["over_critical"].(bool); ok && over {
+			status = "CRITICAL"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			formatNumber(sensor["id"]),
+			getString(sensor["chip"]),
+			getString(sensor["sensor"]),
+			orNone(getString(sensor["label"])),
+			formatFloat(sensor["value"])+unit,
+			critical,
+			status,
+		)
+	}
+
+	return w.Flush()
+}
+
+// FormatContainersTable lists containers, with full IDs and the errors reading
+// them when wide.
+func FormatContainersTable(data map[string]interface{}, wide bool) error {
+	containers, ok := data["containers"].([]interface{})
+	if !ok {
+		return fmt.Errorf("invalid containers data")
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CONTAINER ID\tNAME\tIMAGE\tSTATUS\tRESTARTS\tCPU %\tMEMORY\tNET RX/TX\tBLOCK R/W")
+
+	for _, c := range containers {
+		container := c.(map[string]interface{})
+
+		cpu, memory, network, block := "-", "-", "-", "-"
+		if getString(container["state"]) == "running" {
+			cpu = formatFloat(container["cpu_percent"])
+			memory = formatBytes(container["memory_bytes"])
+			if limit, ok := container["memory_limit_bytes"].(float64); ok && limit > 0 {
+				memory += " / " + formatBytes(limit)
+			}
+			network = formatBytes(container["network_rx_bytes"]) + " / " + formatBytes(container["network_tx_bytes"])
+			block = formatBytes(container["block_read_bytes"]) + " / " + formatBytes(container["block_write_bytes"])
+		}
+
+		containerID := getString(container["container_id"])
+		status := getString(container["status"])
+		if !wide {
+			containerID = shortID(containerID)
+			if getString(container["error"]) != "" {
+				status += " (error)"
+			}
+		} else if err := getString(container["error"]); err != "" {
+			status += " (error: " + err + ")"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			containerID,
+			getString(container["name"]),
+			getString(container["image"]),
+			status,
+			formatNumber(container["restart_count"]),
+			cpu,
+			memory,
+			network,
+			block,
+		)
+	}
 
 	return w.Flush()
 }
 
+func FormatProfilesTable(data map[string]interface{}) error {
+	profiles, ok := data["profiles"].([]interface{})
+	if !ok {
+		return fmt.Errorf("invalid profiles data")
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSELECTOR\tPRIORITY\tINTERVAL\tCOLLECTORS\tMOUNTS\tVERSION")
+
+	for _, p := range profiles {
+		profile := p.(map[string]interface{})
+
+		selector := getString(profile["selector"])
+		if selector == "" {
+			selector = "(all hosts)"
+		}
+
+		interval := "default"
+		if seconds, ok := profile["report_interval_seconds"].(float64); ok && seconds > 0 {
+			interval = fmt.Sprintf("%ds", int64(seconds))
+		}
+
+		collectors := joinStrings(profile["enabled_collectors"])
+		if collectors == "" {
+			collectors = "all"
+		}
+
+		mounts := joinStrings(profile["mount_include"])
+		if mounts == "" {
+			mounts = "/"
+		}
+		if exclude := joinStrings(profile["mount_exclude"]); exclude != "" {
+			mounts += " !" + exclude
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			getString(profile["name"]),
+			selector,
+			formatNumber(profile["priority"]),
+			interval,
+			collectors,
+			mounts,
+			formatNumber(profile["version"]),
+		)
+	}
+
+	return w.Flush()
+}
+
+func FormatTagRulesTable(data map[string]interface{}) error {
+	rules, ok := data["rules"].([]interface{})
+	if !ok {
+		return fmt.Errorf("invalid tagging rules data")
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTAG\tCONDITIONS")
+
+	for _, r := range rules {
+		rule := r.(map[string]interface{})
+		fmt.Fprintf(w, "%s\t%s\t%s\n",
+			getString(rule["name"]),
+			getString(rule["tag"]),
+			tagRuleConditions(rule),
+		)
+	}
+
+	return w.Flush()
+}
+
+// tagRuleConditions describes the conditions of a tagging rule, all of which
+// a host must meet.
+func tagRuleConditions(rule map[string]interface{}) string {
+	var conditions []string
+	if re := getString(rule["hostname_regex"]); re != "" {
+		conditions = append(conditions, "hostname=~"+re)
+	}
+	if cidr := getString(rule["cidr"]); cidr != "" {
+		conditions = append(conditions, "ip in "+cidr)
+	}
+	minCores, _ := rule["min_cpu_cores"].(float64)
+	maxCores, _ := rule["max_cpu_cores"].(float64)
+	switch {
+	case minCores > 0 && maxCores > 0:
+		conditions = append(conditions, fmt.Sprintf("cores %d-%d", int64(minCores), int64(maxCores)))
+	case minCores > 0:
+		conditions = append(conditions, fmt.Sprintf("cores>=%d", int64(minCores)))
+	case maxCores > 0:
+		conditions = append(conditions, fmt.Sprintf("cores<=%d", int64(maxCores)))
+	}
+	if osName := getString(rule["os"]); osName != "" {
+		conditions = append(conditions, "os="+osName)
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+// FormatTagRulePreview prints the hosts a tagging rule matches and the tag
+// changes saving it would make.
+func FormatTagRulePreview(data map[string]interface{}) error {
+	rule, _ := data["rule"].(map[string]interface{})
+	fmt.Printf("Rule %s would tag matching hosts with %s (%s)\n",
+		getString(rule["name"]), getString(rule["tag"]), tagRuleConditions(rule))
+
+	for _, section := range []struct{ title, key string }{
+		{"Matching hosts", "matches"},
+		{"Tag added to", "add"},
+		{"Tag removed from", "remove"},
+	} {
+		hosts, _ := data[section.key].([]interface{})
+		fmt.Printf("%s (%d):\n", section.title, len(hosts))
+		for _, h := range hosts {
+			fmt.Printf("  %s\n", getString(h))
+		}
+	}
+	return nil
+}
+
+func FormatChecksTable(data map[string]interface{}) error {
+	checks, ok := data["checks"].([]interface{})
+	if !ok {
+		return fmt.Errorf("invalid checks data")
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTYPE\tTARGET\tSELECTOR\tINTERVAL\tEXPECT")
+
+	for _, c := range checks {
+		check := c.(map[string]interface{})
+
+		selector := getString(check["selector"])
+		if selector == "" {
+			selector = "(all hosts)"
+		}
+
+		interval := "default"
+		if seconds, ok := check["interval_seconds"].(float64); ok && seconds > 0 {
+			interval = fmt.Sprintf("%ds", int64(seconds))
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			getString(check["name"]),
+			getString(check["type"]),
+			getString(check["target"]),
+			selector,
+			interval,
+			orNone(checkExpectation(check)),
+		)
+	}
+
+	return w.Flush()
+}
+
+// checkExpectation summarizes the type specific expectations of a check.
+func checkExpectation(check map[string]interface{}) string {
+	var parts []string
+	if status, ok := check["expected_status"].(float64); ok && status > 0 {
+		parts = append(parts, fmt.Sprintf("status=%d", int64(status)))
+	}
+	if re := getString(check["body_regex"]); re != "" {
+		parts = append(parts, fmt.Sprintf("body=~%s", re))
+	}
+	if days, ok := check["min_days_valid"].(float64); ok && days > 0 {
+		parts = append(parts, fmt.Sprintf("days>=%d", int64(days)))
+	}
+	if addr := getString(check["expected_address"]); addr != "" {
+		parts = append(parts, "address="+addr)
+	}
+	return strings.Join(parts, " ")
+}
+
+// FormatCheckDetail prints a check followed by its latest result per host.
+func FormatCheckDetail(data map[string]interface{}) error {
+	check, ok := data["check"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("invalid check data")
+	}
+
+	if err := FormatChecksTable(map[string]interface{}{
+		"checks": []interface{}{check},
+	}); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	return FormatCheckResultsTable(map[string]interface{}{
+		"results": data["status"],
+	})
+}
+
+func FormatCheckResultsTable(data map[string]interface{}) error {
+	results, _ := data["results"].([]interface{})
+	if len(results) == 0 {
+		fmt.Println("No results reported")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOSTNAME\tTIME\tRESULT\tLATENCY\tMESSAGE")
+
+	for _, r := range results {
+		result := r.(map[string]interface{})
+
+		outcome := "FAIL"
+		if success, _ := result["success"].(bool); success {
+			outcome = "ok"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s ms\t%s\n",
+			getString(result["hostname"]),
+			formatTime(result["timestamp"]),
+			outcome,
+			formatFloat(result["latency_ms"]),
+			getString(result["message"]),
+		)
+	}
+
+	return w.Flush()
+}
+
+func FormatAlertsTable(data map[string]interface{}) error {
+	alerts, ok := data["alerts"].([]interface{})
+	if !ok {
+		return fmt.Errorf("invalid alerts data")
+	}
+
+	if len(alerts) == 0 {
+		fmt.Println("No alerts")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOSTNAME\tKIND\tSUBJECT\tSTATE\tSTARTED\tRESOLVED\tMESSAGE")
+
+	for _, a := range alerts {
+		alert := a.(map[string]interface{})
+		state := getString(alert["state"])
+		if silenced, _ := alert["silenced"].(bool); silenced {
+			state += " (silenced)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			getString(alert["hostname"]),
+			getString(alert["kind"]),
+			getString(alert["subject"]),
+			state,
+			formatTime(alert["started_at"]),
+			orNone(formatTime(alert["resolved_at"])),
+			getString(alert["message"]),
+		)
+	}
+
+	return w.Flush()
+}
+
+func FormatCertificatesTable(data map[string]interface{}) error {
+	certs, ok := data["certificates"].([]interface{})
+	if !ok {
+		return fmt.Errorf("invalid certificates data")
+	}
+
+	if len(certs) == 0 {
+		fmt.Println("No certificates found")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOSTNAME\tSOURCE\tSUBJECT\tSANS\tISSUER\tNOT AFTER\tEXPIRES")
+
+	for _, c := range certs {
+		cert := c.(map[string]interface{})
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			getString(cert["hostname"]),
+			getString(cert["source"]),
+			getString(cert["subject"]),
+			orNone(joinStrings(cert["sans"])),
+			getString(cert["issuer"]),
+			formatTime(cert["not_after"]),
+			formatExpiry(cert["not_after"]),
+		)
+	}
+
+	return w.Flush()
+}
+
+// formatExpiry describes how long until an RFC 3339 time, e.g. "in 12d" or
+// "EXPIRED 3d ago".
+func formatExpiry(v interface{}) string {
+	t, err := time.Parse(time.RFC3339, getString(v))
+	if err != nil {
+		return ""
+	}
+
+	days := int(time.Until(t).Hours() / 24)
+	if time.Now().After(t) {
+		return fmt.Sprintf("EXPIRED %dd ago", -days)
+	}
+	return fmt.Sprintf("in %dd", days)
+}
+
+func FormatUnitsTable(data map[string]interface{}) error {
+	units, ok := data["units"].([]interface{})
+	if !ok {
+		return fmt.Errorf("invalid units data")
+	}
+
+	if len(units) == 0 {
+		fmt.Println("No units found")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOSTNAME\tUNIT\tLOAD\tACTIVE\tSUB\tSINCE\tDESCRIPTION")
+
+	for _, u := range units {
+		unit := u.(map[string]interface{})
+
+		active := getString(unit["active_state"])
+		if active == "failed" {
+			active = "FAILED"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			getString(unit["hostname"]),
+			getString(unit["name"]),
+			getString(unit["load_state"]),
+			active,
+			getString(unit["sub_state"]),
+			orNone(formatSince(unit["since"])),
+			getString(unit["description"]),
+		)
+	}
+
+	return w.Flush()
+}
+
+// FormatHostUnits prints the units of a host followed by their recent state
+// transitions.
+func FormatHostUnits(data map[string]interface{}) error {
+	if err := FormatUnitsTable(data); err != nil {
+		return err
+	}
+
+	transitions, _ := data["transitions"].([]interface{})
+	if len(transitions) == 0 {
+		return nil
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tUNIT\tFROM\tTO")
+
+	for _, t := range transitions {
+		transition := t.(map[string]interface{})
+		fmt.Fprintf(w, "%s\t%s\t%s (%s)\t%s (%s)\n",
+			formatTime(transition["timestamp"]),
+			getString(transition["unit"]),
+			getString(transition["from_active_state"]),
+			getString(transition["from_sub_state"]),
+			getString(transition["to_active_state"]),
+			getString(transition["to_sub_state"]),
+		)
+	}
+
+	return w.Flush()
+}
+
+func FormatHostPresence(data map[string]interface{}) error {
+	fmt.Printf("Status: %s\n", getString(data["status"]))
+	if reason := getString(data["reason"]); reason != "" {
+		fmt.Printf("Reason: %s\n", reason)
+	}
+	fmt.Printf("Since: %s\n", formatTime(data["since"]))
+	connected := "no"
+	if c, ok := data["connected"].(bool); ok && c {
+		connected = "yes"
+	}
+	fmt.Printf("Connected: %s\n", connected)
+	fmt.Printf("Expected Interval: %ss\n", formatNumber(data["expected_interval_seconds"]))
+
+	transitions, _ := data["transitions"].([]interface{})
+	if len(transitions) == 0 {
+		return nil
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tFROM\tTO\tREASON")
+
+	for _, t := range transitions {
+		transition := t.(map[string]interface{})
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+			formatTime(transition["timestamp"]),
+			getString(transition["from_status"]),
+			getString(transition["to_status"]),
+			getString(transition["reason"]),
+		)
+	}
+
+	return w.Flush()
+}
+
+// formatSince formats an RFC 3339 time, leaving the zero time empty.
+func formatSince(v interface{}) string {
+	t, err := time.Parse(time.RFC3339, getString(v))
+	if err != nil || t.IsZero() {
+		return ""
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+// formatLogSummaries prints the log rules of a host with their recent
+// matching lines.
+func formatLogSummaries(logs []interface{}) error {
+	fmt.Println("Log Rules:")
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RULE\tPATH\tLAST INTERVAL\tLAST HOUR")
+	for _, l := range logs {
+		summary := l.(map[string]interface{})
+		labels, _ := summary["labels"].(map[string]interface{})
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+			getString(summary["rule"]),
+			orNone(getString(labels["path"])),
+			formatNumber(summary["last_count"]),
+			formatNumber(summary["count_last_hour"]),
+		)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, l := range logs {
+		summary := l.(map[string]interface{})
+		lines, _ := summary["lines"].([]interface{})
+		if len(lines) == 0 {
+			continue
+		}
+		fmt.Printf("\nLast matches of %s:\n", getString(summary["rule"]))
+		for _, line := range lines {
+			entry := line.(map[string]interface{})
+			fmt.Printf("  %s  %s\n", formatTime(entry["timestamp"]), getString(entry["line"]))
+		}
+	}
+	return nil
+}
+
+func FormatLogSamplesTable(data map[string]interface{}) error {
+	samples, ok := data["samples"].([]interface{})
+	if !ok {
+		return fmt.Errorf("invalid log samples data")
+	}
+
+	if len(samples) == 0 {
+		fmt.Println("No log samples reported")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIMESTAMP\tRULE\tPATH\tINTERVAL\tCOUNT")
+
+	for _, s := range samples {
+		sample := s.(map[string]interface{})
+		labels, _ := sample["labels"].(map[string]interface{})
+		fmt.Fprintf(w, "%s\t%s\t%s\t%ss\t%s\n",
+			formatTime(sample["timestamp"]),
+			getString(sample["rule"]),
+			orNone(getString(labels["path"])),
+			formatNumber(sample["interval_seconds"]),
+			formatNumber(sample["count"]),
+		)
+	}
+
+	return w.Flush()
+}
+
+// FormatInventory prints the latest inventory of a host and, if showHistory is
+// set, the fields changed by each recorded version.
+func FormatInventory(data map[string]interface{}, showHistory bool) error {
+	record, ok := data["inventory"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("invalid inventory data")
+	}
+	inventory, _ := record["inventory"].(map[string]interface{})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "OS:\t%s %s\n", getString(inventory["os_name"]), getString(inventory["os_version"]))
+	fmt.Fprintf(w, "Kernel:\t%s\n", getString(inventory["kernel_version"]))
+	fmt.Fprintf(w, "Arch:\t%s\n", getString(inventory["arch"]))
+	fmt.Fprintf(w, "CPU Model:\t%s\n", getString(inventory["cpu_model"]))
+	fmt.Fprintf(w, "Virtualization:\t%s\n", orNone(getString(inventory["virtualization"])))
+	fmt.Fprintf(w, "Container:\t%s\n", orNone(getString(inventory["container"])))
+	fmt.Fprintf(w, "Boot Time:\t%s\n", formatTime(inventory["boot_time"]))
+	fmt.Fprintf(w, "Timezone:\t%s\n", getString(inventory["timezone"]))
+	fmt.Fprintf(w, "Version:\t%s (collected %s)\n", formatNumber(record["version"]), formatTime(record["collected_at"]))
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INTERFACE\tMAC\tADDRESSES")
+	interfaces, _ := inventory["interfaces"].([]interface{})
+	for _, i := range interfaces {
+		iface := i.(map[string]interface{})
+		fmt.Fprintf(w, "%s\t%s\t%s\n",
+			getString(iface["name"]),
+			orNone(getString(iface["mac"])),
+			joinStrings(iface["addresses"]),
+		)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if !showHistory {
+		return nil
+	}
+
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tCOLLECTED\tCHANGES")
+	history, _ := data["history"].([]interface{})
+	for _, h := range history {
+		entry := h.(map[string]interface{})
+		changes := joinStrings(entry["changes"])
+		if changes == "" {
+			changes = "(initial)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n",
+			formatNumber(entry["version"]),
+			formatTime(entry["collected_at"]),
+			changes,
+		)
+	}
+	return w.Flush()
+}
+
+func FormatCommandResult(data map[string]interface{}) error {
+	if success, ok := data["success"].(bool); !ok || !success {
+		return fmt.Errorf("command failed: %s", getString(data["error"]))
+	}
+
+	output := getString(data["output"])
+	if output == "" {
+		return nil
+	}
+
+	fmt.Print(output)
+	if output[len(output)-1] != '\n' {
+		fmt.Println()
+	}
+	return nil
+}
+
+func formatAgentVersion(host map[string]interface{}) string {
+	agentVersion := getString(host["agent_version"])
+	if agentVersion == "" {
+		agentVersion = "unknown"
+	}
+	if outdated, _ := host["outdated"].(bool); outdated {
+		agentVersion += " (outdated)"
+	}
+	return agentVersion
+}
+
+func formatConfigStatus(config map[string]interface{}) string {
+	profile := getString(config["profile"])
+	if profile == "" {
+		profile = "defaults"
+	}
+
+	status := fmt.Sprintf("%s (version %s", profile, formatNumber(config["applied_version"]))
+	if inSync, ok := config["in_sync"].(bool); ok && !inSync {
+		status += fmt.Sprintf(", pending version %s", formatNumber(config["desired_version"]))
+	}
+	status += ")"
+
+	if errMsg := getString(config["error"]); errMsg != "" {
+		if failed, ok := config["failed_version"]; ok {
+			status += fmt.Sprintf(" version %s failed: %s", formatNumber(failed), errMsg)
+		} else {
+			status += " error: " + errMsg
+		}
+	}
+	return status
+}
+
+func joinStrings(v interface{}) string {
+	items, ok := v.([]interface{})
+	if !ok {
+		return ""
+	}
+
+	parts := make([]string, 0, len(items))
+	for _, item := range items {
+		parts = append(parts, getString(item))
+	}
+	return strings.Join(parts, ",")
+}
+
+// formatLabels writes labels as key=value pairs sorted by key.
+func formatLabels(labels map[string]interface{}) string {
+	pairs := make([]string, 0, len(labels))
+	for _, key := range sortedKeys(labels) {
+		pairs = append(pairs, key+"="+getString(labels[key]))
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// plainTags returns the tags that aren't one of the labels written as a
+// key:value tag.
+func plainTags(v interface{}, labels map[string]interface{}) []string {
+	items, _ := v.([]interface{})
+	tags := make([]string, 0, len(items))
+	for _, item := range items {
+		tag := getString(item)
+		if key, value, ok := strings.Cut(tag, ":"); ok && labels[key] == value {
+			continue
+		}
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// shortID abbreviates 64 character container ids the way docker does.
+func shortID(id string) string {
+	if len(id) == 64 {
+		return id[:12]
+	}
+	return id
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
 func getString(v interface{}) string {
 	if s, ok := v.(string); ok {
 		return s
@@ -169,6 +1225,20 @@ func formatTime(v interface{}) string {
 	return ""
 }
 
+// formatSkew formats a clock skew in seconds, e.g. "+3s" for a host whose
+// clock is ahead of the controller.
+func formatSkew(v interface{}) string {
+	seconds, ok := v.(float64)
+	if !ok || seconds == 0 {
+		return "-"
+	}
+	skew := (time.Duration(seconds) * time.Second).String()
+	if seconds > 0 {
+		skew = "+" + skew
+	}
+	return skew
+}
+
 func formatUptime(v interface{}) string {
 	var seconds int64
 	switch n := v.(type) {
@@ -195,6 +1265,19 @@ func formatUptime(v interface{}) string {
 	return fmt.Sprintf("%dm", minutes)
 }
 
+// formatStatus returns the presence state of a host, falling back to its
+// online flag for controllers predating presence tracking.
+func formatStatus(host map[string]interface{}) string {
+	status := getString(host["status"])
+	if status == "" {
+		status = formatOnline(host["online"])
+	}
+	if inMaintenance, _ := host["in_maintenance"].(bool); inMaintenance {
+		status += " (maintenance)"
+	}
+	return status
+}
+
 func formatOnline(v interface{}) string {
 	if online, ok := v.(bool); ok && online {
 		return "online"
@@ -0,0 +1,256 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// Chart styles of FormatUsageGraphs
+const (
+	GraphSparkline = "spark"
+	GraphBraille   = "braille"
+)
+
+// GraphOptions set the style of the charts of FormatUsageGraphs, the width of
+// the terminal they are drawn in and the number of lines of a braille chart.
+type GraphOptions struct {
+	Style  string
+	Width  int
+	Height int
+}
+
+// brailleAxisWidth is the width of the axis left of a braille chart
+const brailleAxisWidth = 9
+
+// chartColumns is the number of columns a chart is drawn in
+func (o GraphOptions) chartColumns() int {
+	if o.Style == GraphBraille {
+		return max(o.Width-brailleAxisWidth, 10)
+	}
+	return max(o.Width, 10)
+}
+
+// Points returns the number of points to request from GetHostUsageHistory
+// for the charts to have one per column, or per dot of a braille chart.
+func (o GraphOptions) Points() int {
+	if o.Style == GraphBraille {
+		return o.chartColumns() * 2
+	}
+	return o.chartColumns()
+}
+
+// usageSeries is a usage metric over time with NaN where no usage was
+// reported. total is the value that is 100%, or 0 if unknown, and top is the
+// top of the chart: total, unless a value is higher.
+type usageSeries struct {
+	name    string
+	key     string
+	values  []float64
+	total   float64
+	top     float64
+	percent bool
+}
+
+func usageSeriesFrom(data map[string]interface{}) ([]usageSeries, error) {
+	points, ok := data["points"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid usage data")
+	}
+	from, err := time.Parse(time.RFC3339Nano, getString(data["from"]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid usage data: %w", err)
+	}
+	to, _ := time.Parse(time.RFC3339Nano, getString(data["to"]))
+	stepSeconds, _ := data["step_seconds"].(float64)
+	if stepSeconds <= 0 {
+		return nil, fmt.Errorf("invalid usage data: no step")
+	}
+	step := time.Duration(stepSeconds * float64(time.Second))
+	slots := max(int(math.Ceil(float64(to.Sub(from))/float64(step))), 1)
+
+	totalMemory, _ := data["total_memory_bytes"].(float64)
+	totalStorage, _ := data["total_storage_bytes"].(float64)
+	series := []usageSeries{
+		{name: "CPU", key: "cpu_percent", total: 100, percent: true},
+		{name: "Memory", key: "used_memory_bytes", total: totalMemory},
+		{name: "Storage", key: "used_storage_bytes", total: totalStorage},
+	}
+
+	for i := range series {
+		s := &series[i]
+		s.top = s.total
+		s.values = make([]float64, slots)
+		for j := range s.values {
+			s.values[j] = math.NaN()
+		}
+
+		for _, p := range points {
+			point := p.(map[string]interface{})
+			t, err := time.Parse(time.RFC3339Nano, getString(point["timestamp"]))
+			if err != nil {
+				continue
+			}
+			slot := min(max(int(t.Sub(from)/step), 0), slots-1)
+			if v, ok := point[s.key].(float64); ok {
+				s.values[slot] = v
+				// CPU can go above 100% on some platforms, and storage
+				// and memory above a total that has since shrunk
+				s.top = max(s.top, v)
+			}
+		}
+	}
+	return series, nil
+}
+
+// resample picks n values out of values, spread evenly.
+func resample(values []float64, n int) []float64 {
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = values[i*len(values)/n]
+	}
+	return out
+}
+
+// level scales v to a whole number between 0 and levels-1
+func level(v, top float64, levels int) int {
+	if top <= 0 {
+		return 0
+	}
+	return min(max(int(v/top*float64(levels-1)+0.5), 0), levels-1)
+}
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+func sparkline(values []float64, top float64) string {
+	var b strings.Builder
+	for _, v := range values {
+		if math.IsNaN(v) {
+			b.WriteRune(' ')
+			continue
+		}
+		b.WriteRune(sparkBlocks[level(v, top, len(sparkBlocks))])
+	}
+	return b.String()
+}
+
+// brailleDots are the bits of the dots of a braille character, by column and
+// row from the top left
+var brailleDots = [2][4]rune{
+	{0x01, 0x02, 0x04, 0x40},
+	{0x08, 0x10, 0x20, 0x80},
+}
+
+// brailleChart draws values as a line in a chart of the given number of
+// lines, with two values per column. Consecutive values are joined
+// vertically so that steep changes stay connected.
+func brailleChart(values []float64, top float64, lines int) []string {
+	columns := (len(values) + 1) / 2
+	dots := lines * 4
+	grid := make([][]rune, lines)
+	for i := range grid {
+		grid[i] = make([]rune, columns)
+	}
+
+	prev := -1
+	for x, v := range values {
+		if math.IsNaN(v) {
+			prev = -1
+			continue
+		}
+		y := dots - 1 - level(v, top, dots)
+		lo, hi := y, y
+		if prev >= 0 {
+			lo, hi = min(y, prev), max(y, prev)
+		}
+		for dy := lo; dy <= hi; dy++ {
+			grid[dy/4][x/2] |= brailleDots[x%2][dy%4]
+		}
+		prev = y
+	}
+
+	rows := make([]string, lines)
+	for i, row := range grid {
+		var b strings.Builder
+		for _, bits := range row {
+			b.WriteRune(0x2800 + bits)
+		}
+		rows[i] = b.String()
+	}
+	return rows
+}
+
+// formatUsageSummary writes the lowest, average and highest value of a
+// metric, with bytes also as a percentage of the total.
+func formatUsageSummary(s usageSeries, summary map[string]interface{}) string {
+	if summary == nil {
+		return "no data"
+	}
+
+	parts := make([]string, 0, 3)
+	for _, stat := range []string{"min", "avg", "max"} {
+		v, _ := summary[stat].(float64)
+		value := fmt.Sprintf("%.1f%%", v)
+		if !s.percent {
+			value = formatBytes(v)
+			if s.total > 0 {
+				value += fmt.Sprintf(" (%.1f%%)", v/s.total*100)
+			}
+		}
+		parts = append(parts, stat+" "+value)
+	}
+	return strings.Join(parts, "  ")
+}
+
+// FormatUsageGraphs draws the CPU, memory and storage history returned by
+// GetHostUsageHistory as charts with the lowest, average and highest value
+// of each.
+func FormatUsageGraphs(w io.Writer, data map[string]interface{}, opts GraphOptions) error {
+	series, err := usageSeriesFrom(data)
+	if err != nil {
+		return err
+	}
+	summaries, _ := data["summary"].(map[string]interface{})
+
+	from, _ := time.Parse(time.RFC3339Nano, getString(data["from"]))
+	to, _ := time.Parse(time.RFC3339Nano, getString(data["to"]))
+	stepSeconds, _ := data["step_seconds"].(float64)
+	fmt.Fprintf(w, "%s: %s to %s, %s samples, %s per point\n",
+		getString(data["hostname"]), from.Local().Format("2006-01-02 15:04"), to.Local().Format("2006-01-02 15:04"),
+		formatNumber(data["samples"]), time.Duration(stepSeconds*float64(time.Second)).Round(time.Second))
+
+	timeLayout := "15:04"
+	if to.Sub(from) > 24*time.Hour {
+		timeLayout = "01-02 15:04"
+	}
+
+	columns := opts.chartColumns()
+	for _, s := range series {
+		summary, _ := summaries[s.key].(map[string]interface{})
+		fmt.Fprintf(w, "\n%-8s %s\n", s.name, formatUsageSummary(s, summary))
+
+		if opts.Style != GraphBraille {
+			fmt.Fprintln(w, sparkline(resample(s.values, columns), s.top))
+			continue
+		}
+
+		lines := max(opts.Height, 2)
+		for i, row := range brailleChart(resample(s.values, columns*2), s.top, lines) {
+			axis := ""
+			switch {
+			case i == 0 && s.total > 0:
+				axis = fmt.Sprintf("%.0f%%", s.top/s.total*100)
+			case i == 0:
+				axis = formatBytes(s.top)
+			case i == lines-1:
+				axis = "0"
+			}
+			fmt.Fprintf(w, "%*s │%s\n", brailleAxisWidth-2, axis, row)
+		}
+		start, end := from.Local().Format(timeLayout), to.Local().Format(timeLayout)
+		fmt.Fprintf(w, "%*s %s%*s\n", brailleAxisWidth-1, "", start, columns-len(start), end)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSparkline(t *testing.T) {
+	got := sparkline([]float64{0, 50, math.NaN(), 100}, 100)
+	if got != "▁▅ █" {
+		t.Errorf("Expected %q, got %q", "▁▅ █", got)
+	}
+}
+
+func TestBrailleChart(t *testing.T) {
+	// A rise from the bottom to the top in one step is drawn connected
+	rows := brailleChart([]float64{0, 100}, 100, 2)
+	if len(rows) != 2 {
+		t.Fatalf("Expected 2 lines, got %d", len(rows))
+	}
+	if rows[0] != "⢸" || rows[1] != "⣸" {
+		t.Errorf("Unexpected chart:\n%s", strings.Join(rows, "\n"))
+	}
+}
+
+func TestFormatUsageGraphs(t *testing.T) {
+	from := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	data := map[string]interface{}{
+		"hostname":            "web-1",
+		"from":                from.Format(time.RFC3339Nano),
+		"to":                  from.Add(time.Hour).Format(time.RFC3339Nano),
+		"step_seconds":        float64(60),
+		"total_memory_bytes":  float64(1024),
+		"total_storage_bytes": float64(0),
+		"samples":             float64(2),
+		"points": []interface{}{
+			map[string]interface{}{"timestamp": from.Format(time.RFC3339Nano), "cpu_percent": float64(10), "used_memory_bytes": float64(512)},
+			map[string]interface{}{"timestamp": from.Add(30 * time.Minute).Format(time.RFC3339Nano), "cpu_percent": float64(90), "used_memory_bytes": float64(1024)},
+		},
+		"summary": map[string]interface{}{
+			"cpu_percent":       map[string]interface{}{"min": float64(10), "avg": float64(50), "max": float64(90)},
+			"used_memory_bytes": map[string]interface{}{"min": float64(512), "avg": float64(768), "max": float64(1024)},
+		},
+	}
+
+	for _, style := range []string{GraphSparkline, GraphBraille} {
+		var out bytes.Buffer
+		if err := FormatUsageGraphs(&out, data, GraphOptions{Style: style, Width: 40, Height: 4}); err != nil {
+			t.Fatalf("FormatUsageGraphs(%s) error: %v", style, err)
+		}
+		for _, want := range []string{"min 10.0%  avg 50.0%  max 90.0%", "(75.0%)", "Storage  no data"} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("Expected %s output to contain %q, got:\n%s", style, want, out.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,391 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats of nodectl. Table and wide are drawn by each command, the
+// others work on any response of the API.
+const (
+	OutputTable    = "table"
+	OutputWide     = "wide"
+	OutputJSON     = "json"
+	OutputYAML     = "yaml"
+	OutputCSV      = "csv"
+	OutputJSONPath = "jsonpath"
+	OutputTemplate = "template"
+)
+
+// Output is a format chosen with nodectl --output, such as yaml or
+// jsonpath={.hosts[*].hostname}.
+type Output struct {
+	Format   string
+	path     []pathStep
+	template *template.Template
+}
+
+// ParseOutput parses an output format: table, wide, json, yaml, csv,
+// jsonpath=EXPR or template=TEMPLATE.
+func ParseOutput(s string) (*Output, error) {
+	name, expr, hasExpr := strings.Cut(s, "=")
+	if name == "" {
+		name = OutputTable
+	}
+
+	switch name {
+	case OutputTable, OutputWide, OutputJSON, OutputYAML, OutputCSV:
+		if hasExpr {
+			return nil, fmt.Errorf("output format %s takes no expression", name)
+		}
+		return &Output{Format: name}, nil
+	case OutputJSONPath:
+		path, err := parseJSONPath(expr)
+		if err != nil {
+			return nil, err
+		}
+		return &Output{Format: name, path: path}, nil
+	case OutputTemplate:
+		if expr == "" {
+			return nil, fmt.Errorf("template output needs a template, e.g. template='{{.hostname}}'")
+		}
+		tmpl, err := template.New("output").Funcs(templateFuncs).Parse(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template: %w", err)
+		}
+		return &Output{Format: name, template: tmpl}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q, expected table, wide, json, yaml, csv, jsonpath=... or template=...", s)
+	}
+}
+
+// Structured reports whether the output is printed by Print rather than
+// drawn as a table by the command.
+func (o *Output) Structured() bool {
+	return o.Format != OutputTable && o.Format != OutputWide
+}
+
+// Wide reports whether tables should include their extra columns.
+func (o *Output) Wide() bool {
+	return o.Format == OutputWide
+}
+
+// Print writes data to standard output in a structured format. rows names the
+// list in data written as CSV rows, or an object written as a single row;
+// when empty, data itself is the row.
+func (o *Output) Print(data interface{}, rows string) error {
+	return o.Write(os.Stdout, data, rows)
+}
+
+// Write is Print to w.
+func (o *Output) Write(w io.Writer, data interface{}, rows string) error {
+	value, err := plainValue(data)
+	if err != nil {
+		return err
+	}
+
+	switch o.Format {
+	case OutputYAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(value); err != nil {
+			return err
+		}
+		return encoder.Close()
+	case OutputCSV:
+		return writeCSV(w, value, rows)
+	case OutputJSONPath:
+		results, err := evalJSONPath(o.path, value)
+		if err != nil {
+			return err
+		}
+		parts := make([]string, len(results))
+		for i, r := range results {
+			parts[i] = formatScalar(r)
+		}
+		_, err = fmt.Fprintln(w, strings.Join(parts, " "))
+		return err
+	case OutputTemplate:
+		return o.template.Execute(w, value)
+	default:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(data)
+	}
+}
+
+// plainValue converts data to maps, slices and scalars as decoded from JSON,
+// with whole numbers as int64 so that IDs and byte counts aren't written in
+// exponent notation.
+func plainValue(data interface{}) (interface{}, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return convertNumbers(value), nil
+}
+
+func convertNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = convertNumbers(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = convertNumbers(value)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return v
+}
+
+// formatScalar formats a value for CSV cells and jsonpath results, with
+// objects and lists as JSON.
+func formatScalar(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	}
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) string {
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	},
+	"bytes": formatBytes,
+	"time":  formatTime,
+	"join":  joinStrings,
+}
+
+// csvRows picks the objects to write as CSV rows out of data.
+func csvRows(data interface{}, rows string) ([]interface{}, error) {
+	if list, ok := data.([]interface{}); ok {
+		return list, nil
+	}
+	object, ok := data.(map[string]interface{})
+	if !ok || rows == "" {
+		return []interface{}{data}, nil
+	}
+
+	switch v := object[rows].(type) {
+	case []interface{}:
+		return v, nil
+	case map[string]interface{}:
+		return []interface{}{v}, nil
+	default:
+		return nil, fmt.Errorf("response has no %s to write as CSV", rows)
+	}
+}
+
+// flattenRow flattens nested objects of a CSV row into columns named by their
+// path, e.g. usage.cpu_percent. Lists of scalars are joined with commas.
+func flattenRow(prefix string, v interface{}, row map[string]string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			name := key
+			if prefix != "" {
+				name = prefix + "." + key
+			}
+			flattenRow(name, value, row)
+		}
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			switch item.(type) {
+			case map[string]interface{}, []interface{}:
+				row[prefix] = formatScalar(v)
+				return
+			}
+			parts = append(parts, formatScalar(item))
+		}
+		row[prefix] = strings.Join(parts, ",")
+	default:
+		if prefix == "" {
+			prefix = "value"
+		}
+		row[prefix] = formatScalar(v)
+	}
+}
+
+func writeCSV(w io.Writer, data interface{}, rows string) error {
+	list, err := csvRows(data, rows)
+	if err != nil {
+		return err
+	}
+
+	flattened := make([]map[string]string, len(list))
+	columnSet := make(map[string]bool)
+	for i, item := range list {
+		flattened[i] = make(map[string]string)
+		flattenRow("", item, flattened[i])
+		for column := range flattened[i] {
+			columnSet[column] = true
+		}
+	}
+	columns := make([]string, 0, len(columnSet))
+	for column := range columnSet {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+	for _, row := range flattened {
+		record := make([]string, len(columns))
+		for i, column := range columns {
+			record[i] = row[column]
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// pathStep is a step of a jsonpath expression: a field, an index into a list
+// or, when all is set, every element.
+type pathStep struct {
+	field string
+	index int
+	all   bool
+}
+
+// parseJSONPath parses the subset of JSONPath made of fields, list indexes
+// and wildcards, e.g. {.hosts[*].hostname} or .hosts[0].ip.
+func parseJSONPath(expr string) ([]pathStep, error) {
+	s := strings.TrimSpace(expr)
+	if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+		s = s[1 : len(s)-1]
+	}
+	s = strings.TrimPrefix(s, "$")
+	if s == "" {
+		return nil, fmt.Errorf("jsonpath output needs an expression, e.g. jsonpath='{.hosts[*].hostname}'")
+	}
+
+	var steps []pathStep
+	for s != "" {
+		switch {
+		case s[0] == '.':
+			s = s[1:]
+			if strings.HasPrefix(s, "*") {
+				steps = append(steps, pathStep{all: true})
+				s = s[1:]
+				continue
+			}
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid jsonpath %q: empty field name", expr)
+			}
+			steps = append(steps, pathStep{field: s[:end]})
+			s = s[end:]
+		case s[0] == '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid jsonpath %q: missing ]", expr)
+			}
+			inner := s[1:end]
+			s = s[end+1:]
+			if inner == "*" {
+				steps = append(steps, pathStep{all: true})
+				continue
+			}
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				steps = append(steps, pathStep{field: inner[1 : len(inner)-1]})
+				continue
+			}
+			index, err := strconv.Atoi(inner)
+			if err != nil {
+				return nil, fmt.Errorf("invalid jsonpath %q: bad index %q", expr, inner)
+			}
+			steps = append(steps, pathStep{index: index})
+		case len(steps) == 0:
+			// A leading field without a dot, as in hosts[0]
+			s = "." + s
+		default:
+			return nil, fmt.Errorf("invalid jsonpath %q: unexpected %q", expr, s)
+		}
+	}
+	return steps, nil
+}
+
+func evalJSONPath(steps []pathStep, data interface{}) ([]interface{}, error) {
+	current := []interface{}{data}
+	for _, step := range steps {
+		var next []interface{}
+		for _, v := range current {
+			switch {
+			case step.all:
+				switch v := v.(type) {
+				case []interface{}:
+					next = append(next, v...)
+				case map[string]interface{}:
+					for _, key := range sortedKeys(v) {
+						next = append(next, v[key])
+					}
+				}
+			case step.field != "":
+				if object, ok := v.(map[string]interface{}); ok {
+					if value, ok := object[step.field]; ok {
+						next = append(next, value)
+					}
+				}
+			default:
+				list, ok := v.([]interface{})
+				if !ok {
+					continue
+				}
+				index := step.index
+				if index < 0 {
+					index += len(list)
+				}
+				if index < 0 || index >= len(list) {
+					return nil, fmt.Errorf("jsonpath index %d out of range", step.index)
+				}
+				next = append(next, list[index])
+			}
+		}
+		current = next
+	}
+	return current, nil
+}
@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func outputTestData() map[string]interface{} {
+	return map[string]interface{}{
+		"count": float64(2),
+		"hosts": []interface{}{
+			map[string]interface{}{
+				"hostname":           "web-1",
+				"total_memory_bytes": float64(17179869184),
+				"cpu_percent":        12.5,
+				"tags":               []interface{}{"web", "env:prod"},
+				"config":             map[string]interface{}{"version": float64(3)},
+			},
+			map[string]interface{}{
+				"hostname":           "db-1",
+				"total_memory_bytes": float64(1024),
+			},
+		},
+	}
+}
+
+func writeOutput(t *testing.T, format string, data interface{}, rows string) string {
+	t.Helper()
+	output, err := ParseOutput(format)
+	if err != nil {
+		t.Fatalf("ParseOutput(%q) error: %v", format, err)
+	}
+	var out bytes.Buffer
+	if err := output.Write(&out, data, rows); err != nil {
+		t.Fatalf("Write(%q) error: %v", format, err)
+	}
+	return out.String()
+}
+
+func TestParseOutput(t *testing.T) {
+	for _, format := range []string{"", "table", "wide", "json", "yaml", "csv", "jsonpath={.hosts[*].hostname}", "template={{.count}}"} {
+		if _, err := ParseOutput(format); err != nil {
+			t.Errorf("ParseOutput(%q) error: %v", format, err)
+		}
+	}
+	for _, format := range []string{"xml", "yaml=x", "jsonpath=", "jsonpath={.hosts[x]}", "template=", "template={{.count"} {
+		if _, err := ParseOutput(format); err == nil {
+			t.Errorf("Expected ParseOutput(%q) to fail", format)
+		}
+	}
+
+	if output, _ := ParseOutput("wide"); output.Structured() || !output.Wide() {
+		t.Error("Expected wide to be drawn as a table")
+	}
+	if output, _ := ParseOutput("csv"); !output.Structured() {
+		t.Error("Expected csv to be structured")
+	}
+}
+
+func TestOutputYAML(t *testing.T) {
+	got := writeOutput(t, "yaml", outputTestData(), "")
+	for _, want := range []string{"count: 2\n", "total_memory_bytes: 17179869184\n", "cpu_percent: 12.5\n", "  - hostname: db-1\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected YAML to contain %q, got:\n%s", want, got)
+		}
+	}
+}
+
+func TestOutputCSV(t *testing.T) {
+	got := writeOutput(t, "csv", outputTestData(), "hosts")
+	want := "config.version,cpu_percent,hostname,tags,total_memory_bytes\n" +
+		"3,12.5,web-1,\"web,env:prod\",17179869184\n" +
+		",,db-1,,1024\n"
+	if got != want {
+		t.Errorf("Expected CSV:\n%s\ngot:\n%s", want, got)
+	}
+
+	// Without rows the whole response is a single row
+	if got := writeOutput(t, "csv", map[string]interface{}{"status": "ok"}, ""); got != "status\nok\n" {
+		t.Errorf("Unexpected single row CSV:\n%s", got)
+	}
+
+	output, _ := ParseOutput("csv")
+	if err := output.Write(&bytes.Buffer{}, outputTestData(), "usage"); err == nil {
+		t.Error("Expected an error for rows missing from the response")
+	}
+}
+
+func TestOutputJSONPath(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"{.hosts[*].hostname}", "web-1 db-1\n"},
+		{".hosts[0].total_memory_bytes", "17179869184\n"},
+		{"hosts[-1].hostname", "db-1\n"},
+		{"{.hosts[0].tags}", "[\"web\",\"env:prod\"]\n"},
+		{"{.hosts[0]['config'].version}", "3\n"},
+		{"{.missing}", "\n"},
+	}
+	for _, tt := range tests {
+		if got := writeOutput(t, "jsonpath="+tt.expr, outputTestData(), ""); got != tt.want {
+			t.Errorf("jsonpath %s: expected %q, got %q", tt.expr, tt.want, got)
+		}
+	}
+
+	output, _ := ParseOutput("jsonpath={.hosts[5]}")
+	if err := output.Write(&bytes.Buffer{}, outputTestData(), ""); err == nil {
+		t.Error("Expected an error for an index out of range")
+	}
+}
+
+func TestOutputTemplate(t *testing.T) {
+	got := writeOutput(t, `template={{range .hosts}}{{.hostname}} {{bytes .total_memory_bytes}} {{join .tags}}{{"\n"}}{{end}}`, outputTestData(), "")
+	if got != "web-1 16.0 GB web,env:prod\ndb-1 1.0 KB \n" {
+		t.Errorf("Unexpected template output: %q", got)
+	}
+}
@@ -0,0 +1,242 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TopView is the state of the nodectl top view: how hosts are sorted and
+// from which usage percentage on they are highlighted (0 never).
+type TopView struct {
+	Sort             string
+	Reverse          bool
+	CPUThreshold     float64
+	MemoryThreshold  float64
+	StorageThreshold float64
+	Color            bool
+}
+
+// TopSortKeys are the columns the top view can be sorted by, by the key that
+// selects them.
+var TopSortKeys = map[byte]string{
+	'n': "name",
+	's': "status",
+	'c': "cpu",
+	'm': "memory",
+	'd': "storage",
+}
+
+// ValidTopSort reports whether hosts can be sorted by column.
+func ValidTopSort(column string) bool {
+	for _, c := range TopSortKeys {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleKey updates the view for a key pressed and reports whether it
+// changed. Selecting the column hosts are already sorted by reverses the
+// order, as does r.
+func (v *TopView) HandleKey(key byte) bool {
+	if key == 'r' {
+		v.Reverse = !v.Reverse
+		return true
+	}
+	column, ok := TopSortKeys[key]
+	if !ok {
+		return false
+	}
+	if column == v.Sort {
+		v.Reverse = !v.Reverse
+	} else {
+		v.Sort = column
+		v.Reverse = false
+	}
+	return true
+}
+
+const (
+	ansiReset  = "\033[0m"
+	ansiBold   = "\033[1m"
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+	ansiInvert = "\033[7m"
+
+	topBarWidth = 10
+)
+
+// topRow is a host in the top view. Usage percentages are -1 when unknown.
+type topRow struct {
+	hostname string
+	status   string
+	online   bool
+	cpu      float64
+	memory   float64
+	storage  float64
+	tags     string
+}
+
+func topRows(data map[string]interface{}) ([]topRow, error) {
+	hosts, ok := data["hosts"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid hosts data")
+	}
+
+	rows := make([]topRow, 0, len(hosts))
+	for _, h := range hosts {
+		host := h.(map[string]interface{})
+		online, _ := host["online"].(bool)
+		row := topRow{
+			hostname: getString(host["hostname"]),
+			status:   formatStatus(host),
+			online:   online,
+			cpu:      -1,
+			memory:   percentOf(host["used_memory_bytes"], host["total_memory_bytes"]),
+			storage:  percentOf(host["used_storage_bytes"], host["total_storage_bytes"]),
+			tags:     joinStrings(host["tags"]),
+		}
+		if cpu, ok := host["cpu_percent"].(float64); ok {
+			row.cpu = cpu
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func percentOf(used, total interface{}) float64 {
+	u, ok := used.(float64)
+	t, _ := total.(float64)
+	if !ok || t <= 0 {
+		return -1
+	}
+	return u / t * 100
+}
+
+// sortRows sorts usage columns highest first and the others alphabetically,
+// or the other way around when reversed. Ties are broken by hostname.
+func (v *TopView) sortRows(rows []topRow) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		var less, equal bool
+		switch v.Sort {
+		case "cpu":
+			less, equal = a.cpu > b.cpu, a.cpu == b.cpu
+		case "memory":
+			less, equal = a.memory > b.memory, a.memory == b.memory
+		case "storage":
+			less, equal = a.storage > b.storage, a.storage == b.storage
+		case "status":
+			less, equal = a.status < b.status, a.status == b.status
+		default:
+			less, equal = a.hostname < b.hostname, true
+		}
+		if equal {
+			return a.hostname < b.hostname
+		}
+		return less != v.Reverse
+	})
+}
+
+func (v *TopView) color(code, s string) string {
+	if !v.Color || code == "" {
+		return s
+	}
+	return code + s + ansiReset
+}
+
+// usageCell draws a usage percentage as a bar, red when at or above
+// threshold.
+func (v *TopView) usageCell(percent, threshold float64) string {
+	if percent < 0 {
+		return "[" + strings.Repeat(" ", topBarWidth) + "]      -"
+	}
+
+	filled := int(percent/100*topBarWidth + 0.5)
+	if filled > topBarWidth {
+		filled = topBarWidth
+	}
+	cell := fmt.Sprintf("[%s%s] %5.1f%%", strings.Repeat("|", filled), strings.Repeat(" ", topBarWidth-filled), percent)
+	if threshold > 0 && percent >= threshold {
+		return v.color(ansiRed, cell)
+	}
+	return cell
+}
+
+func (v *TopView) alerting(row topRow) bool {
+	crosses := func(percent, threshold float64) bool { return threshold > 0 && percent >= threshold }
+	return crosses(row.cpu, v.CPUThreshold) || crosses(row.memory, v.MemoryThreshold) ||
+		crosses(row.storage, v.StorageThreshold)
+}
+
+// Render draws one frame of the top view with the hosts of a ListHosts
+// response, fitting the hosts in height lines if it is positive.
+func (v *TopView) Render(w io.Writer, data map[string]interface{}, height int) error {
+	rows, err := topRows(data)
+	if err != nil {
+		return err
+	}
+	v.sortRows(rows)
+
+	online, alerting := 0, 0
+	nameWidth, statusWidth := len("HOSTNAME"), len("STATUS")
+	for _, row := range rows {
+		if row.online {
+			online++
+		}
+		if v.alerting(row) {
+			alerting++
+		}
+		nameWidth = max(nameWidth, len(row.hostname))
+		statusWidth = max(statusWidth, len(row.status))
+	}
+
+	direction := "desc"
+	if (v.Sort == "name" || v.Sort == "status") != v.Reverse {
+		direction = "asc"
+	}
+	fmt.Fprintf(w, "nodectl top - %s - %d hosts, %d online, %d over threshold\n",
+		time.Now().Format("15:04:05"), len(rows), online, alerting)
+	fmt.Fprintf(w, "Sort: %s %s  (c)pu (m)emory (d)isk (n)ame (s)tatus (r)everse (q)uit\n\n", v.Sort, direction)
+
+	barWidth := topBarWidth + 9
+	header := fmt.Sprintf("%-*s  %-*s  %-*s  %-*s  %-*s  %s", nameWidth, "HOSTNAME", statusWidth, "STATUS",
+		barWidth, "CPU", barWidth, "MEMORY", barWidth, "STORAGE", "TAGS")
+	fmt.Fprintln(w, v.color(ansiInvert, header))
+
+	shown := rows
+	if height > 0 && len(rows) > height-5 {
+		shown = rows[:max(height-5, 0)]
+	}
+	for _, row := range shown {
+		name := fmt.Sprintf("%-*s", nameWidth, row.hostname)
+		if v.alerting(row) {
+			name = v.color(ansiBold+ansiRed, name)
+		}
+
+		status := fmt.Sprintf("%-*s", statusWidth, row.status)
+		switch {
+		case strings.Contains(row.status, "maintenance"):
+			status = v.color(ansiYellow, status)
+		case row.online:
+			status = v.color(ansiGreen, status)
+		default:
+			status = v.color(ansiRed, status)
+		}
+
+		fmt.Fprintf(w, "%s  %s  %s  %s  %s  %s\n", name, status,
+			v.usageCell(row.cpu, v.CPUThreshold),
+			v.usageCell(row.memory, v.MemoryThreshold),
+			v.usageCell(row.storage, v.StorageThreshold),
+			row.tags)
+	}
+	if len(shown) < len(rows) {
+		fmt.Fprintf(w, "... %d more hosts\n", len(rows)-len(shown))
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func topTestData() map[string]interface{} {
+	host := func(name string, online bool, cpu, usedMemory float64) interface{} {
+		h := map[string]interface{}{
+			"hostname":           name,
+			"online":             online,
+			"status":             map[bool]string{true: "online", false: "offline"}[online],
+			"total_memory_bytes": float64(100),
+			"used_memory_bytes":  usedMemory,
+		}
+		if cpu >= 0 {
+			h["cpu_percent"] = cpu
+		}
+		return h
+	}
+	return map[string]interface{}{
+		"hosts": []interface{}{
+			host("web-1", true, 20, 95),
+			host("web-2", true, 95, 10),
+			host("db-1", false, -1, 50),
+		},
+	}
+}
+
+func topHostOrder(t *testing.T, view *TopView) []string {
+	t.Helper()
+	var out bytes.Buffer
+	if err := view.Render(&out, topTestData(), 0); err != nil {
+		t.Fatalf("Render() error: %v", err)
+	}
+
+	var order []string
+	for _, line := range strings.Split(out.String(), "\n")[4:] {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			order = append(order, fields[0])
+		}
+	}
+	return order
+}
+
+func TestTopViewSort(t *testing.T) {
+	view := &TopView{Sort: "cpu"}
+	if got := strings.Join(topHostOrder(t, view), ","); got != "web-2,web-1,db-1" {
+		t.Errorf("Expected highest CPU first and unknown last, got %s", got)
+	}
+
+	view.HandleKey('m')
+	if got := strings.Join(topHostOrder(t, view), ","); got != "web-1,db-1,web-2" {
+		t.Errorf("Expected highest memory first, got %s", got)
+	}
+
+	// Selecting the same column again reverses the order
+	view.HandleKey('m')
+	if got := strings.Join(topHostOrder(t, view), ","); got != "web-2,db-1,web-1" {
+		t.Errorf("Expected lowest memory first, got %s", got)
+	}
+
+	view.HandleKey('n')
+	if got := strings.Join(topHostOrder(t, view), ","); got != "db-1,web-1,web-2" || view.Reverse {
+		t.Errorf("Expected hosts by name, got %s", got)
+	}
+
+	if view.HandleKey('x') {
+		t.Error("Expected an unknown key not to change the view")
+	}
+}
+
+func TestTopViewThresholds(t *testing.T) {
+	view := &TopView{Sort: "name", CPUThreshold: 90, MemoryThreshold: 90, Color: true}
+
+	var out bytes.Buffer
+	if err := view.Render(&out, topTestData(), 0); err != nil {
+		t.Fatalf("Render() error: %v", err)
+	}
+	if !strings.Contains(out.String(), "2 over threshold") {
+		t.Errorf("Expected two hosts over threshold, got:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), ansiBold+ansiRed+"web-1") || strings.Contains(out.String(), ansiBold+ansiRed+"db-1") {
+		t.Errorf("Expected only the hosts over threshold highlighted, got:\n%q", out.String())
+	}
+
+	// Only as many hosts as fit are shown
+	out.Reset()
+	if err := view.Render(&out, topTestData(), 7); err != nil {
+		t.Fatalf("Render() error: %v", err)
+	}
+	if !strings.Contains(out.String(), "... 1 more hosts") {
+		t.Errorf("Expected the listing to be cut short, got:\n%s", out.String())
+	}
+}
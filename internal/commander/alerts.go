@@ -0,0 +1,143 @@
+package commander
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/metorial/sentinel/internal/models"
+)
+
+// FireAlert opens an alert for the host, or refreshes the message of the
+// alert already firing for the same kind and subject. While the host is under
+// maintenance or once it is decommissioned, alerts are neither opened nor
+// refreshed. It reports whether a new alert was opened.
+func (db *DB) FireAlert(hostID int64, kind, subject, message string, at time.Time) (bool, error) {
+	var hostname, status string
+	err := db.conn.QueryRow(`SELECT hostname, status FROM hosts WHERE id = ?`, hostID).Scan(&hostname, &status)
+	if err != nil && err != sql.ErrNoRows {
+		return false, err
+	}
+	if status == models.HostDecommissioned {
+		return false, nil
+	}
+	silenced, err := db.InMaintenance(hostname, at)
+	if err != nil {
+		return false, err
+	}
+	if silenced {
+		return false, nil
+	}
+
+	result, err := db.conn.Exec(`UPDATE alerts SET message = ?, updated_at = ?
+		WHERE host_id = ? AND kind = ? AND subject = ? AND state = ?`,
+		message, at, hostID, kind, subject, models.AlertFiring)
+	if err != nil {
+		return false, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n > 0 {
+		return false, err
+	}
+
+	_, err = db.conn.Exec(`INSERT INTO alerts (host_id, kind, subject, message, state, started_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		hostID, kind, subject, message, models.AlertFiring, at, at)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ResolveAlert resolves the firing alert of the host for kind and subject,
+// if any. It reports whether an alert was resolved.
+func (db *DB) ResolveAlert(hostID int64, kind, subject string, at time.Time) (bool, error) {
+	result, err := db.conn.Exec(`UPDATE alerts SET state = ?, resolved_at = ?, updated_at = ?
+		WHERE host_id = ? AND kind = ? AND subject = ? AND state = ?`,
+		models.AlertResolved, at, at, hostID, kind, subject, models.AlertFiring)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// ResolveAlerts resolves the firing alerts of every host for kind and
+// subject, e.g. when the check they were raised for is deleted
+func (db *DB) ResolveAlerts(kind, subject string, at time.Time) error {
+	_, err := db.conn.Exec(`UPDATE alerts SET state = ?, resolved_at = ?, updated_at = ?
+		WHERE kind = ? AND subject = ? AND state = ?`,
+		models.AlertResolved, at, at, kind, subject, models.AlertFiring)
+	return err
+}
+
+// GetAlerts retrieves alerts in the given state, or all alerts if state is
+// empty, most recent first. Firing alerts of hosts under maintenance are
+// marked silenced.
+func (db *DB) GetAlerts(state string) ([]models.Alert, error) {
+	covered, err := db.GetHostsInMaintenance(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT a.id, a.host_id, h.hostname, a.kind, a.subject, a.message, a.state,
+	          a.started_at, a.updated_at, a.resolved_at
+	          FROM alerts a
+	          JOIN hosts h ON a.host_id = h.id
+	          WHERE ? = '' OR a.state = ?
+	          ORDER BY a.started_at DESC, a.id DESC`
+
+	rows, err := db.conn.Query(query, state, state)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []models.Alert
+	for rows.Next() {
+		var a models.Alert
+		var resolvedAt sql.NullTime
+		err := rows.Scan(&a.ID, &a.HostID, &a.Hostname, &a.Kind, &a.Subject, &a.Message, &a.State,
+			&a.StartedAt, &a.UpdatedAt, &resolvedAt)
+		if err != nil {
+			return nil, err
+		}
+		if resolvedAt.Valid {
+			a.ResolvedAt = &resolvedAt.Time
+		}
+		a.Silenced = a.State == models.AlertFiring && covered[a.Hostname]
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
+
+func (api *API) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	state := r.URL.Query().Get("state")
+	switch state {
+	case "":
+		state = models.AlertFiring
+	case "all":
+		state = ""
+	case models.AlertFiring, models.AlertResolved:
+	default:
+		http.Error(w, "Invalid state", http.StatusBadRequest)
+		return
+	}
+
+	alerts, err := api.db.GetAlerts(state)
+	if err != nil {
+		log.Printf("Error getting alerts: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"alerts": alerts,
+		"count":  len(alerts),
+	})
+}
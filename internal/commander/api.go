@@ -1,14 +1,26 @@
 package commander
 
 import (
+	"context"
 	"database/sql"
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/metorial/sentinel/internal/version"
+	pb "github.com/metorial/sentinel/proto"
+)
+
+const (
+	defaultCommandTimeout = 10 * time.Second
+	maxCommandTimeout     = 60 * time.Second
 )
 
 //go:embed web/static/*
@@ -29,6 +41,19 @@ func (api *API) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/v1/stats", api.handleStats)
 	mux.HandleFunc("/api/v1/health", api.handleHealth)
 	mux.HandleFunc("/api/v1/tags", api.handleTags)
+	mux.HandleFunc("/api/v1/tags/", api.handleTag)
+	mux.HandleFunc("/api/v1/labels", api.handleLabels)
+	mux.HandleFunc("/api/v1/tag-rules", api.handleTagRules)
+	mux.HandleFunc("/api/v1/tag-rules/", api.handleTagRule)
+	mux.HandleFunc("/api/v1/profiles", api.handleProfiles)
+	mux.HandleFunc("/api/v1/profiles/", api.handleProfile)
+	mux.HandleFunc("/api/v1/checks", api.handleChecks)
+	mux.HandleFunc("/api/v1/checks/", api.handleCheck)
+	mux.HandleFunc("/api/v1/alerts", api.handleAlerts)
+	mux.HandleFunc("/api/v1/maintenance", api.handleMaintenance)
+	mux.HandleFunc("/api/v1/maintenance/", api.handleMaintenanceWindow)
+	mux.HandleFunc("/api/v1/certificates", api.handleCertificates)
+	mux.HandleFunc("/api/v1/units", api.handleUnits)
 	mux.HandleFunc("/", api.handleUI)
 }
 
@@ -38,17 +63,45 @@ func (api *API) handleHosts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	hosts, err := api.db.GetAllHosts()
+	query, err := parseHostQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	all, err := api.db.GetHostsByTags(query.tags)
 	if err != nil {
 		log.Printf("Error getting hosts: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"hosts": hosts,
-		"count": len(hosts),
-	})
+	tags, err := api.db.GetTagsByHost()
+	if err != nil {
+		log.Printf("Error getting host tags: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	hosts, total, next := query.apply(all, tags)
+	for i := range hosts {
+		hosts[i].Outdated = isOutdated(&hosts[i])
+		hosts[i].Tags = tags[hosts[i].ID]
+	}
+	if err := api.markMaintenance(hosts); err != nil {
+		log.Printf("Error getting hosts in maintenance: %v", err)
+	}
+
+	response := map[string]interface{}{
+		"hosts":              hosts,
+		"count":              len(hosts),
+		"total":              total,
+		"controller_version": version.Version,
+	}
+	if next != "" {
+		response["next_cursor"] = next
+	}
+	respondJSON(w, http.StatusOK, response)
 }
 
 func (api *API) handleHostOrTags(w http.ResponseWriter, r *http.Request) {
@@ -58,8 +111,45 @@ func (api *API) handleHostOrTags(w http.ResponseWriter, r *http.Request) {
 		api.handleHostTags(w, r)
 		return
 	}
+	if path == "tags/bulk" {
+		api.handleBulkHostTags(w, r)
+		return
+	}
 
-	api.handleHost(w, r)
+	hostname, subresource, _ := strings.Cut(path, "/")
+	resource, resourceID, _ := strings.Cut(subresource, "/")
+	switch {
+	case subresource == "" && r.Method == http.MethodDelete:
+		api.handleDeleteHost(w, r, hostname)
+	case subresource == "":
+		api.handleHost(w, r)
+	case subresource == "decommission":
+		api.handleHostDecommission(w, r, hostname)
+	case subresource == "recommission":
+		api.handleHostRecommission(w, r, hostname)
+	case subresource == "commands":
+		api.handleHostCommands(w, r, hostname)
+	case subresource == "inventory":
+		api.handleHostInventory(w, r, hostname)
+	case subresource == "containers":
+		api.handleHostContainers(w, r, hostname)
+	case subresource == "units":
+		api.handleHostUnits(w, r, hostname)
+	case subresource == "logs":
+		api.handleHostLogs(w, r, hostname)
+	case subresource == "presence":
+		api.handleHostPresence(w, r, hostname)
+	case subresource == "usage":
+		api.handleHostUsage(w, r, hostname)
+	case resource == "labels":
+		api.handleHostLabels(w, r, hostname, resourceID)
+	case resource == "cgroups":
+		api.handleHostCgroups(w, r, hostname, resourceID)
+	case resource == "sensors":
+		api.handleHostSensors(w, r, hostname, resourceID)
+	default:
+		http.NotFound(w, r)
+	}
 }
 
 func (api *API) handleHost(w http.ResponseWriter, r *http.Request) {
@@ -84,6 +174,7 @@ func (api *API) handleHost(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	host.Outdated = isOutdated(host)
 
 	limit := 100
 	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
@@ -104,27 +195,133 @@ func (api *API) handleHost(w http.ResponseWriter, r *http.Request) {
 		log.Printf("Error getting tags for %s: %v", hostname, err)
 	}
 
+	labels, err := api.db.GetHostLabels(hostname)
+	if err != nil {
+		log.Printf("Error getting labels for %s: %v", hostname, err)
+	}
+
+	config := map[string]interface{}{
+		"applied_version": host.ConfigVersion,
+		"error":           host.ConfigError,
+	}
+	if host.ConfigError != "" {
+		config["failed_version"] = host.ConfigFailedVersion
+	}
+	if profile, err := api.db.ResolveProfile(hostname); err != nil {
+		log.Printf("Error resolving profile for %s: %v", hostname, err)
+	} else {
+		var desired int64
+		if profile != nil {
+			config["profile"] = profile.Name
+			desired = profile.Version
+		}
+		config["desired_version"] = desired
+		config["in_sync"] = desired == host.ConfigVersion
+	}
+
+	logs, err := api.db.GetLogSummaries(hostname)
+	if err != nil {
+		log.Printf("Error getting log summaries for %s: %v", hostname, err)
+	}
+
+	sensors, err := api.db.GetHostSensors(hostname)
+	if err != nil {
+		log.Printf("Error getting sensors for %s: %v", hostname, err)
+	}
+
+	now := time.Now()
+	maintenance, err := api.db.GetHostMaintenanceWindows(hostname, now)
+	if err != nil {
+		log.Printf("Error getting maintenance windows for %s: %v", hostname, err)
+	}
+	for _, mw := range maintenance {
+		host.InMaintenance = host.InMaintenance || mw.Active
+	}
+
 	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"host":  host,
-		"usage": usage,
-		"tags":  tags,
+		"host":        host,
+		"usage":       usage,
+		"tags":        tags,
+		"labels":      labels,
+		"config":      config,
+		"logs":        logs,
+		"sensors":     sensors,
+		"maintenance": maintenance,
 	})
 }
 
-func (api *API) handleStats(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+func (api *API) handleHostCommands(w http.ResponseWriter, r *http.Request, hostname string) {
+	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	stats, err := api.db.GetClusterStats()
+	var req struct {
+		Command         string `json:"command"`
+		IntervalSeconds int32  `json:"interval_seconds"`
+		Diagnostic      string `json:"diagnostic"`
+		TimeoutSeconds  int    `json:"timeout_seconds"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	cmd := &pb.Command{}
+	switch req.Command {
+	case "collect_now":
+		cmd.Action = &pb.Command_CollectNow{CollectNow: &pb.CollectNow{}}
+	case "set_interval":
+		if req.IntervalSeconds <= 0 {
+			http.Error(w, "interval_seconds must be positive", http.StatusBadRequest)
+			return
+		}
+		cmd.Action = &pb.Command_SetInterval{SetInterval: &pb.SetInterval{IntervalSeconds: req.IntervalSeconds}}
+	case "run_diagnostic":
+		if req.Diagnostic == "" {
+			http.Error(w, "diagnostic is required", http.StatusBadRequest)
+			return
+		}
+		cmd.Action = &pb.Command_RunDiagnostic{RunDiagnostic: &pb.RunDiagnostic{Name: req.Diagnostic}}
+	default:
+		http.Error(w, fmt.Sprintf("Unknown command %q", req.Command), http.StatusBadRequest)
+		return
+	}
+
+	timeout := defaultCommandTimeout
+	if req.TimeoutSeconds > 0 {
+		timeout = min(time.Duration(req.TimeoutSeconds)*time.Second, maxCommandTimeout)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	result, err := api.server.SendCommand(ctx, hostname, cmd)
+	if errors.Is(err, ErrHostNotConnected) {
+		http.Error(w, "Host not connected", http.StatusConflict)
+		return
+	}
+	if errors.Is(err, ErrCommandsUnsupported) {
+		http.Error(w, "Agent does not support commands; upgrade the agent", http.StatusConflict)
+		return
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		http.Error(w, "Timed out waiting for agent response", http.StatusGatewayTimeout)
+		return
+	}
 	if err != nil {
-		log.Printf("Error getting cluster stats: %v", err)
+		log.Printf("Error sending command to %s: %v", hostname, err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, stats)
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"id":      result.Id,
+		"success": result.Success,
+		"output":  result.Output,
+		"error":   result.Error,
+	})
 }
 
 func (api *API) handleHealth(w http.ResponseWriter, r *http.Request) {
@@ -141,6 +338,7 @@ func (api *API) handleHealth(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"status":   "healthy",
 		"database": "connected",
+		"version":  version.Version,
 	})
 }
 
@@ -194,6 +392,8 @@ func (api *API) handleHostTags(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Failed to add tag", http.StatusInternalServerError)
 			return
 		}
+		go api.server.PushConfig(req.Hostname)
+		go api.server.PushChecks(req.Hostname)
 		respondJSON(w, http.StatusOK, map[string]string{
 			"message": "Tag added successfully",
 		})
@@ -204,6 +404,8 @@ func (api *API) handleHostTags(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Failed to remove tag", http.StatusInternalServerError)
 			return
 		}
+		go api.server.PushConfig(req.Hostname)
+		go api.server.PushChecks(req.Hostname)
 		respondJSON(w, http.StatusOK, map[string]string{
 			"message": "Tag removed successfully",
 		})
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -266,7 +267,7 @@ func TestMethodNotAllowed(t *testing.T) {
 		path   string
 	}{
 		{http.MethodPost, "/api/v1/hosts"},
-		{http.MethodDelete, "/api/v1/hosts/test"},
+		{http.MethodPut, "/api/v1/hosts/test"},
 		{http.MethodPut, "/api/v1/stats"},
 		{http.MethodPost, "/api/v1/health"},
 	}
@@ -284,3 +285,40 @@ func TestMethodNotAllowed(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleHostCommands(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	server := NewServer(db)
+	api := NewAPI(db, server)
+	mux := http.NewServeMux()
+	api.RegisterRoutes(mux)
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+	}{
+		{"wrong method", http.MethodGet, "", http.StatusMethodNotAllowed},
+		{"invalid body", http.MethodPost, "not json", http.StatusBadRequest},
+		{"unknown command", http.MethodPost, `{"command":"reboot"}`, http.StatusBadRequest},
+		{"missing interval", http.MethodPost, `{"command":"set_interval"}`, http.StatusBadRequest},
+		{"missing diagnostic", http.MethodPost, `{"command":"run_diagnostic"}`, http.StatusBadRequest},
+		{"host not connected", http.MethodPost, `{"command":"collect_now"}`, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/v1/hosts/test-host/commands", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+
+			mux.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package commander
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/metorial/sentinel/internal/models"
+)
+
+// SelectHostnames retrieves the hostnames of the hosts matching selector, by
+// hostname
+func (db *DB) SelectHostnames(selector *Selector) ([]string, error) {
+	tags, err := db.GetTagsByHost()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.conn.Query(`SELECT id, hostname FROM hosts ORDER BY hostname`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hostnames := []string{}
+	for rows.Next() {
+		var id int64
+		var hostname string
+		if err := rows.Scan(&id, &hostname); err != nil {
+			return nil, err
+		}
+		if selector.Matches(tags[id]) {
+			hostnames = append(hostnames, hostname)
+		}
+	}
+	return hostnames, rows.Err()
+}
+
+// BulkUpdateHostTags adds and removes tags on each of the hosts in a single
+// transaction, so either every host is updated or none is. Removals are done
+// before additions. Hosts that don't exist are reported in their result and
+// skipped.
+func (db *DB) BulkUpdateHostTags(hostnames, add, remove []string) ([]models.HostTagResult, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	tagIDs := make(map[string]int64)
+	for _, tag := range add {
+		if _, _, ok := splitLabelTag(tag); ok {
+			continue
+		}
+		if _, err := tx.Exec(`INSERT INTO tags (name) VALUES (?) ON CONFLICT (name) DO NOTHING`, tag); err != nil {
+			return nil, fmt.Errorf("create tag %s: %w", tag, err)
+		}
+		var id int64
+		if err := tx.QueryRow(`SELECT id FROM tags WHERE name = ?`, tag).Scan(&id); err != nil {
+			return nil, err
+		}
+		tagIDs[tag] = id
+	}
+
+	results := make([]models.HostTagResult, 0, len(hostnames))
+	for _, hostname := range hostnames {
+		result := models.HostTagResult{Hostname: hostname, Added: []string{}, Removed: []string{}}
+
+		var hostID int64
+		err := tx.QueryRow(`SELECT id FROM hosts WHERE hostname = ?`, hostname).Scan(&hostID)
+		if err == sql.ErrNoRows {
+			result.Error = "host not found"
+			results = append(results, result)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tag := range remove {
+			removed, err := removeHostTagTx(tx, hostID, tag)
+			if err != nil {
+				return nil, fmt.Errorf("remove tag %s from %s: %w", tag, hostname, err)
+			}
+			if removed {
+				result.Removed = append(result.Removed, tag)
+			}
+		}
+		for _, tag := range add {
+			added, err := addHostTagTx(tx, hostID, tag, tagIDs[tag])
+			if err != nil {
+				return nil, fmt.Errorf("add tag %s to %s: %w", tag, hostname, err)
+			}
+			if added {
+				result.Added = append(result.Added, tag)
+			}
+		}
+		results = append(results, result)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// addHostTagTx adds a tag to a host the way AddHostTag does, reporting
+// whether the host didn't have it yet. tagID is the id of a plain tag.
+func addHostTagTx(tx *sql.Tx, hostID int64, tag string, tagID int64) (bool, error) {
+	if key, value, ok := splitLabelTag(tag); ok {
+		result, err := tx.Exec(`INSERT INTO host_labels (host_id, key, value, updated_at)
+		                        VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		                        ON CONFLICT (host_id, key) DO UPDATE SET
+		                          value = excluded.value,
+		                          updated_at = excluded.updated_at
+		                        WHERE host_labels.value != excluded.value`, hostID, key, value)
+		return affected(result, err)
+	}
+
+	result, err := tx.Exec(`INSERT OR IGNORE INTO host_tags (host_id, tag_id) VALUES (?, ?)`, hostID, tagID)
+	return affected(result, err)
+}
+
+// removeHostTagTx removes a tag from a host the way RemoveHostTag does,
+// reporting whether the host had it.
+func removeHostTagTx(tx *sql.Tx, hostID int64, tag string) (bool, error) {
+	removed := false
+	if key, value, ok := splitLabelTag(tag); ok {
+		result, err := tx.Exec(`DELETE FROM host_labels WHERE host_id = ? AND key = ? AND value = ?`,
+			hostID, key, value)
+		if removed, err = affected(result, err); err != nil {
+			return false, err
+		}
+	}
+
+	result, err := tx.Exec(`DELETE FROM host_tags WHERE host_id = ?
+	                        AND tag_id = (SELECT id FROM tags WHERE name = ?)`, hostID, tag)
+	tagRemoved, err := affected(result, err)
+	return removed || tagRemoved, err
+}
+
+func affected(result sql.Result, err error) (bool, error) {
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// bulkTagRequest is the body of a bulk tag operation. The hosts are given
+// either by hostname or by selector.
+type bulkTagRequest struct {
+	Hostnames []string `json:"hostnames"`
+	Selector  string   `json:"selector"`
+	Add       []string `json:"add"`
+	Remove    []string `json:"remove"`
+}
+
+func (req *bulkTagRequest) validate() error {
+	if (len(req.Hostnames) == 0) == (req.Selector == "") {
+		return fmt.Errorf("exactly one of hostnames or selector is required")
+	}
+	if len(req.Add) == 0 && len(req.Remove) == 0 {
+		return fmt.Errorf("at least one tag to add or remove is required")
+	}
+
+	adding := make(map[string]bool, len(req.Add))
+	for _, tag := range req.Add {
+		if strings.TrimSpace(tag) == "" {
+			return fmt.Errorf("tags must not be empty")
+		}
+		adding[tag] = true
+	}
+	for _, tag := range req.Remove {
+		if strings.TrimSpace(tag) == "" {
+			return fmt.Errorf("tags must not be empty")
+		}
+		if adding[tag] {
+			return fmt.Errorf("tag %s is both added and removed", tag)
+		}
+	}
+	return nil
+}
+
+func (api *API) handleBulkHostTags(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req bulkTagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hostnames := req.Hostnames
+	if req.Selector != "" {
+		selector, err := ParseSelector(req.Selector)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if hostnames, err = api.db.SelectHostnames(selector); err != nil {
+			log.Printf("Error selecting hosts: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	results, err := api.db.BulkUpdateHostTags(hostnames, req.Add, req.Remove)
+	if err != nil {
+		log.Printf("Error updating tags in bulk: %v", err)
+		http.Error(w, "Failed to update tags", http.StatusInternalServerError)
+		return
+	}
+
+	changed := 0
+	for _, result := range results {
+		if len(result.Added) > 0 || len(result.Removed) > 0 {
+			changed++
+			go api.server.PushConfig(result.Hostname)
+			go api.server.PushChecks(result.Hostname)
+		}
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"results": results,
+		"count":   len(results),
+		"changed": changed,
+	})
+}
@@ -0,0 +1,128 @@
+package commander
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/metorial/sentinel/internal/models"
+)
+
+func TestBulkUpdateHostTags(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	createTestHost(t, db, "web-1")
+	createTestHost(t, db, "web-2")
+
+	if err := db.AddHostTag("web-1", "old"); err != nil {
+		t.Fatalf("Failed to add tag: %v", err)
+	}
+	if err := db.AddHostTag("web-2", "web"); err != nil {
+		t.Fatalf("Failed to add tag: %v", err)
+	}
+
+	results, err := db.BulkUpdateHostTags([]string{"web-1", "web-2", "nope"},
+		[]string{"web", "env:prod"}, []string{"old"})
+	if err != nil {
+		t.Fatalf("Failed to update tags: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %+v", results)
+	}
+	if r := results[0]; len(r.Added) != 2 || len(r.Removed) != 1 || r.Error != "" {
+		t.Errorf("Unexpected result for web-1: %+v", r)
+	}
+	// web-2 already had web and never had old
+	if r := results[1]; len(r.Added) != 1 || r.Added[0] != "env:prod" || len(r.Removed) != 0 {
+		t.Errorf("Unexpected result for web-2: %+v", r)
+	}
+	if r := results[2]; r.Error == "" {
+		t.Errorf("Expected an error for an unknown host, got %+v", r)
+	}
+
+	tags, err := db.GetHostTags("web-1")
+	if err != nil {
+		t.Fatalf("Failed to get tags: %v", err)
+	}
+	if len(tags) != 2 || tags[0] != "env:prod" || tags[1] != "web" {
+		t.Errorf("Unexpected tags of web-1: %v", tags)
+	}
+
+	// Setting a label to the value it has changes nothing
+	results, err = db.BulkUpdateHostTags([]string{"web-1"}, []string{"env:prod"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to update tags: %v", err)
+	}
+	if len(results[0].Added) != 0 {
+		t.Errorf("Expected no changes, got %+v", results[0])
+	}
+}
+
+func TestHandleBulkHostTags(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	server := NewServer(db)
+	api := NewAPI(db, server)
+	mux := http.NewServeMux()
+	api.RegisterRoutes(mux)
+
+	createTestHost(t, db, "web-1")
+	createTestHost(t, db, "web-2")
+	createTestHost(t, db, "db-1")
+	for _, hostname := range []string{"web-1", "web-2"} {
+		if err := db.AddHostTag(hostname, "web"); err != nil {
+			t.Fatalf("Failed to add tag: %v", err)
+		}
+	}
+
+	do := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/hosts/tags/bulk", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, body := range []string{
+		`{"add": ["x"]}`,
+		`{"hostnames": ["web-1"], "selector": "web", "add": ["x"]}`,
+		`{"hostnames": ["web-1"]}`,
+		`{"hostnames": ["web-1"], "add": ["x"], "remove": ["x"]}`,
+		`{"selector": "web AND", "add": ["x"]}`,
+	} {
+		if w := do(body); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", body, w.Code)
+		}
+	}
+
+	w := do(`{"selector": "web", "add": ["frontend"]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		Results []models.HostTagResult `json:"results"`
+		Count   int                    `json:"count"`
+		Changed int                    `json:"changed"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Count != 2 || response.Changed != 2 || response.Results[0].Hostname != "web-1" {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+	if tags, _ := db.GetHostTags("db-1"); len(tags) != 0 {
+		t.Errorf("Expected db-1 to be left alone, got %v", tags)
+	}
+
+	w = do(`{"hostnames": ["web-2", "db-1"], "remove": ["frontend"]}`)
+	response.Results = nil
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Count != 2 || response.Changed != 1 || len(response.Results[0].Removed) != 1 {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+}
@@ -0,0 +1,139 @@
+package commander
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/metorial/sentinel/internal/models"
+	pb "github.com/metorial/sentinel/proto"
+)
+
+// ReplaceCertificates replaces the stored certificates of a host with the
+// latest ones reported by its agent
+func (db *DB) ReplaceCertificates(hostname string, updatedAt time.Time, certs []models.Certificate) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var hostID int64
+	if err := tx.QueryRow(`SELECT id FROM hosts WHERE hostname = ?`, hostname).Scan(&hostID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM host_certificates WHERE host_id = ?`, hostID); err != nil {
+		return err
+	}
+
+	for _, c := range certs {
+		sans, err := json.Marshal(nonNil(c.SANs))
+		if err != nil {
+			return err
+		}
+		// The same certificate may appear twice in a file; keep one.
+		_, err = tx.Exec(`INSERT OR IGNORE INTO host_certificates (host_id, source, fingerprint,
+			subject, issuer, sans, not_before, not_after, serial, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			hostID, c.Source, c.Fingerprint, c.Subject, c.Issuer, string(sans),
+			c.NotBefore, c.NotAfter, c.Serial, updatedAt)
+		if err != nil {
+			return fmt.Errorf("insert certificate %s: %w", c.Source, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetCertificates retrieves the certificates of every host, or only those of
+// hostname if set, soonest expiry first. A non-zero expiresBefore limits the
+// result to certificates expiring before then.
+func (db *DB) GetCertificates(hostname string, expiresBefore time.Time) ([]models.Certificate, error) {
+	query := `SELECT c.host_id, h.hostname, c.source, c.subject, c.issuer, c.sans,
+	          c.not_before, c.not_after, c.serial, c.fingerprint, c.updated_at
+	          FROM host_certificates c
+	          JOIN hosts h ON c.host_id = h.id
+	          WHERE (? = '' OR h.hostname = ?)`
+	args := []interface{}{hostname, hostname}
+	if !expiresBefore.IsZero() {
+		query += ` AND c.not_after < ?`
+		args = append(args, expiresBefore)
+	}
+	query += ` ORDER BY c.not_after, h.hostname, c.source`
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var certs []models.Certificate
+	for rows.Next() {
+		var c models.Certificate
+		var sans string
+		err := rows.Scan(&c.HostID, &c.Hostname, &c.Source, &c.Subject, &c.Issuer, &sans,
+			&c.NotBefore, &c.NotAfter, &c.Serial, &c.Fingerprint, &c.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(sans), &c.SANs); err != nil {
+			return nil, fmt.Errorf("decode sans of %s: %w", c.Source, err)
+		}
+		certs = append(certs, c)
+	}
+	return certs, rows.Err()
+}
+
+func (s *Server) handleCertificates(hostname string, list *pb.CertificateList) error {
+	certs := make([]models.Certificate, 0, len(list.Certificates))
+	for _, c := range list.Certificates {
+		certs = append(certs, models.Certificate{
+			Source:      c.Source,
+			Subject:     c.Subject,
+			Issuer:      c.Issuer,
+			SANs:        c.Sans,
+			NotBefore:   time.Unix(c.NotBefore, 0),
+			NotAfter:    time.Unix(c.NotAfter, 0),
+			Serial:      c.Serial,
+			Fingerprint: c.Fingerprint,
+		})
+	}
+
+	if err := s.db.ReplaceCertificates(hostname, time.Unix(list.Timestamp, 0), certs); err != nil {
+		return fmt.Errorf("replace certificates: %w", err)
+	}
+	return nil
+}
+
+func (api *API) handleCertificates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var expiresBefore time.Time
+	if daysStr := r.URL.Query().Get("within_days"); daysStr != "" {
+		days, err := strconv.Atoi(daysStr)
+		if err != nil || days < 0 {
+			http.Error(w, "Invalid within_days", http.StatusBadRequest)
+			return
+		}
+		expiresBefore = time.Now().AddDate(0, 0, days)
+	}
+
+	certs, err := api.db.GetCertificates(r.URL.Query().Get("host"), expiresBefore)
+	if err != nil {
+		log.Printf("Error getting certificates: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"certificates": certs,
+		"count":        len(certs),
+	})
+}
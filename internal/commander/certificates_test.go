@@ -0,0 +1,145 @@
+package commander
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/metorial/sentinel/internal/models"
+)
+
+func TestReplaceCertificates(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	createTestHost(t, db, "web-1")
+	createTestHost(t, db, "web-2")
+
+	now := time.Now().Truncate(time.Second)
+	cert := func(source string, days int) models.Certificate {
+		return models.Certificate{
+			Source:      source,
+			Subject:     "CN=example.com",
+			Issuer:      "CN=Example CA",
+			SANs:        []string{"example.com"},
+			NotBefore:   now.AddDate(0, 0, -1),
+			NotAfter:    now.AddDate(0, 0, days),
+			Serial:      "2a",
+			Fingerprint: source,
+		}
+	}
+
+	if err := db.ReplaceCertificates("web-1", now, []models.Certificate{
+		cert("/etc/ssl/a.pem", 90),
+		cert("/etc/ssl/b.pem", 5),
+	}); err != nil {
+		t.Fatalf("Failed to replace certificates: %v", err)
+	}
+	if err := db.ReplaceCertificates("web-2", now, []models.Certificate{
+		cert("tcp://127.0.0.1:443", 20),
+	}); err != nil {
+		t.Fatalf("Failed to replace certificates: %v", err)
+	}
+
+	certs, err := db.GetCertificates("", time.Time{})
+	if err != nil {
+		t.Fatalf("Failed to get certificates: %v", err)
+	}
+	var sources []string
+	for _, c := range certs {
+		sources = append(sources, c.Source)
+	}
+	want := []string{"/etc/ssl/b.pem", "tcp://127.0.0.1:443", "/etc/ssl/a.pem"}
+	if len(sources) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, sources)
+	}
+	for i := range want {
+		if sources[i] != want[i] {
+			t.Errorf("Expected %v sorted by expiry, got %v", want, sources)
+			break
+		}
+	}
+	if len(certs[0].SANs) != 1 || certs[0].Hostname != "web-1" {
+		t.Errorf("Unexpected certificate: %+v", certs[0])
+	}
+
+	certs, err = db.GetCertificates("", now.AddDate(0, 0, 30))
+	if err != nil {
+		t.Fatalf("Failed to get certificates: %v", err)
+	}
+	if len(certs) != 2 {
+		t.Errorf("Expected 2 certificates expiring within 30 days, got %d", len(certs))
+	}
+
+	// A new report replaces the previous one
+	if err := db.ReplaceCertificates("web-1", now, nil); err != nil {
+		t.Fatalf("Failed to replace certificates: %v", err)
+	}
+	certs, err = db.GetCertificates("web-1", time.Time{})
+	if err != nil {
+		t.Fatalf("Failed to get certificates: %v", err)
+	}
+	if len(certs) != 0 {
+		t.Errorf("Expected no certificates, got %d", len(certs))
+	}
+}
+
+func TestHandleCertificates(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	createTestHost(t, db, "web-1")
+	now := time.Now()
+	if err := db.ReplaceCertificates("web-1", now, []models.Certificate{{
+		Source:      "/etc/ssl/a.pem",
+		Subject:     "CN=example.com",
+		Issuer:      "CN=Example CA",
+		NotBefore:   now,
+		NotAfter:    now.AddDate(0, 0, 10),
+		Fingerprint: "abc",
+	}}); err != nil {
+		t.Fatalf("Failed to replace certificates: %v", err)
+	}
+
+	api := NewAPI(db, NewServer(db))
+	mux := http.NewServeMux()
+	api.RegisterRoutes(mux)
+
+	tests := []struct {
+		query string
+		code  int
+		count int
+	}{
+		{"", http.StatusOK, 1},
+		{"?within_days=30", http.StatusOK, 1},
+		{"?within_days=5", http.StatusOK, 0},
+		{"?host=web-2", http.StatusOK, 0},
+		{"?within_days=soon", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/certificates"+tt.query, nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		if w.Code != tt.code {
+			t.Errorf("%s: expected status %d, got %d", tt.query, tt.code, w.Code)
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+
+		var response struct {
+			Count int `json:"count"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if response.Count != tt.count {
+			t.Errorf("%s: expected %d certificates, got %d", tt.query, tt.count, response.Count)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package commander
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/metorial/sentinel/internal/models"
+	pb "github.com/metorial/sentinel/proto"
+)
+
+// RecordCgroups stores a snapshot of the container cgroups running on a host.
+// Each cgroup's Usage is appended to its history; cgroups missing from the
+// snapshot are marked inactive.
+func (db *DB) RecordCgroups(hostname string, timestamp time.Time, cgroups []models.Cgroup) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var hostID int64
+	if err := tx.QueryRow(`SELECT id FROM hosts WHERE hostname = ?`, hostname).Scan(&hostID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE host_cgroups SET active = 0 WHERE host_id = ?`, hostID); err != nil {
+		return err
+	}
+
+	for _, cg := range cgroups {
+		var cgroupID int64
+		err := tx.QueryRow(`
+		INSERT INTO host_cgroups (host_id, path, runtime, container_id, active, first_seen, last_seen)
+		VALUES (?, ?, ?, ?, 1, ?, ?)
+		ON CONFLICT(host_id, path) DO UPDATE SET
+			runtime = excluded.runtime,
+			container_id = excluded.container_id,
+			active = 1,
+			last_seen = excluded.last_seen
+		RETURNING id`,
+			hostID, cg.Path, cg.Runtime, cg.ContainerID, timestamp, timestamp).Scan(&cgroupID)
+		if err != nil {
+			return fmt.Errorf("upsert cgroup %s: %w", cg.Path, err)
+		}
+
+		if cg.Usage == nil {
+			continue
+		}
+		_, err = tx.Exec(`INSERT INTO cgroup_usage (cgroup_id, timestamp, cpu_percent, memory_bytes,
+			memory_limit_bytes, io_read_bytes, io_write_bytes) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			cgroupID, timestamp, cg.Usage.CPUPercent, cg.Usage.MemoryBytes,
+			cg.Usage.MemoryLimitBytes, cg.Usage.IOReadBytes, cg.Usage.IOWriteBytes)
+		if err != nil {
+			return fmt.Errorf("insert usage of cgroup %s: %w", cg.Path, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+const cgroupColumns = `c.id, c.host_id, c.path, c.runtime, c.container_id, c.active, c.first_seen, c.last_seen`
+
+// GetHostCgroups retrieves the cgroups of a host with their latest usage,
+// optionally including cgroups that are no longer running
+func (db *DB) GetHostCgroups(hostname string, includeInactive bool) ([]models.Cgroup, error) {
+	query := `
+		SELECT ` + cgroupColumns + `,
+			u.id, u.timestamp, u.cpu_percent, u.memory_bytes, u.memory_limit_bytes,
+			u.io_read_bytes, u.io_write_bytes
+		FROM host_cgroups c
+		JOIN hosts h ON c.host_id = h.id
+		LEFT JOIN (
+			SELECT *, ROW_NUMBER() OVER (PARTITION BY cgroup_id ORDER BY timestamp DESC) as rn
+			FROM cgroup_usage
+		) u ON c.id = u.cgroup_id AND u.rn = 1
+		WHERE h.hostname = ? AND (c.active = 1 OR ?)
+		ORDER BY c.active DESC, c.runtime, c.path`
+
+	rows, err := db.conn.Query(query, hostname, includeInactive)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cgroups []models.Cgroup
+	for rows.Next() {
+		var c models.Cgroup
+		var usageID sql.NullInt64
+		var timestamp sql.NullTime
+		var cpuPercent sql.NullFloat64
+		var memory, memoryLimit, ioRead, ioWrite sql.NullInt64
+
+		err := rows.Scan(&c.ID, &c.HostID, &c.Path, &c.Runtime, &c.ContainerID, &c.Active,
+			&c.FirstSeen, &c.LastSeen,
+			&usageID, &timestamp, &cpuPercent, &memory, &memoryLimit, &ioRead, &ioWrite)
+		if err != nil {
+			return nil, err
+		}
+
+		if usageID.Valid {
+			c.Usage = &models.CgroupUsage{
+				ID:               usageID.Int64,
+				CgroupID:         c.ID,
+				Timestamp:        timestamp.Time,
+				CPUPercent:       cpuPercent.Float64,
+				MemoryBytes:      memory.Int64,
+				MemoryLimitBytes: memoryLimit.Int64,
+				IOReadBytes:      ioRead.Int64,
+				IOWriteBytes:     ioWrite.Int64,
+			}
+		}
+
+		cgroups = append(cgroups, c)
+	}
+	return cgroups, rows.Err()
+}
+
+// GetCgroup retrieves a cgroup of a host by id
+func (db *DB) GetCgroup(hostname string, id int64) (*models.Cgroup, error) {
+	query := `SELECT ` + cgroupColumns + ` FROM host_cgroups c
+	          JOIN hosts h ON c.host_id = h.id
+	          WHERE h.hostname = ? AND c.id = ?`
+
+	var c models.Cgroup
+	err := db.conn.QueryRow(query, hostname, id).Scan(&c.ID, &c.HostID, &c.Path, &c.Runtime,
+		&c.ContainerID, &c.Active, &c.FirstSeen, &c.LastSeen)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// GetCgroupUsage retrieves the most recent usage records of a cgroup
+func (db *DB) GetCgroupUsage(cgroupID int64, limit int) ([]models.CgroupUsage, error) {
+	query := `SELECT id, cgroup_id, timestamp, cpu_percent, memory_bytes, memory_limit_bytes,
+	          io_read_bytes, io_write_bytes
+	          FROM cgroup_usage
+	          WHERE cgroup_id = ?
+	          ORDER BY timestamp DESC
+	          LIMIT ?`
+
+	rows, err := db.conn.Query(query, cgroupID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usage []models.CgroupUsage
+	for rows.Next() {
+		var u models.CgroupUsage
+		err := rows.Scan(&u.ID, &u.CgroupID, &u.Timestamp, &u.CPUPercent, &u.MemoryBytes,
+			&u.MemoryLimitBytes, &u.IOReadBytes, &u.IOWriteBytes)
+		if err != nil {
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+func (s *Server) handleCgroups(hostname string, metrics *pb.CgroupMetrics) error {
+	cgroups := make([]models.Cgroup, 0, len(metrics.Cgroups))
+	for _, cg := range metrics.Cgroups {
+		cgroups = append(cgroups, models.Cgroup{
+			Path:        cg.Path,
+			Runtime:     cg.Runtime,
+			ContainerID: cg.Id,
+			Usage: &models.CgroupUsage{
+				CPUPercent:       cg.CpuPercent,
+				MemoryBytes:      cg.MemoryBytes,
+				MemoryLimitBytes: cg.MemoryLimitBytes,
+				IOReadBytes:      cg.IoReadBytes,
+				IOWriteBytes:     cg.IoWriteBytes,
+			},
+		})
+	}
+
+	if err := s.db.RecordCgroups(hostname, time.Unix(metrics.Timestamp, 0), cgroups); err != nil {
+		return fmt.Errorf("record cgroups: %w", err)
+	}
+	return nil
+}
+
+func (api *API) handleHostCgroups(w http.ResponseWriter, r *http.Request, hostname, cgroupID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, err := api.db.GetHost(hostname); err == sql.ErrNoRows {
+		http.Error(w, "Host not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("Error getting host %s: %v", hostname, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if cgroupID != "" {
+		api.handleHostCgroup(w, r, hostname, cgroupID)
+		return
+	}
+
+	includeInactive := r.URL.Query().Get("all") == "true"
+	cgroups, err := api.db.GetHostCgroups(hostname, includeInactive)
+	if err != nil {
+		log.Printf("Error getting cgroups for %s: %v", hostname, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"cgroups": cgroups,
+		"count":   len(cgroups),
+	})
+}
+
+func (api *API) handleHostCgroup(w http.ResponseWriter, r *http.Request, hostname, cgroupID string) {
+	id, err := strconv.ParseInt(cgroupID, 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid cgroup id", http.StatusBadRequest)
+		return
+	}
+
+	cgroup, err := api.db.GetCgroup(hostname, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Cgroup not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting cgroup %d of %s: %v", id, hostname, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	limit := 100
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 1000 {
+			limit = l
+		}
+	}
+
+	usage, err := api.db.GetCgroupUsage(id, limit)
+	if err != nil {
+		log.Printf("Error getting usage of cgroup %d: %v", id, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"cgroup": cgroup,
+		"usage":  usage,
+	})
+}
@@ -0,0 +1,133 @@
+package commander
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/metorial/sentinel/internal/models"
+)
+
+func TestRecordCgroups(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	createTestHost(t, db, "web-1")
+
+	now := time.Now()
+	err := db.RecordCgroups("web-1", now.Add(-time.Minute), []models.Cgroup{
+		{Path: "system.slice/docker-aaa.scope", Runtime: "docker", ContainerID: "aaa", Usage: &models.CgroupUsage{CPUPercent: 10, MemoryBytes: 1024}},
+		{Path: "system.slice/docker-bbb.scope", Runtime: "docker", ContainerID: "bbb", Usage: &models.CgroupUsage{CPUPercent: 20, MemoryBytes: 2048}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to record cgroups: %v", err)
+	}
+
+	err = db.RecordCgroups("web-1", now, []models.Cgroup{
+		{Path: "system.slice/docker-aaa.scope", Runtime: "docker", ContainerID: "aaa", Usage: &models.CgroupUsage{CPUPercent: 30, MemoryBytes: 4096}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to record cgroups: %v", err)
+	}
+
+	active, err := db.GetHostCgroups("web-1", false)
+	if err != nil {
+		t.Fatalf("Failed to get cgroups: %v", err)
+	}
+	if len(active) != 1 || active[0].ContainerID != "aaa" {
+		t.Fatalf("Expected only cgroup aaa to be active, got %+v", active)
+	}
+	if active[0].Usage == nil || active[0].Usage.CPUPercent != 30 || active[0].Usage.MemoryBytes != 4096 {
+		t.Errorf("Expected latest usage, got %+v", active[0].Usage)
+	}
+
+	all, err := db.GetHostCgroups("web-1", true)
+	if err != nil {
+		t.Fatalf("Failed to get cgroups: %v", err)
+	}
+	if len(all) != 2 || all[1].Active {
+		t.Errorf("Expected inactive cgroup bbb to be listed last, got %+v", all)
+	}
+
+	usage, err := db.GetCgroupUsage(active[0].ID, 10)
+	if err != nil {
+		t.Fatalf("Failed to get cgroup usage: %v", err)
+	}
+	if len(usage) != 2 {
+		t.Errorf("Expected 2 usage records, got %d", len(usage))
+	}
+}
+
+func TestHandleHostCgroups(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	server := NewServer(db)
+	api := NewAPI(db, server)
+	mux := http.NewServeMux()
+	api.RegisterRoutes(mux)
+
+	createTestHost(t, db, "web-1")
+	err := db.RecordCgroups("web-1", time.Now(), []models.Cgroup{
+		{Path: "nomad.slice/8c1f-redis.scope", Runtime: "nomad", ContainerID: "8c1f-redis", Usage: &models.CgroupUsage{CPUPercent: 12.5}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to record cgroups: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/hosts/web-1/cgroups", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var list struct {
+		Cgroups []models.Cgroup `json:"cgroups"`
+		Count   int             `json:"count"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if list.Count != 1 || list.Cgroups[0].Runtime != "nomad" {
+		t.Fatalf("Unexpected cgroups: %+v", list)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/hosts/web-1/cgroups/%d", list.Cgroups[0].ID), nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var detail struct {
+		Cgroup models.Cgroup        `json:"cgroup"`
+		Usage  []models.CgroupUsage `json:"usage"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&detail); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(detail.Usage) != 1 || detail.Usage[0].CPUPercent != 12.5 {
+		t.Errorf("Expected 1 usage record with 12.5%% cpu, got %+v", detail.Usage)
+	}
+
+	for path, want := range map[string]int{
+		"/api/v1/hosts/web-1/cgroups/abc": http.StatusBadRequest,
+		"/api/v1/hosts/web-1/cgroups/999": http.StatusNotFound,
+		"/api/v1/hosts/unknown/cgroups":   http.StatusNotFound,
+		"/api/v1/hosts/web-1/nonexistent": http.StatusNotFound,
+	} {
+		req = httptest.NewRequest(http.MethodGet, path, nil)
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		if w.Code != want {
+			t.Errorf("GET %s: expected status %d, got %d", path, want, w.Code)
+		}
+	}
+}
@@ -0,0 +1,539 @@
+package commander
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/metorial/sentinel/internal/models"
+	pb "github.com/metorial/sentinel/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+// AlertKindCheck is the kind of the alerts raised for failing checks; their
+// subject is the check name.
+const AlertKindCheck = "check"
+
+// Check types supported by the agent.
+var checkTypes = map[string]bool{"http": true, "tcp": true, "tls": true, "dns": true}
+
+// minCheckInterval keeps misconfigured checks from hammering their targets.
+const minCheckInterval = 5
+
+// UpsertCheck creates or replaces the check with check.Name
+func (db *DB) UpsertCheck(check *models.Check) error {
+	query := `
+	INSERT INTO checks (name, type, target, selector, interval_seconds, timeout_seconds,
+		expected_status, body_regex, min_days_valid, expected_address, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(name) DO UPDATE SET
+		type = excluded.type,
+		target = excluded.target,
+		selector = excluded.selector,
+		interval_seconds = excluded.interval_seconds,
+		timeout_seconds = excluded.timeout_seconds,
+		expected_status = excluded.expected_status,
+		body_regex = excluded.body_regex,
+		min_days_valid = excluded.min_days_valid,
+		expected_address = excluded.expected_address,
+		updated_at = excluded.updated_at
+	RETURNING id`
+
+	return db.conn.QueryRow(query, check.Name, check.Type, check.Target, check.Selector,
+		check.IntervalSeconds, check.TimeoutSeconds, check.ExpectedStatus, check.BodyRegex,
+		check.MinDaysValid, check.ExpectedAddress, time.Now()).Scan(&check.ID)
+}
+
+const checkColumns = `c.id, c.name, c.type, c.target, c.selector, c.interval_seconds, c.timeout_seconds,
+	c.expected_status, c.body_regex, c.min_days_valid, c.expected_address, c.created_at, c.updated_at`
+
+func scanCheck(row rowScanner) (*models.Check, error) {
+	var c models.Check
+	err := row.Scan(&c.ID, &c.Name, &c.Type, &c.Target, &c.Selector, &c.IntervalSeconds,
+		&c.TimeoutSeconds, &c.ExpectedStatus, &c.BodyRegex, &c.MinDaysValid, &c.ExpectedAddress,
+		&c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (db *DB) queryChecks(query string, args ...interface{}) ([]models.Check, error) {
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checks []models.Check
+	for rows.Next() {
+		c, err := scanCheck(rows)
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, *c)
+	}
+	return checks, rows.Err()
+}
+
+// GetCheck retrieves a check by name
+func (db *DB) GetCheck(name string) (*models.Check, error) {
+	query := `SELECT ` + checkColumns + ` FROM checks c WHERE c.name = ?`
+	return scanCheck(db.conn.QueryRow(query, name))
+}
+
+// GetAllChecks retrieves all checks ordered by name
+func (db *DB) GetAllChecks() ([]models.Check, error) {
+	return db.queryChecks(`SELECT ` + checkColumns + ` FROM checks c ORDER BY c.name`)
+}
+
+// GetHostChecks retrieves the checks assigned to a host: those whose selector
+// matches the host's tags, or is empty
+func (db *DB) GetHostChecks(hostname string) ([]models.Check, error) {
+	tags, err := db.GetHostTags(hostname)
+	if err != nil {
+		return nil, err
+	}
+	checks, err := db.GetAllChecks()
+	if err != nil {
+		return nil, err
+	}
+
+	var assigned []models.Check
+	for _, check := range checks {
+		selector, err := ParseSelector(check.Selector)
+		if err != nil {
+			// Selectors are validated when saved
+			log.Printf("Skipping check %s with invalid selector: %v", check.Name, err)
+			continue
+		}
+		if selector.Matches(tags) {
+			assigned = append(assigned, check)
+		}
+	}
+	return assigned, nil
+}
+
+// DeleteCheck deletes a check and its results, returning sql.ErrNoRows if it
+// doesn't exist
+func (db *DB) DeleteCheck(name string) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	if err := tx.QueryRow(`DELETE FROM checks WHERE name = ? RETURNING id`, name).Scan(&id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM check_results WHERE check_id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RecordCheckResult stores a result reported by the agent of hostname,
+// filling in its ID and HostID. It returns sql.ErrNoRows if the check or
+// host no longer exists.
+func (db *DB) RecordCheckResult(hostname string, result *models.CheckResult) error {
+	query := `INSERT INTO check_results (check_id, host_id, timestamp, success, latency_ms, message)
+	          SELECT c.id, h.id, ?, ?, ?, ?
+	          FROM checks c, hosts h
+	          WHERE c.id = ? AND h.hostname = ?
+	          RETURNING id, host_id`
+
+	result.Hostname = hostname
+	return db.conn.QueryRow(query, result.Timestamp, result.Success, result.LatencyMs,
+		result.Message, result.CheckID, hostname).Scan(&result.ID, &result.HostID)
+}
+
+const checkResultColumns = `r.id, r.check_id, r.host_id, h.hostname, r.timestamp, r.success, r.latency_ms, r.message`
+
+func (db *DB) queryCheckResults(query string, args ...interface{}) ([]models.CheckResult, error) {
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.CheckResult
+	for rows.Next() {
+		var r models.CheckResult
+		err := rows.Scan(&r.ID, &r.CheckID, &r.HostID, &r.Hostname, &r.Timestamp, &r.Success,
+			&r.LatencyMs, &r.Message)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// GetCheckStatus retrieves the latest result of a check on every host that
+// reported it
+func (db *DB) GetCheckStatus(checkID int64) ([]models.CheckResult, error) {
+	query := `SELECT ` + checkResultColumns + `
+	          FROM (
+	              SELECT *, ROW_NUMBER() OVER (PARTITION BY host_id ORDER BY timestamp DESC, id DESC) as rn
+	              FROM check_results
+	              WHERE check_id = ?
+	          ) r
+	          JOIN hosts h ON r.host_id = h.id
+	          WHERE r.rn = 1
+	          ORDER BY r.success, h.hostname`
+	return db.queryCheckResults(query, checkID)
+}
+
+// GetCheckResults retrieves the most recent results of a check, optionally
+// only those reported by hostname
+func (db *DB) GetCheckResults(checkID int64, hostname string, limit int) ([]models.CheckResult, error) {
+	query := `SELECT ` + checkResultColumns + `
+	          FROM check_results r
+	          JOIN hosts h ON r.host_id = h.id
+	          WHERE r.check_id = ? AND (? = '' OR h.hostname = ?)
+	          ORDER BY r.timestamp DESC, r.id DESC
+	          LIMIT ?`
+	return db.queryCheckResults(query, checkID, hostname, hostname, limit)
+}
+
+func validateCheck(c *models.Check) error {
+	if c.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if !checkTypes[c.Type] {
+		return fmt.Errorf("type must be one of http, tcp, tls or dns")
+	}
+	if c.Target == "" {
+		return fmt.Errorf("target is required")
+	}
+	if _, err := ParseSelector(c.Selector); err != nil {
+		return err
+	}
+
+	switch c.Type {
+	case "http":
+		u, err := url.Parse(c.Target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("target must be an http or https URL")
+		}
+		if c.ExpectedStatus != 0 && (c.ExpectedStatus < 100 || c.ExpectedStatus > 599) {
+			return fmt.Errorf("expected_status must be a valid HTTP status")
+		}
+		if _, err := regexp.Compile(c.BodyRegex); err != nil {
+			return fmt.Errorf("invalid body_regex: %v", err)
+		}
+	case "tcp", "tls":
+		if _, _, err := net.SplitHostPort(c.Target); err != nil {
+			return fmt.Errorf("target must be host:port")
+		}
+	case "dns":
+		if c.ExpectedAddress != "" && net.ParseIP(c.ExpectedAddress) == nil {
+			return fmt.Errorf("expected_address must be an IP address")
+		}
+	}
+
+	if c.IntervalSeconds != 0 && c.IntervalSeconds < minCheckInterval {
+		return fmt.Errorf("interval_seconds must be at least %d", minCheckInterval)
+	}
+	if c.TimeoutSeconds < 0 {
+		return fmt.Errorf("timeout_seconds must not be negative")
+	}
+	if c.MinDaysValid < 0 {
+		return fmt.Errorf("min_days_valid must not be negative")
+	}
+	return nil
+}
+
+// checksFor builds the check assignment of a host.
+func (s *Server) checksFor(hostname string) (*pb.CheckAssignment, error) {
+	checks, err := s.db.GetHostChecks(hostname)
+	if err != nil {
+		return nil, err
+	}
+
+	assignment := &pb.CheckAssignment{}
+	for _, c := range checks {
+		assignment.Checks = append(assignment.Checks, &pb.CheckDefinition{
+			Id:              c.ID,
+			Name:            c.Name,
+			Type:            c.Type,
+			Target:          c.Target,
+			IntervalSeconds: c.IntervalSeconds,
+			TimeoutSeconds:  c.TimeoutSeconds,
+			ExpectedStatus:  c.ExpectedStatus,
+			BodyRegex:       c.BodyRegex,
+			MinDaysValid:    c.MinDaysValid,
+			ExpectedAddress: c.ExpectedAddress,
+		})
+	}
+	return assignment, nil
+}
+
+// sendChecks sends assignment unless it equals the one last sent.
+func (a *agentStream) sendChecks(assignment *pb.CheckAssignment) error {
+	a.sendMu.Lock()
+	defer a.sendMu.Unlock()
+
+	// Agents start every stream without checks.
+	last := a.checks
+	if last == nil {
+		last = &pb.CheckAssignment{}
+	}
+	if proto.Equal(last, assignment) {
+		return nil
+	}
+
+	if err := a.stream.Send(&pb.CollectorMessage{
+		Payload: &pb.CollectorMessage_Checks{Checks: assignment},
+	}); err != nil {
+		return err
+	}
+
+	a.checks = assignment
+	return nil
+}
+
+func (s *Server) pushChecks(hostname string, conn *agentStream) error {
+	if !conn.hasCapability(CapabilityChecks) {
+		return nil
+	}
+
+	assignment, err := s.checksFor(hostname)
+	if err != nil {
+		return fmt.Errorf("resolve checks: %w", err)
+	}
+	return conn.sendChecks(assignment)
+}
+
+// PushChecks sends the current check assignment to hostname if it is
+// connected and its assignment changed.
+func (s *Server) PushChecks(hostname string) {
+	s.mu.RLock()
+	conn, ok := s.streams[hostname]
+	s.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	if err := s.pushChecks(hostname, conn); err != nil {
+		log.Printf("Error pushing checks to %s: %v", hostname, err)
+	}
+}
+
+// PushAllChecks sends updated check assignments to every connected agent,
+// e.g. after a check was modified.
+func (s *Server) PushAllChecks() {
+	s.mu.RLock()
+	hostnames := make([]string, 0, len(s.streams))
+	for hostname := range s.streams {
+		hostnames = append(hostnames, hostname)
+	}
+	s.mu.RUnlock()
+
+	for _, hostname := range hostnames {
+		s.PushChecks(hostname)
+	}
+}
+
+// handleCheckResult stores a check result and fires or resolves the check's
+// alert for the host.
+func (s *Server) handleCheckResult(hostname string, res *pb.CheckResult) error {
+	result := &models.CheckResult{
+		CheckID:   res.CheckId,
+		Timestamp: time.Unix(res.Timestamp, 0),
+		Success:   res.Success,
+		LatencyMs: res.LatencyMs,
+		Message:   res.Message,
+	}
+
+	if err := s.db.RecordCheckResult(hostname, result); err == sql.ErrNoRows {
+		// The check was deleted while the agent ran it.
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("record check result: %w", err)
+	}
+
+	if result.Success {
+		resolved, err := s.db.ResolveAlert(result.HostID, AlertKindCheck, res.Name, result.Timestamp)
+		if err != nil {
+			return fmt.Errorf("resolve alert: %w", err)
+		}
+		if resolved {
+			log.Printf("Check %s recovered on %s", res.Name, hostname)
+		}
+		return nil
+	}
+
+	fired, err := s.db.FireAlert(result.HostID, AlertKindCheck, res.Name, res.Message, result.Timestamp)
+	if err != nil {
+		return fmt.Errorf("fire alert: %w", err)
+	}
+	if fired {
+		log.Printf("Check %s failing on %s: %s", res.Name, hostname, res.Message)
+	}
+	return nil
+}
+
+func (api *API) handleChecks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	checks, err := api.db.GetAllChecks()
+	if err != nil {
+		log.Printf("Error getting checks: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"checks": checks,
+		"count":  len(checks),
+	})
+}
+
+func (api *API) handleCheck(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path[len("/api/v1/checks/"):]
+	name, subresource, _ := strings.Cut(path, "/")
+	if name == "" {
+		http.Error(w, "Check name required", http.StatusBadRequest)
+		return
+	}
+
+	switch subresource {
+	case "":
+	case "results":
+		api.handleCheckResults(w, r, name)
+		return
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		check, err := api.db.GetCheck(name)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Check not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error getting check %s: %v", name, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		status, err := api.db.GetCheckStatus(check.ID)
+		if err != nil {
+			log.Printf("Error getting status of check %s: %v", name, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"check":  check,
+			"status": status,
+		})
+
+	case http.MethodPut:
+		var check models.Check
+		if err := json.NewDecoder(r.Body).Decode(&check); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		check.Name = name
+
+		if err := validateCheck(&check); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := api.db.UpsertCheck(&check); err != nil {
+			log.Printf("Error saving check %s: %v", name, err)
+			http.Error(w, "Failed to save check", http.StatusInternalServerError)
+			return
+		}
+
+		go api.server.PushAllChecks()
+
+		saved, err := api.db.GetCheck(name)
+		if err != nil {
+			log.Printf("Error getting check %s: %v", name, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, http.StatusOK, saved)
+
+	case http.MethodDelete:
+		err := api.db.DeleteCheck(name)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Check not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error deleting check %s: %v", name, err)
+			http.Error(w, "Failed to delete check", http.StatusInternalServerError)
+			return
+		}
+
+		if err := api.db.ResolveAlerts(AlertKindCheck, name, time.Now()); err != nil {
+			log.Printf("Error resolving alerts of check %s: %v", name, err)
+		}
+
+		go api.server.PushAllChecks()
+
+		respondJSON(w, http.StatusOK, map[string]string{
+			"message": "Check deleted successfully",
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (api *API) handleCheckResults(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	check, err := api.db.GetCheck(name)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Check not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting check %s: %v", name, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	limit := 100
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 1000 {
+			limit = l
+		}
+	}
+
+	results, err := api.db.GetCheckResults(check.ID, r.URL.Query().Get("host"), limit)
+	if err != nil {
+		log.Printf("Error getting results of check %s: %v", name, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"results": results,
+		"count":   len(results),
+	})
+}
@@ -0,0 +1,324 @@
+package commander
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metorial/sentinel/internal/models"
+	"github.com/metorial/sentinel/internal/version"
+	pb "github.com/metorial/sentinel/proto"
+)
+
+func TestGetHostChecks(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	createTestHost(t, db, "web-1")
+	createTestHost(t, db, "db-1")
+
+	for _, check := range []*models.Check{
+		{Name: "homepage", Type: "http", Target: "https://example.com"},
+		{Name: "postgres", Type: "tcp", Target: "localhost:5432", Selector: "database"},
+	} {
+		if err := db.UpsertCheck(check); err != nil {
+			t.Fatalf("Failed to create check: %v", err)
+		}
+	}
+	if err := db.AddHostTag("db-1", "database"); err != nil {
+		t.Fatalf("Failed to add tag: %v", err)
+	}
+
+	tests := []struct {
+		hostname string
+		want     []string
+	}{
+		{"web-1", []string{"homepage"}},
+		{"db-1", []string{"homepage", "postgres"}},
+	}
+
+	for _, tt := range tests {
+		checks, err := db.GetHostChecks(tt.hostname)
+		if err != nil {
+			t.Fatalf("Failed to get checks for %s: %v", tt.hostname, err)
+		}
+		var names []string
+		for _, c := range checks {
+			names = append(names, c.Name)
+		}
+		if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("Expected checks %v for %s, got %v", tt.want, tt.hostname, names)
+		}
+	}
+}
+
+func TestValidateCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		check   models.Check
+		wantErr bool
+	}{
+		{"http", models.Check{Name: "a", Type: "http", Target: "https://example.com/health", BodyRegex: "ok"}, false},
+		{"tcp", models.Check{Name: "a", Type: "tcp", Target: "localhost:22"}, false},
+		{"dns", models.Check{Name: "a", Type: "dns", Target: "example.com", ExpectedAddress: "93.184.216.34"}, false},
+		{"unknown type", models.Check{Name: "a", Type: "icmp", Target: "localhost"}, true},
+		{"missing target", models.Check{Name: "a", Type: "tcp"}, true},
+		{"http without scheme", models.Check{Name: "a", Type: "http", Target: "example.com"}, true},
+		{"invalid regex", models.Check{Name: "a", Type: "http", Target: "http://example.com", BodyRegex: "("}, true},
+		{"tls without port", models.Check{Name: "a", Type: "tls", Target: "example.com"}, true},
+		{"interval too short", models.Check{Name: "a", Type: "tcp", Target: "localhost:22", IntervalSeconds: 1}, true},
+		{"selector", models.Check{Name: "a", Type: "tcp", Target: "localhost:22", Selector: "env=prod AND NOT canary"}, false},
+		{"invalid selector", models.Check{Name: "a", Type: "tcp", Target: "localhost:22", Selector: "env in (prod"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCheck(&tt.check)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestHandleCheckResultAlerts(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	createTestHost(t, db, "web-1")
+	check := &models.Check{Name: "homepage", Type: "http", Target: "https://example.com"}
+	if err := db.UpsertCheck(check); err != nil {
+		t.Fatalf("Failed to create check: %v", err)
+	}
+
+	server := NewServer(db)
+	report := func(success bool, message string) {
+		t.Helper()
+		err := server.handleCheckResult("web-1", &pb.CheckResult{
+			CheckId:   check.ID,
+			Name:      check.Name,
+			Timestamp: time.Now().Unix(),
+			Success:   success,
+			LatencyMs: 12.5,
+			Message:   message,
+		})
+		if err != nil {
+			t.Fatalf("Failed to handle check result: %v", err)
+		}
+	}
+
+	report(false, "status 503")
+	report(false, "status 502")
+
+	alerts, err := db.GetAlerts(models.AlertFiring)
+	if err != nil {
+		t.Fatalf("Failed to get alerts: %v", err)
+	}
+	if len(alerts) != 1 {
+		t.Fatalf("Expected 1 firing alert, got %d", len(alerts))
+	}
+	if alerts[0].Kind != AlertKindCheck || alerts[0].Subject != "homepage" || alerts[0].Message != "status 502" {
+		t.Errorf("Unexpected alert: %+v", alerts[0])
+	}
+
+	report(true, "status 200")
+
+	alerts, err = db.GetAlerts(models.AlertFiring)
+	if err != nil {
+		t.Fatalf("Failed to get alerts: %v", err)
+	}
+	if len(alerts) != 0 {
+		t.Errorf("Expected no firing alerts, got %d", len(alerts))
+	}
+
+	results, err := db.GetCheckResults(check.ID, "web-1", 10)
+	if err != nil {
+		t.Fatalf("Failed to get results: %v", err)
+	}
+	if len(results) != 3 {
+		t.Errorf("Expected 3 results, got %d", len(results))
+	}
+
+	status, err := db.GetCheckStatus(check.ID)
+	if err != nil {
+		t.Fatalf("Failed to get status: %v", err)
+	}
+	if len(status) != 1 || !status[0].Success || status[0].Hostname != "web-1" {
+		t.Errorf("Expected latest result to be a success on web-1, got %+v", status)
+	}
+
+	// Results of deleted checks are dropped
+	if err := db.DeleteCheck("homepage"); err != nil {
+		t.Fatalf("Failed to delete check: %v", err)
+	}
+	report(false, "status 503")
+}
+
+func TestChecksPushedOnConnect(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	check := &models.Check{Name: "ssh", Type: "tcp", Target: "localhost:22", IntervalSeconds: 30}
+	if err := db.UpsertCheck(check); err != nil {
+		t.Fatalf("Failed to create check: %v", err)
+	}
+
+	server := NewServer(db)
+	stream := setupTestStream(t, server)
+
+	if err := stream.Send(&pb.AgentMessage{
+		Payload: &pb.AgentMessage_Hello{
+			Hello: &pb.Hello{
+				Hostname:        "test-host",
+				AgentVersion:    version.Version,
+				ProtocolVersion: version.Protocol,
+				Capabilities:    []string{CapabilityChecks},
+			},
+		},
+	}); err != nil {
+		t.Fatalf("Failed to send hello: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Failed to receive welcome: %v", err)
+	}
+
+	if err := stream.Send(testMetrics("test-host")); err != nil {
+		t.Fatalf("Failed to send metrics: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Failed to receive ack: %v", err)
+	}
+
+	msg, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Failed to receive checks: %v", err)
+	}
+
+	assignment := msg.GetChecks()
+	if assignment == nil {
+		t.Fatalf("Expected checks, got %T", msg.Payload)
+	}
+	if len(assignment.Checks) != 1 {
+		t.Fatalf("Expected 1 check, got %d", len(assignment.Checks))
+	}
+	if def := assignment.Checks[0]; def.Id != check.ID || def.Target != "localhost:22" || def.IntervalSeconds != 30 {
+		t.Errorf("Unexpected check definition: %v", def)
+	}
+}
+
+func TestHandleCheck(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	api := NewAPI(db, NewServer(db))
+	mux := http.NewServeMux()
+	api.RegisterRoutes(mux)
+
+	body := `{"type": "http", "target": "https://example.com", "expected_status": 200}`
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/checks/homepage", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var saved models.Check
+	if err := json.NewDecoder(w.Body).Decode(&saved); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if saved.Name != "homepage" || saved.ExpectedStatus != 200 {
+		t.Errorf("Unexpected check: %+v", saved)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/checks/broken", strings.NewReader(`{"type": "icmp"}`))
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/checks/homepage/results", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/checks/homepage", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/checks/homepage", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestHandleAlerts(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	hostID := createTestHost(t, db, "web-1")
+	now := time.Now()
+	if _, err := db.FireAlert(hostID, AlertKindCheck, "homepage", "status 503", now); err != nil {
+		t.Fatalf("Failed to fire alert: %v", err)
+	}
+	if _, err := db.FireAlert(hostID, AlertKindCheck, "ssh", "connection refused", now); err != nil {
+		t.Fatalf("Failed to fire alert: %v", err)
+	}
+	if _, err := db.ResolveAlert(hostID, AlertKindCheck, "ssh", now); err != nil {
+		t.Fatalf("Failed to resolve alert: %v", err)
+	}
+
+	api := NewAPI(db, NewServer(db))
+	mux := http.NewServeMux()
+	api.RegisterRoutes(mux)
+
+	tests := []struct {
+		query string
+		code  int
+		count int
+	}{
+		{"", http.StatusOK, 1},
+		{"?state=resolved", http.StatusOK, 1},
+		{"?state=all", http.StatusOK, 2},
+		{"?state=bogus", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/alerts"+tt.query, nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		if w.Code != tt.code {
+			t.Errorf("%s: expected status %d, got %d", tt.query, tt.code, w.Code)
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+
+		var response struct {
+			Count int `json:"count"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if response.Count != tt.count {
+			t.Errorf("%s: expected %d alerts, got %d", tt.query, tt.count, response.Count)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package commander
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	pb "github.com/metorial/sentinel/proto"
+)
+
+// ErrHostNotConnected is returned when a command targets a host without an
+// active stream.
+var ErrHostNotConnected = errors.New("host not connected")
+
+// ErrCommandsUnsupported is returned when the connected agent did not
+// negotiate the commands capability.
+var ErrCommandsUnsupported = errors.New("agent does not support commands")
+
+// SendCommand dispatches cmd to the agent registered for hostname and waits
+// for the correlated CommandResult until ctx is done. The command ID is
+// assigned here and overwrites any ID already set on cmd.
+func (s *Server) SendCommand(ctx context.Context, hostname string, cmd *pb.Command) (*pb.CommandResult, error) {
+	s.mu.RLock()
+	conn, ok := s.streams[hostname]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrHostNotConnected
+	}
+	if !conn.hasCapability(CapabilityCommands) {
+		return nil, ErrCommandsUnsupported
+	}
+
+	cmd.Id = uuid.NewString()
+	resultChan := make(chan *pb.CommandResult, 1)
+
+	s.pendingMu.Lock()
+	s.pending[cmd.Id] = resultChan
+	s.pendingMu.Unlock()
+
+	defer func() {
+		s.pendingMu.Lock()
+		delete(s.pending, cmd.Id)
+		s.pendingMu.Unlock()
+	}()
+
+	if err := conn.send(&pb.CollectorMessage{
+		Payload: &pb.CollectorMessage_Command{Command: cmd},
+	}); err != nil {
+		return nil, fmt.Errorf("send command: %w", err)
+	}
+
+	select {
+	case result := <-resultChan:
+		if setInterval := cmd.GetSetInterval(); setInterval != nil && result.Success {
+			s.setExpectedInterval(hostname, conn, time.Duration(setInterval.IntervalSeconds)*time.Second)
+		}
+		return result, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *Server) resolveCommand(result *pb.CommandResult) {
+	s.pendingMu.Lock()
+	resultChan, ok := s.pending[result.Id]
+	s.pendingMu.Unlock()
+
+	if !ok {
+		log.Printf("Received result for unknown or expired command: %s", result.Id)
+		return
+	}
+
+	select {
+	case resultChan <- result:
+	default:
+	}
+}
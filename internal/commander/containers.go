@@ -0,0 +1,133 @@
+package commander
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/metorial/sentinel/internal/models"
+	pb "github.com/metorial/sentinel/proto"
+)
+
+// ReplaceContainers replaces the stored container list of a host with the
+// latest one reported by its agent
+func (db *DB) ReplaceContainers(hostname string, updatedAt time.Time, containers []models.Container) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var hostID int64
+	if err := tx.QueryRow(`SELECT id FROM hosts WHERE hostname = ?`, hostname).Scan(&hostID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM host_containers WHERE host_id = ?`, hostID); err != nil {
+		return err
+	}
+
+	for _, c := range containers {
+		_, err := tx.Exec(`INSERT INTO host_containers (host_id, container_id, name, image, state, status,
+			restart_count, created, cpu_percent, memory_bytes, memory_limit_bytes,
+			network_rx_bytes, network_tx_bytes, block_read_bytes, block_write_bytes, error, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			hostID, c.ContainerID, c.Name, c.Image, c.State, c.Status,
+			c.RestartCount, c.Created, c.CPUPercent, c.MemoryBytes, c.MemoryLimitBytes,
+			c.NetworkRxBytes, c.NetworkTxBytes, c.BlockReadBytes, c.BlockWriteBytes, c.Error, updatedAt)
+		if err != nil {
+			return fmt.Errorf("insert container %s: %w", c.ContainerID, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetHostContainers retrieves the latest container list of a host, running
+// containers first
+func (db *DB) GetHostContainers(hostname string) ([]models.Container, error) {
+	query := `SELECT c.host_id, c.container_id, c.name, c.image, c.state, c.status,
+	          c.restart_count, c.created, c.cpu_percent, c.memory_bytes, c.memory_limit_bytes,
+	          c.network_rx_bytes, c.network_tx_bytes, c.block_read_bytes, c.block_write_bytes, c.error, c.updated_at
+	          FROM host_containers c
+	          JOIN hosts h ON c.host_id = h.id
+	          WHERE h.hostname = ?
+	          ORDER BY c.state = 'running' DESC, c.name`
+
+	rows, err := db.conn.Query(query, hostname)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var containers []models.Container
+	for rows.Next() {
+		var c models.Container
+		err := rows.Scan(&c.HostID, &c.ContainerID, &c.Name, &c.Image, &c.State, &c.Status,
+			&c.RestartCount, &c.Created, &c.CPUPercent, &c.MemoryBytes, &c.MemoryLimitBytes,
+			&c.NetworkRxBytes, &c.NetworkTxBytes, &c.BlockReadBytes, &c.BlockWriteBytes, &c.Error, &c.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		containers = append(containers, c)
+	}
+	return containers, rows.Err()
+}
+
+func (s *Server) handleContainers(hostname string, list *pb.ContainerList) error {
+	containers := make([]models.Container, 0, len(list.Containers))
+	for _, c := range list.Containers {
+		containers = append(containers, models.Container{
+			ContainerID:      c.Id,
+			Name:             c.Name,
+			Image:            c.Image,
+			State:            c.State,
+			Status:           c.Status,
+			RestartCount:     c.RestartCount,
+			Created:          time.Unix(c.Created, 0),
+			CPUPercent:       c.CpuPercent,
+			MemoryBytes:      c.MemoryBytes,
+			MemoryLimitBytes: c.MemoryLimitBytes,
+			NetworkRxBytes:   c.NetworkRxBytes,
+			NetworkTxBytes:   c.NetworkTxBytes,
+			BlockReadBytes:   c.BlockReadBytes,
+			BlockWriteBytes:  c.BlockWriteBytes,
+			Error:            c.Error,
+		})
+	}
+
+	if err := s.db.ReplaceContainers(hostname, time.Unix(list.Timestamp, 0), containers); err != nil {
+		return fmt.Errorf("replace containers: %w", err)
+	}
+	return nil
+}
+
+func (api *API) handleHostContainers(w http.ResponseWriter, r *http.Request, hostname string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, err := api.db.GetHost(hostname); err == sql.ErrNoRows {
+		http.Error(w, "Host not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("Error getting host %s: %v", hostname, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	containers, err := api.db.GetHostContainers(hostname)
+	if err != nil {
+		log.Printf("Error getting containers for %s: %v", hostname, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"containers": containers,
+		"count":      len(containers),
+	})
+}
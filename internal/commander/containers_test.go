@@ -0,0 +1,101 @@
+package commander
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/metorial/sentinel/internal/models"
+)
+
+func TestReplaceContainers(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	createTestHost(t, db, "web-1")
+
+	err := db.ReplaceContainers("web-1", time.Now(), []models.Container{
+		{ContainerID: "aaa", Name: "web", Image: "nginx", State: "running", MemoryBytes: 1024},
+		{ContainerID: "bbb", Name: "api", Image: "app", State: "exited", RestartCount: 3, Error: "inspect: timeout"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to store containers: %v", err)
+	}
+
+	containers, err := db.GetHostContainers("web-1")
+	if err != nil {
+		t.Fatalf("Failed to get containers: %v", err)
+	}
+	if len(containers) != 2 || containers[0].Name != "web" || containers[1].RestartCount != 3 {
+		t.Fatalf("Expected running container first, got %+v", containers)
+	}
+	if containers[0].Error != "" || containers[1].Error != "inspect: timeout" {
+		t.Errorf("Expected the error reading api stored, got %+v", containers)
+	}
+
+	err = db.ReplaceContainers("web-1", time.Now(), []models.Container{
+		{ContainerID: "ccc", Name: "worker", Image: "app", State: "running"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to store containers: %v", err)
+	}
+
+	containers, err = db.GetHostContainers("web-1")
+	if err != nil {
+		t.Fatalf("Failed to get containers: %v", err)
+	}
+	if len(containers) != 1 || containers[0].ContainerID != "ccc" {
+		t.Errorf("Expected only the latest list, got %+v", containers)
+	}
+
+	if err := db.ReplaceContainers("unknown", time.Now(), nil); err == nil {
+		t.Error("Expected error for unknown host")
+	}
+}
+
+func TestHandleHostContainers(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	server := NewServer(db)
+	api := NewAPI(db, server)
+	mux := http.NewServeMux()
+	api.RegisterRoutes(mux)
+
+	createTestHost(t, db, "web-1")
+	err := db.ReplaceContainers("web-1", time.Now(), []models.Container{
+		{ContainerID: "aaa", Name: "web", Image: "nginx", State: "running", CPUPercent: 12.5},
+	})
+	if err != nil {
+		t.Fatalf("Failed to store containers: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/hosts/web-1/containers", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Containers []models.Container `json:"containers"`
+		Count      int                `json:"count"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Count != 1 || response.Containers[0].CPUPercent != 12.5 {
+		t.Errorf("Unexpected containers: %+v", response)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/hosts/unknown/containers", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown host, got %d", w.Code)
+	}
+}
@@ -2,7 +2,10 @@ package commander
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/metorial/sentinel/internal/models"
@@ -13,8 +16,71 @@ type DB struct {
 	conn *sql.DB
 }
 
+// hostColumns is the column list scanned by scanHost.
+const hostColumns = `h.id, h.hostname, h.ip, h.uptime_seconds, h.cpu_cores, h.total_memory_bytes,
+	h.total_storage_bytes, h.last_seen, h.online, h.created_at, h.updated_at,
+	h.config_version, h.config_failed_version, h.config_error,
+	h.agent_version, h.protocol_version, h.os, h.arch, h.capabilities, h.clock_skew_seconds,
+	h.status, h.status_reason, h.status_since, h.expected_interval_seconds`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanHost scans a row selected with hostColumns into h, followed by any
+// extra destinations for additional selected columns.
+func scanHost(row rowScanner, h *models.Host, extra ...interface{}) error {
+	var capabilities string
+	dest := []interface{}{
+		&h.ID, &h.Hostname, &h.IP, &h.UptimeSeconds, &h.CPUCores,
+		&h.TotalMemoryBytes, &h.TotalStorageBytes, &h.LastSeen, &h.Online,
+		&h.CreatedAt, &h.UpdatedAt,
+		&h.ConfigVersion, &h.ConfigFailedVersion, &h.ConfigError,
+		&h.AgentVersion, &h.ProtocolVersion, &h.OS, &h.Arch, &capabilities,
+		&h.ClockSkewSeconds,
+		&h.Status, &h.StatusReason, &h.StatusSince, &h.ExpectedIntervalSeconds,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(capabilities), &h.Capabilities)
+}
+
+// columnMigrations lists columns added to tables after their initial release.
+// They are part of the CREATE TABLE statements for new databases and are added
+// to existing databases on startup.
+var columnMigrations = []struct {
+	table, column, definition string
+}{
+	{"hosts", "config_version", "INTEGER NOT NULL DEFAULT 0"},
+	{"hosts", "config_error", "TEXT NOT NULL DEFAULT ''"},
+	{"hosts", "config_failed_version", "INTEGER NOT NULL DEFAULT 0"},
+	{"hosts", "agent_version", "TEXT NOT NULL DEFAULT ''"},
+	{"hosts", "protocol_version", "INTEGER NOT NULL DEFAULT 0"},
+	{"hosts", "os", "TEXT NOT NULL DEFAULT ''"},
+	{"hosts", "arch", "TEXT NOT NULL DEFAULT ''"},
+	{"hosts", "capabilities", "TEXT NOT NULL DEFAULT '[]'"},
+	{"hosts", "clock_skew_seconds", "REAL NOT NULL DEFAULT 0"},
+	{"hosts", "status", "TEXT NOT NULL DEFAULT 'offline'"},
+	{"hosts", "status_reason", "TEXT NOT NULL DEFAULT ''"},
+	{"hosts", "status_since", "TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00'"},
+	{"hosts", "expected_interval_seconds", "INTEGER NOT NULL DEFAULT 0"},
+	{"host_containers", "error", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// busyTimeout makes concurrent writers wait for the database lock instead of
+// failing immediately with SQLITE_BUSY.
+const busyTimeout = "_pragma=busy_timeout(5000)"
+
 func NewDB(path string) (*DB, error) {
-	conn, err := sql.Open("sqlite", path)
+	dsn := path
+	if strings.Contains(dsn, "?") {
+		dsn += "&" + busyTimeout
+	} else {
+		dsn += "?" + busyTimeout
+	}
+
+	conn, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("open database: %w", err)
 	}
@@ -44,7 +110,20 @@ func (db *DB) migrate() error {
 		last_seen TIMESTAMP NOT NULL,
 		online BOOLEAN NOT NULL DEFAULT 1,
 		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		config_version INTEGER NOT NULL DEFAULT 0,
+		config_error TEXT NOT NULL DEFAULT '',
+		config_failed_version INTEGER NOT NULL DEFAULT 0,
+		agent_version TEXT NOT NULL DEFAULT '',
+		protocol_version INTEGER NOT NULL DEFAULT 0,
+		os TEXT NOT NULL DEFAULT '',
+		arch TEXT NOT NULL DEFAULT '',
+		capabilities TEXT NOT NULL DEFAULT '[]',
+		clock_skew_seconds REAL NOT NULL DEFAULT 0,
+		status TEXT NOT NULL DEFAULT 'offline',
+		status_reason TEXT NOT NULL DEFAULT '',
+		status_since TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00',
+		expected_interval_seconds INTEGER NOT NULL DEFAULT 0
 	);
 
 	CREATE INDEX IF NOT EXISTS idx_hosts_hostname ON hosts(hostname);
@@ -79,16 +158,346 @@ func (db *DB) migrate() error {
 		FOREIGN KEY (host_id) REFERENCES hosts(id) ON DELETE CASCADE,
 		FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
 	);
+
+	CREATE TABLE IF NOT EXISTS host_labels (
+		host_id INTEGER NOT NULL,
+		key TEXT NOT NULL,
+		value TEXT NOT NULL,
+		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (host_id, key),
+		FOREIGN KEY (host_id) REFERENCES hosts(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_host_labels_key_value ON host_labels(key, value);
+
+	CREATE VIEW IF NOT EXISTS host_tag_names AS
+		SELECT ht.host_id, t.name FROM host_tags ht JOIN tags t ON ht.tag_id = t.id
+		UNION ALL
+		SELECT host_id, key || ':' || value FROM host_labels;
+
+	CREATE TABLE IF NOT EXISTS agent_profiles (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		selector TEXT NOT NULL DEFAULT '',
+		priority INTEGER NOT NULL DEFAULT 0,
+		report_interval_seconds INTEGER NOT NULL DEFAULT 0,
+		enabled_collectors TEXT NOT NULL DEFAULT '[]',
+		mount_include TEXT NOT NULL DEFAULT '[]',
+		mount_exclude TEXT NOT NULL DEFAULT '[]',
+		version INTEGER NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS profile_version (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		version INTEGER NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS host_inventory (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		host_id INTEGER NOT NULL,
+		version INTEGER NOT NULL,
+		data TEXT NOT NULL,
+		changes TEXT NOT NULL DEFAULT '[]',
+		collected_at TIMESTAMP NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (host_id, version),
+		FOREIGN KEY (host_id) REFERENCES hosts(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS host_cgroups (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		host_id INTEGER NOT NULL,
+		path TEXT NOT NULL,
+		runtime TEXT NOT NULL,
+		container_id TEXT NOT NULL,
+		active BOOLEAN NOT NULL DEFAULT 1,
+		first_seen TIMESTAMP NOT NULL,
+		last_seen TIMESTAMP NOT NULL,
+		UNIQUE (host_id, path),
+		FOREIGN KEY (host_id) REFERENCES hosts(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS cgroup_usage (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		cgroup_id INTEGER NOT NULL,
+		timestamp TIMESTAMP NOT NULL,
+		cpu_percent REAL NOT NULL,
+		memory_bytes INTEGER NOT NULL,
+		memory_limit_bytes INTEGER NOT NULL,
+		io_read_bytes INTEGER NOT NULL,
+		io_write_bytes INTEGER NOT NULL,
+		FOREIGN KEY (cgroup_id) REFERENCES host_cgroups(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_cgroup_usage_cgroup_id ON cgroup_usage(cgroup_id);
+	CREATE INDEX IF NOT EXISTS idx_cgroup_usage_timestamp ON cgroup_usage(timestamp);
+
+	CREATE TABLE IF NOT EXISTS host_containers (
+		host_id INTEGER NOT NULL,
+		container_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		image TEXT NOT NULL,
+		state TEXT NOT NULL,
+		status TEXT NOT NULL,
+		restart_count INTEGER NOT NULL,
+		created TIMESTAMP NOT NULL,
+		cpu_percent REAL NOT NULL,
+		memory_bytes INTEGER NOT NULL,
+		memory_limit_bytes INTEGER NOT NULL,
+		network_rx_bytes INTEGER NOT NULL,
+		network_tx_bytes INTEGER NOT NULL,
+		block_read_bytes INTEGER NOT NULL,
+		block_write_bytes INTEGER NOT NULL,
+		error TEXT NOT NULL DEFAULT '',
+		updated_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (host_id, container_id),
+		FOREIGN KEY (host_id) REFERENCES hosts(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS host_certificates (
+		host_id INTEGER NOT NULL,
+		source TEXT NOT NULL,
+		fingerprint TEXT NOT NULL,
+		subject TEXT NOT NULL,
+		issuer TEXT NOT NULL,
+		sans TEXT NOT NULL DEFAULT '[]',
+		not_before TIMESTAMP NOT NULL,
+		not_after TIMESTAMP NOT NULL,
+		serial TEXT NOT NULL,
+		updated_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (host_id, source, fingerprint),
+		FOREIGN KEY (host_id) REFERENCES hosts(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_host_certificates_not_after ON host_certificates(not_after);
+
+	CREATE TABLE IF NOT EXISTS host_units (
+		host_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		description TEXT NOT NULL,
+		load_state TEXT NOT NULL,
+		active_state TEXT NOT NULL,
+		sub_state TEXT NOT NULL,
+		since TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (host_id, name),
+		FOREIGN KEY (host_id) REFERENCES hosts(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_host_units_active_state ON host_units(active_state);
+
+	CREATE TABLE IF NOT EXISTS unit_transitions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		host_id INTEGER NOT NULL,
+		unit TEXT NOT NULL,
+		from_active_state TEXT NOT NULL,
+		from_sub_state TEXT NOT NULL,
+		to_active_state TEXT NOT NULL,
+		to_sub_state TEXT NOT NULL,
+		timestamp TIMESTAMP NOT NULL,
+		FOREIGN KEY (host_id) REFERENCES hosts(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_unit_transitions_host_timestamp ON unit_transitions(host_id, timestamp);
+
+	CREATE TABLE IF NOT EXISTS log_samples (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		host_id INTEGER NOT NULL,
+		rule TEXT NOT NULL,
+		labels TEXT NOT NULL DEFAULT '{}',
+		timestamp TIMESTAMP NOT NULL,
+		interval_seconds INTEGER NOT NULL,
+		count INTEGER NOT NULL,
+		FOREIGN KEY (host_id) REFERENCES hosts(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_log_samples_host_rule_timestamp ON log_samples(host_id, rule, timestamp);
+	CREATE INDEX IF NOT EXISTS idx_log_samples_timestamp ON log_samples(timestamp);
+
+	CREATE TABLE IF NOT EXISTS log_lines (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		host_id INTEGER NOT NULL,
+		rule TEXT NOT NULL,
+		timestamp TIMESTAMP NOT NULL,
+		line TEXT NOT NULL,
+		FOREIGN KEY (host_id) REFERENCES hosts(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_log_lines_host_rule ON log_lines(host_id, rule, id);
+
+	CREATE TABLE IF NOT EXISTS host_sensors (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		host_id INTEGER NOT NULL,
+		chip TEXT NOT NULL,
+		sensor TEXT NOT NULL,
+		label TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		value REAL NOT NULL,
+		critical REAL NOT NULL,
+		max REAL NOT NULL,
+		updated_at TIMESTAMP NOT NULL,
+		UNIQUE (host_id, chip, sensor),
+		FOREIGN KEY (host_id) REFERENCES hosts(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS sensor_readings (
+		sensor_id INTEGER NOT NULL,
+		timestamp TIMESTAMP NOT NULL,
+		value REAL NOT NULL,
+		FOREIGN KEY (sensor_id) REFERENCES host_sensors(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_sensor_readings_sensor_timestamp ON sensor_readings(sensor_id, timestamp);
+	CREATE INDEX IF NOT EXISTS idx_sensor_readings_timestamp ON sensor_readings(timestamp);
+
+	CREATE TABLE IF NOT EXISTS host_status_transitions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		host_id INTEGER NOT NULL,
+		from_status TEXT NOT NULL,
+		to_status TEXT NOT NULL,
+		reason TEXT NOT NULL,
+		timestamp TIMESTAMP NOT NULL,
+		FOREIGN KEY (host_id) REFERENCES hosts(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_host_status_transitions_host_timestamp ON host_status_transitions(host_id, timestamp);
+
+	CREATE TABLE IF NOT EXISTS maintenance_windows (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		hostname TEXT NOT NULL DEFAULT '',
+		tag TEXT NOT NULL DEFAULT '',
+		reason TEXT NOT NULL DEFAULT '',
+		author TEXT NOT NULL DEFAULT '',
+		starts_at TIMESTAMP NOT NULL,
+		ends_at TIMESTAMP NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_maintenance_windows_ends_at ON maintenance_windows(ends_at);
+
+	CREATE TABLE IF NOT EXISTS tag_rules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		tag TEXT NOT NULL,
+		hostname_regex TEXT NOT NULL DEFAULT '',
+		cidr TEXT NOT NULL DEFAULT '',
+		min_cpu_cores INTEGER NOT NULL DEFAULT 0,
+		max_cpu_cores INTEGER NOT NULL DEFAULT 0,
+		os TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS tag_rule_hosts (
+		rule_id INTEGER NOT NULL,
+		host_id INTEGER NOT NULL,
+		tag TEXT NOT NULL,
+		PRIMARY KEY (rule_id, host_id),
+		FOREIGN KEY (host_id) REFERENCES hosts(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS checks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		type TEXT NOT NULL,
+		target TEXT NOT NULL,
+		selector TEXT NOT NULL DEFAULT '',
+		interval_seconds INTEGER NOT NULL DEFAULT 0,
+		timeout_seconds INTEGER NOT NULL DEFAULT 0,
+		expected_status INTEGER NOT NULL DEFAULT 0,
+		body_regex TEXT NOT NULL DEFAULT '',
+		min_days_valid INTEGER NOT NULL DEFAULT 0,
+		expected_address TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS check_results (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		check_id INTEGER NOT NULL,
+		host_id INTEGER NOT NULL,
+		timestamp TIMESTAMP NOT NULL,
+		success BOOLEAN NOT NULL,
+		latency_ms REAL NOT NULL,
+		message TEXT NOT NULL,
+		FOREIGN KEY (check_id) REFERENCES checks(id) ON DELETE CASCADE,
+		FOREIGN KEY (host_id) REFERENCES hosts(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_check_results_check_host ON check_results(check_id, host_id, timestamp);
+	CREATE INDEX IF NOT EXISTS idx_check_results_timestamp ON check_results(timestamp);
+
+	CREATE TABLE IF NOT EXISTS alerts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		host_id INTEGER NOT NULL,
+		kind TEXT NOT NULL,
+		subject TEXT NOT NULL,
+		message TEXT NOT NULL,
+		state TEXT NOT NULL,
+		started_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL,
+		resolved_at TIMESTAMP,
+		FOREIGN KEY (host_id) REFERENCES hosts(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_alerts_state ON alerts(state);
+
+	CREATE TABLE IF NOT EXISTS data_migrations (
+		name TEXT PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
 	`
 
-	_, err := db.conn.Exec(schema)
-	return err
+	if _, err := db.conn.Exec(schema); err != nil {
+		return err
+	}
+
+	if err := db.addMissingColumns(); err != nil {
+		return err
+	}
+
+	// Start the profile version counter above every version handed out
+	// before it existed, including those of deleted profiles agents may
+	// still run
+	if _, err := db.conn.Exec(`INSERT OR IGNORE INTO profile_version (id, version)
+		SELECT 1, MAX((SELECT COALESCE(MAX(version), 0) FROM agent_profiles),
+		              (SELECT COALESCE(MAX(config_version), 0) FROM hosts))`); err != nil {
+		return fmt.Errorf("seed profile version: %w", err)
+	}
+
+	if err := db.migrateLegacySelectors(); err != nil {
+		return fmt.Errorf("migrate selectors: %w", err)
+	}
+
+	return db.splitLabelTags()
+}
+
+func (db *DB) addMissingColumns() error {
+	for _, m := range columnMigrations {
+		var count int
+		err := db.conn.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`,
+			m.table, m.column).Scan(&count)
+		if err != nil {
+			return fmt.Errorf("inspect %s: %w", m.table, err)
+		}
+		if count > 0 {
+			continue
+		}
+
+		if _, err := db.conn.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s",
+			m.table, m.column, m.definition)); err != nil {
+			return fmt.Errorf("add column %s.%s: %w", m.table, m.column, err)
+		}
+	}
+	return nil
 }
 
 func (db *DB) UpsertHost(host *models.Host) (int64, error) {
 	query := `
-	INSERT INTO hosts (hostname, ip, uptime_seconds, cpu_cores, total_memory_bytes, total_storage_bytes, last_seen, online, updated_at)
-	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	INSERT INTO hosts (hostname, ip, uptime_seconds, cpu_cores, total_memory_bytes, total_storage_bytes, last_seen, online, updated_at, clock_skew_seconds)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	ON CONFLICT(hostname) DO UPDATE SET
 		ip = excluded.ip,
 		uptime_seconds = excluded.uptime_seconds,
@@ -96,17 +505,41 @@ func (db *DB) UpsertHost(host *models.Host) (int64, error) {
 		total_memory_bytes = excluded.total_memory_bytes,
 		total_storage_bytes = excluded.total_storage_bytes,
 		last_seen = excluded.last_seen,
-		online = excluded.online,
-		updated_at = excluded.updated_at
+		online = CASE WHEN hosts.status = 'decommissioned' THEN 0 ELSE excluded.online END,
+		updated_at = excluded.updated_at,
+		clock_skew_seconds = excluded.clock_skew_seconds
 	RETURNING id
 	`
 
 	var id int64
 	err := db.conn.QueryRow(query, host.Hostname, host.IP, host.UptimeSeconds, host.CPUCores,
-		host.TotalMemoryBytes, host.TotalStorageBytes, host.LastSeen, host.Online, time.Now()).Scan(&id)
+		host.TotalMemoryBytes, host.TotalStorageBytes, host.LastSeen, host.Online, time.Now(), host.ClockSkewSeconds).Scan(&id)
 	return id, err
 }
 
+// RegisterHost creates the host hostname if it doesn't exist yet. It has no
+// metrics until the agent reports them.
+func (db *DB) RegisterHost(hostname string) error {
+	_, err := db.conn.Exec(`INSERT INTO hosts (hostname, ip, uptime_seconds, cpu_cores, total_memory_bytes,
+	                        total_storage_bytes, last_seen, online)
+	                        VALUES (?, '', 0, 0, 0, 0, ?, 0)
+	                        ON CONFLICT(hostname) DO NOTHING`, hostname, time.Now())
+	return err
+}
+
+// SetHostAgentInfo records the agent details reported in its Hello message
+func (db *DB) SetHostAgentInfo(hostname, agentVersion string, protocolVersion int32, os, arch string, capabilities []string) error {
+	caps, err := json.Marshal(nonNil(capabilities))
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE hosts SET agent_version = ?, protocol_version = ?, os = ?, arch = ?, capabilities = ?
+	          WHERE hostname = ?`
+	_, err = db.conn.Exec(query, agentVersion, protocolVersion, os, arch, string(caps), hostname)
+	return err
+}
+
 func (db *DB) InsertUsage(usage *models.HostUsage) error {
 	query := `INSERT INTO host_usage (host_id, timestamp, cpu_percent, used_memory_bytes, used_storage_bytes)
 	          VALUES (?, ?, ?, ?, ?)`
@@ -115,16 +548,38 @@ func (db *DB) InsertUsage(usage *models.HostUsage) error {
 	return err
 }
 
-func (db *DB) MarkInactive(threshold time.Duration) error {
-	query := `UPDATE hosts SET online = 0 WHERE last_seen < ? AND online = 1`
-	_, err := db.conn.Exec(query, time.Now().Add(-threshold))
-	return err
-}
-
 func (db *DB) CleanupOldUsage(retention time.Duration) error {
-	query := `DELETE FROM host_usage WHERE timestamp < ?`
-	_, err := db.conn.Exec(query, time.Now().Add(-retention))
-	return err
+	cutoff := time.Now().Add(-retention)
+	for _, query := range []string{
+		`DELETE FROM host_usage WHERE timestamp < ?`,
+		`DELETE FROM cgroup_usage WHERE timestamp < ?`,
+		`DELETE FROM host_cgroups WHERE active = 0 AND last_seen < ?`,
+		`DELETE FROM check_results WHERE timestamp < ?`,
+		`DELETE FROM unit_transitions WHERE timestamp < ?`,
+		`DELETE FROM host_status_transitions WHERE timestamp < ?`,
+		`DELETE FROM log_samples WHERE timestamp < ?`,
+		`DELETE FROM log_lines WHERE timestamp < ?`,
+		`DELETE FROM sensor_readings WHERE timestamp < ?`,
+		`DELETE FROM host_sensors WHERE updated_at < ?`,
+		`DELETE FROM alerts WHERE state = 'resolved' AND resolved_at < ?`,
+		`DELETE FROM maintenance_windows WHERE ends_at < ?`,
+	} {
+		if _, err := db.conn.Exec(query, cutoff); err != nil {
+			return err
+		}
+	}
+
+	// Foreign keys aren't enforced, so remove usage of deleted cgroups and
+	// readings of removed sensors here
+	for _, query := range []string{
+		`DELETE FROM cgroup_usage WHERE cgroup_id NOT IN (SELECT id FROM host_cgroups)`,
+		`DELETE FROM sensor_readings WHERE sensor_id NOT IN (SELECT id FROM host_sensors)`,
+	} {
+		if _, err := db.conn.Exec(query); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (db *DB) Close() error {
@@ -136,11 +591,18 @@ func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
 }
 
 func (db *DB) GetAllHosts() ([]models.Host, error) {
+	return db.queryHostsWithUsage("")
+}
+
+// queryHostsWithUsage retrieves the hosts matching the condition on h, or all
+// hosts if it is empty, with their latest usage, ordered by hostname
+func (db *DB) queryHostsWithUsage(where string, args ...interface{}) ([]models.Host, error) {
+	if where != "" {
+		where = "WHERE " + where
+	}
 	query := `
 		SELECT
-			h.id, h.hostname, h.ip, h.uptime_seconds, h.cpu_cores,
-			h.total_memory_bytes, h.total_storage_bytes, h.last_seen,
-			h.online, h.created_at, h.updated_at,
+			` + hostColumns + `,
 			u.cpu_percent, u.used_memory_bytes, u.used_storage_bytes
 		FROM hosts h
 		LEFT JOIN (
@@ -152,9 +614,10 @@ func (db *DB) GetAllHosts() ([]models.Host, error) {
 				ROW_NUMBER() OVER (PARTITION BY host_id ORDER BY timestamp DESC) as rn
 			FROM host_usage
 		) u ON h.id = u.host_id AND u.rn = 1
+		` + where + `
 		ORDER BY h.hostname`
 
-	rows, err := db.conn.Query(query)
+	rows, err := db.conn.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -167,12 +630,7 @@ func (db *DB) GetAllHosts() ([]models.Host, error) {
 		var usedMemory sql.NullInt64
 		var usedStorage sql.NullInt64
 
-		err := rows.Scan(
-			&h.ID, &h.Hostname, &h.IP, &h.UptimeSeconds, &h.CPUCores,
-			&h.TotalMemoryBytes, &h.TotalStorageBytes, &h.LastSeen, &h.Online,
-			&h.CreatedAt, &h.UpdatedAt,
-			&cpuPercent, &usedMemory, &usedStorage,
-		)
+		err := scanHost(rows, &h, &cpuPercent, &usedMemory, &usedStorage)
 		if err != nil {
 			return nil, err
 		}
@@ -195,14 +653,10 @@ func (db *DB) GetAllHosts() ([]models.Host, error) {
 }
 
 func (db *DB) GetHost(hostname string) (*models.Host, error) {
-	query := `SELECT id, hostname, ip, uptime_seconds, cpu_cores, total_memory_bytes,
-	          total_storage_bytes, last_seen, online, created_at, updated_at
-	          FROM hosts WHERE hostname = ?`
+	query := `SELECT ` + hostColumns + ` FROM hosts h WHERE h.hostname = ?`
 
 	var h models.Host
-	err := db.conn.QueryRow(query, hostname).Scan(&h.ID, &h.Hostname, &h.IP,
-		&h.UptimeSeconds, &h.CPUCores, &h.TotalMemoryBytes, &h.TotalStorageBytes,
-		&h.LastSeen, &h.Online, &h.CreatedAt, &h.UpdatedAt)
+	err := scanHost(db.conn.QueryRow(query, hostname), &h)
 	if err != nil {
 		return nil, err
 	}
@@ -240,11 +694,25 @@ func (db *DB) GetHostUsage(hostname string, limit int) ([]models.HostUsage, erro
 }
 
 func (db *DB) GetClusterStats() (map[string]interface{}, error) {
+	return db.GetClusterStatsFor(nil, defaultStatsWindow)
+}
+
+// GetClusterStatsFor computes the cluster statistics over the hosts with the
+// given ids, or over all hosts if hostIDs is nil. Utilization is computed
+// from the usage reported within window.
+func (db *DB) GetClusterStatsFor(hostIDs []int64, window time.Duration) (map[string]interface{}, error) {
 	stats := make(map[string]interface{})
 
-	var totalHosts, onlineHosts int
-	err := db.conn.QueryRow("SELECT COUNT(*), SUM(CASE WHEN online = 1 THEN 1 ELSE 0 END) FROM hosts").
-		Scan(&totalHosts, &onlineHosts)
+	hostFilter := hostIDFilter("id", hostIDs)
+
+	// Decommissioned hosts are left out of every figure and only counted
+	var totalHosts, onlineHosts, decommissionedHosts int
+	err := db.conn.QueryRow(`SELECT
+	                         COALESCE(SUM(CASE WHEN status != 'decommissioned' THEN 1 ELSE 0 END), 0),
+	                         COALESCE(SUM(CASE WHEN online = 1 AND status != 'decommissioned' THEN 1 ELSE 0 END), 0),
+	                         COALESCE(SUM(CASE WHEN status = 'decommissioned' THEN 1 ELSE 0 END), 0)
+	                         FROM hosts WHERE 1 = 1`+hostFilter).
+		Scan(&totalHosts, &onlineHosts, &decommissionedHosts)
 	if err != nil {
 		return nil, err
 	}
@@ -252,10 +720,39 @@ func (db *DB) GetClusterStats() (map[string]interface{}, error) {
 	stats["total_hosts"] = totalHosts
 	stats["online_hosts"] = onlineHosts
 	stats["offline_hosts"] = totalHosts - onlineHosts
+	stats["decommissioned_hosts"] = decommissionedHosts
+
+	covered, err := db.GetHostsInMaintenance(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	maintenanceHosts := 0
+	if len(covered) > 0 {
+		rows, err := db.conn.Query(`SELECT hostname FROM hosts WHERE status != 'decommissioned'` + hostFilter)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var hostname string
+			if err := rows.Scan(&hostname); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if covered[hostname] {
+				maintenanceHosts++
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	stats["maintenance_hosts"] = maintenanceHosts
 
 	var totalCPUCores, totalMemoryBytes, totalStorageBytes int64
-	err = db.conn.QueryRow(`SELECT SUM(cpu_cores), SUM(total_memory_bytes), SUM(total_storage_bytes)
-	                        FROM hosts WHERE online = 1`).
+	err = db.conn.QueryRow(`SELECT COALESCE(SUM(cpu_cores), 0), COALESCE(SUM(total_memory_bytes), 0),
+	                        COALESCE(SUM(total_storage_bytes), 0)
+	                        FROM hosts WHERE online = 1 AND status != 'decommissioned'`+hostFilter).
 		Scan(&totalCPUCores, &totalMemoryBytes, &totalStorageBytes)
 	if err != nil && err != sql.ErrNoRows {
 		return nil, err
@@ -265,18 +762,31 @@ func (db *DB) GetClusterStats() (map[string]interface{}, error) {
 	stats["total_memory_bytes"] = totalMemoryBytes
 	stats["total_storage_bytes"] = totalStorageBytes
 
-	var avgCPUPercent sql.NullFloat64
-	err = db.conn.QueryRow(`SELECT AVG(cpu_percent) FROM host_usage
-	                        WHERE timestamp > datetime('now', '-5 minutes')`).
-		Scan(&avgCPUPercent)
+	// Memory utilization of hosts reporting no total is NULL and left out
+	var avgCPUPercent, maxCPUPercent, avgMemoryPercent, maxMemoryPercent sql.NullFloat64
+	err = db.conn.QueryRow(`SELECT AVG(u.cpu_percent), MAX(u.cpu_percent),
+	                        AVG(u.used_memory_bytes * 100.0 / h.total_memory_bytes),
+	                        MAX(u.used_memory_bytes * 100.0 / h.total_memory_bytes)
+	                        FROM host_usage u JOIN hosts h ON u.host_id = h.id
+	                        WHERE u.timestamp > ?
+	                        AND u.host_id IN (SELECT id FROM hosts WHERE status != 'decommissioned'`+hostFilter+`)`,
+		time.Now().Add(-window)).
+		Scan(&avgCPUPercent, &maxCPUPercent, &avgMemoryPercent, &maxMemoryPercent)
 	if err != nil && err != sql.ErrNoRows {
 		return nil, err
 	}
 
-	if avgCPUPercent.Valid {
-		stats["avg_cpu_percent"] = avgCPUPercent.Float64
-	} else {
-		stats["avg_cpu_percent"] = 0.0
+	for key, value := range map[string]sql.NullFloat64{
+		"avg_cpu_percent":    avgCPUPercent,
+		"max_cpu_percent":    maxCPUPercent,
+		"avg_memory_percent": avgMemoryPercent,
+		"max_memory_percent": maxMemoryPercent,
+	} {
+		if value.Valid {
+			stats[key] = value.Float64
+		} else {
+			stats[key] = 0.0
+		}
 	}
 
 	return stats, nil
@@ -301,28 +811,69 @@ func (db *DB) GetOrCreateTag(name string) (int64, error) {
 	return id, err
 }
 
-// GetAllTags retrieves all tags
+// GetAllTags retrieves all tags with the number of hosts with each, with
+// labels as key:value tags. A label that isn't also a tag has no id and dates
+// from the earliest time it was set on a host.
 func (db *DB) GetAllTags() ([]models.Tag, error) {
-	query := `SELECT id, name, created_at FROM tags ORDER BY name`
+	query := `SELECT t.id, t.name, t.created_at, COUNT(ht.host_id) FROM tags t
+	          LEFT JOIN host_tags ht ON ht.tag_id = t.id
+	          GROUP BY t.id
+	          ORDER BY t.name`
 	rows, err := db.conn.Query(query)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var tags []models.Tag
+	tags := []models.Tag{}
+	byName := make(map[string]int)
 	for rows.Next() {
 		var t models.Tag
-		if err := rows.Scan(&t.ID, &t.Name, &t.CreatedAt); err != nil {
+		if err := rows.Scan(&t.ID, &t.Name, &t.CreatedAt, &t.Hosts); err != nil {
 			return nil, err
 		}
+		byName[t.Name] = len(tags)
 		tags = append(tags, t)
 	}
-	return tags, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	labels, err := db.conn.Query(`SELECT key, value, updated_at FROM host_labels ORDER BY updated_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer labels.Close()
+
+	for labels.Next() {
+		var key, value string
+		var updatedAt time.Time
+		if err := labels.Scan(&key, &value, &updatedAt); err != nil {
+			return nil, err
+		}
+		name := key + ":" + value
+		if i, ok := byName[name]; ok {
+			tags[i].Hosts++
+			continue
+		}
+		byName[name] = len(tags)
+		tags = append(tags, models.Tag{Name: name, CreatedAt: updatedAt, Hosts: 1})
+	}
+	if err := labels.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags, nil
 }
 
-// AddHostTag adds a tag to a host
+// AddHostTag adds a tag to a host. A tag of the form key:value sets the label
+// key instead, replacing the value it had: a host has one value per key
 func (db *DB) AddHostTag(hostname, tagName string) error {
+	if key, value, ok := splitLabelTag(tagName); ok {
+		return db.SetHostLabel(hostname, key, value)
+	}
+
 	tagID, err := db.GetOrCreateTag(tagName)
 	if err != nil {
 		return err
@@ -338,21 +889,29 @@ func (db *DB) AddHostTag(hostname, tagName string) error {
 	return err
 }
 
-// RemoveHostTag removes a tag from a host
+// RemoveHostTag removes a tag from a host. A tag of the form key:value removes
+// the label key if it has that value
 func (db *DB) RemoveHostTag(hostname, tagName string) error {
+	if key, value, ok := splitLabelTag(tagName); ok {
+		_, err := db.conn.Exec(`DELETE FROM host_labels WHERE host_id = (SELECT id FROM hosts WHERE hostname = ?)
+		                        AND key = ? AND value = ?`, hostname, key, value)
+		if err != nil {
+			return err
+		}
+	}
+
 	query := `DELETE FROM host_tags WHERE host_id = (SELECT id FROM hosts WHERE hostname = ?)
 	          AND tag_id = (SELECT id FROM tags WHERE name = ?)`
 	_, err := db.conn.Exec(query, hostname, tagName)
 	return err
 }
 
-// GetHostTags retrieves all tags for a host
+// GetHostTags retrieves all tags for a host, with labels as key:value tags
 func (db *DB) GetHostTags(hostname string) ([]string, error) {
-	query := `SELECT t.name FROM tags t
-	          JOIN host_tags ht ON t.id = ht.tag_id
+	query := `SELECT ht.name FROM host_tag_names ht
 	          JOIN hosts h ON ht.host_id = h.id
 	          WHERE h.hostname = ?
-	          ORDER BY t.name`
+	          ORDER BY ht.name`
 	rows, err := db.conn.Query(query, hostname)
 	if err != nil {
 		return nil, err
@@ -371,6 +930,7 @@ func (db *DB) GetHostTags(hostname string) ([]string, error) {
 }
 
 // GetHostsByTags retrieves hosts that have ANY of the specified tags (OR logic)
+// with their latest usage
 func (db *DB) GetHostsByTags(tags []string) ([]models.Host, error) {
 	if len(tags) == 0 {
 		return db.GetAllHosts()
@@ -386,30 +946,7 @@ func (db *DB) GetHostsByTags(tags []string) ([]models.Host, error) {
 		args[i] = tag
 	}
 
-	query := `SELECT DISTINCT h.id, h.hostname, h.ip, h.uptime_seconds, h.cpu_cores, h.total_memory_bytes,
-	          h.total_storage_bytes, h.last_seen, h.online, h.created_at, h.updated_at
-	          FROM hosts h
-	          JOIN host_tags ht ON h.id = ht.host_id
-	          JOIN tags t ON ht.tag_id = t.id
-	          WHERE t.name IN (` + placeholders + `)
-	          ORDER BY h.hostname`
-
-	rows, err := db.conn.Query(query, args...)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var hosts []models.Host
-	for rows.Next() {
-		var h models.Host
-		err := rows.Scan(&h.ID, &h.Hostname, &h.IP, &h.UptimeSeconds, &h.CPUCores,
-			&h.TotalMemoryBytes, &h.TotalStorageBytes, &h.LastSeen, &h.Online,
-			&h.CreatedAt, &h.UpdatedAt)
-		if err != nil {
-			return nil, err
-		}
-		hosts = append(hosts, h)
-	}
-	return hosts, rows.Err()
+	return db.queryHostsWithUsage(`h.id IN (
+		SELECT host_id FROM host_tag_names
+		WHERE name IN (`+placeholders+`))`, args...)
 }
@@ -1,6 +1,7 @@
 package commander
 
 import (
+	"database/sql"
 	"os"
 	"testing"
 	"time"
@@ -23,6 +24,51 @@ func TestNewDB(t *testing.T) {
 	}
 }
 
+func TestMigrateAddsMissingColumns(t *testing.T) {
+	dbPath := t.TempDir() + "/old.db"
+
+	// Create a hosts table as it looked before columns were added to it.
+	old, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	_, err = old.Exec(`CREATE TABLE hosts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		hostname TEXT NOT NULL UNIQUE,
+		ip TEXT NOT NULL,
+		uptime_seconds INTEGER NOT NULL,
+		cpu_cores INTEGER NOT NULL,
+		total_memory_bytes INTEGER NOT NULL,
+		total_storage_bytes INTEGER NOT NULL,
+		last_seen TIMESTAMP NOT NULL,
+		online BOOLEAN NOT NULL DEFAULT 1,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	old.Close()
+	if err != nil {
+		t.Fatalf("Failed to create old schema: %v", err)
+	}
+
+	db, err := NewDB(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+	defer db.Close()
+
+	for _, m := range columnMigrations {
+		var count int
+		err := db.conn.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`,
+			m.table, m.column).Scan(&count)
+		if err != nil {
+			t.Fatalf("Failed to inspect table: %v", err)
+		}
+		if count != 1 {
+			t.Errorf("Expected column %s.%s to exist", m.table, m.column)
+		}
+	}
+}
+
 func TestUpsertHost(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -118,56 +164,6 @@ func TestInsertUsage(t *testing.T) {
 	}
 }
 
-func TestMarkInactive(t *testing.T) {
-	db := setupTestDB(t)
-	defer db.Close()
-
-	now := time.Now()
-	oldHost := &models.Host{
-		Hostname:          "old-host",
-		IP:                "192.168.1.100",
-		UptimeSeconds:     3600,
-		CPUCores:          4,
-		TotalMemoryBytes:  8589934592,
-		TotalStorageBytes: 107374182400,
-		LastSeen:          now.Add(-5 * time.Minute),
-		Online:            true,
-	}
-
-	activeHost := &models.Host{
-		Hostname:          "active-host",
-		IP:                "192.168.1.101",
-		UptimeSeconds:     3600,
-		CPUCores:          4,
-		TotalMemoryBytes:  8589934592,
-		TotalStorageBytes: 107374182400,
-		LastSeen:          now,
-		Online:            true,
-	}
-
-	if _, err := db.UpsertHost(oldHost); err != nil {
-		t.Fatalf("Failed to insert old host: %v", err)
-	}
-
-	if _, err := db.UpsertHost(activeHost); err != nil {
-		t.Fatalf("Failed to insert active host: %v", err)
-	}
-
-	if err := db.MarkInactive(2 * time.Minute); err != nil {
-		t.Fatalf("Failed to mark inactive: %v", err)
-	}
-
-	var onlineCount int
-	err := db.conn.QueryRow("SELECT COUNT(*) FROM hosts WHERE online = 1").Scan(&onlineCount)
-	if err != nil {
-		t.Fatalf("Failed to query online count: %v", err)
-	}
-
-	if onlineCount != 1 {
-		t.Errorf("Expected 1 online host, got %d", onlineCount)
-	}
-}
-
 func TestCleanupOldUsage(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -638,3 +634,21 @@ func setupTestDB(t *testing.T) *DB {
 	}
 	return db
 }
+
+func createTestHost(t *testing.T, db *DB, hostname string) int64 {
+	t.Helper()
+	id, err := db.UpsertHost(&models.Host{
+		Hostname:          hostname,
+		IP:                "192.168.1.100",
+		UptimeSeconds:     3600,
+		CPUCores:          4,
+		TotalMemoryBytes:  8589934592,
+		TotalStorageBytes: 107374182400,
+		LastSeen:          time.Now(),
+		Online:            true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert host: %v", err)
+	}
+	return id
+}
@@ -0,0 +1,214 @@
+package commander
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/metorial/sentinel/internal/models"
+)
+
+// hostDataQueries delete everything stored about the host with the given id,
+// except the host row itself. Tables keyed by a per-host parent id are
+// cleared before their parent.
+var hostDataQueries = []string{
+	`DELETE FROM host_usage WHERE host_id = ?`,
+	`DELETE FROM host_tags WHERE host_id = ?`,
+	`DELETE FROM host_labels WHERE host_id = ?`,
+	`DELETE FROM tag_rule_hosts WHERE host_id = ?`,
+	`DELETE FROM host_inventory WHERE host_id = ?`,
+	`DELETE FROM cgroup_usage WHERE cgroup_id IN (SELECT id FROM host_cgroups WHERE host_id = ?)`,
+	`DELETE FROM host_cgroups WHERE host_id = ?`,
+	`DELETE FROM host_containers WHERE host_id = ?`,
+	`DELETE FROM host_certificates WHERE host_id = ?`,
+	`DELETE FROM host_units WHERE host_id = ?`,
+	`DELETE FROM unit_transitions WHERE host_id = ?`,
+	`DELETE FROM log_samples WHERE host_id = ?`,
+	`DELETE FROM log_lines WHERE host_id = ?`,
+	`DELETE FROM sensor_readings WHERE sensor_id IN (SELECT id FROM host_sensors WHERE host_id = ?)`,
+	`DELETE FROM host_sensors WHERE host_id = ?`,
+	`DELETE FROM host_status_transitions WHERE host_id = ?`,
+	`DELETE FROM check_results WHERE host_id = ?`,
+	`DELETE FROM alerts WHERE host_id = ?`,
+}
+
+// DeleteHost deletes a host and everything stored about it. Foreign keys
+// aren't enforced, so every table referencing the host is cleared here.
+func (db *DB) DeleteHost(hostname string) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var hostID int64
+	if err := tx.QueryRow(`SELECT id FROM hosts WHERE hostname = ?`, hostname).Scan(&hostID); err != nil {
+		return err
+	}
+
+	for _, query := range hostDataQueries {
+		if _, err := tx.Exec(query, hostID); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM hosts WHERE id = ?`, hostID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DecommissionHost retires a host: it is left out of listings and stats, its
+// presence is no longer tracked and its firing alerts are resolved. It
+// reports whether the host wasn't decommissioned already.
+func (db *DB) DecommissionHost(hostname, reason string, at time.Time) (bool, error) {
+	changed, err := db.SetHostStatus(hostname, models.HostDecommissioned, reason, at)
+	if err != nil || !changed {
+		return changed, err
+	}
+
+	_, err = db.conn.Exec(`UPDATE alerts SET state = ?, resolved_at = ?, updated_at = ?
+		WHERE host_id = (SELECT id FROM hosts WHERE hostname = ?) AND state = ?`,
+		models.AlertResolved, at, at, hostname, models.AlertFiring)
+	return true, err
+}
+
+// RecommissionHost returns a decommissioned host to the offline state, from
+// which its agent brings it online again. It reports whether the host was
+// decommissioned.
+func (db *DB) RecommissionHost(hostname string, at time.Time) (bool, error) {
+	host, err := db.GetHost(hostname)
+	if err != nil {
+		return false, err
+	}
+	if host.Status != models.HostDecommissioned {
+		return false, nil
+	}
+	return db.setHostStatus(hostname, models.HostOffline, "recommissioned", at, true)
+}
+
+// DecommissionOfflineHosts decommissions hosts that have been offline and
+// unseen for longer than after, returning their hostnames. Hosts under
+// maintenance are left alone.
+func (db *DB) DecommissionOfflineHosts(after time.Duration) ([]string, error) {
+	now := time.Now()
+	cutoff := now.Add(-after)
+
+	covered, err := db.GetHostsInMaintenance(now)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.conn.Query(`SELECT h.hostname FROM hosts h
+		WHERE h.status = ? AND h.status_since < ? AND h.last_seen < ?`,
+		models.HostOffline, cutoff, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	var hostnames []string
+	for rows.Next() {
+		var hostname string
+		if err := rows.Scan(&hostname); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if !covered[hostname] {
+			hostnames = append(hostnames, hostname)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var decommissioned []string
+	for _, hostname := range hostnames {
+		changed, err := db.DecommissionHost(hostname, fmt.Sprintf("offline for more than %s", after), now)
+		if err != nil {
+			return decommissioned, fmt.Errorf("decommission %s: %w", hostname, err)
+		}
+		if changed {
+			decommissioned = append(decommissioned, hostname)
+		}
+	}
+	return decommissioned, nil
+}
+
+func (api *API) handleDeleteHost(w http.ResponseWriter, r *http.Request, hostname string) {
+	// A connected agent would recreate the host with its next report.
+	if api.server.IsConnected(hostname) {
+		http.Error(w, "Host is connected; stop its agent or decommission the host instead", http.StatusConflict)
+		return
+	}
+
+	err := api.db.DeleteHost(hostname)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Host not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error deleting host %s: %v", hostname, err)
+		http.Error(w, "Failed to delete host", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Deleted host %s", hostname)
+
+	respondJSON(w, http.StatusOK, map[string]string{
+		"message": "Host deleted successfully",
+	})
+}
+
+func (api *API) handleHostDecommission(w http.ResponseWriter, r *http.Request, hostname string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	changed, err := api.db.DecommissionHost(hostname, "decommissioned through the API", time.Now())
+	if err == sql.ErrNoRows {
+		http.Error(w, "Host not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error decommissioning host %s: %v", hostname, err)
+		http.Error(w, "Failed to decommission host", http.StatusInternalServerError)
+		return
+	}
+
+	message := "Host decommissioned successfully"
+	if !changed {
+		message = "Host is already decommissioned"
+	}
+	respondJSON(w, http.StatusOK, map[string]string{
+		"message": message,
+	})
+}
+
+func (api *API) handleHostRecommission(w http.ResponseWriter, r *http.Request, hostname string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	changed, err := api.db.RecommissionHost(hostname, time.Now())
+	if err == sql.ErrNoRows {
+		http.Error(w, "Host not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error recommissioning host %s: %v", hostname, err)
+		http.Error(w, "Failed to recommission host", http.StatusInternalServerError)
+		return
+	}
+
+	message := "Host recommissioned successfully"
+	if !changed {
+		message = "Host is not decommissioned"
+	}
+	respondJSON(w, http.StatusOK, map[string]string{
+		"message": message,
+	})
+}
@@ -0,0 +1,270 @@
+package commander
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metorial/sentinel/internal/models"
+)
+
+func TestDeleteHost(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	hostID := createTestHost(t, db, "web-1")
+	createTestHost(t, db, "web-2")
+	now := time.Now()
+
+	if err := db.InsertUsage(&models.HostUsage{HostID: hostID, Timestamp: now, CPUPercent: 10}); err != nil {
+		t.Fatalf("Failed to insert usage: %v", err)
+	}
+	if err := db.AddHostTag("web-1", "prod"); err != nil {
+		t.Fatalf("Failed to add tag: %v", err)
+	}
+	err := db.RecordSensors("web-1", now, []models.Sensor{
+		{Chip: "coretemp", Sensor: "temp1", Kind: "temperature", Value: 40},
+	})
+	if err != nil {
+		t.Fatalf("Failed to record sensors: %v", err)
+	}
+	if _, err := db.FireAlert(hostID, AlertKindClockSkew, "clock", "clock is 1m0s ahead of the controller", now); err != nil {
+		t.Fatalf("Failed to fire alert: %v", err)
+	}
+
+	if err := db.DeleteHost("web-1"); err != nil {
+		t.Fatalf("Failed to delete host: %v", err)
+	}
+
+	if _, err := db.GetHost("web-1"); err == nil {
+		t.Error("Expected host to be deleted")
+	}
+	for _, table := range []string{"host_usage", "host_tags", "host_sensors", "sensor_readings", "alerts"} {
+		var count int
+		if err := db.conn.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&count); err != nil {
+			t.Fatalf("Failed to count %s: %v", table, err)
+		}
+		if count != 0 {
+			t.Errorf("Expected %s to be empty, got %d rows", table, count)
+		}
+	}
+	if _, err := db.GetHost("web-2"); err != nil {
+		t.Errorf("Expected other host to remain, got %v", err)
+	}
+
+	if err := db.DeleteHost("web-1"); err == nil {
+		t.Error("Expected error deleting unknown host")
+	}
+}
+
+func TestDecommissionHost(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	hostID := createTestHost(t, db, "web-1")
+	createTestHost(t, db, "web-2")
+	now := time.Now()
+
+	if _, err := db.FireAlert(hostID, AlertKindClockSkew, "clock", "clock is 1m0s ahead of the controller", now); err != nil {
+		t.Fatalf("Failed to fire alert: %v", err)
+	}
+
+	changed, err := db.DecommissionHost("web-1", "retired", now)
+	if err != nil || !changed {
+		t.Fatalf("Expected host to be decommissioned, got %v, %v", changed, err)
+	}
+	changed, err = db.DecommissionHost("web-1", "retired", now)
+	if err != nil || changed {
+		t.Errorf("Expected no change decommissioning twice, got %v, %v", changed, err)
+	}
+
+	alerts, err := db.GetAlerts(models.AlertFiring)
+	if err != nil {
+		t.Fatalf("Failed to get alerts: %v", err)
+	}
+	if len(alerts) != 0 {
+		t.Errorf("Expected alerts of decommissioned host to be resolved, got %+v", alerts)
+	}
+
+	// Reports from the agent don't bring the host back
+	if _, err := db.UpsertHost(&models.Host{Hostname: "web-1", LastSeen: now, Online: true}); err != nil {
+		t.Fatalf("Failed to upsert host: %v", err)
+	}
+	host, err := db.GetHost("web-1")
+	if err != nil {
+		t.Fatalf("Failed to get host: %v", err)
+	}
+	if host.Status != models.HostDecommissioned || host.Online {
+		t.Errorf("Expected host to stay decommissioned and offline, got %s, online %v", host.Status, host.Online)
+	}
+
+	// Nor do the alerts of an agent that is still connected
+	opened, err := db.FireAlert(hostID, AlertKindClockSkew, "clock", "clock is 1m0s ahead of the controller", now)
+	if err != nil || opened {
+		t.Errorf("Expected no alert for a decommissioned host, got %v, %v", opened, err)
+	}
+
+	stats, err := db.GetClusterStats()
+	if err != nil {
+		t.Fatalf("Failed to get stats: %v", err)
+	}
+	if stats["total_hosts"] != 1 || stats["decommissioned_hosts"] != 1 {
+		t.Errorf("Expected decommissioned host to be left out of stats, got %+v", stats)
+	}
+
+	changed, err = db.RecommissionHost("web-1", now)
+	if err != nil || !changed {
+		t.Fatalf("Expected host to be recommissioned, got %v, %v", changed, err)
+	}
+	host, err = db.GetHost("web-1")
+	if err != nil {
+		t.Fatalf("Failed to get host: %v", err)
+	}
+	if host.Status != models.HostOffline || host.StatusReason != "recommissioned" {
+		t.Errorf("Expected recommissioned host to be offline, got %s (%s)", host.Status, host.StatusReason)
+	}
+}
+
+func TestDecommissionOfflineHosts(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	old := time.Now().Add(-48 * time.Hour)
+	for _, hostname := range []string{"old-1", "recent-1", "online-1"} {
+		createTestHost(t, db, hostname)
+	}
+	if _, err := db.conn.Exec(`UPDATE hosts SET last_seen = ? WHERE hostname IN ('old-1', 'online-1')`, old); err != nil {
+		t.Fatalf("Failed to age hosts: %v", err)
+	}
+	if _, err := db.SetHostStatus("old-1", models.HostOffline, "no stream for 1m0s", old); err != nil {
+		t.Fatalf("Failed to set status: %v", err)
+	}
+	if _, err := db.SetHostStatus("recent-1", models.HostOffline, "agent closed the stream", time.Now()); err != nil {
+		t.Fatalf("Failed to set status: %v", err)
+	}
+	if _, err := db.SetHostStatus("online-1", models.HostOnline, "metrics received", old); err != nil {
+		t.Fatalf("Failed to set status: %v", err)
+	}
+
+	decommissioned, err := db.DecommissionOfflineHosts(24 * time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to decommission hosts: %v", err)
+	}
+	if len(decommissioned) != 1 || decommissioned[0] != "old-1" {
+		t.Fatalf("Expected only old-1 to be decommissioned, got %v", decommissioned)
+	}
+
+	host, err := db.GetHost("old-1")
+	if err != nil {
+		t.Fatalf("Failed to get host: %v", err)
+	}
+	if host.Status != models.HostDecommissioned || !strings.HasPrefix(host.StatusReason, "offline for more than") {
+		t.Errorf("Unexpected status %s (%s)", host.Status, host.StatusReason)
+	}
+}
+
+func TestHandleDecommission(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	server := NewServer(db)
+	api := NewAPI(db, server)
+	mux := http.NewServeMux()
+	api.RegisterRoutes(mux)
+
+	createTestHost(t, db, "web-1")
+	createTestHost(t, db, "web-2")
+
+	listHosts := func(query string) []models.Host {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/hosts"+query, nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var response struct {
+			Hosts []models.Host `json:"hosts"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return response.Hosts
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/hosts/web-1/decommission", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if hosts := listHosts(""); len(hosts) != 1 || hosts[0].Hostname != "web-2" {
+		t.Errorf("Expected decommissioned host to be hidden, got %+v", hosts)
+	}
+	if hosts := listHosts("?include_decommissioned=true"); len(hosts) != 2 {
+		t.Errorf("Expected 2 hosts including decommissioned, got %d", len(hosts))
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/hosts/web-1/recommission", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if hosts := listHosts(""); len(hosts) != 2 {
+		t.Errorf("Expected recommissioned host to be listed, got %d hosts", len(hosts))
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/hosts/unknown/decommission", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestHandleDeleteHost(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	server := NewServer(db)
+	api := NewAPI(db, server)
+	mux := http.NewServeMux()
+	api.RegisterRoutes(mux)
+
+	createTestHost(t, db, "web-1")
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/hosts/web-1", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := db.GetHost("web-1"); err == nil {
+		t.Error("Expected host to be deleted")
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/hosts/web-1", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+
+	// Hosts with a connected agent can't be deleted
+	createTestHost(t, db, "web-2")
+	stream := setupTestStream(t, server)
+	sendHello(t, stream, "web-2")
+	waitForStatus(t, db, "web-2", models.HostConnecting)
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/hosts/web-2", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409, got %d", w.Code)
+	}
+}
@@ -0,0 +1,79 @@
+package commander
+
+import (
+	"fmt"
+
+	"github.com/metorial/sentinel/internal/models"
+	"github.com/metorial/sentinel/internal/version"
+	pb "github.com/metorial/sentinel/proto"
+)
+
+// Agent capabilities understood by this controller.
+const (
+	CapabilityCommands  = "commands"
+	CapabilityConfig    = "config"
+	CapabilityInventory = "inventory"
+	CapabilityCgroups   = "cgroups"
+	CapabilityDocker    = "docker"
+	CapabilityChecks    = "checks"
+	CapabilityCerts     = "certificates"
+	CapabilitySystemd   = "systemd"
+	CapabilityLogs      = "logs"
+	CapabilitySensors   = "sensors"
+)
+
+var serverCapabilities = []string{
+	CapabilityCommands, CapabilityConfig, CapabilityInventory, CapabilityCgroups, CapabilityDocker,
+	CapabilityChecks, CapabilityCerts, CapabilitySystemd, CapabilityLogs, CapabilitySensors,
+}
+
+// checkProtocol returns an error describing why an agent speaking protocol
+// cannot be served, or nil if it is supported.
+func checkProtocol(protocol int32) error {
+	if protocol < version.MinProtocol || protocol > version.Protocol {
+		return fmt.Errorf("agent protocol version %d is not supported by controller %s (supported: %d-%d); upgrade the agent or controller",
+			protocol, version.Version, version.MinProtocol, version.Protocol)
+	}
+	return nil
+}
+
+// acceptCapabilities returns the agent capabilities the controller will use.
+func acceptCapabilities(offered []string) map[string]bool {
+	accepted := make(map[string]bool)
+	for _, c := range offered {
+		for _, supported := range serverCapabilities {
+			if c == supported {
+				accepted[c] = true
+			}
+		}
+	}
+	return accepted
+}
+
+func (a *agentStream) hasCapability(name string) bool {
+	return a.capabilities[name]
+}
+
+func (a *agentStream) welcome() *pb.Welcome {
+	w := &pb.Welcome{
+		ControllerVersion: version.Version,
+		ProtocolVersion:   version.Protocol,
+	}
+	for _, c := range serverCapabilities {
+		if a.capabilities[c] {
+			w.AcceptedCapabilities = append(w.AcceptedCapabilities, c)
+		}
+	}
+	return w
+}
+
+// isOutdated reports whether a host runs an agent older than the controller.
+// Agents newer than the controller, and dev builds on either side, are only
+// outdated if they speak an older protocol.
+func isOutdated(h *models.Host) bool {
+	if h.ProtocolVersion < version.Protocol {
+		return true
+	}
+	cmp, ok := version.Compare(h.AgentVersion, version.Version)
+	return ok && cmp < 0
+}
@@ -0,0 +1,232 @@
+package commander
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/metorial/sentinel/internal/models"
+)
+
+// hostSortKeys are the fields the hosts listing can be sorted by, mapped to
+// the value compared for each host. Hosts without usage data sort before
+// those with any.
+var hostSortKeys = map[string]func(h *models.Host) float64{
+	"hostname": func(h *models.Host) float64 { return 0 },
+	"cpu": func(h *models.Host) float64 {
+		if h.CPUPercent == nil {
+			return -1
+		}
+		return *h.CPUPercent
+	},
+	"memory": func(h *models.Host) float64 {
+		return usagePercent(h.UsedMemoryBytes, h.TotalMemoryBytes)
+	},
+	"storage": func(h *models.Host) float64 {
+		return usagePercent(h.UsedStorageBytes, h.TotalStorageBytes)
+	},
+	"last_seen": func(h *models.Host) float64 {
+		// Microseconds are exact in a float64
+		return float64(h.LastSeen.UnixMicro())
+	},
+}
+
+func usagePercent(used *int64, total int64) float64 {
+	if used == nil || total <= 0 {
+		return -1
+	}
+	return float64(*used) / float64(total) * 100
+}
+
+// hostQuery is a filtered, sorted page of the hosts listing.
+type hostQuery struct {
+	tags     []string
+	selector *Selector
+	online   *bool
+	outdated *bool
+	status   string
+	search   string
+	// sort is a key of hostSortKeys, prefixed with "-" for descending order
+	sort                  string
+	limit                 int
+	after                 *hostCursor
+	includeDecommissioned bool
+}
+
+// hostCursor is the position after the last host of a page: its sort value
+// and hostname, which breaks ties. It is only valid for the same sort.
+type hostCursor struct {
+	Sort     string  `json:"s"`
+	Value    float64 `json:"v"`
+	Hostname string  `json:"h"`
+}
+
+func (c *hostCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeHostCursor(s string) (*hostCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c hostCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// parseHostQuery parses the query parameters of the hosts listing. Tags may
+// be repeated or comma separated.
+func parseHostQuery(values url.Values) (*hostQuery, error) {
+	q := &hostQuery{
+		status:                values.Get("status"),
+		search:                strings.ToLower(values.Get("q")),
+		sort:                  values.Get("sort"),
+		includeDecommissioned: values.Get("include_decommissioned") == "true",
+	}
+
+	for _, tags := range values["tag"] {
+		for _, tag := range strings.Split(tags, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				q.tags = append(q.tags, tag)
+			}
+		}
+	}
+
+	selector, err := ParseSelector(values.Get("selector"))
+	if err != nil {
+		return nil, err
+	}
+	q.selector = selector
+
+	if online := values.Get("online"); online != "" {
+		b, err := strconv.ParseBool(online)
+		if err != nil {
+			return nil, fmt.Errorf("online must be true or false")
+		}
+		q.online = &b
+	}
+
+	if outdated := values.Get("outdated"); outdated != "" {
+		b, err := strconv.ParseBool(outdated)
+		if err != nil {
+			return nil, fmt.Errorf("outdated must be true or false")
+		}
+		q.outdated = &b
+	}
+
+	switch q.status {
+	case "", models.HostConnecting, models.HostOnline, models.HostStale, models.HostOffline:
+	case models.HostDecommissioned:
+		q.includeDecommissioned = true
+	default:
+		return nil, fmt.Errorf("invalid status %q", q.status)
+	}
+
+	if q.sort == "" {
+		q.sort = "hostname"
+	}
+	if _, ok := hostSortKeys[strings.TrimPrefix(q.sort, "-")]; !ok {
+		return nil, fmt.Errorf("invalid sort %q (want hostname, cpu, memory, storage or last_seen, optionally prefixed with -)", q.sort)
+	}
+
+	if limitStr := values.Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 1000 {
+			q.limit = l
+		}
+	}
+
+	if cursor := values.Get("cursor"); cursor != "" {
+		after, err := decodeHostCursor(cursor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		if after.Sort != q.sort {
+			return nil, fmt.Errorf("cursor was issued for sort %q", after.Sort)
+		}
+		q.after = after
+	}
+
+	return q, nil
+}
+
+func (q *hostQuery) matches(h *models.Host, tags []string) bool {
+	if h.Status == models.HostDecommissioned && !q.includeDecommissioned {
+		return false
+	}
+	if !q.selector.Matches(tags) {
+		return false
+	}
+	if q.online != nil && h.Online != *q.online {
+		return false
+	}
+	if q.outdated != nil && isOutdated(h) != *q.outdated {
+		return false
+	}
+	if q.status != "" && h.Status != q.status {
+		return false
+	}
+	if q.search != "" && !strings.Contains(strings.ToLower(h.Hostname), q.search) &&
+		!strings.Contains(h.IP, q.search) {
+		return false
+	}
+	return true
+}
+
+// cursor returns the position of h in the sort order.
+func (q *hostQuery) cursor(h *models.Host) hostCursor {
+	return hostCursor{
+		Sort:     q.sort,
+		Value:    hostSortKeys[strings.TrimPrefix(q.sort, "-")](h),
+		Hostname: h.Hostname,
+	}
+}
+
+// less reports whether position a comes before b in the sort order.
+func (q *hostQuery) less(a, b hostCursor) bool {
+	if strings.HasPrefix(q.sort, "-") {
+		a, b = b, a
+	}
+	if a.Value != b.Value {
+		return a.Value < b.Value
+	}
+	return a.Hostname < b.Hostname
+}
+
+// apply filters and sorts hosts, given the tags of each by host id, returning
+// the requested page, the number of hosts matching the filters and the cursor
+// of the next page, empty on the last page.
+func (q *hostQuery) apply(hosts []models.Host, tags map[int64][]string) ([]models.Host, int, string) {
+	matched := make([]models.Host, 0, len(hosts))
+	for _, h := range hosts {
+		if q.matches(&h, tags[h.ID]) {
+			matched = append(matched, h)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return q.less(q.cursor(&matched[i]), q.cursor(&matched[j]))
+	})
+
+	page := matched
+	if q.after != nil {
+		start := sort.Search(len(page), func(i int) bool {
+			return q.less(*q.after, q.cursor(&page[i]))
+		})
+		page = page[start:]
+	}
+
+	var next string
+	if q.limit > 0 && len(page) > q.limit {
+		page = page[:q.limit]
+		last := q.cursor(&page[len(page)-1])
+		next = last.encode()
+	}
+	return page, len(matched), next
+}
@@ -0,0 +1,180 @@
+package commander
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/metorial/sentinel/internal/models"
+	"github.com/metorial/sentinel/internal/version"
+)
+
+func TestParseHostQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		wantErr bool
+	}{
+		{"", false},
+		{"tag=web,db&tag=prod&online=false&q=web&sort=-cpu&limit=10", false},
+		{"online=maybe", true},
+		{"outdated=soon", true},
+		{"status=sleeping", true},
+		{"sort=name", true},
+		{"cursor=not-a-cursor", true},
+		{"sort=cpu&cursor=" + (&hostCursor{Sort: "memory"}).encode(), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			values, _ := url.ParseQuery(tt.query)
+			_, err := parseHostQuery(values)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseHostQuery(%q) error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			}
+		})
+	}
+
+	values, _ := url.ParseQuery("tag=web,db&tag=prod")
+	q, err := parseHostQuery(values)
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+	if len(q.tags) != 3 || q.tags[0] != "web" || q.tags[2] != "prod" {
+		t.Errorf("Expected tags web, db and prod, got %v", q.tags)
+	}
+}
+
+func TestHandleHostsQuery(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	server := NewServer(db)
+	api := NewAPI(db, server)
+	mux := http.NewServeMux()
+	api.RegisterRoutes(mux)
+
+	now := time.Now()
+	for i, host := range []struct {
+		hostname string
+		cpu      float64
+		tag      string
+	}{
+		{"web-1", 50, "web"},
+		{"web-2", 90, "web"},
+		{"db-1", 10, "db"},
+		{"db-2", 70, "db"},
+		{"cache-1", 30, ""},
+	} {
+		hostID := createTestHost(t, db, host.hostname)
+		if _, err := db.SetHostStatus(host.hostname, models.HostOnline, "metrics received", now); err != nil {
+			t.Fatalf("Failed to set status: %v", err)
+		}
+		err := db.InsertUsage(&models.HostUsage{HostID: hostID, Timestamp: now, CPUPercent: host.cpu,
+			UsedMemoryBytes: int64(i+1) * 1 << 30})
+		if err != nil {
+			t.Fatalf("Failed to insert usage: %v", err)
+		}
+		if host.tag != "" {
+			if err := db.AddHostTag(host.hostname, host.tag); err != nil {
+				t.Fatalf("Failed to add tag: %v", err)
+			}
+		}
+	}
+	if _, err := db.SetHostStatus("db-2", models.HostOffline, "agent closed the stream", now); err != nil {
+		t.Fatalf("Failed to set status: %v", err)
+	}
+	// Every host but web-1 runs the agent of the controller
+	for _, hostname := range []string{"web-2", "db-1", "db-2", "cache-1"} {
+		if err := db.SetHostAgentInfo(hostname, version.Version, version.Protocol, "linux", "amd64", nil); err != nil {
+			t.Fatalf("Failed to set agent info: %v", err)
+		}
+	}
+
+	list := func(query string) (hostnames []string, total int, next string) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/hosts?"+query, nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 for %q, got %d: %s", query, w.Code, w.Body.String())
+		}
+		var response struct {
+			Hosts      []models.Host `json:"hosts"`
+			Total      int           `json:"total"`
+			NextCursor string        `json:"next_cursor"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		for _, h := range response.Hosts {
+			hostnames = append(hostnames, h.Hostname)
+		}
+		return hostnames, response.Total, response.NextCursor
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"cache-1", "db-1", "db-2", "web-1", "web-2"}},
+		{"tag=web", []string{"web-1", "web-2"}},
+		{"tag=web,db&online=true", []string{"db-1", "web-1", "web-2"}},
+		{"status=offline", []string{"db-2"}},
+		{"outdated=true", []string{"web-1"}},
+		{"outdated=false&tag=web", []string{"web-2"}},
+		{"q=DB", []string{"db-1", "db-2"}},
+		{"q=192.168", []string{"cache-1", "db-1", "db-2", "web-1", "web-2"}},
+		{"sort=-cpu", []string{"web-2", "db-2", "web-1", "cache-1", "db-1"}},
+		{"sort=memory", []string{"web-1", "web-2", "db-1", "db-2", "cache-1"}},
+	}
+	for _, tt := range tests {
+		got, _, _ := list(tt.query)
+		if len(got) != len(tt.want) {
+			t.Errorf("%q: expected %v, got %v", tt.query, tt.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%q: expected %v, got %v", tt.query, tt.want, got)
+				break
+			}
+		}
+	}
+
+	// Page through the hosts by descending CPU
+	var paged []string
+	query := "sort=-cpu&limit=2"
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("Expected pagination to end")
+		}
+		hostnames, total, next := list(query)
+		if total != 5 {
+			t.Errorf("Expected total 5, got %d", total)
+		}
+		paged = append(paged, hostnames...)
+		if next == "" {
+			break
+		}
+		query = "sort=-cpu&limit=2&cursor=" + next
+	}
+	want := []string{"web-2", "db-2", "web-1", "cache-1", "db-1"}
+	if len(paged) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, paged)
+	}
+	for i := range want {
+		if paged[i] != want[i] {
+			t.Fatalf("Expected %v, got %v", want, paged)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/hosts?sort=bogus", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
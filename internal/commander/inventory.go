@@ -0,0 +1,240 @@
+package commander
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/metorial/sentinel/internal/models"
+	pb "github.com/metorial/sentinel/proto"
+)
+
+const (
+	defaultInventoryHistory = 20
+	maxInventoryHistory     = 500
+)
+
+// RecordInventory stores inventory as a new version for the host if it
+// differs from the latest recorded version. It returns the latest record and
+// whether a new version was created.
+func (db *DB) RecordInventory(hostname string, inventory *models.Inventory, collectedAt time.Time) (*models.InventoryRecord, bool, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	var hostID int64
+	if err := tx.QueryRow(`SELECT id FROM hosts WHERE hostname = ?`, hostname).Scan(&hostID); err != nil {
+		return nil, false, err
+	}
+
+	latest, err := scanInventory(tx.QueryRow(`SELECT `+inventoryColumns+` FROM host_inventory i
+		WHERE i.host_id = ? ORDER BY i.version DESC LIMIT 1`, hostID))
+	if err != nil && err != sql.ErrNoRows {
+		return nil, false, err
+	}
+
+	var changes []string
+	version := 1
+	if latest != nil {
+		changes = diffInventory(&latest.Inventory, inventory)
+		if len(changes) == 0 {
+			return latest, false, nil
+		}
+		version = latest.Version + 1
+	}
+
+	data, err := json.Marshal(inventory)
+	if err != nil {
+		return nil, false, err
+	}
+	changesJSON, err := json.Marshal(nonNil(changes))
+	if err != nil {
+		return nil, false, err
+	}
+
+	record := &models.InventoryRecord{
+		HostID:      hostID,
+		Version:     version,
+		Inventory:   *inventory,
+		Changes:     nonNil(changes),
+		CollectedAt: collectedAt,
+	}
+	err = tx.QueryRow(`INSERT INTO host_inventory (host_id, version, data, changes, collected_at)
+		VALUES (?, ?, ?, ?, ?) RETURNING id, created_at`,
+		hostID, version, string(data), string(changesJSON), collectedAt).Scan(&record.ID, &record.CreatedAt)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return record, true, tx.Commit()
+}
+
+const inventoryColumns = `i.id, i.host_id, i.version, i.data, i.changes, i.collected_at, i.created_at`
+
+func scanInventory(row rowScanner) (*models.InventoryRecord, error) {
+	var r models.InventoryRecord
+	var data, changes string
+	if err := row.Scan(&r.ID, &r.HostID, &r.Version, &data, &changes, &r.CollectedAt, &r.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(data), &r.Inventory); err != nil {
+		return nil, fmt.Errorf("decode inventory %d: %w", r.ID, err)
+	}
+	if err := json.Unmarshal([]byte(changes), &r.Changes); err != nil {
+		return nil, fmt.Errorf("decode inventory changes %d: %w", r.ID, err)
+	}
+	return &r, nil
+}
+
+// GetInventoryHistory retrieves up to limit inventory versions of a host,
+// newest first
+func (db *DB) GetInventoryHistory(hostname string, limit int) ([]models.InventoryRecord, error) {
+	query := `SELECT ` + inventoryColumns + ` FROM host_inventory i
+	          JOIN hosts h ON i.host_id = h.id
+	          WHERE h.hostname = ?
+	          ORDER BY i.version DESC
+	          LIMIT ?`
+	rows, err := db.conn.Query(query, hostname, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []models.InventoryRecord
+	for rows.Next() {
+		r, err := scanInventory(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, *r)
+	}
+	return records, rows.Err()
+}
+
+// diffInventory returns the names of the fields that differ between two
+// inventories. Network interfaces are compared individually and reported as
+// "interfaces.<name>".
+func diffInventory(old, new *models.Inventory) []string {
+	var changes []string
+	field := func(name string, a, b interface{}) {
+		if !reflect.DeepEqual(a, b) {
+			changes = append(changes, name)
+		}
+	}
+
+	field("os_name", old.OSName, new.OSName)
+	field("os_version", old.OSVersion, new.OSVersion)
+	field("kernel_version", old.KernelVersion, new.KernelVersion)
+	field("arch", old.Arch, new.Arch)
+	field("cpu_model", old.CPUModel, new.CPUModel)
+	field("virtualization", old.Virtualization, new.Virtualization)
+	field("container", old.Container, new.Container)
+	field("boot_time", old.BootTime.Unix(), new.BootTime.Unix())
+	field("timezone", old.Timezone, new.Timezone)
+
+	oldIfaces := make(map[string]models.NetworkInterface, len(old.Interfaces))
+	for _, iface := range old.Interfaces {
+		oldIfaces[iface.Name] = iface
+	}
+	seen := make(map[string]bool, len(new.Interfaces))
+	for _, iface := range new.Interfaces {
+		seen[iface.Name] = true
+		prev, ok := oldIfaces[iface.Name]
+		if !ok || prev.MAC != iface.MAC || !reflect.DeepEqual(nonNil(prev.Addresses), nonNil(iface.Addresses)) {
+			changes = append(changes, "interfaces."+iface.Name)
+		}
+	}
+	for _, iface := range old.Interfaces {
+		if !seen[iface.Name] {
+			changes = append(changes, "interfaces."+iface.Name)
+		}
+	}
+
+	return changes
+}
+
+func inventoryFromProto(inv *pb.Inventory) *models.Inventory {
+	inventory := &models.Inventory{
+		OSName:         inv.OsName,
+		OSVersion:      inv.OsVersion,
+		KernelVersion:  inv.KernelVersion,
+		Arch:           inv.Arch,
+		CPUModel:       inv.CpuModel,
+		Virtualization: inv.Virtualization,
+		Container:      inv.Container,
+		BootTime:       time.Unix(inv.BootTime, 0).UTC(),
+		Timezone:       inv.Timezone,
+		Interfaces:     []models.NetworkInterface{},
+	}
+	for _, iface := range inv.Interfaces {
+		inventory.Interfaces = append(inventory.Interfaces, models.NetworkInterface{
+			Name:      iface.Name,
+			MAC:       iface.Mac,
+			Addresses: nonNil(iface.Addresses),
+		})
+	}
+	return inventory
+}
+
+func (s *Server) handleInventory(hostname string, inv *pb.Inventory) error {
+	record, changed, err := s.db.RecordInventory(hostname, inventoryFromProto(inv), time.Unix(inv.Timestamp, 0))
+	if err != nil {
+		return fmt.Errorf("record inventory: %w", err)
+	}
+	if changed && record.Version > 1 {
+		log.Printf("Inventory of %s changed (version %d): %v", hostname, record.Version, record.Changes)
+	}
+	if changed {
+		for _, h := range s.applyTagRules(hostname) {
+			s.PushConfig(h)
+			s.PushChecks(h)
+		}
+	}
+	return nil
+}
+
+func (api *API) handleHostInventory(w http.ResponseWriter, r *http.Request, hostname string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, err := api.db.GetHost(hostname); err == sql.ErrNoRows {
+		http.Error(w, "Host not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("Error getting host %s: %v", hostname, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	limit := defaultInventoryHistory
+	if limitStr := r.URL.Query().Get("history"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= maxInventoryHistory {
+			limit = l
+		}
+	}
+
+	history, err := api.db.GetInventoryHistory(hostname, limit)
+	if err != nil {
+		log.Printf("Error getting inventory for %s: %v", hostname, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if len(history) == 0 {
+		http.Error(w, "No inventory reported for host", http.StatusNotFound)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"inventory": history[0],
+		"history":   history,
+	})
+}
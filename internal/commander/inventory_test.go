@@ -0,0 +1,136 @@
+package commander
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/metorial/sentinel/internal/models"
+)
+
+func testInventory() *models.Inventory {
+	return &models.Inventory{
+		OSName:        "ubuntu",
+		OSVersion:     "24.04",
+		KernelVersion: "6.8.0-45-generic",
+		Arch:          "x86_64",
+		CPUModel:      "AMD EPYC 7763 64-Core Processor",
+		BootTime:      time.Unix(1733000000, 0).UTC(),
+		Timezone:      "Europe/Amsterdam",
+		Interfaces: []models.NetworkInterface{
+			{Name: "eth0", MAC: "52:54:00:12:34:56", Addresses: []string{"10.0.0.5/24", "fe80::1/64"}},
+		},
+	}
+}
+
+func TestRecordInventory(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	createTestHost(t, db, "web-1")
+
+	record, changed, err := db.RecordInventory("web-1", testInventory(), time.Now())
+	if err != nil {
+		t.Fatalf("Failed to record inventory: %v", err)
+	}
+	if !changed || record.Version != 1 {
+		t.Errorf("Expected new version 1, got version %d (changed: %v)", record.Version, changed)
+	}
+
+	record, changed, err = db.RecordInventory("web-1", testInventory(), time.Now())
+	if err != nil {
+		t.Fatalf("Failed to record inventory: %v", err)
+	}
+	if changed || record.Version != 1 {
+		t.Errorf("Expected unchanged version 1, got version %d (changed: %v)", record.Version, changed)
+	}
+
+	updated := testInventory()
+	updated.KernelVersion = "6.8.0-49-generic"
+	updated.Interfaces = append(updated.Interfaces, models.NetworkInterface{Name: "eth1", MAC: "52:54:00:12:34:57"})
+
+	record, changed, err = db.RecordInventory("web-1", updated, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to record inventory: %v", err)
+	}
+	if !changed || record.Version != 2 {
+		t.Errorf("Expected new version 2, got version %d (changed: %v)", record.Version, changed)
+	}
+
+	history, err := db.GetInventoryHistory("web-1", 10)
+	if err != nil {
+		t.Fatalf("Failed to get inventory history: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("Expected 2 versions, got %d", len(history))
+	}
+
+	latest := history[0]
+	if latest.Inventory.KernelVersion != "6.8.0-49-generic" {
+		t.Errorf("Expected latest kernel 6.8.0-49-generic, got %s", latest.Inventory.KernelVersion)
+	}
+	if len(latest.Changes) != 2 || latest.Changes[0] != "kernel_version" || latest.Changes[1] != "interfaces.eth1" {
+		t.Errorf("Expected changes [kernel_version interfaces.eth1], got %v", latest.Changes)
+	}
+
+	if _, _, err := db.RecordInventory("unknown", testInventory(), time.Now()); err == nil {
+		t.Error("Expected error for unknown host")
+	}
+}
+
+func TestHandleHostInventory(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	server := NewServer(db)
+	api := NewAPI(db, server)
+	mux := http.NewServeMux()
+	api.RegisterRoutes(mux)
+
+	createTestHost(t, db, "web-1")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/hosts/web-1/inventory", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 before inventory is reported, got %d", w.Code)
+	}
+
+	if _, _, err := db.RecordInventory("web-1", testInventory(), time.Now()); err != nil {
+		t.Fatalf("Failed to record inventory: %v", err)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/hosts/web-1/inventory", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Inventory models.InventoryRecord   `json:"inventory"`
+		History   []models.InventoryRecord `json:"history"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if response.Inventory.Version != 1 || response.Inventory.Inventory.OSName != "ubuntu" {
+		t.Errorf("Unexpected inventory: %+v", response.Inventory)
+	}
+	if len(response.History) != 1 {
+		t.Errorf("Expected 1 history entry, got %d", len(response.History))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/hosts/unknown/inventory", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown host, got %d", w.Code)
+	}
+}
@@ -0,0 +1,321 @@
+package commander
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/metorial/sentinel/internal/models"
+)
+
+// Labels are key/value pairs on a host, at most one value per key. Before
+// they existed, labels were tags of the form key:value, and everything that
+// matches tags (selectors, profiles, maintenance windows and the tag
+// endpoints) still sees a label as that tag through the host_tag_names view.
+
+// validateLabel checks that a label can be written as the tag key:value and
+// picked by a selector without quoting its key
+func validateLabel(key, value string) error {
+	if key == "" {
+		return fmt.Errorf("label key is required")
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] == ':' || !isSelectorIdentChar(key[i]) {
+			return fmt.Errorf("label key %q may only contain letters, digits and \"._-/\"", key)
+		}
+	}
+	if value == "" {
+		return fmt.Errorf("label %s needs a value", key)
+	}
+	return nil
+}
+
+// splitLabelTag splits a tag of the form key:value into a label. Tags without
+// a valid label key or with an empty value are plain tags.
+func splitLabelTag(tag string) (key, value string, ok bool) {
+	key, value, ok = strings.Cut(tag, ":")
+	if !ok || validateLabel(key, value) != nil {
+		return "", "", false
+	}
+	return key, value, true
+}
+
+// splitLabelTags moves tags of the form key:value to labels. A tag for a key
+// the host already has a label for stays a tag.
+func (db *DB) splitLabelTags() error {
+	rows, err := db.conn.Query(`SELECT ht.host_id, ht.tag_id, t.name FROM host_tags ht
+		JOIN tags t ON ht.tag_id = t.id
+		WHERE t.name LIKE '%:%'
+		ORDER BY ht.host_id, t.name`)
+	if err != nil {
+		return err
+	}
+
+	type hostTag struct {
+		hostID, tagID int64
+		name          string
+	}
+	var tags []hostTag
+	for rows.Next() {
+		var t hostTag
+		if err := rows.Scan(&t.hostID, &t.tagID, &t.name); err != nil {
+			rows.Close()
+			return err
+		}
+		tags = append(tags, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, t := range tags {
+		key, value, ok := splitLabelTag(t.name)
+		if !ok {
+			continue
+		}
+
+		result, err := tx.Exec(`INSERT INTO host_labels (host_id, key, value) VALUES (?, ?, ?)
+		                        ON CONFLICT (host_id, key) DO NOTHING`, t.hostID, key, value)
+		if err != nil {
+			return fmt.Errorf("label host %d with %s: %w", t.hostID, t.name, err)
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			log.Printf("Keeping tag %s of host %d as a tag: the host already has a label %s", t.name, t.hostID, key)
+			continue
+		}
+
+		if _, err := tx.Exec(`DELETE FROM host_tags WHERE host_id = ? AND tag_id = ?`, t.hostID, t.tagID); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM tags WHERE name LIKE '%:%'
+	                      AND id NOT IN (SELECT tag_id FROM host_tags)`); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetHostLabel sets the label key of a host to value
+func (db *DB) SetHostLabel(hostname, key, value string) error {
+	result, err := db.conn.Exec(`INSERT INTO host_labels (host_id, key, value, updated_at)
+	                             SELECT id, ?, ?, CURRENT_TIMESTAMP FROM hosts WHERE hostname = ?
+	                             ON CONFLICT (host_id, key) DO UPDATE SET
+	                               value = excluded.value,
+	                               updated_at = excluded.updated_at`, key, value, hostname)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RemoveHostLabel removes the label key from a host
+func (db *DB) RemoveHostLabel(hostname, key string) error {
+	result, err := db.conn.Exec(`DELETE FROM host_labels
+	                             WHERE host_id = (SELECT id FROM hosts WHERE hostname = ?) AND key = ?`,
+		hostname, key)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetHostLabels retrieves the labels of a host
+func (db *DB) GetHostLabels(hostname string) (map[string]string, error) {
+	rows, err := db.conn.Query(`SELECT l.key, l.value FROM host_labels l
+	                            JOIN hosts h ON l.host_id = h.id
+	                            WHERE h.hostname = ?`, hostname)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	labels := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		labels[key] = value
+	}
+	return labels, rows.Err()
+}
+
+// GetLabels retrieves every label key in use with the number of hosts with
+// each of its values
+func (db *DB) GetLabels() ([]models.LabelSummary, error) {
+	rows, err := db.conn.Query(`SELECT key, value, COUNT(*) FROM host_labels
+	                            GROUP BY key, value
+	                            ORDER BY key, value`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	labels := []models.LabelSummary{}
+	for rows.Next() {
+		var key, value string
+		var hosts int
+		if err := rows.Scan(&key, &value, &hosts); err != nil {
+			return nil, err
+		}
+		if len(labels) == 0 || labels[len(labels)-1].Key != key {
+			labels = append(labels, models.LabelSummary{Key: key, Values: make(map[string]int)})
+		}
+		labels[len(labels)-1].Values[value] = hosts
+	}
+	return labels, rows.Err()
+}
+
+// GetLabelValues retrieves the value of the label key of every host that has
+// it, by host id
+func (db *DB) GetLabelValues(key string) (map[int64]string, error) {
+	rows, err := db.conn.Query(`SELECT host_id, value FROM host_labels WHERE key = ?`, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := make(map[int64]string)
+	for rows.Next() {
+		var hostID int64
+		var value string
+		if err := rows.Scan(&hostID, &value); err != nil {
+			return nil, err
+		}
+		values[hostID] = value
+	}
+	return values, rows.Err()
+}
+
+func (api *API) handleLabels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	labels, err := api.db.GetLabels()
+	if err != nil {
+		log.Printf("Error getting labels: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"labels": labels,
+		"count":  len(labels),
+	})
+}
+
+func (api *API) handleHostLabels(w http.ResponseWriter, r *http.Request, hostname, key string) {
+	if key == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if _, err := api.db.GetHost(hostname); err == sql.ErrNoRows {
+			http.Error(w, "Host not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Printf("Error getting host %s: %v", hostname, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		labels, err := api.db.GetHostLabels(hostname)
+		if err != nil {
+			log.Printf("Error getting labels for %s: %v", hostname, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"hostname": hostname,
+			"labels":   labels,
+			"count":    len(labels),
+		})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		var req struct {
+			Value string `json:"value"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := validateLabel(key, req.Value); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err := api.db.SetHostLabel(hostname, key, req.Value)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Host not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error setting label %s on %s: %v", key, hostname, err)
+			http.Error(w, "Failed to set label", http.StatusInternalServerError)
+			return
+		}
+		go api.server.PushConfig(hostname)
+		go api.server.PushChecks(hostname)
+		respondJSON(w, http.StatusOK, map[string]string{
+			"message": "Label set successfully",
+		})
+
+	case http.MethodDelete:
+		err := api.db.RemoveHostLabel(hostname, key)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Label not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error removing label %s from %s: %v", key, hostname, err)
+			http.Error(w, "Failed to remove label", http.StatusInternalServerError)
+			return
+		}
+		go api.server.PushConfig(hostname)
+		go api.server.PushChecks(hostname)
+		respondJSON(w, http.StatusOK, map[string]string{
+			"message": "Label removed successfully",
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,277 @@
+package commander
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSplitLabelTags(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	webID := createTestHost(t, db, "web-1")
+	dbID := createTestHost(t, db, "db-1")
+
+	// Tag the hosts the way they were tagged before labels existed
+	for _, tag := range []struct {
+		hostID int64
+		name   string
+	}{
+		{webID, "web"},
+		{webID, "env:prod"},
+		{webID, "url:http://web-1"},
+		{dbID, "env:prod"},
+		{dbID, "env:staging"},
+		{dbID, "odd key:value"},
+	} {
+		tagID, err := db.GetOrCreateTag(tag.name)
+		if err != nil {
+			t.Fatalf("Failed to create tag: %v", err)
+		}
+		if _, err := db.conn.Exec(`INSERT INTO host_tags (host_id, tag_id) VALUES (?, ?)`, tag.hostID, tagID); err != nil {
+			t.Fatalf("Failed to tag host: %v", err)
+		}
+	}
+
+	if err := db.splitLabelTags(); err != nil {
+		t.Fatalf("Failed to split tags: %v", err)
+	}
+	// A second run finds nothing left to move
+	if err := db.splitLabelTags(); err != nil {
+		t.Fatalf("Failed to split tags again: %v", err)
+	}
+
+	labels, err := db.GetHostLabels("web-1")
+	if err != nil {
+		t.Fatalf("Failed to get labels: %v", err)
+	}
+	if len(labels) != 2 || labels["env"] != "prod" || labels["url"] != "http://web-1" {
+		t.Errorf("Unexpected labels of web-1: %v", labels)
+	}
+
+	labels, err = db.GetHostLabels("db-1")
+	if err != nil {
+		t.Fatalf("Failed to get labels: %v", err)
+	}
+	if len(labels) != 1 || labels["env"] != "prod" {
+		t.Errorf("Unexpected labels of db-1: %v", labels)
+	}
+
+	// The conflicting and invalid tags stay tags, and labels still read as tags
+	tags, err := db.GetHostTags("db-1")
+	if err != nil {
+		t.Fatalf("Failed to get tags: %v", err)
+	}
+	want := []string{"env:prod", "env:staging", "odd key:value"}
+	if len(tags) != len(want) {
+		t.Fatalf("Expected tags %v, got %v", want, tags)
+	}
+	for i := range want {
+		if tags[i] != want[i] {
+			t.Errorf("Expected tags %v, got %v", want, tags)
+			break
+		}
+	}
+
+	// The tags list keeps listing the tags moved to labels
+	all, err := db.GetAllTags()
+	if err != nil {
+		t.Fatalf("Failed to get tags: %v", err)
+	}
+	hosts := make(map[string]int)
+	for _, tag := range all {
+		hosts[tag.Name] = tag.Hosts
+	}
+	for name, count := range map[string]int{"web": 1, "env:prod": 2, "env:staging": 1, "url:http://web-1": 1, "odd key:value": 1} {
+		if hosts[name] != count {
+			t.Errorf("Expected tag %s listed with %d hosts, got %v", name, count, all)
+		}
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i-1].Name > all[i].Name {
+			t.Errorf("Expected tags sorted by name, got %v", all)
+			break
+		}
+	}
+	var tagIDs int
+	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM tags WHERE name IN ('env:prod', 'url:http://web-1')`).Scan(&tagIDs); err != nil || tagIDs != 0 {
+		t.Errorf("Expected the moved tags deleted from the tags table, got %d, %v", tagIDs, err)
+	}
+}
+
+func TestHostTagsAsLabels(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	createTestHost(t, db, "web-1")
+
+	if err := db.AddHostTag("web-1", "env:prod"); err != nil {
+		t.Fatalf("Failed to add tag: %v", err)
+	}
+	if err := db.AddHostTag("web-1", "env:staging"); err != nil {
+		t.Fatalf("Failed to add tag: %v", err)
+	}
+	if labels, _ := db.GetHostLabels("web-1"); labels["env"] != "staging" {
+		t.Errorf("Expected a key:value tag to set the label, got %v", labels)
+	}
+
+	hosts, err := db.GetHostsByTags([]string{"env:staging"})
+	if err != nil || len(hosts) != 1 {
+		t.Errorf("Expected the label to match as a tag, got %d hosts, %v", len(hosts), err)
+	}
+
+	// Only the current value removes the label
+	if err := db.RemoveHostTag("web-1", "env:prod"); err != nil {
+		t.Fatalf("Failed to remove tag: %v", err)
+	}
+	if labels, _ := db.GetHostLabels("web-1"); labels["env"] != "staging" {
+		t.Errorf("Expected label to stay, got %v", labels)
+	}
+	if err := db.RemoveHostTag("web-1", "env:staging"); err != nil {
+		t.Fatalf("Failed to remove tag: %v", err)
+	}
+	if labels, _ := db.GetHostLabels("web-1"); len(labels) != 0 {
+		t.Errorf("Expected no labels, got %v", labels)
+	}
+}
+
+func TestValidateLabel(t *testing.T) {
+	tests := []struct {
+		key, value string
+		wantErr    bool
+	}{
+		{"env", "prod", false},
+		{"team.example.com/owner", "alice smith", false},
+		{"", "prod", true},
+		{"env", "", true},
+		{"env:x", "prod", true},
+		{"my env", "prod", true},
+	}
+
+	for _, tt := range tests {
+		err := validateLabel(tt.key, tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validateLabel(%q, %q) error = %v, wantErr %v", tt.key, tt.value, err, tt.wantErr)
+		}
+	}
+}
+
+func TestHandleHostLabels(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	server := NewServer(db)
+	api := NewAPI(db, server)
+	mux := http.NewServeMux()
+	api.RegisterRoutes(mux)
+
+	createTestHost(t, db, "web-1")
+	createTestHost(t, db, "web-2")
+	createTestHost(t, db, "db-1")
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, label := range []struct{ host, key, value string }{
+		{"web-1", "env", "prod"},
+		{"web-2", "env", "staging"},
+		{"db-1", "env", "prod"},
+		{"db-1", "role", "db"},
+	} {
+		w := do(http.MethodPut, "/api/v1/hosts/"+label.host+"/labels/"+label.key, `{"value": "`+label.value+`"}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+
+	if w := do(http.MethodPut, "/api/v1/hosts/web-1/labels/env", `{"value": ""}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an empty value, got %d", w.Code)
+	}
+	if w := do(http.MethodPut, "/api/v1/hosts/nope/labels/env", `{"value": "prod"}`); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown host, got %d", w.Code)
+	}
+
+	w := do(http.MethodGet, "/api/v1/hosts/db-1/labels", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var hostLabels struct {
+		Labels map[string]string `json:"labels"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&hostLabels); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(hostLabels.Labels) != 2 || hostLabels.Labels["role"] != "db" {
+		t.Errorf("Unexpected labels: %v", hostLabels.Labels)
+	}
+
+	// Selectors see labels
+	w = do(http.MethodGet, "/api/v1/hosts?selector=env%3Dprod+AND+NOT+role", "")
+	var hosts struct {
+		Hosts []struct {
+			Hostname string `json:"hostname"`
+		} `json:"hosts"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&hosts); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(hosts.Hosts) != 1 || hosts.Hosts[0].Hostname != "web-1" {
+		t.Errorf("Expected only web-1, got %+v", hosts.Hosts)
+	}
+
+	w = do(http.MethodGet, "/api/v1/stats?group_by=label:role", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var stats struct {
+		Groups []map[string]interface{} `json:"groups"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(stats.Groups) != 2 || stats.Groups[0]["group"] != "db" || stats.Groups[0]["total_hosts"] != float64(1) ||
+		stats.Groups[1]["group"] != "" || stats.Groups[1]["total_hosts"] != float64(2) {
+		t.Errorf("Unexpected groups: %+v", stats.Groups)
+	}
+
+	w = do(http.MethodGet, "/api/v1/stats?group_by=label:env&selector=NOT+role", "")
+	stats.Groups = nil
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(stats.Groups) != 2 || stats.Groups[0]["group"] != "prod" || stats.Groups[1]["group"] != "staging" {
+		t.Errorf("Unexpected groups: %+v", stats.Groups)
+	}
+
+	if w := do(http.MethodGet, "/api/v1/stats?group_by=env:prod", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid group_by, got %d", w.Code)
+	}
+
+	w = do(http.MethodGet, "/api/v1/labels", "")
+	var labels struct {
+		Labels []struct {
+			Key    string         `json:"key"`
+			Values map[string]int `json:"values"`
+		} `json:"labels"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&labels); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(labels.Labels) != 2 || labels.Labels[0].Key != "env" || labels.Labels[0].Values["prod"] != 2 {
+		t.Errorf("Unexpected labels: %+v", labels.Labels)
+	}
+
+	if w := do(http.MethodDelete, "/api/v1/hosts/db-1/labels/role", ""); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodDelete, "/api/v1/hosts/db-1/labels/role", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 removing a label twice, got %d", w.Code)
+	}
+}
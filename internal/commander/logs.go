@@ -0,0 +1,228 @@
+package commander
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/metorial/sentinel/internal/models"
+	pb "github.com/metorial/sentinel/proto"
+)
+
+// logLinesKept is how many of the most recent matching lines are kept per
+// host and log rule.
+const logLinesKept = 10
+
+// RecordLogSamples stores the log rule counts of one interval reported by a
+// host, keeping only the most recent matching lines per rule
+func (db *DB) RecordLogSamples(hostname string, samples []models.LogSample) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var hostID int64
+	if err := tx.QueryRow(`SELECT id FROM hosts WHERE hostname = ?`, hostname).Scan(&hostID); err != nil {
+		return err
+	}
+
+	for _, sample := range samples {
+		labels, err := json.Marshal(sample.Labels)
+		if err != nil {
+			return err
+		}
+		if sample.Labels == nil {
+			labels = []byte("{}")
+		}
+
+		_, err = tx.Exec(`INSERT INTO log_samples (host_id, rule, labels, timestamp, interval_seconds, count)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			hostID, sample.Rule, string(labels), sample.Timestamp, sample.IntervalSeconds, sample.Count)
+		if err != nil {
+			return fmt.Errorf("insert sample of %s: %w", sample.Rule, err)
+		}
+
+		if len(sample.Lines) == 0 {
+			continue
+		}
+		for _, line := range sample.Lines {
+			_, err := tx.Exec(`INSERT INTO log_lines (host_id, rule, timestamp, line) VALUES (?, ?, ?, ?)`,
+				hostID, sample.Rule, sample.Timestamp, line)
+			if err != nil {
+				return fmt.Errorf("insert line of %s: %w", sample.Rule, err)
+			}
+		}
+		_, err = tx.Exec(`DELETE FROM log_lines WHERE host_id = ? AND rule = ? AND id NOT IN (
+			SELECT id FROM log_lines WHERE host_id = ? AND rule = ? ORDER BY id DESC LIMIT ?)`,
+			hostID, sample.Rule, hostID, sample.Rule, logLinesKept)
+		if err != nil {
+			return fmt.Errorf("trim lines of %s: %w", sample.Rule, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetLogSummaries retrieves, for every log rule reported by a host, its
+// latest count, its total over the last hour and its most recent matching
+// lines
+func (db *DB) GetLogSummaries(hostname string) ([]models.LogRuleSummary, error) {
+	query := `SELECT s.rule, s.labels, s.count, s.timestamp,
+	          (SELECT COALESCE(SUM(count), 0) FROM log_samples
+	           WHERE host_id = s.host_id AND rule = s.rule AND timestamp > ?)
+	          FROM (
+	              SELECT *, ROW_NUMBER() OVER (PARTITION BY host_id, rule ORDER BY timestamp DESC, id DESC) as rn
+	              FROM log_samples
+	          ) s
+	          JOIN hosts h ON s.host_id = h.id
+	          WHERE h.hostname = ? AND s.rn = 1
+	          ORDER BY s.rule`
+
+	rows, err := db.conn.Query(query, time.Now().Add(-time.Hour), hostname)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summaries []models.LogRuleSummary
+	for rows.Next() {
+		var s models.LogRuleSummary
+		var labels string
+		if err := rows.Scan(&s.Rule, &labels, &s.LastCount, &s.LastSampleAt, &s.CountLastHour); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(labels), &s.Labels); err != nil {
+			return nil, fmt.Errorf("decode labels of %s: %w", s.Rule, err)
+		}
+		summaries = append(summaries, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i := range summaries {
+		lines, err := db.getLogLines(hostname, summaries[i].Rule)
+		if err != nil {
+			return nil, err
+		}
+		summaries[i].Lines = lines
+	}
+	return summaries, nil
+}
+
+func (db *DB) getLogLines(hostname, rule string) ([]models.LogLine, error) {
+	query := `SELECT l.timestamp, l.line
+	          FROM log_lines l
+	          JOIN hosts h ON l.host_id = h.id
+	          WHERE h.hostname = ? AND l.rule = ?
+	          ORDER BY l.id`
+
+	rows, err := db.conn.Query(query, hostname, rule)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := []models.LogLine{}
+	for rows.Next() {
+		var l models.LogLine
+		if err := rows.Scan(&l.Timestamp, &l.Line); err != nil {
+			return nil, err
+		}
+		lines = append(lines, l)
+	}
+	return lines, rows.Err()
+}
+
+// GetLogSamples retrieves the most recent samples of a host, optionally only
+// those of rule
+func (db *DB) GetLogSamples(hostname, rule string, limit int) ([]models.LogSample, error) {
+	query := `SELECT s.id, s.host_id, s.rule, s.labels, s.timestamp, s.interval_seconds, s.count
+	          FROM log_samples s
+	          JOIN hosts h ON s.host_id = h.id
+	          WHERE h.hostname = ? AND (? = '' OR s.rule = ?)
+	          ORDER BY s.timestamp DESC, s.id DESC
+	          LIMIT ?`
+
+	rows, err := db.conn.Query(query, hostname, rule, rule, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var samples []models.LogSample
+	for rows.Next() {
+		var s models.LogSample
+		var labels string
+		err := rows.Scan(&s.ID, &s.HostID, &s.Rule, &labels, &s.Timestamp, &s.IntervalSeconds, &s.Count)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(labels), &s.Labels); err != nil {
+			return nil, fmt.Errorf("decode labels of %s: %w", s.Rule, err)
+		}
+		samples = append(samples, s)
+	}
+	return samples, rows.Err()
+}
+
+func (s *Server) handleLogCounts(hostname string, counts *pb.LogCounts) error {
+	timestamp := time.Unix(counts.Timestamp, 0)
+	samples := make([]models.LogSample, 0, len(counts.Counts))
+	for _, c := range counts.Counts {
+		samples = append(samples, models.LogSample{
+			Rule:            c.Rule,
+			Labels:          c.Labels,
+			Timestamp:       timestamp,
+			IntervalSeconds: counts.IntervalSeconds,
+			Count:           c.Count,
+			Lines:           c.Lines,
+		})
+	}
+
+	if err := s.db.RecordLogSamples(hostname, samples); err != nil {
+		return fmt.Errorf("record log samples: %w", err)
+	}
+	return nil
+}
+
+func (api *API) handleHostLogs(w http.ResponseWriter, r *http.Request, hostname string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, err := api.db.GetHost(hostname); err == sql.ErrNoRows {
+		http.Error(w, "Host not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("Error getting host %s: %v", hostname, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	limit := 100
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 1000 {
+			limit = l
+		}
+	}
+
+	samples, err := api.db.GetLogSamples(hostname, r.URL.Query().Get("rule"), limit)
+	if err != nil {
+		log.Printf("Error getting log samples for %s: %v", hostname, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"samples": samples,
+		"count":   len(samples),
+	})
+}
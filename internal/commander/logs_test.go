@@ -0,0 +1,110 @@
+package commander
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/metorial/sentinel/internal/models"
+)
+
+func TestRecordLogSamples(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	createTestHost(t, db, "web-1")
+	now := time.Now()
+	labels := map[string]string{"path": "/var/log/app.log"}
+
+	var lines []string
+	for i := 0; i < logLinesKept+3; i++ {
+		lines = append(lines, fmt.Sprintf("ERROR %d", i))
+	}
+
+	if err := db.RecordLogSamples("web-1", []models.LogSample{
+		{Rule: "errors", Labels: labels, Timestamp: now.Add(-2 * time.Minute), IntervalSeconds: 10, Count: 5, Lines: lines[:5]},
+	}); err != nil {
+		t.Fatalf("Failed to record samples: %v", err)
+	}
+	if err := db.RecordLogSamples("web-1", []models.LogSample{
+		{Rule: "errors", Labels: labels, Timestamp: now, IntervalSeconds: 10, Count: 8, Lines: lines[5:]},
+		{Rule: "timeouts", Labels: labels, Timestamp: now, IntervalSeconds: 10, Count: 0},
+	}); err != nil {
+		t.Fatalf("Failed to record samples: %v", err)
+	}
+
+	summaries, err := db.GetLogSummaries("web-1")
+	if err != nil {
+		t.Fatalf("Failed to get summaries: %v", err)
+	}
+	if len(summaries) != 2 {
+		t.Fatalf("Expected 2 rules, got %d", len(summaries))
+	}
+
+	rule := summaries[0]
+	if rule.Rule != "errors" || rule.LastCount != 8 || rule.CountLastHour != 13 {
+		t.Errorf("Unexpected summary: %+v", rule)
+	}
+	if rule.Labels["path"] != "/var/log/app.log" {
+		t.Errorf("Unexpected labels: %v", rule.Labels)
+	}
+	if len(rule.Lines) != logLinesKept || rule.Lines[len(rule.Lines)-1].Line != lines[len(lines)-1] {
+		t.Errorf("Expected the last %d lines, got %+v", logLinesKept, rule.Lines)
+	}
+	if len(summaries[1].Lines) != 0 {
+		t.Errorf("Expected no lines for timeouts, got %d", len(summaries[1].Lines))
+	}
+
+	samples, err := db.GetLogSamples("web-1", "errors", 10)
+	if err != nil {
+		t.Fatalf("Failed to get samples: %v", err)
+	}
+	if len(samples) != 2 || samples[0].Count != 8 {
+		t.Errorf("Unexpected samples: %+v", samples)
+	}
+}
+
+func TestHostDetailIncludesLogs(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	createTestHost(t, db, "web-1")
+	if err := db.RecordLogSamples("web-1", []models.LogSample{
+		{Rule: "errors", Timestamp: time.Now(), IntervalSeconds: 10, Count: 1, Lines: []string{"ERROR boom"}},
+	}); err != nil {
+		t.Fatalf("Failed to record samples: %v", err)
+	}
+
+	api := NewAPI(db, NewServer(db))
+	mux := http.NewServeMux()
+	api.RegisterRoutes(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/hosts/web-1", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response struct {
+		Logs []models.LogRuleSummary `json:"logs"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Logs) != 1 || len(response.Logs[0].Lines) != 1 || response.Logs[0].Lines[0].Line != "ERROR boom" {
+		t.Errorf("Unexpected logs: %+v", response.Logs)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/hosts/web-1/logs?rule=errors", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+}
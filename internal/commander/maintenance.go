@@ -0,0 +1,346 @@
+package commander
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/metorial/sentinel/internal/models"
+)
+
+// maintenanceCovers reports whether the window applies to a host with the given name
+// and tags.
+func maintenanceCovers(mw *models.MaintenanceWindow, hostname string, tags []string) bool {
+	if mw.Hostname != "" {
+		return mw.Hostname == hostname
+	}
+	selector, err := ParseSelector(mw.Tag)
+	if err != nil {
+		// Selectors are validated when saved
+		log.Printf("Skipping maintenance window %d with invalid selector: %v", mw.ID, err)
+		return false
+	}
+	return selector.Matches(tags)
+}
+
+// CreateMaintenanceWindow stores a maintenance window, setting its id and
+// creation time
+func (db *DB) CreateMaintenanceWindow(mw *models.MaintenanceWindow) error {
+	// Windows are compared as stored, so keep them all in UTC
+	mw.StartsAt = mw.StartsAt.UTC()
+	mw.EndsAt = mw.EndsAt.UTC()
+
+	query := `INSERT INTO maintenance_windows (hostname, tag, reason, author, starts_at, ends_at)
+	          VALUES (?, ?, ?, ?, ?, ?)
+	          RETURNING id, created_at`
+
+	return db.conn.QueryRow(query, mw.Hostname, mw.Tag, mw.Reason, mw.Author, mw.StartsAt, mw.EndsAt).
+		Scan(&mw.ID, &mw.CreatedAt)
+}
+
+const maintenanceColumns = `m.id, m.hostname, m.tag, m.reason, m.author, m.starts_at, m.ends_at, m.created_at`
+
+func scanMaintenanceWindow(row rowScanner, at time.Time) (models.MaintenanceWindow, error) {
+	var mw models.MaintenanceWindow
+	err := row.Scan(&mw.ID, &mw.Hostname, &mw.Tag, &mw.Reason, &mw.Author, &mw.StartsAt, &mw.EndsAt, &mw.CreatedAt)
+	mw.Active = !mw.StartsAt.After(at) && mw.EndsAt.After(at)
+	return mw, err
+}
+
+// GetMaintenanceWindow retrieves a maintenance window by id
+func (db *DB) GetMaintenanceWindow(id int64, at time.Time) (*models.MaintenanceWindow, error) {
+	query := `SELECT ` + maintenanceColumns + ` FROM maintenance_windows m WHERE m.id = ?`
+
+	mw, err := scanMaintenanceWindow(db.conn.QueryRow(query, id), at)
+	if err != nil {
+		return nil, err
+	}
+	return &mw, nil
+}
+
+// GetMaintenanceWindows retrieves the maintenance windows that haven't ended
+// at the given time, or all windows if includeEnded is set, in start order
+func (db *DB) GetMaintenanceWindows(at time.Time, includeEnded bool) ([]models.MaintenanceWindow, error) {
+	query := `SELECT ` + maintenanceColumns + ` FROM maintenance_windows m
+	          WHERE ? OR m.ends_at > ?
+	          ORDER BY m.starts_at, m.id`
+
+	return db.queryMaintenanceWindows(query, at, includeEnded, at.UTC())
+}
+
+// GetHostMaintenanceWindows retrieves the maintenance windows covering a host
+// that haven't ended at the given time, in start order
+func (db *DB) GetHostMaintenanceWindows(hostname string, at time.Time) ([]models.MaintenanceWindow, error) {
+	tags, err := db.GetHostTags(hostname)
+	if err != nil {
+		return nil, err
+	}
+	windows, err := db.GetMaintenanceWindows(at, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var covering []models.MaintenanceWindow
+	for _, mw := range windows {
+		if maintenanceCovers(&mw, hostname, tags) {
+			covering = append(covering, mw)
+		}
+	}
+	return covering, nil
+}
+
+func (db *DB) queryMaintenanceWindows(query string, at time.Time, args ...interface{}) ([]models.MaintenanceWindow, error) {
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var windows []models.MaintenanceWindow
+	for rows.Next() {
+		mw, err := scanMaintenanceWindow(rows, at)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, mw)
+	}
+	return windows, rows.Err()
+}
+
+// DeleteMaintenanceWindow deletes a maintenance window by id, returning
+// sql.ErrNoRows if it doesn't exist
+func (db *DB) DeleteMaintenanceWindow(id int64) error {
+	result, err := db.conn.Exec(`DELETE FROM maintenance_windows WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// activeMaintenanceWindows retrieves the maintenance windows in effect at the
+// given time
+func (db *DB) activeMaintenanceWindows(at time.Time) ([]models.MaintenanceWindow, error) {
+	query := `SELECT ` + maintenanceColumns + ` FROM maintenance_windows m
+	          WHERE m.starts_at <= ? AND m.ends_at > ?`
+
+	return db.queryMaintenanceWindows(query, at, at.UTC(), at.UTC())
+}
+
+// GetHostsInMaintenance retrieves the names of the hosts covered by a
+// maintenance window at the given time
+func (db *DB) GetHostsInMaintenance(at time.Time) (map[string]bool, error) {
+	hostnames := make(map[string]bool)
+	windows, err := db.activeMaintenanceWindows(at)
+	if err != nil || len(windows) == 0 {
+		return hostnames, err
+	}
+	tags, err := db.GetTagsByHost()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.conn.Query(`SELECT id, hostname FROM hosts`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var hostname string
+		if err := rows.Scan(&id, &hostname); err != nil {
+			return nil, err
+		}
+		for _, mw := range windows {
+			if maintenanceCovers(&mw, hostname, tags[id]) {
+				hostnames[hostname] = true
+				break
+			}
+		}
+	}
+	return hostnames, rows.Err()
+}
+
+// InMaintenance reports whether a maintenance window covers the host at the
+// given time
+func (db *DB) InMaintenance(hostname string, at time.Time) (bool, error) {
+	windows, err := db.activeMaintenanceWindows(at)
+	if err != nil || len(windows) == 0 {
+		return false, err
+	}
+	tags, err := db.GetHostTags(hostname)
+	if err != nil {
+		return false, err
+	}
+
+	for _, mw := range windows {
+		if maintenanceCovers(&mw, hostname, tags) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// markMaintenance sets InMaintenance on the hosts covered by a maintenance
+// window now.
+func (api *API) markMaintenance(hosts []models.Host) error {
+	covered, err := api.db.GetHostsInMaintenance(time.Now())
+	if err != nil {
+		return err
+	}
+	for i := range hosts {
+		hosts[i].InMaintenance = covered[hosts[i].Hostname]
+	}
+	return nil
+}
+
+func (api *API) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		windows, err := api.db.GetMaintenanceWindows(time.Now(), r.URL.Query().Get("all") == "true")
+		if err != nil {
+			log.Printf("Error getting maintenance windows: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"windows": windows,
+			"count":   len(windows),
+		})
+
+	case http.MethodPost:
+		var req struct {
+			Hostname string     `json:"hostname"`
+			Tag      string     `json:"tag"`
+			Reason   string     `json:"reason"`
+			Author   string     `json:"author"`
+			StartsAt *time.Time `json:"starts_at"`
+			EndsAt   *time.Time `json:"ends_at"`
+			Duration string     `json:"duration"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		now := time.Now()
+		mw := models.MaintenanceWindow{
+			Hostname: req.Hostname,
+			Tag:      req.Tag,
+			Reason:   req.Reason,
+			Author:   req.Author,
+			StartsAt: now,
+		}
+		if req.StartsAt != nil {
+			mw.StartsAt = *req.StartsAt
+		}
+		if err := resolveMaintenanceEnd(&mw, req.EndsAt, req.Duration, now); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if (mw.Hostname == "") == (mw.Tag == "") {
+			http.Error(w, "Exactly one of hostname or tag is required", http.StatusBadRequest)
+			return
+		}
+		if _, err := ParseSelector(mw.Tag); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := api.db.CreateMaintenanceWindow(&mw); err != nil {
+			log.Printf("Error creating maintenance window: %v", err)
+			http.Error(w, "Failed to create maintenance window", http.StatusInternalServerError)
+			return
+		}
+		mw.Active = !mw.StartsAt.After(now)
+
+		log.Printf("Maintenance window %d for %s%s by %s until %s: %s", mw.ID, mw.Hostname, mw.Tag,
+			mw.Author, mw.EndsAt.Format(time.RFC3339), mw.Reason)
+
+		respondJSON(w, http.StatusOK, mw)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// resolveMaintenanceEnd sets the end of mw from either endsAt or a duration
+// from its start, and checks the window is still to end.
+func resolveMaintenanceEnd(mw *models.MaintenanceWindow, endsAt *time.Time, duration string, now time.Time) error {
+	switch {
+	case endsAt != nil && duration != "":
+		return fmt.Errorf("only one of ends_at or duration may be set")
+	case endsAt != nil:
+		mw.EndsAt = *endsAt
+	case duration != "":
+		d, err := time.ParseDuration(duration)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("duration must be a positive duration such as 2h30m")
+		}
+		mw.EndsAt = mw.StartsAt.Add(d)
+	default:
+		return fmt.Errorf("ends_at or duration is required")
+	}
+
+	if !mw.EndsAt.After(mw.StartsAt) {
+		return fmt.Errorf("ends_at must be after starts_at")
+	}
+	if !mw.EndsAt.After(now) {
+		return fmt.Errorf("ends_at must be in the future")
+	}
+	return nil
+}
+
+func (api *API) handleMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.URL.Path[len("/api/v1/maintenance/"):], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid maintenance window id", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		mw, err := api.db.GetMaintenanceWindow(id, time.Now())
+		if err == sql.ErrNoRows {
+			http.Error(w, "Maintenance window not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error getting maintenance window %d: %v", id, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, http.StatusOK, mw)
+
+	case http.MethodDelete:
+		err := api.db.DeleteMaintenanceWindow(id)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Maintenance window not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error deleting maintenance window %d: %v", id, err)
+			http.Error(w, "Failed to delete maintenance window", http.StatusInternalServerError)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, map[string]string{
+			"message": "Maintenance window deleted successfully",
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,245 @@
+package commander
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/metorial/sentinel/internal/models"
+)
+
+func TestMaintenanceWindows(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	createTestHost(t, db, "web-1")
+	createTestHost(t, db, "db-1")
+	createTestHost(t, db, "db-2")
+	if err := db.AddHostTag("db-1", "db"); err != nil {
+		t.Fatalf("Failed to add tag: %v", err)
+	}
+	if err := db.AddHostTag("db-2", "db"); err != nil {
+		t.Fatalf("Failed to add tag: %v", err)
+	}
+	if err := db.AddHostTag("db-2", "canary"); err != nil {
+		t.Fatalf("Failed to add tag: %v", err)
+	}
+	now := time.Now()
+
+	windows := []models.MaintenanceWindow{
+		{Hostname: "web-1", Reason: "kernel upgrade", StartsAt: now.Add(-time.Minute), EndsAt: now.Add(time.Hour)},
+		{Tag: "db AND NOT canary", Reason: "patching", StartsAt: now.Add(-time.Minute), EndsAt: now.Add(time.Hour)},
+		{Hostname: "db-2", Reason: "scheduled", StartsAt: now.Add(time.Hour), EndsAt: now.Add(2 * time.Hour)},
+		{Hostname: "db-2", Reason: "over", StartsAt: now.Add(-2 * time.Hour), EndsAt: now.Add(-time.Hour)},
+	}
+	for i := range windows {
+		if err := db.CreateMaintenanceWindow(&windows[i]); err != nil {
+			t.Fatalf("Failed to create window: %v", err)
+		}
+	}
+
+	covered, err := db.GetHostsInMaintenance(now)
+	if err != nil {
+		t.Fatalf("Failed to get hosts in maintenance: %v", err)
+	}
+	if len(covered) != 2 || !covered["web-1"] || !covered["db-1"] {
+		t.Errorf("Expected web-1 and db-1 in maintenance, got %v", covered)
+	}
+
+	if in, err := db.InMaintenance("db-2", now); err != nil || in {
+		t.Errorf("Expected db-2 not in maintenance yet, got %v, %v", in, err)
+	}
+	if in, err := db.InMaintenance("db-2", now.Add(90*time.Minute)); err != nil || !in {
+		t.Errorf("Expected db-2 in its scheduled window, got %v, %v", in, err)
+	}
+
+	listed, err := db.GetMaintenanceWindows(now, false)
+	if err != nil {
+		t.Fatalf("Failed to get windows: %v", err)
+	}
+	if len(listed) != 3 {
+		t.Fatalf("Expected 3 windows that haven't ended, got %+v", listed)
+	}
+	if !listed[0].Active || listed[2].Active || listed[2].Reason != "scheduled" {
+		t.Errorf("Unexpected windows: %+v", listed)
+	}
+	if all, err := db.GetMaintenanceWindows(now, true); err != nil || len(all) != 4 {
+		t.Errorf("Expected 4 windows including ended ones, got %d, %v", len(all), err)
+	}
+
+	hostWindows, err := db.GetHostMaintenanceWindows("db-2", now)
+	if err != nil {
+		t.Fatalf("Failed to get host windows: %v", err)
+	}
+	if len(hostWindows) != 1 || hostWindows[0].Reason != "scheduled" {
+		t.Errorf("Expected only the scheduled window of db-2, got %+v", hostWindows)
+	}
+
+	if err := db.DeleteMaintenanceWindow(windows[1].ID); err != nil {
+		t.Fatalf("Failed to delete window: %v", err)
+	}
+	if in, err := db.InMaintenance("db-1", now); err != nil || in {
+		t.Errorf("Expected db-1 out of maintenance after deleting its window, got %v, %v", in, err)
+	}
+	if err := db.DeleteMaintenanceWindow(windows[1].ID); err == nil {
+		t.Error("Expected error deleting window twice")
+	}
+}
+
+func TestFireAlertInMaintenance(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	hostID := createTestHost(t, db, "web-1")
+	now := time.Now()
+
+	mw := models.MaintenanceWindow{Hostname: "web-1", StartsAt: now.Add(-time.Minute), EndsAt: now.Add(time.Hour)}
+	if err := db.CreateMaintenanceWindow(&mw); err != nil {
+		t.Fatalf("Failed to create window: %v", err)
+	}
+
+	fired, err := db.FireAlert(hostID, AlertKindUnit, "nginx.service", "nginx.service is failed (failed)", now)
+	if err != nil || fired {
+		t.Fatalf("Expected alert to be suppressed, got %v, %v", fired, err)
+	}
+	if alerts, _ := db.GetAlerts(""); len(alerts) != 0 {
+		t.Errorf("Expected no alerts during maintenance, got %+v", alerts)
+	}
+
+	fired, err = db.FireAlert(hostID, AlertKindUnit, "nginx.service", "nginx.service is failed (failed)", now.Add(2*time.Hour))
+	if err != nil || !fired {
+		t.Errorf("Expected alert to fire after maintenance, got %v, %v", fired, err)
+	}
+}
+
+func TestAlertFiringBeforeMaintenance(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	hostID := createTestHost(t, db, "web-1")
+	now := time.Now()
+
+	fired, err := db.FireAlert(hostID, AlertKindCheck, "homepage", "status 503, expected 200", now.Add(-2*time.Hour))
+	if err != nil || !fired {
+		t.Fatalf("Expected alert to fire, got %v, %v", fired, err)
+	}
+
+	mw := models.MaintenanceWindow{Hostname: "web-1", StartsAt: now.Add(-time.Minute), EndsAt: now.Add(time.Hour)}
+	if err := db.CreateMaintenanceWindow(&mw); err != nil {
+		t.Fatalf("Failed to create window: %v", err)
+	}
+
+	if _, err := db.FireAlert(hostID, AlertKindCheck, "homepage", "connection refused", now); err != nil {
+		t.Fatalf("Failed to fire alert: %v", err)
+	}
+	alerts, err := db.GetAlerts(models.AlertFiring)
+	if err != nil || len(alerts) != 1 {
+		t.Fatalf("Expected the alert to stay firing, got %+v, %v", alerts, err)
+	}
+	if !alerts[0].Silenced || alerts[0].Message != "status 503, expected 200" {
+		t.Errorf("Expected the alert to be silenced and not refreshed, got %+v", alerts[0])
+	}
+
+	if err := db.DeleteMaintenanceWindow(mw.ID); err != nil {
+		t.Fatalf("Failed to delete window: %v", err)
+	}
+	if _, err := db.FireAlert(hostID, AlertKindCheck, "homepage", "connection refused", now); err != nil {
+		t.Fatalf("Failed to fire alert: %v", err)
+	}
+	alerts, err = db.GetAlerts(models.AlertFiring)
+	if err != nil || len(alerts) != 1 {
+		t.Fatalf("Expected the alert to stay firing, got %+v, %v", alerts, err)
+	}
+	if alerts[0].Silenced || alerts[0].Message != "connection refused" {
+		t.Errorf("Expected the alert to be refreshed after maintenance, got %+v", alerts[0])
+	}
+}
+
+func TestHandleMaintenance(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	server := NewServer(db)
+	api := NewAPI(db, server)
+	mux := http.NewServeMux()
+	api.RegisterRoutes(mux)
+
+	createTestHost(t, db, "web-1")
+	createTestHost(t, db, "web-2")
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/maintenance", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, body := range []string{
+		`{"duration": "1h"}`,
+		`{"hostname": "web-1", "tag": "db", "duration": "1h"}`,
+		`{"hostname": "web-1"}`,
+		`{"hostname": "web-1", "duration": "soon"}`,
+		`{"hostname": "web-1", "ends_at": "2020-01-01T00:00:00Z"}`,
+	} {
+		if w := post(body); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", body, w.Code)
+		}
+	}
+
+	w := post(`{"hostname": "web-1", "duration": "2h", "reason": "kernel upgrade", "author": "alice"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var created models.MaintenanceWindow
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if created.ID == 0 || !created.Active || created.Author != "alice" {
+		t.Errorf("Unexpected window: %+v", created)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/hosts", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	var hosts struct {
+		Hosts []models.Host `json:"hosts"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&hosts); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	for _, host := range hosts.Hosts {
+		if host.InMaintenance != (host.Hostname == "web-1") {
+			t.Errorf("Unexpected maintenance flag on %s: %v", host.Hostname, host.InMaintenance)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/stats", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	var stats map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if stats["maintenance_hosts"] != float64(1) {
+		t.Errorf("Expected 1 host in maintenance, got %v", stats["maintenance_hosts"])
+	}
+
+	path := fmt.Sprintf("/api/v1/maintenance/%d", created.ID)
+	req = httptest.NewRequest(http.MethodDelete, path, nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, path, nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
@@ -0,0 +1,281 @@
+package commander
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/metorial/sentinel/internal/models"
+)
+
+const (
+	// defaultReportInterval is the interval agents report at unless a
+	// profile or command changes it.
+	defaultReportInterval = 10 * time.Second
+	// A host with an open stream becomes stale after missing staleIntervals
+	// reports. A host without a stream goes offline after offlineIntervals
+	// reports, but no sooner than minOfflineAfter so agents have time to
+	// reconnect.
+	staleIntervals   = 3
+	offlineIntervals = 6
+	minOfflineAfter  = time.Minute
+)
+
+// SetHostStatus moves a host into status, recording the transition and its
+// reason. It reports whether the status changed; decommissioned hosts keep
+// their status.
+func (db *DB) SetHostStatus(hostname, status, reason string, at time.Time) (bool, error) {
+	return db.setHostStatus(hostname, status, reason, at, false)
+}
+
+// setHostStatus implements SetHostStatus; with recommission set it also moves
+// decommissioned hosts.
+func (db *DB) setHostStatus(hostname, status, reason string, at time.Time, recommission bool) (bool, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var hostID int64
+	var current string
+	err = tx.QueryRow(`SELECT id, status FROM hosts WHERE hostname = ?`, hostname).Scan(&hostID, &current)
+	if err != nil {
+		return false, err
+	}
+	if current == status || current == models.HostDecommissioned && !recommission {
+		return false, nil
+	}
+
+	_, err = tx.Exec(`UPDATE hosts SET status = ?, status_reason = ?, status_since = ?, online = ? WHERE id = ?`,
+		status, reason, at, status == models.HostOnline, hostID)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(`INSERT INTO host_status_transitions (host_id, from_status, to_status, reason, timestamp)
+		VALUES (?, ?, ?, ?, ?)`, hostID, current, status, reason, at)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// SetHostExpectedInterval records the interval a host's agent reports at
+func (db *DB) SetHostExpectedInterval(hostname string, interval time.Duration) error {
+	_, err := db.conn.Exec(`UPDATE hosts SET expected_interval_seconds = ? WHERE hostname = ?`,
+		int64(interval/time.Second), hostname)
+	return err
+}
+
+// GetTrackedHosts retrieves the hosts whose presence is tracked, i.e. those
+// neither offline nor decommissioned
+func (db *DB) GetTrackedHosts() ([]models.Host, error) {
+	query := `SELECT ` + hostColumns + ` FROM hosts h WHERE h.status NOT IN (?, ?)`
+
+	rows, err := db.conn.Query(query, models.HostOffline, models.HostDecommissioned)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hosts []models.Host
+	for rows.Next() {
+		var h models.Host
+		if err := scanHost(rows, &h); err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts, rows.Err()
+}
+
+// GetStatusTransitions retrieves the most recent presence transitions of a host
+func (db *DB) GetStatusTransitions(hostname string, limit int) ([]models.StatusTransition, error) {
+	query := `SELECT t.id, t.host_id, t.from_status, t.to_status, t.reason, t.timestamp
+	          FROM host_status_transitions t
+	          JOIN hosts h ON t.host_id = h.id
+	          WHERE h.hostname = ?
+	          ORDER BY t.timestamp DESC, t.id DESC
+	          LIMIT ?`
+
+	rows, err := db.conn.Query(query, hostname, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transitions []models.StatusTransition
+	for rows.Next() {
+		var t models.StatusTransition
+		if err := rows.Scan(&t.ID, &t.HostID, &t.FromStatus, &t.ToStatus, &t.Reason, &t.Timestamp); err != nil {
+			return nil, err
+		}
+		transitions = append(transitions, t)
+	}
+	return transitions, rows.Err()
+}
+
+// streamActivity returns when the agent last sent metrics on the stream, or
+// when the stream was opened if it hasn't yet, and the interval it is
+// expected to report at.
+func (a *agentStream) streamActivity() (time.Time, time.Duration) {
+	a.presenceMu.Lock()
+	defer a.presenceMu.Unlock()
+	if a.lastMetrics.IsZero() {
+		return a.connectedAt, a.interval
+	}
+	return a.lastMetrics, a.interval
+}
+
+func (a *agentStream) metricsReceived(at time.Time) {
+	a.presenceMu.Lock()
+	a.lastMetrics = at
+	a.presenceMu.Unlock()
+}
+
+// setInterval sets the expected report interval and reports whether it
+// changed.
+func (a *agentStream) setInterval(interval time.Duration) bool {
+	a.presenceMu.Lock()
+	defer a.presenceMu.Unlock()
+	if a.interval == interval {
+		return false
+	}
+	a.interval = interval
+	return true
+}
+
+// setPresence moves hostname into status, logging the transition unless the
+// host is under maintenance. Hosts only exist once they have sent metrics, so
+// unknown hosts are skipped.
+func (s *Server) setPresence(hostname, status, reason string) {
+	changed, err := s.db.SetHostStatus(hostname, status, reason, time.Now())
+	if err == sql.ErrNoRows {
+		return
+	}
+	if err != nil {
+		log.Printf("Error setting status of %s to %s: %v", hostname, status, err)
+		return
+	}
+	if !changed {
+		return
+	}
+
+	// Hosts under maintenance are expected to come and go
+	if silenced, err := s.db.InMaintenance(hostname, time.Now()); err != nil {
+		log.Printf("Error checking maintenance of %s: %v", hostname, err)
+	} else if silenced {
+		return
+	}
+	log.Printf("Host %s is %s: %s", hostname, status, reason)
+}
+
+// setExpectedInterval records the interval the agent on conn reports at.
+func (s *Server) setExpectedInterval(hostname string, conn *agentStream, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultReportInterval
+	}
+	if !conn.setInterval(interval) {
+		return
+	}
+	if err := s.db.SetHostExpectedInterval(hostname, interval); err != nil {
+		log.Printf("Error recording report interval of %s: %v", hostname, err)
+	}
+}
+
+// IsConnected reports whether hostname has an open stream.
+func (s *Server) IsConnected(hostname string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.streams[hostname]
+	return ok
+}
+
+// SweepPresence marks hosts whose agent stopped reporting on an open stream
+// stale, and hosts that have been without a stream for too long offline.
+func (s *Server) SweepPresence(now time.Time) error {
+	hosts, err := s.db.GetTrackedHosts()
+	if err != nil {
+		return err
+	}
+
+	for _, host := range hosts {
+		s.mu.RLock()
+		conn, connected := s.streams[host.Hostname]
+		s.mu.RUnlock()
+
+		if connected {
+			last, interval := conn.streamActivity()
+			if host.Status != models.HostStale && now.Sub(last) > staleIntervals*interval {
+				s.setPresence(host.Hostname, models.HostStale,
+					fmt.Sprintf("no metrics for %s on an open stream", now.Sub(last).Round(time.Second)))
+			}
+			continue
+		}
+
+		interval := time.Duration(host.ExpectedIntervalSeconds) * time.Second
+		if interval <= 0 {
+			interval = defaultReportInterval
+		}
+		offlineAfter := max(offlineIntervals*interval, minOfflineAfter)
+
+		// Streams don't survive a controller restart; give agents time to
+		// reconnect before counting it against them.
+		since := host.StatusSince
+		if s.startedAt.After(since) {
+			since = s.startedAt
+		}
+		if now.Sub(since) > offlineAfter {
+			s.setPresence(host.Hostname, models.HostOffline,
+				fmt.Sprintf("no stream for %s", now.Sub(since).Round(time.Second)))
+		}
+	}
+	return nil
+}
+
+func (api *API) handleHostPresence(w http.ResponseWriter, r *http.Request, hostname string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	host, err := api.db.GetHost(hostname)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Host not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting host %s: %v", hostname, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	limit := 100
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 1000 {
+			limit = l
+		}
+	}
+
+	transitions, err := api.db.GetStatusTransitions(hostname, limit)
+	if err != nil {
+		log.Printf("Error getting status transitions for %s: %v", hostname, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"status":                    host.Status,
+		"reason":                    host.StatusReason,
+		"since":                     host.StatusSince,
+		"connected":                 api.server.IsConnected(hostname),
+		"expected_interval_seconds": host.ExpectedIntervalSeconds,
+		"transitions":               transitions,
+		"count":                     len(transitions),
+	})
+}
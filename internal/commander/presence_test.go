@@ -0,0 +1,185 @@
+package commander
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metorial/sentinel/internal/models"
+)
+
+func waitForStatus(t *testing.T, db *DB, hostname, status string) *models.Host {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		host, err := db.GetHost(hostname)
+		if err != nil {
+			t.Fatalf("Failed to get host: %v", err)
+		}
+		if host.Status == status {
+			return host
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected status %s, got %s (%s)", status, host.Status, host.StatusReason)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSetHostStatus(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	createTestHost(t, db, "web-1")
+	now := time.Now()
+
+	changed, err := db.SetHostStatus("web-1", models.HostOnline, "metrics received", now)
+	if err != nil || !changed {
+		t.Fatalf("Expected status change, got %v, %v", changed, err)
+	}
+	changed, err = db.SetHostStatus("web-1", models.HostOnline, "metrics received", now)
+	if err != nil || changed {
+		t.Fatalf("Expected no change for the same status, got %v, %v", changed, err)
+	}
+	if _, err := db.SetHostStatus("web-1", models.HostStale, "stream lost", now.Add(time.Second)); err != nil {
+		t.Fatalf("Failed to set status: %v", err)
+	}
+
+	host, err := db.GetHost("web-1")
+	if err != nil {
+		t.Fatalf("Failed to get host: %v", err)
+	}
+	if host.Status != models.HostStale || host.StatusReason != "stream lost" || host.Online {
+		t.Errorf("Expected host stale and not online, got %s (%s), online %v", host.Status, host.StatusReason, host.Online)
+	}
+
+	transitions, err := db.GetStatusTransitions("web-1", 10)
+	if err != nil {
+		t.Fatalf("Failed to get transitions: %v", err)
+	}
+	if len(transitions) != 2 {
+		t.Fatalf("Expected 2 transitions, got %+v", transitions)
+	}
+	if transitions[0].FromStatus != models.HostOnline || transitions[0].ToStatus != models.HostStale {
+		t.Errorf("Expected latest transition online -> stale, got %+v", transitions[0])
+	}
+
+	if _, err := db.conn.Exec(`UPDATE hosts SET status = ? WHERE hostname = ?`, models.HostDecommissioned, "web-1"); err != nil {
+		t.Fatalf("Failed to decommission host: %v", err)
+	}
+	changed, err = db.SetHostStatus("web-1", models.HostOnline, "metrics received", now)
+	if err != nil || changed {
+		t.Errorf("Expected decommissioned host to keep its status, got %v, %v", changed, err)
+	}
+}
+
+func TestStreamPresence(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	createTestHost(t, db, "test-host")
+	server := NewServer(db)
+	stream := setupTestStream(t, server)
+
+	sendHello(t, stream, "test-host")
+	waitForStatus(t, db, "test-host", models.HostConnecting)
+
+	if err := stream.Send(testMetrics("test-host")); err != nil {
+		t.Fatalf("Failed to send metrics: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Failed to receive ack: %v", err)
+	}
+	host := waitForStatus(t, db, "test-host", models.HostOnline)
+	if !host.Online {
+		t.Error("Expected online host to be marked online")
+	}
+
+	// An open stream without metrics goes stale
+	if err := server.SweepPresence(time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("Failed to sweep presence: %v", err)
+	}
+	host = waitForStatus(t, db, "test-host", models.HostStale)
+	if !strings.Contains(host.StatusReason, "on an open stream") {
+		t.Errorf("Expected reason to mention the open stream, got %q", host.StatusReason)
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("Failed to close stream: %v", err)
+	}
+	host = waitForStatus(t, db, "test-host", models.HostOffline)
+	if host.StatusReason != "agent closed the stream" {
+		t.Errorf("Expected clean disconnect reason, got %q", host.StatusReason)
+	}
+}
+
+func TestSweepPresenceWithoutStream(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	createTestHost(t, db, "web-1")
+	server := NewServer(db)
+	if _, err := db.SetHostStatus("web-1", models.HostStale, "stream lost: connection reset", time.Now()); err != nil {
+		t.Fatalf("Failed to set status: %v", err)
+	}
+
+	if err := server.SweepPresence(time.Now().Add(30 * time.Second)); err != nil {
+		t.Fatalf("Failed to sweep presence: %v", err)
+	}
+	waitForStatus(t, db, "web-1", models.HostStale)
+
+	if err := server.SweepPresence(time.Now().Add(2 * time.Minute)); err != nil {
+		t.Fatalf("Failed to sweep presence: %v", err)
+	}
+	host := waitForStatus(t, db, "web-1", models.HostOffline)
+	if !strings.HasPrefix(host.StatusReason, "no stream for") {
+		t.Errorf("Expected reason to mention the missing stream, got %q", host.StatusReason)
+	}
+}
+
+func TestHandleHostPresence(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	server := NewServer(db)
+	api := NewAPI(db, server)
+	mux := http.NewServeMux()
+	api.RegisterRoutes(mux)
+
+	createTestHost(t, db, "web-1")
+	if _, err := db.SetHostStatus("web-1", models.HostOnline, "metrics received", time.Now()); err != nil {
+		t.Fatalf("Failed to set status: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/hosts/web-1/presence", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var presence struct {
+		Status      string                    `json:"status"`
+		Reason      string                    `json:"reason"`
+		Connected   bool                      `json:"connected"`
+		Transitions []models.StatusTransition `json:"transitions"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&presence); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if presence.Status != models.HostOnline || presence.Connected || len(presence.Transitions) != 1 {
+		t.Errorf("Unexpected presence: %+v", presence)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/hosts/unknown/presence", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
@@ -0,0 +1,393 @@
+package commander
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/metorial/sentinel/internal/models"
+	pb "github.com/metorial/sentinel/proto"
+)
+
+// UpsertProfile creates or replaces the profile with profile.Name. Every write
+// assigns a new version from a counter that never goes back, even when
+// profiles are deleted, so agents can tell when their effective config changed
+// even if they move between profiles.
+func (db *DB) UpsertProfile(profile *models.AgentProfile) error {
+	collectors, err := json.Marshal(nonNil(profile.EnabledCollectors))
+	if err != nil {
+		return err
+	}
+	include, err := json.Marshal(nonNil(profile.MountInclude))
+	if err != nil {
+		return err
+	}
+	exclude, err := json.Marshal(nonNil(profile.MountExclude))
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var version int64
+	err = tx.QueryRow(`UPDATE profile_version SET version = version + 1 WHERE id = 1 RETURNING version`).Scan(&version)
+	if err != nil {
+		return fmt.Errorf("next profile version: %w", err)
+	}
+
+	query := `
+	INSERT INTO agent_profiles (name, selector, priority, report_interval_seconds,
+		enabled_collectors, mount_include, mount_exclude, version, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(name) DO UPDATE SET
+		selector = excluded.selector,
+		priority = excluded.priority,
+		report_interval_seconds = excluded.report_interval_seconds,
+		enabled_collectors = excluded.enabled_collectors,
+		mount_include = excluded.mount_include,
+		mount_exclude = excluded.mount_exclude,
+		version = excluded.version,
+		updated_at = excluded.updated_at
+	RETURNING id, version`
+
+	err = tx.QueryRow(query, profile.Name, profile.Selector, profile.Priority,
+		profile.ReportIntervalSeconds, string(collectors), string(include), string(exclude),
+		version, time.Now()).Scan(&profile.ID, &profile.Version)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+const profileColumns = `p.id, p.name, p.selector, p.priority, p.report_interval_seconds,
+	p.enabled_collectors, p.mount_include, p.mount_exclude, p.version, p.created_at, p.updated_at`
+
+func scanProfile(row rowScanner) (*models.AgentProfile, error) {
+	var p models.AgentProfile
+	var collectors, include, exclude string
+	err := row.Scan(&p.ID, &p.Name, &p.Selector, &p.Priority, &p.ReportIntervalSeconds,
+		&collectors, &include, &exclude, &p.Version, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range []struct {
+		raw  string
+		dest *[]string
+	}{
+		{collectors, &p.EnabledCollectors},
+		{include, &p.MountInclude},
+		{exclude, &p.MountExclude},
+	} {
+		if err := json.Unmarshal([]byte(f.raw), f.dest); err != nil {
+			return nil, fmt.Errorf("decode profile %s: %w", p.Name, err)
+		}
+	}
+
+	return &p, nil
+}
+
+// GetProfile retrieves a profile by name
+func (db *DB) GetProfile(name string) (*models.AgentProfile, error) {
+	query := `SELECT ` + profileColumns + ` FROM agent_profiles p WHERE p.name = ?`
+	return scanProfile(db.conn.QueryRow(query, name))
+}
+
+// GetAllProfiles retrieves all profiles, highest priority first
+func (db *DB) GetAllProfiles() ([]models.AgentProfile, error) {
+	query := `SELECT ` + profileColumns + ` FROM agent_profiles p ORDER BY p.priority DESC, p.name`
+	rows, err := db.conn.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var profiles []models.AgentProfile
+	for rows.Next() {
+		p, err := scanProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, *p)
+	}
+	return profiles, rows.Err()
+}
+
+// DeleteProfile deletes a profile by name, returning sql.ErrNoRows if it
+// doesn't exist
+func (db *DB) DeleteProfile(name string) error {
+	result, err := db.conn.Exec(`DELETE FROM agent_profiles WHERE name = ?`, name)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ResolveProfile returns the highest priority profile whose selector matches
+// the host, or nil if none does. An empty selector matches every host.
+func (db *DB) ResolveProfile(hostname string) (*models.AgentProfile, error) {
+	tags, err := db.GetHostTags(hostname)
+	if err != nil {
+		return nil, err
+	}
+	profiles, err := db.GetAllProfiles()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, profile := range profiles {
+		selector, err := ParseSelector(profile.Selector)
+		if err != nil {
+			// Selectors are validated when saved
+			log.Printf("Skipping profile %s with invalid selector: %v", profile.Name, err)
+			continue
+		}
+		if selector.Matches(tags) {
+			return &profile, nil
+		}
+	}
+	return nil, nil
+}
+
+// SetHostConfigStatus records the config version an agent reports as applied,
+// or as failed to apply with applyErr
+func (db *DB) SetHostConfigStatus(hostname string, version int64, applyErr string) error {
+	query := `UPDATE hosts SET config_version = ?, config_failed_version = 0, config_error = ''
+		WHERE hostname = ?`
+	args := []interface{}{version, hostname}
+	if applyErr != "" {
+		query = `UPDATE hosts SET config_failed_version = ?, config_error = ? WHERE hostname = ?`
+		args = []interface{}{version, applyErr, hostname}
+	}
+	_, err := db.conn.Exec(query, args...)
+	return err
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+func validateProfile(p *models.AgentProfile) error {
+	if p.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if _, err := ParseSelector(p.Selector); err != nil {
+		return err
+	}
+	if p.ReportIntervalSeconds < 0 {
+		return fmt.Errorf("report_interval_seconds must not be negative")
+	}
+	for _, pattern := range append(append([]string{}, p.MountInclude...), p.MountExclude...) {
+		if _, err := filepath.Match(pattern, "/"); err != nil {
+			return fmt.Errorf("invalid mount pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// configFor resolves the agent config for a host. Hosts without a matching
+// profile get an empty config with version 0, restoring agent defaults.
+func (s *Server) configFor(hostname string) (*pb.AgentConfig, error) {
+	profile, err := s.db.ResolveProfile(hostname)
+	if err != nil {
+		return nil, err
+	}
+	if profile == nil {
+		return &pb.AgentConfig{}, nil
+	}
+
+	return &pb.AgentConfig{
+		Version:               profile.Version,
+		Profile:               profile.Name,
+		ReportIntervalSeconds: profile.ReportIntervalSeconds,
+		EnabledCollectors:     profile.EnabledCollectors,
+		MountInclude:          profile.MountInclude,
+		MountExclude:          profile.MountExclude,
+	}, nil
+}
+
+// sendConfig sends config unless the agent already has that version.
+func (a *agentStream) sendConfig(config *pb.AgentConfig) error {
+	a.sendMu.Lock()
+	defer a.sendMu.Unlock()
+
+	if a.configVersion == config.Version {
+		return nil
+	}
+
+	if err := a.stream.Send(&pb.CollectorMessage{
+		Payload: &pb.CollectorMessage_Config{Config: config},
+	}); err != nil {
+		return err
+	}
+
+	a.configVersion = config.Version
+	return nil
+}
+
+// configFailed forgets that version was sent if it is the last config sent,
+// so that the next push sends it again.
+func (a *agentStream) configFailed(version int64) {
+	a.sendMu.Lock()
+	defer a.sendMu.Unlock()
+
+	if a.configVersion == version {
+		a.configVersion = -1
+	}
+}
+
+func (s *Server) pushConfig(hostname string, conn *agentStream) error {
+	if !conn.hasCapability(CapabilityConfig) {
+		return nil
+	}
+
+	config, err := s.configFor(hostname)
+	if err != nil {
+		return fmt.Errorf("resolve config: %w", err)
+	}
+	if err := conn.sendConfig(config); err != nil {
+		return err
+	}
+	s.setExpectedInterval(hostname, conn, time.Duration(config.ReportIntervalSeconds)*time.Second)
+	return nil
+}
+
+// PushConfig sends the current config to hostname if it is connected and its
+// effective config changed.
+func (s *Server) PushConfig(hostname string) {
+	s.mu.RLock()
+	conn, ok := s.streams[hostname]
+	s.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	if err := s.pushConfig(hostname, conn); err != nil {
+		log.Printf("Error pushing config to %s: %v", hostname, err)
+	}
+}
+
+// PushConfigs sends updated configs to every connected agent whose effective
+// config changed, e.g. after a profile was modified.
+func (s *Server) PushConfigs() {
+	s.mu.RLock()
+	hostnames := make([]string, 0, len(s.streams))
+	for hostname := range s.streams {
+		hostnames = append(hostnames, hostname)
+	}
+	s.mu.RUnlock()
+
+	for _, hostname := range hostnames {
+		s.PushConfig(hostname)
+	}
+}
+
+func (api *API) handleProfiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	profiles, err := api.db.GetAllProfiles()
+	if err != nil {
+		log.Printf("Error getting profiles: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"profiles": profiles,
+		"count":    len(profiles),
+	})
+}
+
+func (api *API) handleProfile(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Path[len("/api/v1/profiles/"):]
+	if name == "" {
+		http.Error(w, "Profile name required", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		profile, err := api.db.GetProfile(name)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Profile not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error getting profile %s: %v", name, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, http.StatusOK, profile)
+
+	case http.MethodPut:
+		var profile models.AgentProfile
+		if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		profile.Name = name
+
+		if err := validateProfile(&profile); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := api.db.UpsertProfile(&profile); err != nil {
+			log.Printf("Error saving profile %s: %v", name, err)
+			http.Error(w, "Failed to save profile", http.StatusInternalServerError)
+			return
+		}
+
+		go api.server.PushConfigs()
+
+		saved, err := api.db.GetProfile(name)
+		if err != nil {
+			log.Printf("Error getting profile %s: %v", name, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, http.StatusOK, saved)
+
+	case http.MethodDelete:
+		err := api.db.DeleteProfile(name)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Profile not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error deleting profile %s: %v", name, err)
+			http.Error(w, "Failed to delete profile", http.StatusInternalServerError)
+			return
+		}
+
+		go api.server.PushConfigs()
+
+		respondJSON(w, http.StatusOK, map[string]string{
+			"message": "Profile deleted successfully",
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,401 @@
+package commander
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metorial/sentinel/internal/models"
+	pb "github.com/metorial/sentinel/proto"
+)
+
+func TestUpsertProfile(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	profile := &models.AgentProfile{
+		Name:                  "default",
+		ReportIntervalSeconds: 30,
+		EnabledCollectors:     []string{"docker"},
+	}
+
+	if err := db.UpsertProfile(profile); err != nil {
+		t.Fatalf("Failed to create profile: %v", err)
+	}
+
+	firstVersion := profile.Version
+	if firstVersion == 0 {
+		t.Fatal("Expected non-zero version")
+	}
+
+	other := &models.AgentProfile{Name: "other"}
+	if err := db.UpsertProfile(other); err != nil {
+		t.Fatalf("Failed to create profile: %v", err)
+	}
+
+	profile.ReportIntervalSeconds = 60
+	if err := db.UpsertProfile(profile); err != nil {
+		t.Fatalf("Failed to update profile: %v", err)
+	}
+
+	if profile.Version <= other.Version {
+		t.Errorf("Expected version to exceed every earlier version, got %d (other: %d)", profile.Version, other.Version)
+	}
+
+	retrieved, err := db.GetProfile("default")
+	if err != nil {
+		t.Fatalf("Failed to get profile: %v", err)
+	}
+
+	if retrieved.ReportIntervalSeconds != 60 {
+		t.Errorf("Expected interval 60, got %d", retrieved.ReportIntervalSeconds)
+	}
+
+	if len(retrieved.EnabledCollectors) != 1 || retrieved.EnabledCollectors[0] != "docker" {
+		t.Errorf("Expected collectors [docker], got %v", retrieved.EnabledCollectors)
+	}
+
+	if retrieved.MountInclude == nil {
+		t.Error("Expected empty, non-nil mount include list")
+	}
+}
+
+func TestResolveProfile(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	for _, hostname := range []string{"web-1", "db-1"} {
+		host := &models.Host{
+			Hostname:          hostname,
+			IP:                "192.168.1.100",
+			UptimeSeconds:     3600,
+			CPUCores:          4,
+			TotalMemoryBytes:  8589934592,
+			TotalStorageBytes: 107374182400,
+			LastSeen:          time.Now(),
+			Online:            true,
+		}
+		if _, err := db.UpsertHost(host); err != nil {
+			t.Fatalf("Failed to insert host: %v", err)
+		}
+	}
+
+	profile, err := db.ResolveProfile("web-1")
+	if err != nil {
+		t.Fatalf("Failed to resolve profile: %v", err)
+	}
+	if profile != nil {
+		t.Errorf("Expected no profile, got %s", profile.Name)
+	}
+
+	if err := db.UpsertProfile(&models.AgentProfile{Name: "fleet"}); err != nil {
+		t.Fatalf("Failed to create profile: %v", err)
+	}
+	if err := db.UpsertProfile(&models.AgentProfile{Name: "databases", Selector: "database", Priority: 10}); err != nil {
+		t.Fatalf("Failed to create profile: %v", err)
+	}
+	if err := db.UpsertProfile(&models.AgentProfile{Name: "prod-databases", Selector: "database AND env=prod", Priority: 20}); err != nil {
+		t.Fatalf("Failed to create profile: %v", err)
+	}
+	if err := db.AddHostTag("db-1", "database"); err != nil {
+		t.Fatalf("Failed to add tag: %v", err)
+	}
+	if err := db.AddHostTag("web-1", "env:prod"); err != nil {
+		t.Fatalf("Failed to add tag: %v", err)
+	}
+
+	tests := []struct {
+		hostname string
+		want     string
+	}{
+		{"web-1", "fleet"},
+		{"db-1", "databases"},
+	}
+
+	for _, tt := range tests {
+		profile, err := db.ResolveProfile(tt.hostname)
+		if err != nil {
+			t.Fatalf("Failed to resolve profile for %s: %v", tt.hostname, err)
+		}
+		if profile == nil || profile.Name != tt.want {
+			t.Errorf("Expected profile %s for %s, got %v", tt.want, tt.hostname, profile)
+		}
+	}
+
+	if err := db.AddHostTag("db-1", "env:prod"); err != nil {
+		t.Fatalf("Failed to add tag: %v", err)
+	}
+	if profile, err := db.ResolveProfile("db-1"); err != nil || profile == nil || profile.Name != "prod-databases" {
+		t.Errorf("Expected the selector of prod-databases to match db-1, got %v, %v", profile, err)
+	}
+
+	if err := validateProfile(&models.AgentProfile{Name: "broken", Selector: "env in (prod"}); err == nil {
+		t.Error("Expected an invalid selector to be rejected")
+	}
+}
+
+func TestDeleteProfile(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	if err := db.UpsertProfile(&models.AgentProfile{Name: "temp"}); err != nil {
+		t.Fatalf("Failed to create profile: %v", err)
+	}
+
+	if err := db.DeleteProfile("temp"); err != nil {
+		t.Fatalf("Failed to delete profile: %v", err)
+	}
+
+	if err := db.DeleteProfile("temp"); err == nil {
+		t.Error("Expected error when deleting missing profile")
+	}
+}
+
+func TestConfigPushedOnConnect(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	profile := &models.AgentProfile{Name: "fleet", ReportIntervalSeconds: 30}
+	if err := db.UpsertProfile(profile); err != nil {
+		t.Fatalf("Failed to create profile: %v", err)
+	}
+
+	server := NewServer(db)
+	stream := setupTestStream(t, server)
+	sendHello(t, stream, "test-host")
+
+	if err := stream.Send(testMetrics("test-host")); err != nil {
+		t.Fatalf("Failed to send metrics: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Failed to receive ack: %v", err)
+	}
+
+	msg, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Failed to receive config: %v", err)
+	}
+
+	config := msg.GetConfig()
+	if config == nil {
+		t.Fatalf("Expected config, got %T", msg.Payload)
+	}
+
+	if config.Version != profile.Version || config.ReportIntervalSeconds != 30 {
+		t.Errorf("Unexpected config: %v", config)
+	}
+
+	if err := stream.Send(&pb.AgentMessage{
+		Payload: &pb.AgentMessage_ConfigApplied{
+			ConfigApplied: &pb.ConfigApplied{Version: config.Version, Success: true},
+		},
+	}); err != nil {
+		t.Fatalf("Failed to send config ack: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		host, err := db.GetHost("test-host")
+		if err != nil {
+			t.Fatalf("Failed to get host: %v", err)
+		}
+		if host.ConfigVersion == config.Version {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected applied version %d, got %d", config.Version, host.ConfigVersion)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestConfigResentAfterApplyFailed(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	profile := &models.AgentProfile{Name: "fleet", ReportIntervalSeconds: 30}
+	if err := db.UpsertProfile(profile); err != nil {
+		t.Fatalf("Failed to create profile: %v", err)
+	}
+
+	server := NewServer(db)
+	stream := setupTestStream(t, server)
+	sendHello(t, stream, "test-host")
+
+	if err := stream.Send(testMetrics("test-host")); err != nil {
+		t.Fatalf("Failed to send metrics: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Failed to receive ack: %v", err)
+	}
+	msg, err := stream.Recv()
+	if err != nil || msg.GetConfig() == nil {
+		t.Fatalf("Expected config, got %v, error %v", msg, err)
+	}
+
+	ackConfig := func(success bool, applyErr string) {
+		if err := stream.Send(&pb.AgentMessage{
+			Payload: &pb.AgentMessage_ConfigApplied{
+				ConfigApplied: &pb.ConfigApplied{Version: profile.Version, Success: success, Error: applyErr},
+			},
+		}); err != nil {
+			t.Fatalf("Failed to send config ack: %v", err)
+		}
+	}
+	waitForHost := func(done func(*models.Host) bool) *models.Host {
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			host, err := db.GetHost("test-host")
+			if err != nil {
+				t.Fatalf("Failed to get host: %v", err)
+			}
+			if done(host) || time.Now().After(deadline) {
+				return host
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	ackConfig(false, "bad mount pattern")
+	host := waitForHost(func(h *models.Host) bool { return h.ConfigError != "" })
+	if host.ConfigVersion != 0 || host.ConfigFailedVersion != profile.Version || host.ConfigError != "bad mount pattern" {
+		t.Errorf("Unexpected config status: version %d, failed version %d, error %q",
+			host.ConfigVersion, host.ConfigFailedVersion, host.ConfigError)
+	}
+
+	// The failed config is sent again on the next push
+	server.PushConfig("test-host")
+	msg, err = stream.Recv()
+	if err != nil || msg.GetConfig().GetVersion() != profile.Version {
+		t.Fatalf("Expected the config to be sent again, got %v, error %v", msg, err)
+	}
+
+	ackConfig(true, "")
+	host = waitForHost(func(h *models.Host) bool { return h.ConfigError == "" })
+	if host.ConfigVersion != profile.Version || host.ConfigFailedVersion != 0 || host.ConfigError != "" {
+		t.Errorf("Unexpected config status: version %d, failed version %d, error %q",
+			host.ConfigVersion, host.ConfigFailedVersion, host.ConfigError)
+	}
+}
+
+func TestConfigPushedAfterProfileDeleted(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	if err := db.UpsertProfile(&models.AgentProfile{Name: "fleet", ReportIntervalSeconds: 30}); err != nil {
+		t.Fatalf("Failed to create profile: %v", err)
+	}
+	latest := &models.AgentProfile{Name: "latest", Priority: 10, ReportIntervalSeconds: 45}
+	if err := db.UpsertProfile(latest); err != nil {
+		t.Fatalf("Failed to create profile: %v", err)
+	}
+
+	server := NewServer(db)
+	stream := setupTestStream(t, server)
+	sendHello(t, stream, "test-host")
+
+	if err := stream.Send(testMetrics("test-host")); err != nil {
+		t.Fatalf("Failed to send metrics: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Failed to receive ack: %v", err)
+	}
+	msg, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Failed to receive config: %v", err)
+	}
+	if config := msg.GetConfig(); config == nil || config.Version != latest.Version {
+		t.Fatalf("Expected config version %d, got %v", latest.Version, msg.Payload)
+	}
+
+	// Replace the profile with the highest version before the agent hears
+	// about the deletion: the new profile must not reuse its version
+	if err := db.DeleteProfile("latest"); err != nil {
+		t.Fatalf("Failed to delete profile: %v", err)
+	}
+	replacement := &models.AgentProfile{Name: "replacement", Priority: 10, ReportIntervalSeconds: 60}
+	if err := db.UpsertProfile(replacement); err != nil {
+		t.Fatalf("Failed to create profile: %v", err)
+	}
+	if replacement.Version <= latest.Version {
+		t.Errorf("Expected version above %d, got %d", latest.Version, replacement.Version)
+	}
+
+	server.PushConfig("test-host")
+	msg, err = stream.Recv()
+	if err != nil {
+		t.Fatalf("Failed to receive config: %v", err)
+	}
+	config := msg.GetConfig()
+	if config == nil || config.Profile != "replacement" || config.ReportIntervalSeconds != 60 {
+		t.Errorf("Expected the replacement profile to be pushed, got %v", msg.Payload)
+	}
+}
+
+func TestHandleProfile(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	server := NewServer(db)
+	api := NewAPI(db, server)
+	mux := http.NewServeMux()
+	api.RegisterRoutes(mux)
+
+	body := `{"selector":"web","report_interval_seconds":15,"mount_include":["/","/data*"]}`
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/profiles/web", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var saved models.AgentProfile
+	if err := json.NewDecoder(w.Body).Decode(&saved); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if saved.Name != "web" || saved.Selector != "web" || len(saved.MountInclude) != 2 {
+		t.Errorf("Unexpected profile: %+v", saved)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/profiles/bad", strings.NewReader(`{"mount_include":["[" ]}`))
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid pattern, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/profiles", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	var list map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if count := int(list["count"].(float64)); count != 1 {
+		t.Errorf("Expected 1 profile, got %d", count)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/profiles/web", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/profiles/web", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 after delete, got %d", w.Code)
+	}
+}
@@ -0,0 +1,620 @@
+package commander
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A Selector picks hosts by their tags. The syntax is
+//
+//	web                     host has the tag web, or a label web
+//	"web"                   host has exactly the tag web
+//	env=prod, env==prod     host has the label env with the value prod
+//	env!=prod               host has no label env, or one with another value
+//	env in (prod, staging)  host has the label env with one of the values
+//	env not in (dev)        host has no label env, or one with another value
+//	NOT expr, !expr
+//	expr AND expr, expr && expr
+//	expr OR expr, expr || expr
+//	(expr)
+//
+// NOT binds tighter than AND, which binds tighter than OR. Keywords are case
+// insensitive. Values containing characters other than letters, digits and
+// "._-/:" must be double quoted. Tags of the form key:value are labels
+// key=value, so env=prod matches a host tagged env:prod. A quoted tag on its
+// own matches that tag literally, as profiles, checks and maintenance windows
+// did before they took selectors.
+type Selector struct {
+	source string
+	root   selectorNode
+}
+
+// SelectorError is a syntax error in a selector, at byte offset Pos.
+type SelectorError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SelectorError) Error() string {
+	return fmt.Sprintf("invalid selector at position %d: %s", e.Pos+1, e.Msg)
+}
+
+// ParseSelector parses a selector. The empty selector matches every host.
+func ParseSelector(s string) (*Selector, error) {
+	p := &selectorParser{lexer: selectorLexer{input: s}}
+	p.next()
+	if p.tok.kind == tokEOF {
+		return &Selector{source: s}, nil
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %s", p.tok)
+	}
+	return &Selector{source: s, root: root}, nil
+}
+
+// Matches reports whether a host with the given tags matches the selector.
+func (s *Selector) Matches(tags []string) bool {
+	return s.matches(newLabelSet(tags))
+}
+
+func (s *Selector) matches(set labelSet) bool {
+	return s.root == nil || s.root.match(set)
+}
+
+// Empty reports whether the selector matches every host.
+func (s *Selector) Empty() bool {
+	return s.root == nil
+}
+
+func (s *Selector) String() string {
+	return s.source
+}
+
+// labelSet is what selectors are evaluated against: the tags of a host and
+// the labels derived from them.
+type labelSet struct {
+	tags   map[string]bool
+	labels map[string]string
+}
+
+func newLabelSet(tags []string) labelSet {
+	set := labelSet{tags: make(map[string]bool, len(tags)), labels: make(map[string]string)}
+	for _, tag := range tags {
+		set.tags[tag] = true
+		if key, value, ok := strings.Cut(tag, ":"); ok && key != "" {
+			set.labels[key] = value
+		}
+	}
+	return set
+}
+
+type selectorNode interface {
+	match(set labelSet) bool
+}
+
+type andNode struct{ left, right selectorNode }
+
+func (n andNode) match(set labelSet) bool { return n.left.match(set) && n.right.match(set) }
+
+type orNode struct{ left, right selectorNode }
+
+func (n orNode) match(set labelSet) bool { return n.left.match(set) || n.right.match(set) }
+
+type notNode struct{ expr selectorNode }
+
+func (n notNode) match(set labelSet) bool { return !n.expr.match(set) }
+
+type existsNode struct{ key string }
+
+func (n existsNode) match(set labelSet) bool {
+	_, ok := set.labels[n.key]
+	return ok || set.tags[n.key]
+}
+
+// tagNode matches hosts with exactly the tag, whatever their labels.
+type tagNode struct{ tag string }
+
+func (n tagNode) match(set labelSet) bool { return set.tags[n.tag] }
+
+// inNode matches hosts whose label key has one of values; key=value is an
+// inNode with a single value. Negated, it matches hosts without the label or
+// with another value.
+type inNode struct {
+	key     string
+	values  []string
+	negated bool
+}
+
+func (n inNode) match(set labelSet) bool {
+	value, ok := set.labels[n.key]
+	found := false
+	if ok {
+		for _, v := range n.values {
+			if v == value {
+				found = true
+				break
+			}
+		}
+	}
+	return found != n.negated
+}
+
+type selectorParser struct {
+	lexer selectorLexer
+	tok   selectorToken
+}
+
+func (p *selectorParser) next() {
+	p.tok = p.lexer.next()
+}
+
+func (p *selectorParser) errorf(format string, args ...interface{}) error {
+	if p.tok.kind == tokError {
+		return &SelectorError{Pos: p.tok.pos, Msg: p.tok.text}
+	}
+	return &SelectorError{Pos: p.tok.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *selectorParser) parseOr() (selectorNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *selectorParser) parseAnd() (selectorNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokAnd {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *selectorParser) parseUnary() (selectorNode, error) {
+	switch p.tok.kind {
+	case tokNot:
+		p.next()
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{expr}, nil
+
+	case tokLParen:
+		p.next()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokRParen {
+			return nil, p.errorf("expected ) but found %s", p.tok)
+		}
+		p.next()
+		return expr, nil
+
+	case tokIdent, tokString:
+		return p.parseTerm()
+
+	default:
+		return nil, p.errorf("expected a tag, label or ( but found %s", p.tok)
+	}
+}
+
+func (p *selectorParser) parseTerm() (selectorNode, error) {
+	key := p.tok.text
+	quoted := p.tok.kind == tokString
+	p.next()
+
+	switch p.tok.kind {
+	case tokEq, tokNotEq:
+		negated := p.tok.kind == tokNotEq
+		p.next()
+		if p.tok.kind != tokIdent && p.tok.kind != tokString {
+			return nil, p.errorf("expected a value for %s but found %s", key, p.tok)
+		}
+		value := p.tok.text
+		p.next()
+		return inNode{key: key, values: []string{value}, negated: negated}, nil
+
+	case tokNot:
+		// Only "not in" may follow a key
+		p.next()
+		if p.tok.kind != tokIn {
+			return nil, p.errorf("expected in after not but found %s", p.tok)
+		}
+		values, err := p.parseValueList(key)
+		if err != nil {
+			return nil, err
+		}
+		return inNode{key: key, values: values, negated: true}, nil
+
+	case tokIn:
+		values, err := p.parseValueList(key)
+		if err != nil {
+			return nil, err
+		}
+		return inNode{key: key, values: values}, nil
+
+	default:
+		if quoted {
+			return tagNode{tag: key}, nil
+		}
+		return existsNode{key: key}, nil
+	}
+}
+
+// quoteTag returns the selector matching exactly the given tag.
+func quoteTag(tag string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(tag) + `"`
+}
+
+// renameSelectorTag rewrites the mentions of the tag name in selector s as
+// newName, which must be a valid identifier, keeping the rest of s as written.
+// Quoted mentions stay quoted. Label keys and values aren't tags, so they are
+// left alone. It reports whether s mentioned the tag.
+func renameSelectorTag(s, name, newName string) (string, bool) {
+	type span struct {
+		token    selectorToken
+		end      int
+		isTagRef bool
+	}
+	l := &selectorLexer{input: s}
+	var tokens []span
+	for {
+		token := l.next()
+		if token.kind == tokEOF || token.kind == tokError {
+			break
+		}
+		tokens = append(tokens, span{token: token, end: l.pos})
+	}
+
+	// A tag is an identifier or string in the place of a term: not a value
+	// after = or != or in a list, and not followed by what makes it a key
+	inList := false
+	for i := range tokens {
+		kind := tokens[i].token.kind
+		switch kind {
+		case tokLParen:
+			inList = i > 0 && tokens[i-1].token.kind == tokIn
+			continue
+		case tokRParen:
+			inList = false
+			continue
+		case tokIdent, tokString:
+		default:
+			continue
+		}
+		if inList || i > 0 && (tokens[i-1].token.kind == tokEq || tokens[i-1].token.kind == tokNotEq) {
+			continue
+		}
+		if i+1 < len(tokens) {
+			switch tokens[i+1].token.kind {
+			case tokEq, tokNotEq, tokIn, tokNot:
+				continue
+			}
+		}
+		tokens[i].isTagRef = tokens[i].token.text == name
+	}
+
+	var b strings.Builder
+	last := 0
+	renamed := false
+	for _, t := range tokens {
+		if !t.isTagRef {
+			continue
+		}
+		b.WriteString(s[last:t.token.pos])
+		if t.token.kind == tokString {
+			b.WriteString(quoteTag(newName))
+		} else {
+			b.WriteString(newName)
+		}
+		last = t.end
+		renamed = true
+	}
+	b.WriteString(s[last:])
+	return b.String(), renamed
+}
+
+// checkSelectorIdent returns an error unless name can be written bare in a
+// selector, as a single identifier that isn't a keyword.
+func checkSelectorIdent(name string) error {
+	l := &selectorLexer{input: name}
+	token := l.next()
+	if token.kind != tokIdent || token.pos != 0 || token.text != name {
+		if token.kind == tokError {
+			return fmt.Errorf("%q can't be used in a selector: %s", name, token.text)
+		}
+		return fmt.Errorf("%q can't be used in a selector; use letters, digits and . _ - / only, and not and, or, not or in", name)
+	}
+	return nil
+}
+
+// parseValueList parses "in (a, b, ...)" with the parser at "in".
+func (p *selectorParser) parseValueList(key string) ([]string, error) {
+	p.next()
+	if p.tok.kind != tokLParen {
+		return nil, p.errorf("expected ( after in but found %s", p.tok)
+	}
+	p.next()
+
+	var values []string
+	for {
+		if p.tok.kind != tokIdent && p.tok.kind != tokString {
+			return nil, p.errorf("expected a value for %s but found %s", key, p.tok)
+		}
+		values = append(values, p.tok.text)
+		p.next()
+
+		switch p.tok.kind {
+		case tokComma:
+			p.next()
+		case tokRParen:
+			p.next()
+			return values, nil
+		default:
+			return nil, p.errorf("expected , or ) but found %s", p.tok)
+		}
+	}
+}
+
+type selectorTokenKind int
+
+const (
+	tokEOF selectorTokenKind = iota
+	tokError
+	tokIdent
+	tokString
+	tokEq
+	tokNotEq
+	tokLParen
+	tokRParen
+	tokComma
+	tokAnd
+	tokOr
+	tokNot
+	tokIn
+)
+
+type selectorToken struct {
+	kind selectorTokenKind
+	text string
+	pos  int
+}
+
+func (t selectorToken) String() string {
+	if t.kind == tokEOF {
+		return "end of selector"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+type selectorLexer struct {
+	input string
+	pos   int
+}
+
+var selectorKeywords = map[string]selectorTokenKind{
+	"and": tokAnd,
+	"or":  tokOr,
+	"not": tokNot,
+	"in":  tokIn,
+}
+
+func isSelectorIdentChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '.' || c == '_' || c == '-' || c == '/' || c == ':'
+}
+
+func (l *selectorLexer) next() selectorToken {
+	for l.pos < len(l.input) && (l.input[l.pos] == ' ' || l.input[l.pos] == '\t' || l.input[l.pos] == '\n') {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos >= len(l.input) {
+		return selectorToken{kind: tokEOF, pos: start}
+	}
+
+	token := func(kind selectorTokenKind, n int) selectorToken {
+		l.pos += n
+		return selectorToken{kind: kind, text: l.input[start:l.pos], pos: start}
+	}
+
+	rest := l.input[l.pos:]
+	switch {
+	case strings.HasPrefix(rest, "&&"):
+		return token(tokAnd, 2)
+	case strings.HasPrefix(rest, "||"):
+		return token(tokOr, 2)
+	case strings.HasPrefix(rest, "!="):
+		return token(tokNotEq, 2)
+	case strings.HasPrefix(rest, "=="):
+		return token(tokEq, 2)
+	}
+
+	switch c := rest[0]; {
+	case c == '=':
+		return token(tokEq, 1)
+	case c == '!':
+		return token(tokNot, 1)
+	case c == '(':
+		return token(tokLParen, 1)
+	case c == ')':
+		return token(tokRParen, 1)
+	case c == ',':
+		return token(tokComma, 1)
+	case c == '"':
+		var b strings.Builder
+		for i := 1; i < len(rest); i++ {
+			switch rest[i] {
+			case '\\':
+				if i+1 < len(rest) {
+					i++
+					b.WriteByte(rest[i])
+				}
+			case '"':
+				l.pos += i + 1
+				return selectorToken{kind: tokString, text: b.String(), pos: start}
+			default:
+				b.WriteByte(rest[i])
+			}
+		}
+		l.pos = len(l.input)
+		return selectorToken{kind: tokError, text: "unterminated string", pos: start}
+	case isSelectorIdentChar(c):
+		n := 1
+		for n < len(rest) && isSelectorIdentChar(rest[n]) {
+			n++
+		}
+		if kind, ok := selectorKeywords[strings.ToLower(rest[:n])]; ok {
+			return token(kind, n)
+		}
+		return token(tokIdent, n)
+	default:
+		l.pos++
+		return selectorToken{kind: tokError, text: fmt.Sprintf("unexpected character %q", c), pos: start}
+	}
+}
+
+// GetTagsByHost retrieves the tags of every host that has any, by host id,
+// with labels as key:value tags
+func (db *DB) GetTagsByHost() (map[int64][]string, error) {
+	rows, err := db.conn.Query(`SELECT host_id, name FROM host_tag_names ORDER BY host_id, name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make(map[int64][]string)
+	for rows.Next() {
+		var hostID int64
+		var tag string
+		if err := rows.Scan(&hostID, &tag); err != nil {
+			return nil, err
+		}
+		tags[hostID] = append(tags[hostID], tag)
+	}
+	return tags, rows.Err()
+}
+
+// SelectHostIDs retrieves the ids of the hosts matching selector
+func (db *DB) SelectHostIDs(selector *Selector) ([]int64, error) {
+	tags, err := db.GetTagsByHost()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.conn.Query(`SELECT id FROM hosts ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		if selector.Matches(tags[id]) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, rows.Err()
+}
+
+// selectorColumns are the stored selectors, which were a single tag name
+// before selectors existed.
+var selectorColumns = []struct{ table, column string }{
+	{"agent_profiles", "selector"},
+	{"checks", "selector"},
+	{"maintenance_windows", "tag"},
+}
+
+// migrateLegacySelectors quotes the stored selectors that name a single tag,
+// so that they keep matching exactly that tag rather than a label of the same
+// name too. It runs once per database; any stored selector that doesn't parse
+// afterwards fails the startup instead of being skipped when matching.
+func (db *DB) migrateLegacySelectors() error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`INSERT OR IGNORE INTO data_migrations (name) VALUES ('quote_legacy_selectors')`)
+	if err != nil {
+		return err
+	}
+	migrate, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	for _, c := range selectorColumns {
+		rows, err := tx.Query(fmt.Sprintf(`SELECT rowid, %s, %s IN (SELECT name FROM host_tag_names)
+			FROM %s WHERE %s != ''`, c.column, c.column, c.table, c.column))
+		if err != nil {
+			return err
+		}
+
+		updates := make(map[int64]string)
+		for rows.Next() {
+			var id int64
+			var value string
+			var isTag bool
+			if err := rows.Scan(&id, &value, &isTag); err != nil {
+				rows.Close()
+				return err
+			}
+
+			selector, err := ParseSelector(value)
+			plainTag := false
+			if err == nil {
+				_, plainTag = selector.root.(existsNode)
+			}
+			switch {
+			case migrate > 0 && (err != nil || plainTag || isTag):
+				updates[id] = quoteTag(value)
+			case err != nil:
+				rows.Close()
+				return fmt.Errorf("%s %d has an invalid selector %q: %w", c.table, id, value, err)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for id, value := range updates {
+			if _, err := tx.Exec(fmt.Sprintf(`UPDATE %s SET %s = ? WHERE rowid = ?`, c.table, c.column),
+				value, id); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,341 @@
+package commander
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSelectorMatches(t *testing.T) {
+	tags := []string{"web", "env:prod", "region:eu-west-1", "canary"}
+
+	tests := []struct {
+		selector string
+		want     bool
+	}{
+		{"", true},
+		{"web", true},
+		{"db", false},
+		{"env", true},
+		{"env:prod", true},
+		{"env=prod", true},
+		{"env==prod", true},
+		{"env=staging", false},
+		{"env!=staging", true},
+		{"team!=ops", true},
+		{"env in (staging, prod)", true},
+		{"env IN (staging)", false},
+		{"env not in (staging, dev)", true},
+		{"region in (eu-west-1)", true},
+		{"web AND env=prod", true},
+		{"web && db", false},
+		{"db OR canary", true},
+		{"db || env=staging", false},
+		{"NOT canary", false},
+		{"!db", true},
+		{"web AND NOT canary OR env=prod", true},
+		{"web AND NOT (canary OR env=prod)", false},
+		{"not db and (env=prod or env=staging)", true},
+		{`env="prod"`, true},
+		{`"web"`, true},
+		{`"env:prod"`, true},
+		// Quoted, a tag matches literally and not as a label
+		{`"env"`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			s, err := ParseSelector(tt.selector)
+			if err != nil {
+				t.Fatalf("ParseSelector(%q) error: %v", tt.selector, err)
+			}
+			if got := s.Matches(tags); got != tt.want {
+				t.Errorf("%q matches %v = %v, want %v", tt.selector, tags, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSelectorErrors(t *testing.T) {
+	tests := []struct {
+		selector string
+		pos      int
+		msg      string
+	}{
+		{"env=", 5, "expected a value for env but found end of selector"},
+		{"web AND", 8, "expected a tag, label or ( but found end of selector"},
+		{"(web OR db", 11, "expected ) but found end of selector"},
+		{"env in prod", 8, "expected ( after in but found \"prod\""},
+		{"env in (prod", 13, "expected , or ) but found end of selector"},
+		{"env not prod", 9, "expected in after not but found \"prod\""},
+		{"web db", 5, "unexpected \"db\""},
+		{"web & db", 5, "unexpected character '&'"},
+		{`env="prod`, 5, "unterminated string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			_, err := ParseSelector(tt.selector)
+			var selectorErr *SelectorError
+			if !errors.As(err, &selectorErr) {
+				t.Fatalf("Expected a SelectorError, got %v", err)
+			}
+			if selectorErr.Pos+1 != tt.pos || selectorErr.Msg != tt.msg {
+				t.Errorf("Expected %q at position %d, got %q at %d", tt.msg, tt.pos, selectorErr.Msg, selectorErr.Pos+1)
+			}
+		})
+	}
+}
+
+func TestRenameSelectorTag(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     string
+		renamed  bool
+	}{
+		{"web", "frontend", true},
+		{`"web"`, `"frontend"`, true},
+		{"web AND env=prod", "frontend AND env=prod", true},
+		{"NOT web OR (db && !web)", "NOT frontend OR (db && !frontend)", true},
+		{"webserver", "webserver", false},
+		{"env=web", "env=web", false},
+		{"web!=prod", "web!=prod", false},
+		{"web in (web, db)", "web in (web, db)", false},
+		{"web not in (db)", "web not in (db)", false},
+		{"tier in (db) and  web", "tier in (db) and  frontend", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			got, renamed := renameSelectorTag(tt.selector, "web", "frontend")
+			if got != tt.want || renamed != tt.renamed {
+				t.Errorf("renameSelectorTag(%q) = %q, %v, want %q, %v", tt.selector, got, renamed, tt.want, tt.renamed)
+			}
+		})
+	}
+}
+
+func TestSelectHostIDs(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	webID := createTestHost(t, db, "web-1")
+	dbID := createTestHost(t, db, "db-1")
+	untaggedID := createTestHost(t, db, "misc-1")
+	for hostname, tags := range map[string][]string{
+		"web-1": {"web", "env:prod"},
+		"db-1":  {"db", "env:staging"},
+	} {
+		for _, tag := range tags {
+			if err := db.AddHostTag(hostname, tag); err != nil {
+				t.Fatalf("Failed to add tag: %v", err)
+			}
+		}
+	}
+
+	tests := []struct {
+		selector string
+		want     []int64
+	}{
+		{"env=prod", []int64{webID}},
+		{"env in (prod, staging)", []int64{webID, dbID}},
+		{"NOT env", []int64{untaggedID}},
+		{"db AND env=prod", []int64{}},
+	}
+	for _, tt := range tests {
+		s, err := ParseSelector(tt.selector)
+		if err != nil {
+			t.Fatalf("ParseSelector(%q) error: %v", tt.selector, err)
+		}
+		ids, err := db.SelectHostIDs(s)
+		if err != nil {
+			t.Fatalf("Failed to select hosts: %v", err)
+		}
+		if len(ids) != len(tt.want) {
+			t.Errorf("%q: expected %v, got %v", tt.selector, tt.want, ids)
+			continue
+		}
+		for i := range ids {
+			if ids[i] != tt.want[i] {
+				t.Errorf("%q: expected %v, got %v", tt.selector, tt.want, ids)
+				break
+			}
+		}
+	}
+}
+
+func TestHandleSelector(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	server := NewServer(db)
+	api := NewAPI(db, server)
+	mux := http.NewServeMux()
+	api.RegisterRoutes(mux)
+
+	createTestHost(t, db, "web-1")
+	createTestHost(t, db, "web-2")
+	createTestHost(t, db, "db-1")
+	for _, tag := range []struct{ host, tag string }{
+		{"web-1", "env:prod"},
+		{"web-2", "env:staging"},
+		{"db-1", "env:prod"},
+		{"db-1", "db"},
+	} {
+		if err := db.AddHostTag(tag.host, tag.tag); err != nil {
+			t.Fatalf("Failed to add tag: %v", err)
+		}
+	}
+
+	get := func(path, selector string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path+"?"+url.Values{"selector": {selector}}.Encode(), nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/api/v1/hosts", "env=prod AND NOT db")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var hosts struct {
+		Hosts []struct {
+			Hostname string `json:"hostname"`
+		} `json:"hosts"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&hosts); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(hosts.Hosts) != 1 || hosts.Hosts[0].Hostname != "web-1" {
+		t.Errorf("Expected only web-1, got %+v", hosts.Hosts)
+	}
+
+	w = get("/api/v1/stats", "env=prod")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var stats map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if stats["total_hosts"] != float64(2) || stats["total_cpu_cores"] != float64(8) {
+		t.Errorf("Expected stats over the 2 prod hosts, got %+v", stats)
+	}
+
+	w = get("/api/v1/stats", "env=nothing")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	for _, path := range []string{"/api/v1/hosts", "/api/v1/stats"} {
+		w = get(path, "env in (prod")
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", path, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), "invalid selector at position") {
+			t.Errorf("%s: expected a parse error, got %q", path, w.Body.String())
+		}
+	}
+}
+
+func TestMigrateLegacySelectors(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	createTestHost(t, db, "web-1")
+	if err := db.AddHostTag("web-1", "web"); err != nil {
+		t.Fatalf("Failed to add tag: %v", err)
+	}
+	if err := db.AddHostTag("web-1", "env:prod"); err != nil {
+		t.Fatalf("Failed to add tag: %v", err)
+	}
+
+	// Store selectors the way they were stored before they were selectors:
+	// a single tag name, whatever its characters
+	for _, query := range []string{
+		`INSERT INTO checks (name, type, target, selector) VALUES
+			('plain', 'tcp', 'localhost:80', 'web'),
+			('label', 'tcp', 'localhost:80', 'env:prod'),
+			('keyword', 'tcp', 'localhost:80', 'or'),
+			('spaced', 'tcp', 'localhost:80', 'web app'),
+			('expression', 'tcp', 'localhost:80', 'web AND env=prod'),
+			('all', 'tcp', 'localhost:80', '')`,
+		`INSERT INTO agent_profiles (name, selector, version) VALUES ('web', 'web', 1)`,
+		`INSERT INTO maintenance_windows (tag, starts_at, ends_at) VALUES ('web', '2020-01-01', '2030-01-01')`,
+		`DELETE FROM data_migrations`,
+	} {
+		if _, err := db.conn.Exec(query); err != nil {
+			t.Fatalf("Failed to store legacy rows: %v", err)
+		}
+	}
+
+	if err := db.migrateLegacySelectors(); err != nil {
+		t.Fatalf("Failed to migrate selectors: %v", err)
+	}
+
+	want := map[string]string{
+		"plain":      `"web"`,
+		"label":      `"env:prod"`,
+		"keyword":    `"or"`,
+		"spaced":     `"web app"`,
+		"expression": "web AND env=prod",
+		"all":        "",
+	}
+	for name, selector := range want {
+		check, err := db.GetCheck(name)
+		if err != nil {
+			t.Fatalf("Failed to get check %s: %v", name, err)
+		}
+		if check.Selector != selector {
+			t.Errorf("Expected check %s to have selector %q, got %q", name, selector, check.Selector)
+		}
+	}
+	if profile, err := db.GetProfile("web"); err != nil || profile.Selector != `"web"` {
+		t.Errorf("Expected the profile selector quoted, got %+v, %v", profile, err)
+	}
+	var tag string
+	if err := db.conn.QueryRow(`SELECT tag FROM maintenance_windows`).Scan(&tag); err != nil || tag != `"web"` {
+		t.Errorf("Expected the maintenance window tag quoted, got %q, %v", tag, err)
+	}
+
+	// Legacy selectors match the tag they named, and only that tag
+	if err := db.AddHostTag("web-1", "web:frontend"); err != nil {
+		t.Fatalf("Failed to add tag: %v", err)
+	}
+	createTestHost(t, db, "web-2")
+	if err := db.AddHostTag("web-2", "web:backend"); err != nil {
+		t.Fatalf("Failed to add tag: %v", err)
+	}
+	for hostname, count := range map[string]int{"web-1": 4, "web-2": 1} {
+		checks, err := db.GetHostChecks(hostname)
+		if err != nil {
+			t.Fatalf("Failed to get checks of %s: %v", hostname, err)
+		}
+		if len(checks) != count {
+			t.Errorf("Expected %d checks for %s, got %+v", count, hostname, checks)
+		}
+	}
+
+	// Selectors saved once migrated are left alone
+	if _, err := db.conn.Exec(`UPDATE checks SET selector = 'web' WHERE name = 'plain'`); err != nil {
+		t.Fatalf("Failed to update check: %v", err)
+	}
+	if err := db.migrateLegacySelectors(); err != nil {
+		t.Fatalf("Failed to migrate selectors again: %v", err)
+	}
+	if check, _ := db.GetCheck("plain"); check.Selector != "web" {
+		t.Errorf("Expected a selector saved after the migration to stay, got %q", check.Selector)
+	}
+
+	// A stored selector that doesn't parse fails the startup
+	if _, err := db.conn.Exec(`UPDATE checks SET selector = 'web AND' WHERE name = 'plain'`); err != nil {
+		t.Fatalf("Failed to update check: %v", err)
+	}
+	if err := db.migrateLegacySelectors(); err == nil || !strings.Contains(err.Error(), "invalid selector") {
+		t.Errorf("Expected an invalid stored selector to fail the migration, got %v", err)
+	}
+}
@@ -0,0 +1,224 @@
+package commander
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/metorial/sentinel/internal/models"
+	pb "github.com/metorial/sentinel/proto"
+)
+
+// RecordSensors stores the latest readings of the hardware sensors of a host
+// and appends each to the sensor's history. Sensors missing from the report
+// are removed.
+func (db *DB) RecordSensors(hostname string, timestamp time.Time, sensors []models.Sensor) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var hostID int64
+	if err := tx.QueryRow(`SELECT id FROM hosts WHERE hostname = ?`, hostname).Scan(&hostID); err != nil {
+		return err
+	}
+
+	for _, s := range sensors {
+		var sensorID int64
+		err := tx.QueryRow(`
+		INSERT INTO host_sensors (host_id, chip, sensor, label, kind, value, critical, max, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(host_id, chip, sensor) DO UPDATE SET
+			label = excluded.label,
+			kind = excluded.kind,
+			value = excluded.value,
+			critical = excluded.critical,
+			max = excluded.max,
+			updated_at = excluded.updated_at
+		RETURNING id`,
+			hostID, s.Chip, s.Sensor, s.Label, s.Kind, s.Value, s.Critical, s.Max, timestamp).Scan(&sensorID)
+		if err != nil {
+			return fmt.Errorf("upsert sensor %s/%s: %w", s.Chip, s.Sensor, err)
+		}
+
+		_, err = tx.Exec(`INSERT INTO sensor_readings (sensor_id, timestamp, value) VALUES (?, ?, ?)`,
+			sensorID, timestamp, s.Value)
+		if err != nil {
+			return fmt.Errorf("insert reading of sensor %s/%s: %w", s.Chip, s.Sensor, err)
+		}
+	}
+
+	_, err = tx.Exec(`DELETE FROM host_sensors WHERE host_id = ? AND updated_at < ?`, hostID, timestamp)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+const sensorColumns = `s.id, s.host_id, s.chip, s.sensor, s.label, s.kind, s.value, s.critical, s.max, s.updated_at`
+
+func scanSensor(scanner interface{ Scan(...any) error }) (models.Sensor, error) {
+	var s models.Sensor
+	err := scanner.Scan(&s.ID, &s.HostID, &s.Chip, &s.Sensor, &s.Label, &s.Kind, &s.Value,
+		&s.Critical, &s.Max, &s.UpdatedAt)
+	s.OverCritical = s.Kind == "temperature" && s.Critical > 0 && s.Value >= s.Critical
+	return s, err
+}
+
+// GetHostSensors retrieves the sensors of a host with their latest reading
+func (db *DB) GetHostSensors(hostname string) ([]models.Sensor, error) {
+	query := `SELECT ` + sensorColumns + ` FROM host_sensors s
+	          JOIN hosts h ON s.host_id = h.id
+	          WHERE h.hostname = ?
+	          ORDER BY s.chip, s.kind DESC, s.id`
+
+	rows, err := db.conn.Query(query, hostname)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sensors []models.Sensor
+	for rows.Next() {
+		s, err := scanSensor(rows)
+		if err != nil {
+			return nil, err
+		}
+		sensors = append(sensors, s)
+	}
+	return sensors, rows.Err()
+}
+
+// GetSensor retrieves a sensor of a host by id
+func (db *DB) GetSensor(hostname string, id int64) (*models.Sensor, error) {
+	query := `SELECT ` + sensorColumns + ` FROM host_sensors s
+	          JOIN hosts h ON s.host_id = h.id
+	          WHERE h.hostname = ? AND s.id = ?`
+
+	s, err := scanSensor(db.conn.QueryRow(query, hostname, id))
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// GetSensorReadings retrieves the most recent readings of a sensor
+func (db *DB) GetSensorReadings(sensorID int64, limit int) ([]models.SensorReading, error) {
+	query := `SELECT sensor_id, timestamp, value
+	          FROM sensor_readings
+	          WHERE sensor_id = ?
+	          ORDER BY timestamp DESC
+	          LIMIT ?`
+
+	rows, err := db.conn.Query(query, sensorID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var readings []models.SensorReading
+	for rows.Next() {
+		var r models.SensorReading
+		if err := rows.Scan(&r.SensorID, &r.Timestamp, &r.Value); err != nil {
+			return nil, err
+		}
+		readings = append(readings, r)
+	}
+	return readings, rows.Err()
+}
+
+func (s *Server) handleSensors(hostname string, readings *pb.SensorReadings) error {
+	sensors := make([]models.Sensor, 0, len(readings.Sensors))
+	for _, r := range readings.Sensors {
+		sensors = append(sensors, models.Sensor{
+			Chip:     r.Chip,
+			Sensor:   r.Sensor,
+			Label:    r.Label,
+			Kind:     r.Kind,
+			Value:    r.Value,
+			Critical: r.Critical,
+			Max:      r.Max,
+		})
+	}
+
+	if err := s.db.RecordSensors(hostname, time.Unix(readings.Timestamp, 0), sensors); err != nil {
+		return fmt.Errorf("record sensors: %w", err)
+	}
+	return nil
+}
+
+func (api *API) handleHostSensors(w http.ResponseWriter, r *http.Request, hostname, sensorID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, err := api.db.GetHost(hostname); err == sql.ErrNoRows {
+		http.Error(w, "Host not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("Error getting host %s: %v", hostname, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if sensorID != "" {
+		api.handleHostSensor(w, r, hostname, sensorID)
+		return
+	}
+
+	sensors, err := api.db.GetHostSensors(hostname)
+	if err != nil {
+		log.Printf("Error getting sensors for %s: %v", hostname, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"sensors": sensors,
+		"count":   len(sensors),
+	})
+}
+
+func (api *API) handleHostSensor(w http.ResponseWriter, r *http.Request, hostname, sensorID string) {
+	id, err := strconv.ParseInt(sensorID, 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid sensor id", http.StatusBadRequest)
+		return
+	}
+
+	sensor, err := api.db.GetSensor(hostname, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Sensor not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting sensor %d of %s: %v", id, hostname, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	limit := 100
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 1000 {
+			limit = l
+		}
+	}
+
+	readings, err := api.db.GetSensorReadings(id, limit)
+	if err != nil {
+		log.Printf("Error getting readings of sensor %d: %v", id, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"sensor":   sensor,
+		"readings": readings,
+	})
+}
@@ -0,0 +1,143 @@
+package commander
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/metorial/sentinel/internal/models"
+)
+
+func TestRecordSensors(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	createTestHost(t, db, "web-1")
+
+	now := time.Now()
+	err := db.RecordSensors("web-1", now.Add(-time.Minute), []models.Sensor{
+		{Chip: "coretemp", Sensor: "temp1", Label: "Package id 0", Kind: "temperature", Value: 60, Critical: 100},
+		{Chip: "nct6775", Sensor: "fan1", Kind: "fan", Value: 1200},
+	})
+	if err != nil {
+		t.Fatalf("Failed to record sensors: %v", err)
+	}
+
+	err = db.RecordSensors("web-1", now, []models.Sensor{
+		{Chip: "coretemp", Sensor: "temp1", Label: "Package id 0", Kind: "temperature", Value: 102, Critical: 100},
+	})
+	if err != nil {
+		t.Fatalf("Failed to record sensors: %v", err)
+	}
+
+	sensors, err := db.GetHostSensors("web-1")
+	if err != nil {
+		t.Fatalf("Failed to get sensors: %v", err)
+	}
+	if len(sensors) != 1 {
+		t.Fatalf("Expected the missing fan to be removed, got %+v", sensors)
+	}
+	if sensors[0].Value != 102 || !sensors[0].OverCritical {
+		t.Errorf("Expected latest reading of 102 flagged over critical, got %+v", sensors[0])
+	}
+
+	readings, err := db.GetSensorReadings(sensors[0].ID, 10)
+	if err != nil {
+		t.Fatalf("Failed to get sensor readings: %v", err)
+	}
+	if len(readings) != 2 || readings[0].Value != 102 || readings[1].Value != 60 {
+		t.Errorf("Expected readings 102, 60, got %+v", readings)
+	}
+
+	if err := db.RecordSensors("unknown", now, nil); err == nil {
+		t.Error("Expected error for unknown host")
+	}
+}
+
+func TestHandleHostSensors(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	server := NewServer(db)
+	api := NewAPI(db, server)
+	mux := http.NewServeMux()
+	api.RegisterRoutes(mux)
+
+	createTestHost(t, db, "web-1")
+	err := db.RecordSensors("web-1", time.Now(), []models.Sensor{
+		{Chip: "coretemp", Sensor: "temp1", Kind: "temperature", Value: 55, Critical: 100},
+		{Chip: "nvme", Sensor: "temp1", Kind: "temperature", Value: 85, Critical: 84.85},
+	})
+	if err != nil {
+		t.Fatalf("Failed to record sensors: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/hosts/web-1/sensors", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var list struct {
+		Sensors []models.Sensor `json:"sensors"`
+		Count   int             `json:"count"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if list.Count != 2 || list.Sensors[0].OverCritical || !list.Sensors[1].OverCritical {
+		t.Fatalf("Expected only the nvme sensor over critical, got %+v", list)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/hosts/web-1/sensors/%d", list.Sensors[1].ID), nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var detail struct {
+		Sensor   models.Sensor          `json:"sensor"`
+		Readings []models.SensorReading `json:"readings"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&detail); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if detail.Sensor.Chip != "nvme" || len(detail.Readings) != 1 || detail.Readings[0].Value != 85 {
+		t.Errorf("Unexpected sensor detail: %+v", detail)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/hosts/web-1", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	var host struct {
+		Sensors []models.Sensor `json:"sensors"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&host); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(host.Sensors) != 2 || !host.Sensors[1].OverCritical {
+		t.Errorf("Expected host detail to include flagged sensors, got %+v", host.Sensors)
+	}
+
+	for path, want := range map[string]int{
+		"/api/v1/hosts/web-1/sensors/abc": http.StatusBadRequest,
+		"/api/v1/hosts/web-1/sensors/999": http.StatusNotFound,
+		"/api/v1/hosts/unknown/sensors":   http.StatusNotFound,
+	} {
+		req = httptest.NewRequest(http.MethodGet, path, nil)
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		if w.Code != want {
+			t.Errorf("GET %s: expected status %d, got %d", path, want, w.Code)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package commander
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -9,34 +10,117 @@ import (
 
 	"github.com/metorial/sentinel/internal/models"
 	pb "github.com/metorial/sentinel/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type Server struct {
 	pb.UnimplementedMetricsCollectorServer
 	db      *DB
-	streams map[string]pb.MetricsCollector_StreamMetricsServer
+	streams map[string]*agentStream
 	mu      sync.RWMutex
+
+	pending   map[string]chan *pb.CommandResult
+	pendingMu sync.Mutex
+
+	skewThreshold time.Duration
+	skewAction    SkewAction
+
+	startedAt time.Time
+
+	// Serializes evaluations of the tagging rules
+	tagRulesMu sync.Mutex
+}
+
+// agentStream wraps a registered agent stream so that acknowledgments from
+// StreamMetrics and commands from the API can be sent concurrently.
+type agentStream struct {
+	stream pb.MetricsCollector_StreamMetricsServer
+	sendMu sync.Mutex
+
+	// Version of the last config sent on this stream, guarded by sendMu.
+	// Agents start every stream on their defaults, i.e. version 0; -1 after
+	// the agent failed to apply the last config sent.
+	configVersion int64
+	// Check assignment last sent on this stream, guarded by sendMu.
+	checks *pb.CheckAssignment
+
+	// Set from the agent's Hello before the stream is registered; nil and
+	// empty for agents predating the handshake.
+	hello        *pb.Hello
+	capabilities map[string]bool
+
+	presenceMu  sync.Mutex
+	connectedAt time.Time
+	lastMetrics time.Time
+	interval    time.Duration
+}
+
+func (a *agentStream) send(msg *pb.CollectorMessage) error {
+	a.sendMu.Lock()
+	defer a.sendMu.Unlock()
+	return a.stream.Send(msg)
 }
 
-func NewServer(db *DB) *Server {
-	return &Server{
-		db:      db,
-		streams: make(map[string]pb.MetricsCollector_StreamMetricsServer),
+// sendResend tells the agent that the data of its collector named collector
+// couldn't be stored, so that it sends it again rather than only on change.
+func (a *agentStream) sendResend(collector string, err error) error {
+	return a.send(&pb.CollectorMessage{
+		Payload: &pb.CollectorMessage_Ack{
+			Ack: &pb.Acknowledgment{
+				Success: false,
+				Message: err.Error(),
+				Resend:  collector,
+			},
+		},
+	})
+}
+
+func NewServer(db *DB, opts ...ServerOption) *Server {
+	s := &Server{
+		db:         db,
+		streams:    make(map[string]*agentStream),
+		pending:    make(map[string]chan *pb.CommandResult),
+		skewAction: SkewIgnore,
+		startedAt:  time.Now(),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
-func (s *Server) StreamMetrics(stream pb.MetricsCollector_StreamMetricsServer) error {
+func (s *Server) StreamMetrics(stream pb.MetricsCollector_StreamMetricsServer) (streamErr error) {
 	ctx := stream.Context()
 	log.Println("New client connected")
 
 	var hostname string
+	var seenMetrics bool
+	conn := &agentStream{
+		stream:      stream,
+		connectedAt: time.Now(),
+		interval:    defaultReportInterval,
+	}
 
 	defer func() {
 		if hostname != "" {
 			s.mu.Lock()
-			delete(s.streams, hostname)
+			current := s.streams[hostname] == conn
+			if current {
+				delete(s.streams, hostname)
+			}
 			s.mu.Unlock()
 			log.Printf("Removed stream for host: %s", hostname)
+
+			// A replaced stream says nothing about the host's presence.
+			if !current {
+				return
+			}
+			if streamErr == nil {
+				s.setPresence(hostname, models.HostOffline, "agent closed the stream")
+			} else {
+				s.setPresence(hostname, models.HostStale, fmt.Sprintf("stream lost: %v", streamErr))
+			}
 		}
 	}()
 
@@ -59,19 +143,53 @@ func (s *Server) StreamMetrics(stream pb.MetricsCollector_StreamMetricsServer) e
 		}
 
 		switch payload := msg.Payload.(type) {
+		case *pb.AgentMessage_Hello:
+			hello := payload.Hello
+			if hostname != "" {
+				log.Printf("Ignoring repeated hello from %s", hostname)
+				continue
+			}
+			if err := checkProtocol(hello.ProtocolVersion); err != nil {
+				log.Printf("Rejecting agent %s (version %s): %v", hello.Hostname, hello.AgentVersion, err)
+				return status.Error(codes.FailedPrecondition, err.Error())
+			}
+
+			conn.hello = hello
+			conn.capabilities = acceptCapabilities(hello.Capabilities)
+			hostname = hello.Hostname
+			s.register(hostname, conn)
+			log.Printf("Agent %s version %s (protocol %d, %s/%s) connected",
+				hostname, hello.AgentVersion, hello.ProtocolVersion, hello.Os, hello.Arch)
+			// The host exists from here on, so that what the collectors send
+			// ahead of the first metrics is stored
+			if err := s.db.RegisterHost(hostname); err != nil {
+				log.Printf("Error registering host %s: %v", hostname, err)
+			}
+			s.saveAgentInfo(hostname, conn)
+			s.setPresence(hostname, models.HostConnecting,
+				fmt.Sprintf("stream opened by agent %s", hello.AgentVersion))
+
+			if err := conn.send(&pb.CollectorMessage{
+				Payload: &pb.CollectorMessage_Welcome{Welcome: conn.welcome()},
+			}); err != nil {
+				return err
+			}
+
 		case *pb.AgentMessage_Metrics:
 			metrics := payload.Metrics
 			if hostname == "" {
+				// Agents predating the handshake register with their first metrics.
 				hostname = metrics.Hostname
-				s.mu.Lock()
-				s.streams[hostname] = stream
-				s.mu.Unlock()
-				log.Printf("Registered stream for host: %s", hostname)
+				s.register(hostname, conn)
 			}
 
-			if err := s.handleMetrics(metrics); err != nil {
+			// Rejected samples still mark the host as seen, so the stream
+			// carries on as if they had been stored.
+			err := s.handleMetrics(metrics)
+			rejected := errors.Is(err, errClockSkew)
+			if err != nil && !rejected {
 				log.Printf("Error handling metrics from %s: %v", metrics.Hostname, err)
-				if err := stream.Send(&pb.CollectorMessage{
+				if err := conn.send(&pb.CollectorMessage{
 					Payload: &pb.CollectorMessage_Ack{
 						Ack: &pb.Acknowledgment{
 							Success: false,
@@ -84,28 +202,188 @@ func (s *Server) StreamMetrics(stream pb.MetricsCollector_StreamMetricsServer) e
 				continue
 			}
 
-			if err := stream.Send(&pb.CollectorMessage{
-				Payload: &pb.CollectorMessage_Ack{
-					Ack: &pb.Acknowledgment{
-						Success: true,
-						Message: "received",
-					},
-				},
+			conn.metricsReceived(time.Now())
+			s.setPresence(hostname, models.HostOnline, "metrics received")
+
+			firstMetrics := !seenMetrics
+			seenMetrics = true
+			if firstMetrics {
+				// Tags from tagging rules are in place before config and
+				// checks are pushed below
+				s.applyTagRules(hostname)
+			}
+
+			ack := &pb.Acknowledgment{
+				Success: true,
+				Message: "received",
+			}
+			if rejected {
+				log.Printf("Rejected metrics from %s: %v", metrics.Hostname, err)
+				ack = &pb.Acknowledgment{
+					Success: false,
+					Message: err.Error(),
+				}
+			}
+			if err := conn.send(&pb.CollectorMessage{
+				Payload: &pb.CollectorMessage_Ack{Ack: ack},
 			}); err != nil {
 				return err
 			}
 
+			if firstMetrics {
+				if err := s.pushConfig(hostname, conn); err != nil {
+					log.Printf("Error pushing config to %s: %v", hostname, err)
+				}
+				if err := s.pushChecks(hostname, conn); err != nil {
+					log.Printf("Error pushing checks to %s: %v", hostname, err)
+				}
+			}
+
+		case *pb.AgentMessage_ConfigApplied:
+			applied := payload.ConfigApplied
+			if hostname == "" {
+				log.Println("Ignoring config acknowledgment from unregistered stream")
+				continue
+			}
+			applyErr := ""
+			if !applied.Success {
+				log.Printf("Host %s failed to apply config version %d: %s", hostname, applied.Version, applied.Error)
+				conn.configFailed(applied.Version)
+				applyErr = applied.Error
+				if applyErr == "" {
+					applyErr = "unknown error"
+				}
+			}
+			if err := s.db.SetHostConfigStatus(hostname, applied.Version, applyErr); err != nil {
+				log.Printf("Error recording config status for %s: %v", hostname, err)
+			}
+
+		case *pb.AgentMessage_Inventory:
+			if hostname == "" {
+				log.Println("Ignoring inventory from unregistered stream")
+				continue
+			}
+			if err := s.handleInventory(hostname, payload.Inventory); err != nil {
+				log.Printf("Error handling inventory from %s: %v", hostname, err)
+				if err := conn.sendResend("inventory", err); err != nil {
+					return err
+				}
+			}
+
+		case *pb.AgentMessage_Cgroups:
+			if hostname == "" {
+				log.Println("Ignoring cgroup metrics from unregistered stream")
+				continue
+			}
+			if err := s.handleCgroups(hostname, payload.Cgroups); err != nil {
+				log.Printf("Error handling cgroup metrics from %s: %v", hostname, err)
+			}
+
+		case *pb.AgentMessage_Containers:
+			if hostname == "" {
+				log.Println("Ignoring container list from unregistered stream")
+				continue
+			}
+			if err := s.handleContainers(hostname, payload.Containers); err != nil {
+				log.Printf("Error handling container list from %s: %v", hostname, err)
+			}
+
+		case *pb.AgentMessage_Certificates:
+			if hostname == "" {
+				log.Println("Ignoring certificate list from unregistered stream")
+				continue
+			}
+			if err := s.handleCertificates(hostname, payload.Certificates); err != nil {
+				log.Printf("Error handling certificate list from %s: %v", hostname, err)
+			}
+
+		case *pb.AgentMessage_Units:
+			if hostname == "" {
+				log.Println("Ignoring unit states from unregistered stream")
+				continue
+			}
+			if err := s.handleUnits(hostname, payload.Units); err != nil {
+				log.Printf("Error handling unit states from %s: %v", hostname, err)
+				if err := conn.sendResend("systemd", err); err != nil {
+					return err
+				}
+			}
+
+		case *pb.AgentMessage_LogCounts:
+			if hostname == "" {
+				log.Println("Ignoring log counts from unregistered stream")
+				continue
+			}
+			if err := s.handleLogCounts(hostname, payload.LogCounts); err != nil {
+				log.Printf("Error handling log counts from %s: %v", hostname, err)
+			}
+
+		case *pb.AgentMessage_Sensors:
+			if hostname == "" {
+				log.Println("Ignoring sensor readings from unregistered stream")
+				continue
+			}
+			if err := s.handleSensors(hostname, payload.Sensors); err != nil {
+				log.Printf("Error handling sensor readings from %s: %v", hostname, err)
+			}
+
+		case *pb.AgentMessage_CheckResult:
+			if hostname == "" {
+				log.Println("Ignoring check result from unregistered stream")
+				continue
+			}
+			if err := s.handleCheckResult(hostname, payload.CheckResult); err != nil {
+				log.Printf("Error handling check result from %s: %v", hostname, err)
+			}
+
+		case *pb.AgentMessage_CommandResult:
+			s.resolveCommand(payload.CommandResult)
+
 		default:
 			log.Printf("Unknown message type: %T", payload)
 		}
 	}
 }
 
+func (s *Server) register(hostname string, conn *agentStream) {
+	s.mu.Lock()
+	s.streams[hostname] = conn
+	s.mu.Unlock()
+	log.Printf("Registered stream for host: %s", hostname)
+}
+
+// saveAgentInfo records the Hello details of the host.
+func (s *Server) saveAgentInfo(hostname string, conn *agentStream) {
+	hello := conn.hello
+	if hello == nil {
+		return
+	}
+
+	capabilities := make([]string, 0, len(conn.capabilities))
+	for _, c := range serverCapabilities {
+		if conn.capabilities[c] {
+			capabilities = append(capabilities, c)
+		}
+	}
+
+	if err := s.db.SetHostAgentInfo(hostname, hello.AgentVersion, hello.ProtocolVersion,
+		hello.Os, hello.Arch, capabilities); err != nil {
+		log.Printf("Error recording agent info for %s: %v", hostname, err)
+	}
+}
+
 func (s *Server) handleMetrics(metrics *pb.HostMetrics) error {
 	if metrics.Info == nil || metrics.Usage == nil {
 		return fmt.Errorf("missing info or usage data")
 	}
 
+	received := time.Now()
+	timestamp := time.Unix(metrics.Timestamp, 0)
+	skew, skewed := s.measureSkew(timestamp, received)
+	if skewed && s.skewAction != SkewIgnore {
+		timestamp = received
+	}
+
 	host := &models.Host{
 		Hostname:          metrics.Hostname,
 		IP:                metrics.Ip,
@@ -113,8 +391,9 @@ func (s *Server) handleMetrics(metrics *pb.HostMetrics) error {
 		CPUCores:          metrics.Info.CpuCores,
 		TotalMemoryBytes:  metrics.Info.TotalMemoryBytes,
 		TotalStorageBytes: metrics.Info.TotalStorageBytes,
-		LastSeen:          time.Unix(metrics.Timestamp, 0),
+		LastSeen:          timestamp,
 		Online:            true,
+		ClockSkewSeconds:  skew.Seconds(),
 	}
 
 	hostID, err := s.db.UpsertHost(host)
@@ -122,9 +401,16 @@ func (s *Server) handleMetrics(metrics *pb.HostMetrics) error {
 		return fmt.Errorf("upsert host: %w", err)
 	}
 
+	if err := s.updateSkewAlert(hostID, skew, skewed, received); err != nil {
+		return fmt.Errorf("update clock skew alert: %w", err)
+	}
+	if skewed && s.skewAction == SkewReject {
+		return fmt.Errorf("%w: %s is more than %s", errClockSkew, skew, s.skewThreshold)
+	}
+
 	usage := &models.HostUsage{
 		HostID:           hostID,
-		Timestamp:        time.Unix(metrics.Timestamp, 0),
+		Timestamp:        timestamp,
 		CPUPercent:       metrics.Usage.CpuPercent,
 		UsedMemoryBytes:  metrics.Usage.UsedMemoryBytes,
 		UsedStorageBytes: metrics.Usage.UsedStorageBytes,
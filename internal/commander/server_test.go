@@ -2,14 +2,19 @@ package commander
 
 import (
 	"context"
+	"strings"
 	"testing"
 	"time"
 
 	"net"
 
+	"github.com/metorial/sentinel/internal/models"
+	"github.com/metorial/sentinel/internal/version"
 	pb "github.com/metorial/sentinel/proto"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/test/bufconn"
 )
 
@@ -173,3 +178,366 @@ func TestHandleMetricsMissingData(t *testing.T) {
 		})
 	}
 }
+
+func setupTestStream(t *testing.T, server *Server) pb.MetricsCollector_StreamMetricsClient {
+	t.Helper()
+
+	listener := bufconn.Listen(bufSize)
+	grpcServer := grpc.NewServer()
+	pb.RegisterMetricsCollectorServer(grpcServer, server)
+
+	go func() {
+		if err := grpcServer.Serve(listener); err != nil {
+			t.Logf("Server error: %v", err)
+		}
+	}()
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return listener.Dial()
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	stream, err := pb.NewMetricsCollectorClient(conn).StreamMetrics(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to create stream: %v", err)
+	}
+
+	return stream
+}
+
+func testMetrics(hostname string) *pb.AgentMessage {
+	return &pb.AgentMessage{
+		Payload: &pb.AgentMessage_Metrics{
+			Metrics: &pb.HostMetrics{
+				Hostname:  hostname,
+				Ip:        "192.168.1.100",
+				Timestamp: time.Now().Unix(),
+				Info: &pb.HostInfo{
+					UptimeSeconds:     3600,
+					CpuCores:          4,
+					TotalMemoryBytes:  8589934592,
+					TotalStorageBytes: 107374182400,
+				},
+				Usage: &pb.ResourceUsage{
+					CpuPercent:       45.5,
+					UsedMemoryBytes:  4294967296,
+					UsedStorageBytes: 53687091200,
+				},
+			},
+		},
+	}
+}
+
+func sendHello(t *testing.T, stream pb.MetricsCollector_StreamMetricsClient, hostname string) *pb.Welcome {
+	t.Helper()
+
+	if err := stream.Send(&pb.AgentMessage{
+		Payload: &pb.AgentMessage_Hello{
+			Hello: &pb.Hello{
+				Hostname:        hostname,
+				AgentVersion:    version.Version,
+				ProtocolVersion: version.Protocol,
+				Os:              "linux",
+				Arch:            "amd64",
+				Capabilities:    []string{CapabilityCommands, CapabilityConfig, "teleport"},
+			},
+		},
+	}); err != nil {
+		t.Fatalf("Failed to send hello: %v", err)
+	}
+
+	msg, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Failed to receive welcome: %v", err)
+	}
+
+	welcome := msg.GetWelcome()
+	if welcome == nil {
+		t.Fatalf("Expected welcome, got %T", msg.Payload)
+	}
+	return welcome
+}
+
+func TestHandshake(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	server := NewServer(db)
+	stream := setupTestStream(t, server)
+
+	welcome := sendHello(t, stream, "test-host")
+
+	if welcome.ProtocolVersion != version.Protocol {
+		t.Errorf("Expected protocol %d, got %d", version.Protocol, welcome.ProtocolVersion)
+	}
+
+	if len(welcome.AcceptedCapabilities) != 2 {
+		t.Errorf("Expected unknown capabilities to be dropped, got %v", welcome.AcceptedCapabilities)
+	}
+
+	if err := stream.Send(testMetrics("test-host")); err != nil {
+		t.Fatalf("Failed to send metrics: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Failed to receive ack: %v", err)
+	}
+
+	host, err := db.GetHost("test-host")
+	if err != nil {
+		t.Fatalf("Failed to get host: %v", err)
+	}
+
+	if host.AgentVersion != version.Version || host.ProtocolVersion != version.Protocol {
+		t.Errorf("Expected agent %s/%d, got %s/%d", version.Version, version.Protocol, host.AgentVersion, host.ProtocolVersion)
+	}
+
+	if host.OS != "linux" || host.Arch != "amd64" {
+		t.Errorf("Expected linux/amd64, got %s/%s", host.OS, host.Arch)
+	}
+
+	if isOutdated(host) {
+		t.Error("Expected host with current agent not to be outdated")
+	}
+}
+
+func TestInventoryBeforeFirstMetrics(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	server := NewServer(db)
+	stream := setupTestStream(t, server)
+
+	// A new host whose collectors run before its first metrics are sent
+	sendHello(t, stream, "new-host")
+	if err := stream.Send(&pb.AgentMessage{
+		Payload: &pb.AgentMessage_Inventory{Inventory: &pb.Inventory{
+			Hostname: "new-host", OsName: "ubuntu", Timestamp: time.Now().Unix(),
+		}},
+	}); err != nil {
+		t.Fatalf("Failed to send inventory: %v", err)
+	}
+	if err := stream.Send(testMetrics("new-host")); err != nil {
+		t.Fatalf("Failed to send metrics: %v", err)
+	}
+	msg, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Failed to receive ack: %v", err)
+	}
+	if ack := msg.GetAck(); ack == nil || !ack.Success {
+		t.Fatalf("Expected the metrics to be acknowledged, got %v", msg)
+	}
+
+	history, err := db.GetInventoryHistory("new-host", 10)
+	if err != nil {
+		t.Fatalf("Failed to get inventory history: %v", err)
+	}
+	if len(history) != 1 || history[0].Inventory.OSName != "ubuntu" {
+		t.Errorf("Expected the inventory sent before the metrics to be stored, got %+v", history)
+	}
+	host, err := db.GetHost("new-host")
+	if err != nil {
+		t.Fatalf("Failed to get host: %v", err)
+	}
+	if host.AgentVersion != version.Version || host.CPUCores != 4 {
+		t.Errorf("Expected the agent info and metrics recorded, got %+v", host)
+	}
+}
+
+func TestIsOutdated(t *testing.T) {
+	defer func(v string) { version.Version = v }(version.Version)
+
+	tests := []struct {
+		controller string
+		agent      string
+		protocol   int32
+		outdated   bool
+	}{
+		{"v1.4.0", "v1.3.9", version.Protocol, true},
+		{"v1.4.0", "v1.4.0", version.Protocol, false},
+		{"v1.4.0", "1.4.0", version.Protocol, false},
+		{"v1.4.0", "v1.10.0", version.Protocol, false},
+		{"v1.4.0", "v1.4.0-rc.1", version.Protocol, true},
+		{"v1.4.0-rc.2", "v1.4.0-rc.10", version.Protocol, false},
+		{"v1.4.0", "dev", version.Protocol, false},
+		{"dev", "v1.0.0", version.Protocol, false},
+		{"v1.4.0", "v1.5.0", version.Protocol - 1, true},
+	}
+	for _, tt := range tests {
+		version.Version = tt.controller
+		host := &models.Host{AgentVersion: tt.agent, ProtocolVersion: tt.protocol}
+		if got := isOutdated(host); got != tt.outdated {
+			t.Errorf("Agent %s (protocol %d) with controller %s: expected outdated %v, got %v",
+				tt.agent, tt.protocol, tt.controller, tt.outdated, got)
+		}
+	}
+}
+
+func TestHandshakeRejectsIncompatibleProtocol(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	server := NewServer(db)
+	stream := setupTestStream(t, server)
+
+	if err := stream.Send(&pb.AgentMessage{
+		Payload: &pb.AgentMessage_Hello{
+			Hello: &pb.Hello{
+				Hostname:        "future-host",
+				AgentVersion:    "v99.0.0",
+				ProtocolVersion: version.Protocol + 1,
+			},
+		},
+	}); err != nil {
+		t.Fatalf("Failed to send hello: %v", err)
+	}
+
+	_, err := stream.Recv()
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("Expected FailedPrecondition, got %v", err)
+	}
+
+	if !strings.Contains(status.Convert(err).Message(), "not supported") {
+		t.Errorf("Expected clear rejection message, got %q", status.Convert(err).Message())
+	}
+}
+
+func TestSendCommandUnsupported(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	server := NewServer(db)
+	stream := setupTestStream(t, server)
+
+	// Agents predating the handshake register without capabilities.
+	if err := stream.Send(testMetrics("legacy-host")); err != nil {
+		t.Fatalf("Failed to send metrics: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Failed to receive ack: %v", err)
+	}
+
+	_, err := server.SendCommand(context.Background(), "legacy-host", &pb.Command{
+		Action: &pb.Command_CollectNow{CollectNow: &pb.CollectNow{}},
+	})
+	if err != ErrCommandsUnsupported {
+		t.Errorf("Expected ErrCommandsUnsupported, got %v", err)
+	}
+
+	host, err := db.GetHost("legacy-host")
+	if err != nil {
+		t.Fatalf("Failed to get host: %v", err)
+	}
+
+	if !isOutdated(host) {
+		t.Error("Expected host without handshake to be outdated")
+	}
+}
+
+func TestSendCommand(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	server := NewServer(db)
+	stream := setupTestStream(t, server)
+	sendHello(t, stream, "test-host")
+
+	if err := stream.Send(testMetrics("test-host")); err != nil {
+		t.Fatalf("Failed to send metrics: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Failed to receive ack: %v", err)
+	}
+
+	// Act as the agent: answer the first command with a result.
+	go func() {
+		msg, err := stream.Recv()
+		if err != nil {
+			return
+		}
+		cmd := msg.GetCommand()
+		if cmd == nil || cmd.GetRunDiagnostic() == nil {
+			return
+		}
+		stream.Send(&pb.AgentMessage{
+			Payload: &pb.AgentMessage_CommandResult{
+				CommandResult: &pb.CommandResult{
+					Id:      cmd.Id,
+					Success: true,
+					Output:  "diagnostic " + cmd.GetRunDiagnostic().Name,
+				},
+			},
+		})
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	result, err := server.SendCommand(ctx, "test-host", &pb.Command{
+		Action: &pb.Command_RunDiagnostic{RunDiagnostic: &pb.RunDiagnostic{Name: "uptime"}},
+	})
+	if err != nil {
+		t.Fatalf("SendCommand() error: %v", err)
+	}
+
+	if !result.Success {
+		t.Errorf("Expected success, got error: %s", result.Error)
+	}
+
+	if result.Output != "diagnostic uptime" {
+		t.Errorf("Expected output 'diagnostic uptime', got %q", result.Output)
+	}
+}
+
+func TestSendCommandTimeout(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	server := NewServer(db)
+	stream := setupTestStream(t, server)
+	sendHello(t, stream, "test-host")
+
+	if err := stream.Send(testMetrics("test-host")); err != nil {
+		t.Fatalf("Failed to send metrics: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Failed to receive ack: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err := server.SendCommand(ctx, "test-host", &pb.Command{
+		Action: &pb.Command_CollectNow{CollectNow: &pb.CollectNow{}},
+	})
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+
+	server.pendingMu.Lock()
+	pending := len(server.pending)
+	server.pendingMu.Unlock()
+	if pending != 0 {
+		t.Errorf("Expected no pending commands after timeout, got %d", pending)
+	}
+}
+
+func TestSendCommandNotConnected(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	server := NewServer(db)
+
+	_, err := server.SendCommand(context.Background(), "missing-host", &pb.Command{
+		Action: &pb.Command_CollectNow{CollectNow: &pb.CollectNow{}},
+	})
+	if err != ErrHostNotConnected {
+		t.Errorf("Expected ErrHostNotConnected, got %v", err)
+	}
+}
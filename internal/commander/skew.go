@@ -0,0 +1,79 @@
+package commander
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// AlertKindClockSkew is the kind of the alert raised for hosts whose clock is
+// further than the skew threshold from the controller's; its subject is
+// always "clock".
+const AlertKindClockSkew = "clock_skew"
+
+// errClockSkew is returned for metrics rejected because of the clock skew of
+// their host.
+var errClockSkew = errors.New("sample rejected, clock skew exceeds threshold")
+
+// SkewAction is what the controller does with metrics whose timestamp is
+// further than the skew threshold from the time they were received.
+type SkewAction string
+
+const (
+	// SkewIgnore stores metrics with the timestamp reported by the agent.
+	SkewIgnore SkewAction = "ignore"
+	// SkewCorrect stores metrics with the time they were received instead.
+	SkewCorrect SkewAction = "correct"
+	// SkewReject drops the usage sample, only marking the host as seen.
+	SkewReject SkewAction = "reject"
+)
+
+// ParseSkewAction parses a SkewAction; an empty string means SkewIgnore.
+func ParseSkewAction(s string) (SkewAction, error) {
+	switch action := SkewAction(s); action {
+	case "":
+		return SkewIgnore, nil
+	case SkewIgnore, SkewCorrect, SkewReject:
+		return action, nil
+	default:
+		return "", fmt.Errorf("invalid clock skew action %q (want ignore, correct or reject)", s)
+	}
+}
+
+type ServerOption func(*Server)
+
+// WithClockSkew alerts on hosts whose clock is off by more than threshold and
+// applies action to their metrics. A threshold of 0 disables both.
+func WithClockSkew(threshold time.Duration, action SkewAction) ServerOption {
+	return func(s *Server) {
+		s.skewThreshold = threshold
+		s.skewAction = action
+	}
+}
+
+// measureSkew returns how far timestamp, as reported by an agent, is ahead
+// of received, and whether that exceeds the skew threshold. Agent timestamps
+// have a resolution of one second, so the skew is rounded to seconds.
+func (s *Server) measureSkew(timestamp, received time.Time) (time.Duration, bool) {
+	skew := timestamp.Sub(received).Round(time.Second)
+	if s.skewThreshold <= 0 {
+		return skew, false
+	}
+	return skew, skew > s.skewThreshold || skew < -s.skewThreshold
+}
+
+// updateSkewAlert fires the clock skew alert of a host if its clock is off by
+// more than the threshold, and resolves it otherwise.
+func (s *Server) updateSkewAlert(hostID int64, skew time.Duration, skewed bool, at time.Time) error {
+	if !skewed {
+		_, err := s.db.ResolveAlert(hostID, AlertKindClockSkew, "clock", at)
+		return err
+	}
+
+	message := fmt.Sprintf("clock is %s ahead of the controller", skew)
+	if skew < 0 {
+		message = fmt.Sprintf("clock is %s behind the controller", -skew)
+	}
+	_, err := s.db.FireAlert(hostID, AlertKindClockSkew, "clock", message, at)
+	return err
+}
@@ -0,0 +1,134 @@
+package commander
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metorial/sentinel/internal/models"
+)
+
+func TestParseSkewAction(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    SkewAction
+		wantErr bool
+	}{
+		{"", SkewIgnore, false},
+		{"ignore", SkewIgnore, false},
+		{"correct", SkewCorrect, false},
+		{"reject", SkewReject, false},
+		{"drop", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSkewAction(tt.input)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseSkewAction(%q) = %q, %v, want %q (error: %v)", tt.input, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestHandleMetricsClockSkew(t *testing.T) {
+	ahead := time.Now().Add(10 * time.Minute).Truncate(time.Second)
+
+	tests := []struct {
+		action        SkewAction
+		wantErr       bool
+		wantUsage     int
+		wantTimestamp func(time.Time) bool
+	}{
+		{SkewIgnore, false, 1, func(ts time.Time) bool { return ts.Equal(ahead) }},
+		{SkewCorrect, false, 1, func(ts time.Time) bool { return ts.Before(ahead.Add(-9 * time.Minute)) }},
+		{SkewReject, true, 0, func(ts time.Time) bool { return ts.Before(ahead.Add(-9 * time.Minute)) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.action), func(t *testing.T) {
+			db := setupTestDB(t)
+			defer db.Close()
+
+			server := NewServer(db, WithClockSkew(30*time.Second, tt.action))
+			metrics := testMetrics("web-1").GetMetrics()
+			metrics.Timestamp = ahead.Unix()
+
+			err := server.handleMetrics(metrics)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+
+			host, err := db.GetHost("web-1")
+			if err != nil {
+				t.Fatalf("Failed to get host: %v", err)
+			}
+			if host.ClockSkewSeconds < 595 || host.ClockSkewSeconds > 601 {
+				t.Errorf("Expected clock skew of about 600s, got %v", host.ClockSkewSeconds)
+			}
+			if !tt.wantTimestamp(host.LastSeen) {
+				t.Errorf("Unexpected last seen %v for skewed clock at %v", host.LastSeen, ahead)
+			}
+
+			usage, err := db.GetHostUsage("web-1", 10)
+			if err != nil {
+				t.Fatalf("Failed to get usage: %v", err)
+			}
+			if len(usage) != tt.wantUsage {
+				t.Fatalf("Expected %d usage records, got %d", tt.wantUsage, len(usage))
+			}
+			if len(usage) > 0 && !tt.wantTimestamp(usage[0].Timestamp) {
+				t.Errorf("Unexpected usage timestamp %v for skewed clock at %v", usage[0].Timestamp, ahead)
+			}
+
+			alerts, err := db.GetAlerts(models.AlertFiring)
+			if err != nil {
+				t.Fatalf("Failed to get alerts: %v", err)
+			}
+			if len(alerts) != 1 || alerts[0].Kind != AlertKindClockSkew {
+				t.Fatalf("Expected a firing clock skew alert, got %+v", alerts)
+			}
+		})
+	}
+}
+
+func TestHandleMetricsClockSkewResolved(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	server := NewServer(db, WithClockSkew(30*time.Second, SkewIgnore))
+	metrics := testMetrics("web-1").GetMetrics()
+	metrics.Timestamp = time.Now().Add(-2 * time.Minute).Unix()
+	if err := server.handleMetrics(metrics); err != nil {
+		t.Fatalf("Failed to handle metrics: %v", err)
+	}
+
+	alerts, err := db.GetAlerts(models.AlertFiring)
+	if err != nil {
+		t.Fatalf("Failed to get alerts: %v", err)
+	}
+	// Timestamps are truncated to seconds, so the skew may round up to 2m1s
+	if len(alerts) != 1 || !strings.HasPrefix(alerts[0].Message, "clock is 2m") ||
+		!strings.HasSuffix(alerts[0].Message, "behind the controller") {
+		t.Fatalf("Expected clock to be reported behind, got %+v", alerts)
+	}
+
+	metrics.Timestamp = time.Now().Unix()
+	if err := server.handleMetrics(metrics); err != nil {
+		t.Fatalf("Failed to handle metrics: %v", err)
+	}
+
+	alerts, err = db.GetAlerts(models.AlertFiring)
+	if err != nil {
+		t.Fatalf("Failed to get alerts: %v", err)
+	}
+	if len(alerts) != 0 {
+		t.Errorf("Expected clock skew alert to be resolved, got %+v", alerts)
+	}
+
+	host, err := db.GetHost("web-1")
+	if err != nil {
+		t.Fatalf("Failed to get host: %v", err)
+	}
+	if host.ClockSkewSeconds > 1 || host.ClockSkewSeconds < -1 {
+		t.Errorf("Expected no clock skew, got %v", host.ClockSkewSeconds)
+	}
+}
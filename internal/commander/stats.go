@@ -0,0 +1,259 @@
+package commander
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultStatsWindow is how far back cluster statistics look for usage
+const defaultStatsWindow = 5 * time.Minute
+
+// statsGroupBy is what cluster statistics are grouped by: each tag, or each
+// value of a label.
+type statsGroupBy struct {
+	tags  bool
+	label string
+}
+
+// parseStatsGroupBy parses the group_by parameter of the stats endpoint:
+// "tag", or "label:<key>" or just "<key>" for a label.
+func parseStatsGroupBy(s string) (statsGroupBy, error) {
+	if s == "tag" {
+		return statsGroupBy{tags: true}, nil
+	}
+
+	key := strings.TrimPrefix(s, "label:")
+	if err := validateLabel(key, "-"); err != nil {
+		return statsGroupBy{}, fmt.Errorf("invalid group_by %q (want tag or a label key): %v", s, err)
+	}
+	return statsGroupBy{label: key}, nil
+}
+
+// hostIDFilter returns the condition limiting column to hostIDs, or nothing
+// if hostIDs is nil. The ids come from the database, so they are inlined.
+func hostIDFilter(column string, hostIDs []int64) string {
+	if hostIDs == nil {
+		return ""
+	}
+	ids := make([]string, len(hostIDs))
+	for i, id := range hostIDs {
+		ids[i] = strconv.FormatInt(id, 10)
+	}
+	return " AND " + column + " IN (" + strings.Join(ids, ",") + ")"
+}
+
+// statsGroup accumulates the cluster statistics of a group.
+type statsGroup struct {
+	hosts, online, maintenance          int
+	cpuCores, memoryBytes, storageBytes int64
+	cpuSum, memorySum                   float64
+	cpuSamples, memorySamples           int64
+	maxCPU, maxMemory                   sql.NullFloat64
+}
+
+func maxNull(a, b sql.NullFloat64) sql.NullFloat64 {
+	if !a.Valid || b.Valid && b.Float64 > a.Float64 {
+		return b
+	}
+	return a
+}
+
+func (g *statsGroup) stats() map[string]interface{} {
+	stats := map[string]interface{}{
+		"total_hosts":         g.hosts,
+		"online_hosts":        g.online,
+		"offline_hosts":       g.hosts - g.online,
+		"maintenance_hosts":   g.maintenance,
+		"total_cpu_cores":     g.cpuCores,
+		"total_memory_bytes":  g.memoryBytes,
+		"total_storage_bytes": g.storageBytes,
+		"avg_cpu_percent":     0.0,
+		"max_cpu_percent":     g.maxCPU.Float64,
+		"avg_memory_percent":  0.0,
+		"max_memory_percent":  g.maxMemory.Float64,
+	}
+	if g.cpuSamples > 0 {
+		stats["avg_cpu_percent"] = g.cpuSum / float64(g.cpuSamples)
+	}
+	if g.memorySamples > 0 {
+		stats["avg_memory_percent"] = g.memorySum / float64(g.memorySamples)
+	}
+	return stats
+}
+
+// GetClusterStatsGrouped computes the cluster statistics of GetClusterStatsFor
+// for each group of the hosts with the given ids, or of all hosts if hostIDs
+// is nil. Each group has its name as "group", and they are sorted by name
+// except for the hosts in no group, which come last in the group "". A host
+// with several tags is counted in the group of each. Decommissioned hosts are
+// left out, so groups have no decommissioned_hosts.
+func (db *DB) GetClusterStatsGrouped(groupBy statsGroupBy, hostIDs []int64, window time.Duration) ([]map[string]interface{}, error) {
+	// Labels have their own grouping, so only plain tags count as tags
+	groups, args := `SELECT host_id, value AS grp FROM host_labels WHERE key = ?`, []interface{}{groupBy.label}
+	if groupBy.tags {
+		groups, args = `SELECT ht.host_id, t.name AS grp FROM host_tags ht JOIN tags t ON ht.tag_id = t.id`, nil
+	}
+	args = append(args, time.Now().Add(-window))
+
+	// A row for each host in each of its groups, with its usage within the
+	// window summed up so that the averages are over every sample of a group.
+	// Memory utilization of hosts reporting no total is NULL and left out.
+	rows, err := db.conn.Query(`WITH groups AS (`+groups+`),
+	                            usage AS (
+	                              SELECT u.host_id,
+	                                     SUM(u.cpu_percent) AS cpu_sum, COUNT(*) AS cpu_samples,
+	                                     MAX(u.cpu_percent) AS cpu_max,
+	                                     SUM(u.used_memory_bytes * 100.0 / h.total_memory_bytes) AS memory_sum,
+	                                     COUNT(u.used_memory_bytes * 100.0 / h.total_memory_bytes) AS memory_samples,
+	                                     MAX(u.used_memory_bytes * 100.0 / h.total_memory_bytes) AS memory_max
+	                              FROM host_usage u JOIN hosts h ON u.host_id = h.id
+	                              WHERE u.timestamp > ?
+	                              GROUP BY u.host_id)
+	                            SELECT COALESCE(g.grp, ''), h.hostname, h.online, h.cpu_cores,
+	                                   h.total_memory_bytes, h.total_storage_bytes,
+	                                   COALESCE(u.cpu_sum, 0), COALESCE(u.cpu_samples, 0), u.cpu_max,
+	                                   COALESCE(u.memory_sum, 0), COALESCE(u.memory_samples, 0), u.memory_max
+	                            FROM hosts h
+	                            LEFT JOIN groups g ON g.host_id = h.id
+	                            LEFT JOIN usage u ON u.host_id = h.id
+	                            WHERE h.status != 'decommissioned'`+hostIDFilter("h.id", hostIDs), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	covered, err := db.GetHostsInMaintenance(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*statsGroup)
+	for rows.Next() {
+		var name, hostname string
+		var online bool
+		var cpuCores int32
+		var memoryBytes, storageBytes, cpuSamples, memorySamples int64
+		var cpuSum, memorySum float64
+		var maxCPU, maxMemory sql.NullFloat64
+		if err := rows.Scan(&name, &hostname, &online, &cpuCores, &memoryBytes, &storageBytes,
+			&cpuSum, &cpuSamples, &maxCPU, &memorySum, &memorySamples, &maxMemory); err != nil {
+			return nil, err
+		}
+
+		g := byName[name]
+		if g == nil {
+			g = &statsGroup{}
+			byName[name] = g
+		}
+		g.hosts++
+		if online {
+			g.online++
+			g.cpuCores += int64(cpuCores)
+			g.memoryBytes += memoryBytes
+			g.storageBytes += storageBytes
+		}
+		if covered[hostname] {
+			g.maintenance++
+		}
+		g.cpuSum += cpuSum
+		g.cpuSamples += cpuSamples
+		g.memorySum += memorySum
+		g.memorySamples += memorySamples
+		g.maxCPU = maxNull(g.maxCPU, maxCPU)
+		g.maxMemory = maxNull(g.maxMemory, maxMemory)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if names[i] == "" || names[j] == "" {
+			return names[j] == ""
+		}
+		return names[i] < names[j]
+	})
+
+	stats := make([]map[string]interface{}, 0, len(names))
+	for _, name := range names {
+		group := byName[name].stats()
+		group["group"] = name
+		stats = append(stats, group)
+	}
+	return stats, nil
+}
+
+func (api *API) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	selector, err := ParseSelector(r.URL.Query().Get("selector"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	window := defaultStatsWindow
+	if s := r.URL.Query().Get("window"); s != "" {
+		if window, err = time.ParseDuration(s); err != nil || window <= 0 {
+			http.Error(w, "window must be a positive duration such as 15m", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var groupBy statsGroupBy
+	groupByParam := r.URL.Query().Get("group_by")
+	if groupByParam != "" {
+		if groupBy, err = parseStatsGroupBy(groupByParam); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var hostIDs []int64
+	if !selector.Empty() {
+		if hostIDs, err = api.db.SelectHostIDs(selector); err != nil {
+			log.Printf("Error selecting hosts: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if groupByParam != "" {
+		groups, err := api.db.GetClusterStatsGrouped(groupBy, hostIDs, window)
+		if err != nil {
+			log.Printf("Error getting cluster stats by %s: %v", groupByParam, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"group_by":       groupByParam,
+			"window_seconds": int64(window.Seconds()),
+			"groups":         groups,
+			"count":          len(groups),
+		})
+		return
+	}
+
+	stats, err := api.db.GetClusterStatsFor(hostIDs, window)
+	if err != nil {
+		log.Printf("Error getting cluster stats: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	stats["window_seconds"] = int64(window.Seconds())
+
+	respondJSON(w, http.StatusOK, stats)
+}
@@ -0,0 +1,146 @@
+package commander
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/metorial/sentinel/internal/models"
+)
+
+func TestParseStatsGroupBy(t *testing.T) {
+	tests := []struct {
+		groupBy string
+		want    statsGroupBy
+		wantErr bool
+	}{
+		{"tag", statsGroupBy{tags: true}, false},
+		{"label:env", statsGroupBy{label: "env"}, false},
+		{"env", statsGroupBy{label: "env"}, false},
+		{"label:", statsGroupBy{}, true},
+		{"env:prod", statsGroupBy{}, true},
+		{"my env", statsGroupBy{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseStatsGroupBy(tt.groupBy)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseStatsGroupBy(%q) error = %v, wantErr %v", tt.groupBy, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseStatsGroupBy(%q) = %+v, want %+v", tt.groupBy, got, tt.want)
+		}
+	}
+}
+
+func TestHandleStatsGroupBy(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	server := NewServer(db)
+	api := NewAPI(db, server)
+	mux := http.NewServeMux()
+	api.RegisterRoutes(mux)
+
+	now := time.Now()
+	for _, host := range []struct {
+		hostname string
+		tags     []string
+		usage    []float64
+	}{
+		{"web-1", []string{"web", "canary", "env:prod"}, []float64{20, 60}},
+		{"web-2", []string{"web", "env:prod"}, []float64{40}},
+		{"db-1", []string{"env:staging"}, []float64{90}},
+		{"db-2", []string{"env:staging", "canary"}, []float64{100}},
+	} {
+		hostID := createTestHost(t, db, host.hostname)
+		for _, tag := range host.tags {
+			if err := db.AddHostTag(host.hostname, tag); err != nil {
+				t.Fatalf("Failed to add tag: %v", err)
+			}
+		}
+		for i, cpu := range host.usage {
+			// Memory utilization follows CPU: 8 GiB hosts using cpu% of it
+			err := db.InsertUsage(&models.HostUsage{HostID: hostID, Timestamp: now.Add(-time.Duration(i) * time.Minute),
+				CPUPercent: cpu, UsedMemoryBytes: int64(cpu / 100 * 8589934592)})
+			if err != nil {
+				t.Fatalf("Failed to insert usage: %v", err)
+			}
+		}
+	}
+	// Decommissioned hosts are left out of the groups
+	if _, err := db.DecommissionHost("db-2", "retired", now); err != nil {
+		t.Fatalf("Failed to decommission host: %v", err)
+	}
+	// Usage older than the window is left out
+	hostID := createTestHost(t, db, "old-1")
+	if err := db.InsertUsage(&models.HostUsage{HostID: hostID, Timestamp: now.Add(-time.Hour), CPUPercent: 100}); err != nil {
+		t.Fatalf("Failed to insert usage: %v", err)
+	}
+
+	getGroups := func(query string) []map[string]interface{} {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/stats?"+query, nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 for %q, got %d: %s", query, w.Code, w.Body.String())
+		}
+		var response struct {
+			Groups []map[string]interface{} `json:"groups"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return response.Groups
+	}
+	near := func(v interface{}, want float64) bool {
+		f, ok := v.(float64)
+		return ok && math.Abs(f-want) < 0.01
+	}
+
+	groups := getGroups("group_by=tag")
+	if len(groups) != 3 {
+		t.Fatalf("Expected groups canary, web and no tag, got %+v", groups)
+	}
+	canary, web, none := groups[0], groups[1], groups[2]
+	if canary["group"] != "canary" || canary["total_hosts"] != float64(1) || canary["decommissioned_hosts"] != nil {
+		t.Errorf("Unexpected canary group: %+v", canary)
+	}
+	if web["group"] != "web" || web["total_hosts"] != float64(2) || web["total_cpu_cores"] != float64(8) ||
+		!near(web["avg_cpu_percent"], 40) || !near(web["max_cpu_percent"], 60) ||
+		!near(web["avg_memory_percent"], 40) || !near(web["max_memory_percent"], 60) {
+		t.Errorf("Unexpected web group: %+v", web)
+	}
+	if none["group"] != "" || none["total_hosts"] != float64(2) || !near(none["max_cpu_percent"], 90) {
+		t.Errorf("Unexpected group of untagged hosts: %+v", none)
+	}
+
+	groups = getGroups("group_by=env&selector=web")
+	if len(groups) != 1 || groups[0]["group"] != "prod" || groups[0]["total_hosts"] != float64(2) {
+		t.Errorf("Expected only the prod group, got %+v", groups)
+	}
+
+	// A window reaching back an hour includes the old usage
+	groups = getGroups("group_by=env&window=2h")
+	if len(groups) != 3 || groups[2]["group"] != "" || !near(groups[2]["max_cpu_percent"], 100) {
+		t.Errorf("Expected old usage within a 2h window, got %+v", groups)
+	}
+	if len(groups) == 3 && (groups[1]["group"] != "staging" || groups[1]["total_hosts"] != float64(1) ||
+		!near(groups[1]["max_cpu_percent"], 90)) {
+		t.Errorf("Expected only db-1 in the staging group, got %+v", groups[1])
+	}
+
+	for _, query := range []string{"window=soon", "window=-5m", "group_by=label:"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/stats?"+query, nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %q, got %d", query, w.Code)
+		}
+	}
+}
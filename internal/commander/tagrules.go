@@ -0,0 +1,635 @@
+package commander
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/metorial/sentinel/internal/models"
+)
+
+// UpsertTagRule creates or replaces the tagging rule with rule.Name
+func (db *DB) UpsertTagRule(rule *models.TagRule) error {
+	query := `
+	INSERT INTO tag_rules (name, tag, hostname_regex, cidr, min_cpu_cores, max_cpu_cores, os, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(name) DO UPDATE SET
+		tag = excluded.tag,
+		hostname_regex = excluded.hostname_regex,
+		cidr = excluded.cidr,
+		min_cpu_cores = excluded.min_cpu_cores,
+		max_cpu_cores = excluded.max_cpu_cores,
+		os = excluded.os,
+		updated_at = excluded.updated_at
+	RETURNING id`
+
+	return db.conn.QueryRow(query, rule.Name, rule.Tag, rule.HostnameRegex, rule.CIDR,
+		rule.MinCPUCores, rule.MaxCPUCores, rule.OS, time.Now()).Scan(&rule.ID)
+}
+
+const tagRuleColumns = `r.id, r.name, r.tag, r.hostname_regex, r.cidr, r.min_cpu_cores, r.max_cpu_cores,
+	r.os, r.created_at, r.updated_at`
+
+func scanTagRule(row rowScanner) (*models.TagRule, error) {
+	var r models.TagRule
+	err := row.Scan(&r.ID, &r.Name, &r.Tag, &r.HostnameRegex, &r.CIDR, &r.MinCPUCores, &r.MaxCPUCores,
+		&r.OS, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// GetTagRule retrieves a tagging rule by name
+func (db *DB) GetTagRule(name string) (*models.TagRule, error) {
+	query := `SELECT ` + tagRuleColumns + ` FROM tag_rules r WHERE r.name = ?`
+	return scanTagRule(db.conn.QueryRow(query, name))
+}
+
+// GetTagRules retrieves all tagging rules, by name
+func (db *DB) GetTagRules() ([]models.TagRule, error) {
+	rows, err := db.conn.Query(`SELECT ` + tagRuleColumns + ` FROM tag_rules r ORDER BY r.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []models.TagRule{}
+	for rows.Next() {
+		r, err := scanTagRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *r)
+	}
+	return rules, rows.Err()
+}
+
+// DeleteTagRule deletes a tagging rule by name, returning sql.ErrNoRows if it
+// doesn't exist. The tags it added stay until the rules are applied again.
+func (db *DB) DeleteTagRule(name string) error {
+	result, err := db.conn.Exec(`DELETE FROM tag_rules WHERE name = ?`, name)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// tagRuleMatcher is a tagging rule with its conditions parsed.
+type tagRuleMatcher struct {
+	models.TagRule
+	hostname *regexp.Regexp
+	network  *net.IPNet
+}
+
+func compileTagRule(rule models.TagRule) (*tagRuleMatcher, error) {
+	m := &tagRuleMatcher{TagRule: rule}
+	if rule.HostnameRegex != "" {
+		re, err := regexp.Compile(rule.HostnameRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hostname_regex: %v", err)
+		}
+		m.hostname = re
+	}
+	if rule.CIDR != "" {
+		_, network, err := net.ParseCIDR(rule.CIDR)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cidr %q", rule.CIDR)
+		}
+		m.network = network
+	}
+	return m, nil
+}
+
+func validateTagRule(rule *models.TagRule) error {
+	if rule.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if strings.TrimSpace(rule.Tag) == "" {
+		return fmt.Errorf("tag is required")
+	}
+	if rule.HostnameRegex == "" && rule.CIDR == "" && rule.MinCPUCores == 0 && rule.MaxCPUCores == 0 && rule.OS == "" {
+		return fmt.Errorf("at least one of hostname_regex, cidr, min_cpu_cores, max_cpu_cores or os is required")
+	}
+	if rule.MinCPUCores < 0 || rule.MaxCPUCores < 0 {
+		return fmt.Errorf("min_cpu_cores and max_cpu_cores must not be negative")
+	}
+	if rule.MaxCPUCores > 0 && rule.MaxCPUCores < rule.MinCPUCores {
+		return fmt.Errorf("max_cpu_cores must not be less than min_cpu_cores")
+	}
+	_, err := compileTagRule(*rule)
+	return err
+}
+
+// tagRuleHost is what tagging rules are matched against.
+type tagRuleHost struct {
+	id       int64
+	hostname string
+	ip       string
+	cores    int32
+	// os is reported by the agent (e.g. linux) and osName is the platform
+	// from its inventory (e.g. ubuntu); a rule's os may match either
+	os, osName string
+	tags       map[string]bool
+}
+
+func (m *tagRuleMatcher) matches(h *tagRuleHost) bool {
+	if m.hostname != nil && !m.hostname.MatchString(h.hostname) {
+		return false
+	}
+	if m.network != nil {
+		ip := net.ParseIP(h.ip)
+		if ip == nil || !m.network.Contains(ip) {
+			return false
+		}
+	}
+	if m.MinCPUCores > 0 && h.cores < m.MinCPUCores {
+		return false
+	}
+	if m.MaxCPUCores > 0 && h.cores > m.MaxCPUCores {
+		return false
+	}
+	if m.OS != "" && !strings.EqualFold(m.OS, h.os) && !strings.EqualFold(m.OS, h.osName) {
+		return false
+	}
+	return true
+}
+
+// tagRuleChange is a tag a rule adds to or removes from a host.
+type tagRuleChange struct {
+	ruleID   int64
+	rule     string
+	hostID   int64
+	hostname string
+	tag      string
+	add      bool
+}
+
+// planTagRules works out the changes that bring the hosts in line with the
+// rules, given the tag each rule added to each host by rule id and host id.
+// Tags added by rules that are gone, changed their tag or no longer match are
+// removed before any are added, so another rule with the same tag adds it
+// back. A tag the host already has isn't added, and so never removed, while
+// one removed by hand is added again as long as a rule matches.
+func planTagRules(rules []*tagRuleMatcher, hosts []tagRuleHost, applied map[int64]map[int64]string) []tagRuleChange {
+	byID := make(map[int64]*tagRuleMatcher, len(rules))
+	for _, rule := range rules {
+		byID[rule.ID] = rule
+	}
+
+	var changes []tagRuleChange
+	for i := range hosts {
+		h := &hosts[i]
+
+		ruleIDs := make([]int64, 0, len(applied[h.id]))
+		for ruleID := range applied[h.id] {
+			ruleIDs = append(ruleIDs, ruleID)
+		}
+		sort.Slice(ruleIDs, func(i, j int) bool { return ruleIDs[i] < ruleIDs[j] })
+
+		for _, ruleID := range ruleIDs {
+			tag := applied[h.id][ruleID]
+			rule, ok := byID[ruleID]
+			if ok && rule.Tag == tag && rule.matches(h) {
+				continue
+			}
+			name := ""
+			if ok {
+				name = rule.Name
+			}
+			changes = append(changes, tagRuleChange{ruleID: ruleID, rule: name, hostID: h.id,
+				hostname: h.hostname, tag: tag})
+			h.tags[tag] = false
+		}
+
+		for _, rule := range rules {
+			if !rule.matches(h) || h.tags[rule.Tag] {
+				continue
+			}
+			changes = append(changes, tagRuleChange{ruleID: rule.ID, rule: rule.Name, hostID: h.id,
+				hostname: h.hostname, tag: rule.Tag, add: true})
+			h.tags[rule.Tag] = true
+		}
+	}
+	return changes
+}
+
+// tagRuleHosts retrieves what tagging rules match on for hostname, or for
+// every host if hostname is empty. Decommissioned hosts are left out.
+func (db *DB) tagRuleHosts(hostname string) ([]tagRuleHost, error) {
+	query := `SELECT id, hostname, ip, cpu_cores, os FROM hosts WHERE status != 'decommissioned'`
+	var args []interface{}
+	if hostname != "" {
+		query += ` AND hostname = ?`
+		args = append(args, hostname)
+	}
+	rows, err := db.conn.Query(query+` ORDER BY hostname`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hosts []tagRuleHost
+	for rows.Next() {
+		host := tagRuleHost{tags: make(map[string]bool)}
+		if err := rows.Scan(&host.id, &host.hostname, &host.ip, &host.cores, &host.os); err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, host)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	tags, err := db.tagsByHost(hostname)
+	if err != nil {
+		return nil, err
+	}
+
+	query = `SELECT i.host_id, i.data FROM host_inventory i
+		WHERE i.version = (SELECT MAX(version) FROM host_inventory WHERE host_id = i.host_id)`
+	args = nil
+	if hostname != "" {
+		query += ` AND i.host_id = (SELECT id FROM hosts WHERE hostname = ?)`
+		args = append(args, hostname)
+	}
+	rows, err = db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	osNames := make(map[int64]string)
+	for rows.Next() {
+		var hostID int64
+		var data string
+		if err := rows.Scan(&hostID, &data); err != nil {
+			return nil, err
+		}
+		var inventory models.Inventory
+		if err := json.Unmarshal([]byte(data), &inventory); err != nil {
+			return nil, fmt.Errorf("decode inventory of host %d: %w", hostID, err)
+		}
+		osNames[hostID] = inventory.OSName
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range hosts {
+		hosts[i].osName = osNames[hosts[i].id]
+		for _, tag := range tags[hosts[i].id] {
+			hosts[i].tags[tag] = true
+		}
+	}
+	return hosts, nil
+}
+
+// tagsByHost is GetTagsByHost for hostname alone, or for every host if
+// hostname is empty
+func (db *DB) tagsByHost(hostname string) (map[int64][]string, error) {
+	if hostname == "" {
+		return db.GetTagsByHost()
+	}
+
+	rows, err := db.conn.Query(`SELECT host_id, name FROM host_tag_names
+	                            WHERE host_id = (SELECT id FROM hosts WHERE hostname = ?)
+	                            ORDER BY name`, hostname)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make(map[int64][]string)
+	for rows.Next() {
+		var hostID int64
+		var tag string
+		if err := rows.Scan(&hostID, &tag); err != nil {
+			return nil, err
+		}
+		tags[hostID] = append(tags[hostID], tag)
+	}
+	return tags, rows.Err()
+}
+
+// appliedTagRules retrieves the tag each rule added to hostname, or to every
+// host if hostname is empty, by host id and rule id
+func (db *DB) appliedTagRules(hostname string) (map[int64]map[int64]string, error) {
+	query := `SELECT rule_id, host_id, tag FROM tag_rule_hosts`
+	var args []interface{}
+	if hostname != "" {
+		query += ` WHERE host_id = (SELECT id FROM hosts WHERE hostname = ?)`
+		args = append(args, hostname)
+	}
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]map[int64]string)
+	for rows.Next() {
+		var ruleID, hostID int64
+		var tag string
+		if err := rows.Scan(&ruleID, &hostID, &tag); err != nil {
+			return nil, err
+		}
+		if applied[hostID] == nil {
+			applied[hostID] = make(map[int64]string)
+		}
+		applied[hostID][ruleID] = tag
+	}
+	return applied, rows.Err()
+}
+
+func (db *DB) compiledTagRules() ([]*tagRuleMatcher, error) {
+	rules, err := db.GetTagRules()
+	if err != nil {
+		return nil, err
+	}
+
+	compiled := make([]*tagRuleMatcher, 0, len(rules))
+	for _, rule := range rules {
+		m, err := compileTagRule(rule)
+		if err != nil {
+			// Rules are validated when saved, so this is only a safeguard
+			log.Printf("Skipping tagging rule %s: %v", rule.Name, err)
+			continue
+		}
+		compiled = append(compiled, m)
+	}
+	return compiled, nil
+}
+
+// ApplyTagRules evaluates the tagging rules for hostname, or for every host
+// if hostname is empty, adding and removing tags as planTagRules works out.
+// It returns the changes made.
+func (db *DB) ApplyTagRules(hostname string) ([]tagRuleChange, error) {
+	rules, err := db.compiledTagRules()
+	if err != nil {
+		return nil, err
+	}
+	hosts, err := db.tagRuleHosts(hostname)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := db.appliedTagRules(hostname)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := planTagRules(rules, hosts, applied)
+	if len(changes) == 0 {
+		return changes, nil
+	}
+
+	// A tag and the record of the rule that added it are changed together
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	for _, c := range changes {
+		if c.add {
+			if err := addRuleTagTx(tx, c); err != nil {
+				return nil, fmt.Errorf("add tag %s to %s: %w", c.tag, c.hostname, err)
+			}
+			_, err = tx.Exec(`INSERT OR REPLACE INTO tag_rule_hosts (rule_id, host_id, tag) VALUES (?, ?, ?)`,
+				c.ruleID, c.hostID, c.tag)
+		} else {
+			if _, err := removeHostTagTx(tx, c.hostID, c.tag); err != nil {
+				return nil, fmt.Errorf("remove tag %s from %s: %w", c.tag, c.hostname, err)
+			}
+			_, err = tx.Exec(`DELETE FROM tag_rule_hosts WHERE rule_id = ? AND host_id = ?`, c.ruleID, c.hostID)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// addRuleTagTx adds the tag of c to its host, creating the tag if needed
+func addRuleTagTx(tx *sql.Tx, c tagRuleChange) error {
+	var tagID int64
+	if _, _, ok := splitLabelTag(c.tag); !ok {
+		if _, err := tx.Exec(`INSERT INTO tags (name) VALUES (?) ON CONFLICT (name) DO NOTHING`, c.tag); err != nil {
+			return err
+		}
+		if err := tx.QueryRow(`SELECT id FROM tags WHERE name = ?`, c.tag).Scan(&tagID); err != nil {
+			return err
+		}
+	}
+	_, err := addHostTagTx(tx, c.hostID, c.tag, tagID)
+	return err
+}
+
+// PreviewTagRule works out what saving rule would change, without saving it
+func (db *DB) PreviewTagRule(rule models.TagRule) (*models.TagRulePreview, error) {
+	if existing, err := db.GetTagRule(rule.Name); err == nil {
+		rule.ID = existing.ID
+		rule.CreatedAt = existing.CreatedAt
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	m, err := compileTagRule(rule)
+	if err != nil {
+		return nil, err
+	}
+	hosts, err := db.tagRuleHosts("")
+	if err != nil {
+		return nil, err
+	}
+	applied, err := db.appliedTagRules("")
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the records of this rule, if it exists, are considered
+	ruleApplied := make(map[int64]map[int64]string)
+	for hostID, rules := range applied {
+		if tag, ok := rules[rule.ID]; ok && rule.ID != 0 {
+			ruleApplied[hostID] = map[int64]string{rule.ID: tag}
+		}
+	}
+
+	preview := &models.TagRulePreview{Rule: rule, Matches: []string{}, Add: []string{}, Remove: []string{}}
+	for i := range hosts {
+		if m.matches(&hosts[i]) {
+			preview.Matches = append(preview.Matches, hosts[i].hostname)
+		}
+	}
+	for _, c := range planTagRules([]*tagRuleMatcher{m}, hosts, ruleApplied) {
+		if c.add {
+			preview.Add = append(preview.Add, c.hostname)
+		} else {
+			preview.Remove = append(preview.Remove, c.hostname)
+		}
+	}
+	return preview, nil
+}
+
+// applyTagRules evaluates the tagging rules for hostname, or for every host if
+// hostname is empty, and returns the hosts whose tags changed.
+func (s *Server) applyTagRules(hostname string) []string {
+	s.tagRulesMu.Lock()
+	defer s.tagRulesMu.Unlock()
+
+	changes, err := s.db.ApplyTagRules(hostname)
+	if err != nil {
+		log.Printf("Error applying tagging rules: %v", err)
+	}
+
+	var hostnames []string
+	for _, c := range changes {
+		if c.add {
+			log.Printf("Tagging rule %s added tag %s to %s", c.rule, c.tag, c.hostname)
+		} else {
+			log.Printf("Removed tag %s added by tagging rule %s from %s", c.tag, orDeleted(c.rule), c.hostname)
+		}
+		if len(hostnames) == 0 || hostnames[len(hostnames)-1] != c.hostname {
+			hostnames = append(hostnames, c.hostname)
+		}
+	}
+	return hostnames
+}
+
+func orDeleted(rule string) string {
+	if rule == "" {
+		return "(deleted)"
+	}
+	return rule
+}
+
+// ReapplyTagRules evaluates the tagging rules for every host and sends config
+// and checks to the connected hosts whose tags changed, e.g. after a rule was
+// modified.
+func (s *Server) ReapplyTagRules() {
+	for _, hostname := range s.applyTagRules("") {
+		s.PushConfig(hostname)
+		s.PushChecks(hostname)
+	}
+}
+
+func (api *API) handleTagRules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rules, err := api.db.GetTagRules()
+	if err != nil {
+		log.Printf("Error getting tagging rules: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"rules": rules,
+		"count": len(rules),
+	})
+}
+
+func (api *API) handleTagRule(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Path[len("/api/v1/tag-rules/"):]
+	if name == "" {
+		http.Error(w, "Rule name required", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		rule, err := api.db.GetTagRule(name)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Tagging rule not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error getting tagging rule %s: %v", name, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, http.StatusOK, rule)
+
+	case http.MethodPut:
+		var rule models.TagRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		rule.Name = name
+
+		if err := validateTagRule(&rule); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if r.URL.Query().Get("dry_run") == "true" {
+			preview, err := api.db.PreviewTagRule(rule)
+			if err != nil {
+				log.Printf("Error previewing tagging rule %s: %v", name, err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			respondJSON(w, http.StatusOK, preview)
+			return
+		}
+
+		if err := api.db.UpsertTagRule(&rule); err != nil {
+			log.Printf("Error saving tagging rule %s: %v", name, err)
+			http.Error(w, "Failed to save tagging rule", http.StatusInternalServerError)
+			return
+		}
+
+		go api.server.ReapplyTagRules()
+
+		saved, err := api.db.GetTagRule(name)
+		if err != nil {
+			log.Printf("Error getting tagging rule %s: %v", name, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, http.StatusOK, saved)
+
+	case http.MethodDelete:
+		err := api.db.DeleteTagRule(name)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Tagging rule not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error deleting tagging rule %s: %v", name, err)
+			http.Error(w, "Failed to delete tagging rule", http.StatusInternalServerError)
+			return
+		}
+
+		go api.server.ReapplyTagRules()
+
+		respondJSON(w, http.StatusOK, map[string]string{
+			"message": "Tagging rule deleted successfully",
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
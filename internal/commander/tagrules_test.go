@@ -0,0 +1,244 @@
+package commander
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/metorial/sentinel/internal/models"
+)
+
+func TestValidateTagRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    models.TagRule
+		wantErr bool
+	}{
+		{"hostname", models.TagRule{Name: "web", Tag: "web", HostnameRegex: "^web-"}, false},
+		{"cores", models.TagRule{Name: "big", Tag: "big", MinCPUCores: 16}, false},
+		{"all", models.TagRule{Name: "r", Tag: "t", HostnameRegex: "x", CIDR: "10.0.0.0/8", MinCPUCores: 2, MaxCPUCores: 8, OS: "linux"}, false},
+		{"no name", models.TagRule{Tag: "web", HostnameRegex: "^web-"}, true},
+		{"no tag", models.TagRule{Name: "web", HostnameRegex: "^web-"}, true},
+		{"no condition", models.TagRule{Name: "web", Tag: "web"}, true},
+		{"bad regex", models.TagRule{Name: "web", Tag: "web", HostnameRegex: "web-("}, true},
+		{"bad cidr", models.TagRule{Name: "net", Tag: "net", CIDR: "10.0.0.0"}, true},
+		{"negative cores", models.TagRule{Name: "c", Tag: "c", MinCPUCores: -1}, true},
+		{"inverted cores", models.TagRule{Name: "c", Tag: "c", MinCPUCores: 8, MaxCPUCores: 4}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTagRule(&tt.rule)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateTagRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTagRuleMatches(t *testing.T) {
+	host := &tagRuleHost{hostname: "web-1", ip: "10.0.1.5", cores: 4, os: "linux", osName: "ubuntu"}
+
+	tests := []struct {
+		rule models.TagRule
+		want bool
+	}{
+		{models.TagRule{HostnameRegex: "^web-"}, true},
+		{models.TagRule{HostnameRegex: "^db-"}, false},
+		{models.TagRule{CIDR: "10.0.0.0/16"}, true},
+		{models.TagRule{CIDR: "10.1.0.0/16"}, false},
+		{models.TagRule{MinCPUCores: 4, MaxCPUCores: 8}, true},
+		{models.TagRule{MinCPUCores: 8}, false},
+		{models.TagRule{MaxCPUCores: 2}, false},
+		{models.TagRule{OS: "Linux"}, true},
+		{models.TagRule{OS: "ubuntu"}, true},
+		{models.TagRule{OS: "windows"}, false},
+		{models.TagRule{HostnameRegex: "^web-", OS: "windows"}, false},
+	}
+
+	for _, tt := range tests {
+		m, err := compileTagRule(tt.rule)
+		if err != nil {
+			t.Fatalf("Failed to compile %+v: %v", tt.rule, err)
+		}
+		if got := m.matches(host); got != tt.want {
+			t.Errorf("%+v matches = %v, want %v", tt.rule, got, tt.want)
+		}
+	}
+}
+
+func TestApplyTagRules(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	createTestHost(t, db, "web-1")
+	createTestHost(t, db, "web-2")
+	createTestHost(t, db, "db-1")
+
+	// web-2 has the tag already, so the rule must leave it alone
+	if err := db.AddHostTag("web-2", "web"); err != nil {
+		t.Fatalf("Failed to add tag: %v", err)
+	}
+
+	rule := &models.TagRule{Name: "web-hosts", Tag: "web", HostnameRegex: "^web-"}
+	if err := db.UpsertTagRule(rule); err != nil {
+		t.Fatalf("Failed to save rule: %v", err)
+	}
+
+	changes, err := db.ApplyTagRules("")
+	if err != nil {
+		t.Fatalf("Failed to apply rules: %v", err)
+	}
+	if len(changes) != 1 || changes[0].hostname != "web-1" || !changes[0].add {
+		t.Errorf("Expected web-1 to be tagged, got %+v", changes)
+	}
+
+	// Applying again changes nothing
+	if changes, err := db.ApplyTagRules(""); err != nil || len(changes) != 0 {
+		t.Errorf("Expected no changes, got %+v (%v)", changes, err)
+	}
+
+	// A tag removed by hand is added back
+	if err := db.RemoveHostTag("web-1", "web"); err != nil {
+		t.Fatalf("Failed to remove tag: %v", err)
+	}
+	if changes, err := db.ApplyTagRules("web-1"); err != nil || len(changes) != 1 || !changes[0].add {
+		t.Errorf("Expected the tag to be added back, got %+v (%v)", changes, err)
+	}
+
+	// Once the rule is gone, only the tag it added is removed
+	if err := db.DeleteTagRule("web-hosts"); err != nil {
+		t.Fatalf("Failed to delete rule: %v", err)
+	}
+	if _, err := db.ApplyTagRules(""); err != nil {
+		t.Fatalf("Failed to apply rules: %v", err)
+	}
+	if tags, _ := db.GetHostTags("web-1"); len(tags) != 0 {
+		t.Errorf("Expected web-1 to lose the tag, got %v", tags)
+	}
+	if tags, _ := db.GetHostTags("web-2"); len(tags) != 1 || tags[0] != "web" {
+		t.Errorf("Expected web-2 to keep its tag, got %v", tags)
+	}
+
+	if err := db.DeleteTagRule("web-hosts"); err == nil {
+		t.Error("Expected an error deleting a rule twice")
+	}
+}
+
+func TestApplyTagRulesOnInventory(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	createTestHost(t, db, "web-1")
+	createTestHost(t, db, "web-2")
+	if _, _, err := db.RecordInventory("web-2", testInventory(), time.Now()); err != nil {
+		t.Fatalf("Failed to record inventory: %v", err)
+	}
+
+	if err := db.UpsertTagRule(&models.TagRule{Name: "ubuntu", Tag: "os:ubuntu", OS: "ubuntu"}); err != nil {
+		t.Fatalf("Failed to save rule: %v", err)
+	}
+
+	if changes, err := db.ApplyTagRules("web-1"); err != nil || len(changes) != 0 {
+		t.Fatalf("Expected no changes without inventory, got %+v (%v)", changes, err)
+	}
+
+	if _, _, err := db.RecordInventory("web-1", testInventory(), time.Now()); err != nil {
+		t.Fatalf("Failed to record inventory: %v", err)
+	}
+	if _, err := db.ApplyTagRules("web-1"); err != nil {
+		t.Fatalf("Failed to apply rules: %v", err)
+	}
+	if labels, _ := db.GetHostLabels("web-1"); labels["os"] != "ubuntu" {
+		t.Errorf("Expected the os label from the rule, got %v", labels)
+	}
+	// Only the host evaluated is tagged
+	if labels, _ := db.GetHostLabels("web-2"); len(labels) != 0 {
+		t.Errorf("Expected web-2 left alone, got %v", labels)
+	}
+
+	changed := testInventory()
+	changed.OSName = "debian"
+	if _, _, err := db.RecordInventory("web-1", changed, time.Now()); err != nil {
+		t.Fatalf("Failed to record inventory: %v", err)
+	}
+	if _, err := db.ApplyTagRules("web-1"); err != nil {
+		t.Fatalf("Failed to apply rules: %v", err)
+	}
+	if labels, _ := db.GetHostLabels("web-1"); len(labels) != 0 {
+		t.Errorf("Expected the label to be removed, got %v", labels)
+	}
+}
+
+func TestHandleTagRules(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	server := NewServer(db)
+	api := NewAPI(db, server)
+	mux := http.NewServeMux()
+	api.RegisterRoutes(mux)
+
+	createTestHost(t, db, "web-1")
+	createTestHost(t, db, "db-1")
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPut, "/api/v1/tag-rules/web?dry_run=true", `{"tag": "web", "hostname_regex": "^web-"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var preview models.TagRulePreview
+	if err := json.NewDecoder(w.Body).Decode(&preview); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(preview.Matches) != 1 || preview.Matches[0] != "web-1" || len(preview.Add) != 1 || len(preview.Remove) != 0 {
+		t.Errorf("Unexpected preview: %+v", preview)
+	}
+	if rules, _ := db.GetTagRules(); len(rules) != 0 {
+		t.Errorf("Expected a dry run not to save the rule, got %+v", rules)
+	}
+
+	if w := do(http.MethodPut, "/api/v1/tag-rules/web", `{"tag": "web"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a rule without conditions, got %d", w.Code)
+	}
+
+	w = do(http.MethodPut, "/api/v1/tag-rules/web", `{"tag": "web", "hostname_regex": "^web-"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var saved models.TagRule
+	if err := json.NewDecoder(w.Body).Decode(&saved); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if saved.ID == 0 || saved.Name != "web" || saved.HostnameRegex != "^web-" {
+		t.Errorf("Unexpected rule: %+v", saved)
+	}
+
+	w = do(http.MethodGet, "/api/v1/tag-rules", "")
+	var list struct {
+		Rules []models.TagRule `json:"rules"`
+		Count int              `json:"count"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if list.Count != 1 || list.Rules[0].Tag != "web" {
+		t.Errorf("Unexpected rules: %+v", list)
+	}
+
+	if w := do(http.MethodDelete, "/api/v1/tag-rules/web", ""); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodGet, "/api/v1/tag-rules/web", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a deleted rule, got %d", w.Code)
+	}
+}
@@ -0,0 +1,271 @@
+package commander
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// errTagInUse is returned when deleting a tag that hosts still have
+var errTagInUse = errors.New("tag is in use")
+
+// tagHostnames retrieves the hostnames of the hosts with the tag with the
+// given id
+func tagHostnames(tx *sql.Tx, tagID int64) ([]string, error) {
+	rows, err := tx.Query(`SELECT h.hostname FROM host_tags ht
+	                       JOIN hosts h ON ht.host_id = h.id
+	                       WHERE ht.tag_id = ?
+	                       ORDER BY h.hostname`, tagID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hostnames := []string{}
+	for rows.Next() {
+		var hostname string
+		if err := rows.Scan(&hostname); err != nil {
+			return nil, err
+		}
+		hostnames = append(hostnames, hostname)
+	}
+	return hostnames, rows.Err()
+}
+
+// DeleteTag deletes a tag, returning sql.ErrNoRows if it doesn't exist. A tag
+// that hosts still have is only deleted, and removed from them, with force;
+// otherwise errTagInUse is returned. It returns the hosts that had the tag.
+func (db *DB) DeleteTag(name string, force bool) ([]string, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	if err := tx.QueryRow(`SELECT id FROM tags WHERE name = ?`, name).Scan(&id); err != nil {
+		return nil, err
+	}
+
+	hostnames, err := tagHostnames(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(hostnames) > 0 && !force {
+		return hostnames, errTagInUse
+	}
+
+	if _, err := tx.Exec(`DELETE FROM host_tags WHERE tag_id = ?`, id); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM tags WHERE id = ?`, id); err != nil {
+		return nil, err
+	}
+	return hostnames, tx.Commit()
+}
+
+// RenameTag renames a tag, returning sql.ErrNoRows if it doesn't exist. If a
+// tag named newName exists already, the two are merged into it. Profiles,
+// checks, maintenance windows and tagging rules that refer to just the tag
+// are updated too. It returns the hosts that had the tag and whether it was
+// merged.
+func (db *DB) RenameTag(name, newName string) ([]string, bool, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	if err := tx.QueryRow(`SELECT id FROM tags WHERE name = ?`, name).Scan(&id); err != nil {
+		return nil, false, err
+	}
+
+	hostnames, err := tagHostnames(tx, id)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var newID int64
+	err = tx.QueryRow(`SELECT id FROM tags WHERE name = ?`, newName).Scan(&newID)
+	merged := err == nil
+	switch {
+	case merged:
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO host_tags (host_id, tag_id)
+		                      SELECT host_id, ? FROM host_tags WHERE tag_id = ?`, newID, id); err != nil {
+			return nil, false, err
+		}
+		if _, err := tx.Exec(`DELETE FROM host_tags WHERE tag_id = ?`, id); err != nil {
+			return nil, false, err
+		}
+		if _, err := tx.Exec(`DELETE FROM tags WHERE id = ?`, id); err != nil {
+			return nil, false, err
+		}
+	case err == sql.ErrNoRows:
+		if _, err := tx.Exec(`UPDATE tags SET name = ? WHERE id = ?`, newName, id); err != nil {
+			return nil, false, err
+		}
+	default:
+		return nil, false, err
+	}
+
+	for _, query := range []string{
+		`UPDATE tag_rules SET tag = ? WHERE tag = ?`,
+		`UPDATE tag_rule_hosts SET tag = ? WHERE tag = ?`,
+	} {
+		if _, err := tx.Exec(query, newName, name); err != nil {
+			return nil, false, err
+		}
+	}
+	for _, c := range selectorColumns {
+		if err := renameTagInSelectors(tx, c.table, c.column, name, newName); err != nil {
+			return nil, false, err
+		}
+	}
+
+	return hostnames, merged, tx.Commit()
+}
+
+// renameTagInSelectors rewrites the selectors stored in column of table that
+// mention the tag name to mention newName instead
+func renameTagInSelectors(tx *sql.Tx, table, column, name, newName string) error {
+	rows, err := tx.Query(fmt.Sprintf(`SELECT rowid, %s FROM %s WHERE %s != ''`, column, table, column))
+	if err != nil {
+		return err
+	}
+
+	updates := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var selector string
+		if err := rows.Scan(&id, &selector); err != nil {
+			rows.Close()
+			return err
+		}
+		if renamed, ok := renameSelectorTag(selector, name, newName); ok {
+			updates[id] = renamed
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, selector := range updates {
+		if _, err := tx.Exec(fmt.Sprintf(`UPDATE %s SET %s = ? WHERE rowid = ?`, table, column),
+			selector, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (api *API) handleTag(w http.ResponseWriter, r *http.Request) {
+	// Tag names may contain slashes, so the action is taken off the end and
+	// the rest of the path, unescaped, is the name
+	path := strings.TrimPrefix(r.URL.EscapedPath(), "/api/v1/tags/")
+	action := ""
+	if rest, ok := strings.CutSuffix(path, "/rename"); ok {
+		path, action = rest, "rename"
+	}
+	name, err := url.PathUnescape(path)
+	if err != nil {
+		http.Error(w, "Invalid tag name", http.StatusBadRequest)
+		return
+	}
+	if name == "" {
+		http.Error(w, "Tag name required", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodDelete:
+		api.handleDeleteTag(w, r, name)
+	case action == "rename" && r.Method == http.MethodPost:
+		api.handleRenameTag(w, r, name)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (api *API) handleDeleteTag(w http.ResponseWriter, r *http.Request, name string) {
+	hostnames, err := api.db.DeleteTag(name, r.URL.Query().Get("force") == "true")
+	if err == sql.ErrNoRows {
+		http.Error(w, "Tag not found", http.StatusNotFound)
+		return
+	}
+	if err == errTagInUse {
+		http.Error(w, fmt.Sprintf("Tag is in use by %d hosts; remove it from them first or delete with force=true",
+			len(hostnames)), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Error deleting tag %s: %v", name, err)
+		http.Error(w, "Failed to delete tag", http.StatusInternalServerError)
+		return
+	}
+
+	for _, hostname := range hostnames {
+		go api.server.PushConfig(hostname)
+		go api.server.PushChecks(hostname)
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Tag deleted successfully",
+		"hosts":   hostnames,
+	})
+}
+
+func (api *API) handleRenameTag(w http.ResponseWriter, r *http.Request, name string) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		http.Error(w, "New tag name required", http.StatusBadRequest)
+		return
+	}
+	if req.Name == name {
+		http.Error(w, "New tag name is the current name", http.StatusBadRequest)
+		return
+	}
+	if _, _, ok := splitLabelTag(req.Name); ok {
+		http.Error(w, "New tag name is a key:value label; set labels instead", http.StatusBadRequest)
+		return
+	}
+	if err := checkSelectorIdent(req.Name); err != nil {
+		http.Error(w, "Invalid tag name: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hostnames, merged, err := api.db.RenameTag(name, req.Name)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Tag not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error renaming tag %s to %s: %v", name, req.Name, err)
+		http.Error(w, "Failed to rename tag", http.StatusInternalServerError)
+		return
+	}
+
+	for _, hostname := range hostnames {
+		go api.server.PushConfig(hostname)
+		go api.server.PushChecks(hostname)
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Tag renamed successfully",
+		"name":    req.Name,
+		"merged":  merged,
+		"hosts":   hostnames,
+	})
+}
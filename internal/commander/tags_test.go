@@ -0,0 +1,172 @@
+package commander
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/metorial/sentinel/internal/models"
+)
+
+func TestRenameTag(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	createTestHost(t, db, "web-1")
+	createTestHost(t, db, "web-2")
+	if err := db.AddHostTag("web-1", "webserver"); err != nil {
+		t.Fatalf("Failed to add tag: %v", err)
+	}
+	if err := db.AddHostTag("web-2", "web"); err != nil {
+		t.Fatalf("Failed to add tag: %v", err)
+	}
+	if err := db.UpsertProfile(&models.AgentProfile{Name: "web", Selector: "webserver"}); err != nil {
+		t.Fatalf("Failed to save profile: %v", err)
+	}
+	if err := db.UpsertProfile(&models.AgentProfile{Name: "legacy", Selector: `"webserver"`}); err != nil {
+		t.Fatalf("Failed to save profile: %v", err)
+	}
+	if err := db.UpsertProfile(&models.AgentProfile{Name: "prod-web", Selector: "webserver AND env=prod"}); err != nil {
+		t.Fatalf("Failed to save profile: %v", err)
+	}
+	if err := db.UpsertProfile(&models.AgentProfile{Name: "role", Selector: "role=webserver"}); err != nil {
+		t.Fatalf("Failed to save profile: %v", err)
+	}
+
+	hostnames, merged, err := db.RenameTag("webserver", "frontend")
+	if err != nil {
+		t.Fatalf("Failed to rename tag: %v", err)
+	}
+	if merged || len(hostnames) != 1 || hostnames[0] != "web-1" {
+		t.Errorf("Unexpected rename result: %v (merged: %v)", hostnames, merged)
+	}
+	if profile, _ := db.GetProfile("web"); profile.Selector != "frontend" {
+		t.Errorf("Expected the profile selector to follow the rename, got %q", profile.Selector)
+	}
+	if profile, _ := db.GetProfile("legacy"); profile.Selector != `"frontend"` {
+		t.Errorf("Expected the quoted profile selector to follow the rename, got %q", profile.Selector)
+	}
+	if profile, _ := db.GetProfile("prod-web"); profile.Selector != "frontend AND env=prod" {
+		t.Errorf("Expected the compound profile selector to follow the rename, got %q", profile.Selector)
+	}
+	if profile, _ := db.GetProfile("role"); profile.Selector != "role=webserver" {
+		t.Errorf("Expected a label value to be left alone, got %q", profile.Selector)
+	}
+
+	// Renaming to an existing tag merges the two
+	if _, merged, err = db.RenameTag("frontend", "web"); err != nil || !merged {
+		t.Fatalf("Expected a merge, got merged %v, error %v", merged, err)
+	}
+	hosts, err := db.GetHostsByTags([]string{"web"})
+	if err != nil || len(hosts) != 2 {
+		t.Errorf("Expected both hosts to have the tag, got %d hosts, %v", len(hosts), err)
+	}
+
+	tags, err := db.GetAllTags()
+	if err != nil {
+		t.Fatalf("Failed to get tags: %v", err)
+	}
+	if len(tags) != 1 || tags[0].Name != "web" || tags[0].Hosts != 2 {
+		t.Errorf("Unexpected tags: %+v", tags)
+	}
+
+	if _, _, err := db.RenameTag("nope", "x"); err == nil {
+		t.Error("Expected an error renaming an unknown tag")
+	}
+}
+
+func TestHandleTag(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	server := NewServer(db)
+	api := NewAPI(db, server)
+	mux := http.NewServeMux()
+	api.RegisterRoutes(mux)
+
+	createTestHost(t, db, "web-1")
+	if err := db.AddHostTag("web-1", "web"); err != nil {
+		t.Fatalf("Failed to add tag: %v", err)
+	}
+	if _, err := db.CreateTag("unused"); err != nil {
+		t.Fatalf("Failed to create tag: %v", err)
+	}
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(http.MethodDelete, "/api/v1/tags/unused", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodDelete, "/api/v1/tags/unused", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 deleting a tag twice, got %d", w.Code)
+	}
+	if w := do(http.MethodDelete, "/api/v1/tags/web", ""); w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 deleting a tag in use, got %d", w.Code)
+	}
+
+	if w := do(http.MethodPost, "/api/v1/tags/web/rename", `{"name": "env:prod"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 renaming to a label, got %d", w.Code)
+	}
+	for _, name := range []string{"web app", "and", "NOT", "web!", `"web"`} {
+		body, _ := json.Marshal(map[string]string{"name": name})
+		if w := do(http.MethodPost, "/api/v1/tags/web/rename", string(body)); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 renaming to %q, got %d", name, w.Code)
+		}
+	}
+	w := do(http.MethodPost, "/api/v1/tags/web/rename", `{"name": "frontend"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Host listings carry the tags
+	w = do(http.MethodGet, "/api/v1/hosts", "")
+	var list struct {
+		Hosts []models.Host `json:"hosts"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(list.Hosts) != 1 || len(list.Hosts[0].Tags) != 1 || list.Hosts[0].Tags[0] != "frontend" {
+		t.Errorf("Unexpected hosts: %+v", list.Hosts)
+	}
+
+	if w := do(http.MethodDelete, "/api/v1/tags/frontend?force=true", ""); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if tags, _ := db.GetHostTags("web-1"); len(tags) != 0 {
+		t.Errorf("Expected the tag to be removed from the host, got %v", tags)
+	}
+
+	// Tag names may contain slashes, escaped or not
+	if err := db.AddHostTag("web-1", "team/a"); err != nil {
+		t.Fatalf("Failed to add tag: %v", err)
+	}
+	if w := do(http.MethodPost, "/api/v1/tags/team/a/rename", `{"name": "team/b"}`); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPost, "/api/v1/tags/team%2Fb/rename", `{"name": "team/c"}`); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if tags, _ := db.GetHostTags("web-1"); len(tags) != 1 || tags[0] != "team/c" {
+		t.Errorf("Expected the host to have team/c, got %v", tags)
+	}
+	if w := do(http.MethodDelete, "/api/v1/tags/team/c?force=true", ""); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := db.CreateTag("team/d"); err != nil {
+		t.Fatalf("Failed to create tag: %v", err)
+	}
+	if w := do(http.MethodDelete, "/api/v1/tags/team%2Fd", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if tags, _ := db.GetAllTags(); len(tags) != 0 {
+		t.Errorf("Expected no tags left, got %+v", tags)
+	}
+}
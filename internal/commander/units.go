@@ -0,0 +1,270 @@
+package commander
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/metorial/sentinel/internal/models"
+	pb "github.com/metorial/sentinel/proto"
+)
+
+// AlertKindUnit is the kind of the alerts raised for failed systemd units;
+// their subject is the unit name.
+const AlertKindUnit = "unit"
+
+// RecordUnits stores the state of the units watched on a host, recording a
+// transition for every unit whose state changed. Units missing from the list
+// are no longer watched and are removed; their names are returned.
+func (db *DB) RecordUnits(hostname string, timestamp time.Time, units []models.Unit) (hostID int64, transitions []models.UnitTransition, removed []string, err error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	defer tx.Rollback()
+
+	if err := tx.QueryRow(`SELECT id FROM hosts WHERE hostname = ?`, hostname).Scan(&hostID); err != nil {
+		return 0, nil, nil, err
+	}
+
+	rows, err := tx.Query(`SELECT name, active_state, sub_state FROM host_units WHERE host_id = ?`, hostID)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	previous := make(map[string][2]string)
+	for rows.Next() {
+		var name, active, sub string
+		if err := rows.Scan(&name, &active, &sub); err != nil {
+			rows.Close()
+			return 0, nil, nil, err
+		}
+		previous[name] = [2]string{active, sub}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, nil, nil, err
+	}
+
+	for _, u := range units {
+		if prev, ok := previous[u.Name]; ok && prev != [2]string{u.ActiveState, u.SubState} {
+			t := models.UnitTransition{
+				HostID:          hostID,
+				Unit:            u.Name,
+				FromActiveState: prev[0],
+				FromSubState:    prev[1],
+				ToActiveState:   u.ActiveState,
+				ToSubState:      u.SubState,
+				Timestamp:       timestamp,
+			}
+			err := tx.QueryRow(`INSERT INTO unit_transitions (host_id, unit, from_active_state,
+				from_sub_state, to_active_state, to_sub_state, timestamp)
+				VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+				hostID, t.Unit, t.FromActiveState, t.FromSubState, t.ToActiveState, t.ToSubState,
+				t.Timestamp).Scan(&t.ID)
+			if err != nil {
+				return 0, nil, nil, fmt.Errorf("insert transition of %s: %w", u.Name, err)
+			}
+			transitions = append(transitions, t)
+		}
+		delete(previous, u.Name)
+
+		_, err := tx.Exec(`
+		INSERT INTO host_units (host_id, name, description, load_state, active_state, sub_state, since, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(host_id, name) DO UPDATE SET
+			description = excluded.description,
+			load_state = excluded.load_state,
+			active_state = excluded.active_state,
+			sub_state = excluded.sub_state,
+			since = excluded.since,
+			updated_at = excluded.updated_at`,
+			hostID, u.Name, u.Description, u.LoadState, u.ActiveState, u.SubState, u.Since, timestamp)
+		if err != nil {
+			return 0, nil, nil, fmt.Errorf("upsert unit %s: %w", u.Name, err)
+		}
+	}
+
+	for name := range previous {
+		if _, err := tx.Exec(`DELETE FROM host_units WHERE host_id = ? AND name = ?`, hostID, name); err != nil {
+			return 0, nil, nil, err
+		}
+		removed = append(removed, name)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, nil, nil, err
+	}
+	return hostID, transitions, removed, nil
+}
+
+// GetUnits retrieves the watched units of every host, or only those of
+// hostname if set, optionally only those in activeState. Failed units are
+// listed first.
+func (db *DB) GetUnits(hostname, activeState string) ([]models.Unit, error) {
+	query := `SELECT u.host_id, h.hostname, u.name, u.description, u.load_state, u.active_state,
+	          u.sub_state, u.since, u.updated_at
+	          FROM host_units u
+	          JOIN hosts h ON u.host_id = h.id
+	          WHERE (? = '' OR h.hostname = ?) AND (? = '' OR u.active_state = ?)
+	          ORDER BY u.active_state = 'failed' DESC, h.hostname, u.name`
+
+	rows, err := db.conn.Query(query, hostname, hostname, activeState, activeState)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var units []models.Unit
+	for rows.Next() {
+		var u models.Unit
+		err := rows.Scan(&u.HostID, &u.Hostname, &u.Name, &u.Description, &u.LoadState,
+			&u.ActiveState, &u.SubState, &u.Since, &u.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		units = append(units, u)
+	}
+	return units, rows.Err()
+}
+
+// GetUnitTransitions retrieves the most recent unit state transitions of a host
+func (db *DB) GetUnitTransitions(hostname string, limit int) ([]models.UnitTransition, error) {
+	query := `SELECT t.id, t.host_id, t.unit, t.from_active_state, t.from_sub_state,
+	          t.to_active_state, t.to_sub_state, t.timestamp
+	          FROM unit_transitions t
+	          JOIN hosts h ON t.host_id = h.id
+	          WHERE h.hostname = ?
+	          ORDER BY t.timestamp DESC, t.id DESC
+	          LIMIT ?`
+
+	rows, err := db.conn.Query(query, hostname, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transitions []models.UnitTransition
+	for rows.Next() {
+		var t models.UnitTransition
+		err := rows.Scan(&t.ID, &t.HostID, &t.Unit, &t.FromActiveState, &t.FromSubState,
+			&t.ToActiveState, &t.ToSubState, &t.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+		transitions = append(transitions, t)
+	}
+	return transitions, rows.Err()
+}
+
+// handleUnits stores the reported unit states and fires an alert for every
+// failed unit, resolving it once the unit recovers or is no longer watched.
+func (s *Server) handleUnits(hostname string, list *pb.UnitStatusList) error {
+	timestamp := time.Unix(list.Timestamp, 0)
+	units := make([]models.Unit, 0, len(list.Units))
+	for _, u := range list.Units {
+		unit := models.Unit{
+			Name:        u.Name,
+			Description: u.Description,
+			LoadState:   u.LoadState,
+			ActiveState: u.ActiveState,
+			SubState:    u.SubState,
+		}
+		if u.Since != 0 {
+			unit.Since = time.Unix(u.Since, 0)
+		}
+		units = append(units, unit)
+	}
+
+	hostID, transitions, removed, err := s.db.RecordUnits(hostname, timestamp, units)
+	if err != nil {
+		return fmt.Errorf("record units: %w", err)
+	}
+	for _, t := range transitions {
+		log.Printf("Unit %s on %s changed from %s (%s) to %s (%s)", t.Unit, hostname,
+			t.FromActiveState, t.FromSubState, t.ToActiveState, t.ToSubState)
+	}
+
+	for _, u := range units {
+		if u.ActiveState == "failed" {
+			message := fmt.Sprintf("%s is failed (%s)", u.Name, u.SubState)
+			if _, err := s.db.FireAlert(hostID, AlertKindUnit, u.Name, message, timestamp); err != nil {
+				return fmt.Errorf("fire alert: %w", err)
+			}
+			continue
+		}
+		if _, err := s.db.ResolveAlert(hostID, AlertKindUnit, u.Name, timestamp); err != nil {
+			return fmt.Errorf("resolve alert: %w", err)
+		}
+	}
+	for _, name := range removed {
+		if _, err := s.db.ResolveAlert(hostID, AlertKindUnit, name, timestamp); err != nil {
+			return fmt.Errorf("resolve alert: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (api *API) handleUnits(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	units, err := api.db.GetUnits(r.URL.Query().Get("host"), r.URL.Query().Get("state"))
+	if err != nil {
+		log.Printf("Error getting units: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"units": units,
+		"count": len(units),
+	})
+}
+
+func (api *API) handleHostUnits(w http.ResponseWriter, r *http.Request, hostname string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, err := api.db.GetHost(hostname); err == sql.ErrNoRows {
+		http.Error(w, "Host not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("Error getting host %s: %v", hostname, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	units, err := api.db.GetUnits(hostname, "")
+	if err != nil {
+		log.Printf("Error getting units for %s: %v", hostname, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	limit := 100
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 1000 {
+			limit = l
+		}
+	}
+
+	transitions, err := api.db.GetUnitTransitions(hostname, limit)
+	if err != nil {
+		log.Printf("Error getting unit transitions for %s: %v", hostname, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"units":       units,
+		"transitions": transitions,
+	})
+}
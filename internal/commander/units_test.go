@@ -0,0 +1,160 @@
+package commander
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/metorial/sentinel/internal/models"
+	pb "github.com/metorial/sentinel/proto"
+)
+
+func TestRecordUnits(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	createTestHost(t, db, "web-1")
+	now := time.Now()
+
+	units := []models.Unit{
+		{Name: "nginx.service", LoadState: "loaded", ActiveState: "active", SubState: "running"},
+		{Name: "cron.service", LoadState: "loaded", ActiveState: "active", SubState: "running"},
+	}
+	_, transitions, _, err := db.RecordUnits("web-1", now, units)
+	if err != nil {
+		t.Fatalf("Failed to record units: %v", err)
+	}
+	if len(transitions) != 0 {
+		t.Errorf("Expected no transitions for new units, got %d", len(transitions))
+	}
+
+	units = []models.Unit{
+		{Name: "nginx.service", LoadState: "loaded", ActiveState: "failed", SubState: "failed"},
+	}
+	_, transitions, removed, err := db.RecordUnits("web-1", now.Add(time.Minute), units)
+	if err != nil {
+		t.Fatalf("Failed to record units: %v", err)
+	}
+	if len(transitions) != 1 || transitions[0].FromActiveState != "active" || transitions[0].ToActiveState != "failed" {
+		t.Errorf("Unexpected transitions: %+v", transitions)
+	}
+	if len(removed) != 1 || removed[0] != "cron.service" {
+		t.Errorf("Expected cron.service removed, got %v", removed)
+	}
+
+	stored, err := db.GetUnits("", "failed")
+	if err != nil {
+		t.Fatalf("Failed to get units: %v", err)
+	}
+	if len(stored) != 1 || stored[0].Name != "nginx.service" || stored[0].Hostname != "web-1" {
+		t.Errorf("Unexpected failed units: %+v", stored)
+	}
+
+	history, err := db.GetUnitTransitions("web-1", 10)
+	if err != nil {
+		t.Fatalf("Failed to get transitions: %v", err)
+	}
+	if len(history) != 1 {
+		t.Errorf("Expected 1 transition, got %d", len(history))
+	}
+}
+
+func TestHandleUnitsAlerts(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	createTestHost(t, db, "web-1")
+	server := NewServer(db)
+
+	report := func(activeState string) {
+		t.Helper()
+		err := server.handleUnits("web-1", &pb.UnitStatusList{
+			Hostname:  "web-1",
+			Timestamp: time.Now().Unix(),
+			Units: []*pb.UnitStatus{
+				{Name: "nginx.service", LoadState: "loaded", ActiveState: activeState, SubState: "dead"},
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to handle units: %v", err)
+		}
+	}
+
+	report("failed")
+
+	alerts, err := db.GetAlerts(models.AlertFiring)
+	if err != nil {
+		t.Fatalf("Failed to get alerts: %v", err)
+	}
+	if len(alerts) != 1 || alerts[0].Kind != AlertKindUnit || alerts[0].Subject != "nginx.service" {
+		t.Fatalf("Expected unit alert for nginx.service, got %+v", alerts)
+	}
+
+	report("inactive")
+
+	alerts, err = db.GetAlerts(models.AlertFiring)
+	if err != nil {
+		t.Fatalf("Failed to get alerts: %v", err)
+	}
+	if len(alerts) != 0 {
+		t.Errorf("Expected no firing alerts, got %d", len(alerts))
+	}
+}
+
+func TestHandleHostUnits(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	createTestHost(t, db, "web-1")
+	if _, _, _, err := db.RecordUnits("web-1", time.Now(), []models.Unit{
+		{Name: "nginx.service", LoadState: "loaded", ActiveState: "failed", SubState: "failed"},
+	}); err != nil {
+		t.Fatalf("Failed to record units: %v", err)
+	}
+
+	api := NewAPI(db, NewServer(db))
+	mux := http.NewServeMux()
+	api.RegisterRoutes(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/hosts/web-1/units", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response struct {
+		Units []models.Unit `json:"units"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Units) != 1 {
+		t.Errorf("Expected 1 unit, got %d", len(response.Units))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/units?state=active", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	var list struct {
+		Count int `json:"count"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if list.Count != 0 {
+		t.Errorf("Expected no active units, got %d", list.Count)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/hosts/missing/units", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
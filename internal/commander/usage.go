@@ -0,0 +1,166 @@
+package commander
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/metorial/sentinel/internal/models"
+)
+
+const (
+	defaultUsageRange  = time.Hour
+	defaultUsagePoints = 120
+	maxUsagePoints     = 2000
+)
+
+// GetHostUsageSince retrieves the usage of a host reported since the given
+// time, oldest first
+func (db *DB) GetHostUsageSince(hostname string, since time.Time) ([]models.HostUsage, error) {
+	query := `SELECT hu.id, hu.host_id, hu.timestamp, hu.cpu_percent,
+	          hu.used_memory_bytes, hu.used_storage_bytes
+	          FROM host_usage hu
+	          JOIN hosts h ON hu.host_id = h.id
+	          WHERE h.hostname = ? AND hu.timestamp >= ?
+	          ORDER BY hu.timestamp`
+
+	rows, err := db.conn.Query(query, hostname, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := []models.HostUsage{}
+	for rows.Next() {
+		var u models.HostUsage
+		err := rows.Scan(&u.ID, &u.HostID, &u.Timestamp, &u.CPUPercent,
+			&u.UsedMemoryBytes, &u.UsedStorageBytes)
+		if err != nil {
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+// downsampleUsage averages usage, oldest first, over steps of the given length
+// starting at from. Steps without usage are left out.
+func downsampleUsage(usage []models.HostUsage, from time.Time, step time.Duration) []models.UsagePoint {
+	points := []models.UsagePoint{}
+	var cpu float64
+	var memory, storage int64
+	flush := func() {
+		p := &points[len(points)-1]
+		p.CPUPercent = cpu / float64(p.Samples)
+		p.UsedMemoryBytes = memory / int64(p.Samples)
+		p.UsedStorageBytes = storage / int64(p.Samples)
+	}
+
+	for _, u := range usage {
+		start := from.Add(u.Timestamp.Sub(from) / step * step)
+		if len(points) == 0 || !points[len(points)-1].Timestamp.Equal(start) {
+			if len(points) > 0 {
+				flush()
+			}
+			points = append(points, models.UsagePoint{Timestamp: start})
+			cpu, memory, storage = 0, 0, 0
+		}
+		points[len(points)-1].Samples++
+		cpu += u.CPUPercent
+		memory += u.UsedMemoryBytes
+		storage += u.UsedStorageBytes
+	}
+	if len(points) > 0 {
+		flush()
+	}
+	return points
+}
+
+// summarizeUsage computes the lowest, average and highest value of each usage
+// metric, by name
+func summarizeUsage(usage []models.HostUsage) map[string]models.UsageSummary {
+	summary := make(map[string]models.UsageSummary)
+	if len(usage) == 0 {
+		return summary
+	}
+
+	metrics := map[string]func(models.HostUsage) float64{
+		"cpu_percent":        func(u models.HostUsage) float64 { return u.CPUPercent },
+		"used_memory_bytes":  func(u models.HostUsage) float64 { return float64(u.UsedMemoryBytes) },
+		"used_storage_bytes": func(u models.HostUsage) float64 { return float64(u.UsedStorageBytes) },
+	}
+	for name, value := range metrics {
+		s := models.UsageSummary{Min: value(usage[0]), Max: value(usage[0])}
+		var sum float64
+		for _, u := range usage {
+			v := value(u)
+			s.Min = min(s.Min, v)
+			s.Max = max(s.Max, v)
+			sum += v
+		}
+		s.Avg = sum / float64(len(usage))
+		summary[name] = s
+	}
+	return summary
+}
+
+func (api *API) handleHostUsage(w http.ResponseWriter, r *http.Request, hostname string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	usageRange := defaultUsageRange
+	if s := r.URL.Query().Get("range"); s != "" {
+		var err error
+		if usageRange, err = time.ParseDuration(s); err != nil || usageRange <= 0 {
+			http.Error(w, "range must be a positive duration such as 6h", http.StatusBadRequest)
+			return
+		}
+	}
+
+	points := defaultUsagePoints
+	if s := r.URL.Query().Get("points"); s != "" {
+		p, err := strconv.Atoi(s)
+		if err != nil || p <= 0 || p > maxUsagePoints {
+			http.Error(w, "points must be between 1 and 2000", http.StatusBadRequest)
+			return
+		}
+		points = p
+	}
+
+	host, err := api.db.GetHost(hostname)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Host not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting host %s: %v", hostname, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	to := time.Now()
+	from := to.Add(-usageRange)
+	usage, err := api.db.GetHostUsageSince(hostname, from)
+	if err != nil {
+		log.Printf("Error getting usage for %s: %v", hostname, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	step := max(usageRange/time.Duration(points), time.Second)
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"hostname":            hostname,
+		"from":                from,
+		"to":                  to,
+		"step_seconds":        step.Seconds(),
+		"total_memory_bytes":  host.TotalMemoryBytes,
+		"total_storage_bytes": host.TotalStorageBytes,
+		"points":              downsampleUsage(usage, from, step),
+		"summary":             summarizeUsage(usage),
+		"samples":             len(usage),
+	})
+}
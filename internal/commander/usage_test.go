@@ -0,0 +1,128 @@
+package commander
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/metorial/sentinel/internal/models"
+)
+
+func TestDownsampleUsage(t *testing.T) {
+	from := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	usage := []models.HostUsage{
+		{Timestamp: from.Add(10 * time.Second), CPUPercent: 10, UsedMemoryBytes: 100},
+		{Timestamp: from.Add(50 * time.Second), CPUPercent: 30, UsedMemoryBytes: 300},
+		{Timestamp: from.Add(3 * time.Minute), CPUPercent: 80, UsedMemoryBytes: 800, UsedStorageBytes: 5},
+	}
+
+	points := downsampleUsage(usage, from, time.Minute)
+	if len(points) != 2 {
+		t.Fatalf("Expected 2 points with the empty minutes left out, got %+v", points)
+	}
+	if !points[0].Timestamp.Equal(from) || points[0].CPUPercent != 20 || points[0].UsedMemoryBytes != 200 || points[0].Samples != 2 {
+		t.Errorf("Expected the first minute averaged, got %+v", points[0])
+	}
+	if !points[1].Timestamp.Equal(from.Add(3*time.Minute)) || points[1].CPUPercent != 80 || points[1].UsedStorageBytes != 5 {
+		t.Errorf("Unexpected second point: %+v", points[1])
+	}
+
+	if points := downsampleUsage(nil, from, time.Minute); len(points) != 0 {
+		t.Errorf("Expected no points without usage, got %+v", points)
+	}
+}
+
+func TestSummarizeUsage(t *testing.T) {
+	summary := summarizeUsage([]models.HostUsage{
+		{CPUPercent: 10, UsedMemoryBytes: 400},
+		{CPUPercent: 50, UsedMemoryBytes: 100},
+		{CPUPercent: 30, UsedMemoryBytes: 100},
+	})
+
+	if cpu := summary["cpu_percent"]; cpu.Min != 10 || cpu.Avg != 30 || cpu.Max != 50 {
+		t.Errorf("Unexpected CPU summary: %+v", cpu)
+	}
+	if memory := summary["used_memory_bytes"]; memory.Min != 100 || memory.Avg != 200 || memory.Max != 400 {
+		t.Errorf("Unexpected memory summary: %+v", memory)
+	}
+	if len(summarizeUsage(nil)) != 0 {
+		t.Error("Expected no summary without usage")
+	}
+}
+
+func TestHandleHostUsage(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	server := NewServer(db)
+	api := NewAPI(db, server)
+	mux := http.NewServeMux()
+	api.RegisterRoutes(mux)
+
+	hostID := createTestHost(t, db, "web-1")
+	now := time.Now()
+	for i, cpu := range []float64{20, 40, 60} {
+		err := db.InsertUsage(&models.HostUsage{
+			HostID:          hostID,
+			Timestamp:       now.Add(time.Duration(i-3) * time.Minute),
+			CPUPercent:      cpu,
+			UsedMemoryBytes: 1024,
+		})
+		if err != nil {
+			t.Fatalf("Failed to insert usage: %v", err)
+		}
+	}
+	// Older than the range
+	if err := db.InsertUsage(&models.HostUsage{HostID: hostID, Timestamp: now.Add(-2 * time.Hour), CPUPercent: 100}); err != nil {
+		t.Fatalf("Failed to insert usage: %v", err)
+	}
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/api/v1/hosts/web-1/usage?range=1h&points=60")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var history struct {
+		StepSeconds      float64                        `json:"step_seconds"`
+		TotalMemoryBytes int64                          `json:"total_memory_bytes"`
+		Points           []models.UsagePoint            `json:"points"`
+		Summary          map[string]models.UsageSummary `json:"summary"`
+		Samples          int                            `json:"samples"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&history); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if history.StepSeconds != 60 || history.Samples != 3 || history.TotalMemoryBytes == 0 {
+		t.Errorf("Unexpected usage history: %+v", history)
+	}
+	if len(history.Points) != 3 {
+		t.Errorf("Expected a point per minute, got %+v", history.Points)
+	}
+	if cpu := history.Summary["cpu_percent"]; cpu.Min != 20 || cpu.Avg != 40 || cpu.Max != 60 {
+		t.Errorf("Expected samples outside the range left out of the summary, got %+v", cpu)
+	}
+
+	for _, path := range []string{
+		"/api/v1/hosts/web-1/usage?range=soon",
+		"/api/v1/hosts/web-1/usage?range=-1h",
+		"/api/v1/hosts/web-1/usage?points=0",
+		"/api/v1/hosts/web-1/usage?points=5000",
+	} {
+		if w := get(path); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", path, w.Code)
+		}
+	}
+
+	if w := get("/api/v1/hosts/unknown/usage"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
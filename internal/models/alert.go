@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Alert states
+const (
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+)
+
+// Alert is a problem detected on a host. An alert is identified by its host,
+// Kind (e.g. "check") and Subject (e.g. the check name); it fires once and
+// stays firing until the condition clears.
+type Alert struct {
+	ID         int64      `json:"id"`
+	HostID     int64      `json:"host_id"`
+	Hostname   string     `json:"hostname"`
+	Kind       string     `json:"kind"`
+	Subject    string     `json:"subject"`
+	Message    string     `json:"message"`
+	State      string     `json:"state"`
+	StartedAt  time.Time  `json:"started_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	// Set on firing alerts of hosts under maintenance, which aren't
+	// refreshed until the maintenance ends
+	Silenced bool `json:"silenced,omitempty"`
+}
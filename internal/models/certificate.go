@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Certificate is a TLS certificate found on a host, either in a PEM file or
+// served on a local listening port (Source "tcp://address:port").
+type Certificate struct {
+	HostID      int64     `json:"host_id"`
+	Hostname    string    `json:"hostname"`
+	Source      string    `json:"source"`
+	Subject     string    `json:"subject"`
+	Issuer      string    `json:"issuer"`
+	SANs        []string  `json:"sans"`
+	NotBefore   time.Time `json:"not_before"`
+	NotAfter    time.Time `json:"not_after"`
+	Serial      string    `json:"serial"`
+	Fingerprint string    `json:"fingerprint"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
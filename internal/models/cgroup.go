@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Cgroup is a container cgroup (v2) reported by a host's agent. Cgroups are
+// child entities of their host, identified by their path in the hierarchy.
+type Cgroup struct {
+	ID          int64  `json:"id"`
+	HostID      int64  `json:"host_id"`
+	Path        string `json:"path"`
+	Runtime     string `json:"runtime"`
+	ContainerID string `json:"container_id"`
+	// Active is false once the cgroup is missing from the host's reports
+	Active    bool      `json:"active"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	// Latest usage (optional, populated when listing cgroups)
+	Usage *CgroupUsage `json:"usage,omitempty"`
+}
+
+type CgroupUsage struct {
+	ID               int64     `json:"id"`
+	CgroupID         int64     `json:"cgroup_id"`
+	Timestamp        time.Time `json:"timestamp"`
+	CPUPercent       float64   `json:"cpu_percent"`
+	MemoryBytes      int64     `json:"memory_bytes"`
+	MemoryLimitBytes int64     `json:"memory_limit_bytes"`
+	IOReadBytes      int64     `json:"io_read_bytes"`
+	IOWriteBytes     int64     `json:"io_write_bytes"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Check is a synthetic check run by the agents of the hosts matching
+// Selector (empty for all hosts).
+type Check struct {
+	ID              int64  `json:"id"`
+	Name            string `json:"name"`
+	Type            string `json:"type"`
+	Target          string `json:"target"`
+	Selector        string `json:"selector"`
+	IntervalSeconds int32  `json:"interval_seconds"`
+	TimeoutSeconds  int32  `json:"timeout_seconds"`
+	// Type specific expectations, see CheckDefinition in metrics.proto
+	ExpectedStatus  int32     `json:"expected_status,omitempty"`
+	BodyRegex       string    `json:"body_regex,omitempty"`
+	MinDaysValid    int32     `json:"min_days_valid,omitempty"`
+	ExpectedAddress string    `json:"expected_address,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+type CheckResult struct {
+	ID        int64     `json:"id"`
+	CheckID   int64     `json:"check_id"`
+	HostID    int64     `json:"host_id"`
+	Hostname  string    `json:"hostname"`
+	Timestamp time.Time `json:"timestamp"`
+	Success   bool      `json:"success"`
+	LatencyMs float64   `json:"latency_ms"`
+	Message   string    `json:"message"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Container is a Docker container on a host, as last reported by its agent.
+type Container struct {
+	HostID       int64     `json:"host_id"`
+	ContainerID  string    `json:"container_id"`
+	Name         string    `json:"name"`
+	Image        string    `json:"image"`
+	State        string    `json:"state"`
+	Status       string    `json:"status"`
+	RestartCount int32     `json:"restart_count"`
+	Created      time.Time `json:"created"`
+	// Resource usage, zero unless the container is running
+	CPUPercent       float64 `json:"cpu_percent"`
+	MemoryBytes      int64   `json:"memory_bytes"`
+	MemoryLimitBytes int64   `json:"memory_limit_bytes"`
+	NetworkRxBytes   int64   `json:"network_rx_bytes"`
+	NetworkTxBytes   int64   `json:"network_tx_bytes"`
+	BlockReadBytes   int64   `json:"block_read_bytes"`
+	BlockWriteBytes  int64   `json:"block_write_bytes"`
+	// Why the agent couldn't read the details or usage of the container, which
+	// are then missing or out of date
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
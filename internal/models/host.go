@@ -12,8 +12,38 @@ type Host struct {
 	TotalStorageBytes int64     `json:"total_storage_bytes"`
 	LastSeen          time.Time `json:"last_seen"`
 	Online            bool      `json:"online"`
-	CreatedAt         time.Time `json:"created_at"`
-	UpdatedAt         time.Time `json:"updated_at"`
+	// Presence state, the reason for entering it and when it was entered.
+	// Online is true only in the online state.
+	Status       string    `json:"status"`
+	StatusReason string    `json:"status_reason"`
+	StatusSince  time.Time `json:"status_since"`
+	// Report interval the agent is expected to send metrics at, 0 if unknown
+	ExpectedIntervalSeconds int32     `json:"expected_interval_seconds"`
+	CreatedAt               time.Time `json:"created_at"`
+	UpdatedAt               time.Time `json:"updated_at"`
+	// Version of the agent config last applied by the agent. A failed apply
+	// leaves it alone and records the version and error reported instead,
+	// until a later config applies.
+	ConfigVersion       int64  `json:"config_version"`
+	ConfigFailedVersion int64  `json:"config_failed_version,omitempty"`
+	ConfigError         string `json:"config_error,omitempty"`
+	// Reported by the agent in its Hello; empty for agents predating the
+	// handshake
+	AgentVersion    string   `json:"agent_version"`
+	ProtocolVersion int32    `json:"protocol_version"`
+	OS              string   `json:"os"`
+	Arch            string   `json:"arch"`
+	Capabilities    []string `json:"capabilities"`
+	// How far the host's clock was ahead of the controller's (negative if
+	// behind) when its latest metrics were received
+	ClockSkewSeconds float64 `json:"clock_skew_seconds"`
+	// Outdated is computed by the API by comparing against the controller
+	Outdated bool `json:"outdated"`
+	// InMaintenance is computed by the API from the active maintenance windows
+	InMaintenance bool `json:"in_maintenance"`
+	// Tags, with labels as key:value tags, are filled in by the API in host
+	// listings
+	Tags []string `json:"tags,omitempty"`
 	// Latest usage data (optional, populated by GetAllHosts)
 	CPUPercent       *float64 `json:"cpu_percent,omitempty"`
 	UsedMemoryBytes  *int64   `json:"used_memory_bytes,omitempty"`
@@ -28,3 +58,20 @@ type HostUsage struct {
 	UsedMemoryBytes  int64     `json:"used_memory_bytes"`
 	UsedStorageBytes int64     `json:"used_storage_bytes"`
 }
+
+// UsagePoint is the average usage of a host over one step of its usage
+// history, starting at Timestamp.
+type UsagePoint struct {
+	Timestamp        time.Time `json:"timestamp"`
+	CPUPercent       float64   `json:"cpu_percent"`
+	UsedMemoryBytes  int64     `json:"used_memory_bytes"`
+	UsedStorageBytes int64     `json:"used_storage_bytes"`
+	Samples          int       `json:"samples"`
+}
+
+// UsageSummary is the lowest, average and highest value of a usage metric.
+type UsageSummary struct {
+	Min float64 `json:"min"`
+	Avg float64 `json:"avg"`
+	Max float64 `json:"max"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Inventory is the OS and hardware inventory reported by a host's agent.
+type Inventory struct {
+	OSName         string             `json:"os_name"`
+	OSVersion      string             `json:"os_version"`
+	KernelVersion  string             `json:"kernel_version"`
+	Arch           string             `json:"arch"`
+	CPUModel       string             `json:"cpu_model"`
+	Virtualization string             `json:"virtualization"`
+	Container      string             `json:"container"`
+	BootTime       time.Time          `json:"boot_time"`
+	Timezone       string             `json:"timezone"`
+	Interfaces     []NetworkInterface `json:"interfaces"`
+}
+
+type NetworkInterface struct {
+	Name      string   `json:"name"`
+	MAC       string   `json:"mac"`
+	Addresses []string `json:"addresses"`
+}
+
+// InventoryRecord is one version of a host's inventory. A new version is
+// recorded whenever the reported inventory differs from the previous one;
+// Changes lists the fields that differ.
+type InventoryRecord struct {
+	ID          int64     `json:"id"`
+	HostID      int64     `json:"host_id"`
+	Version     int       `json:"version"`
+	Inventory   Inventory `json:"inventory"`
+	Changes     []string  `json:"changes"`
+	CollectedAt time.Time `json:"collected_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,8 @@
+package models
+
+// LabelSummary is a label key and the number of hosts with each of its
+// values.
+type LabelSummary struct {
+	Key    string         `json:"key"`
+	Values map[string]int `json:"values"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// LogSample is the number of lines matching a log rule on a host during one
+// report interval.
+type LogSample struct {
+	ID              int64             `json:"id"`
+	HostID          int64             `json:"host_id"`
+	Rule            string            `json:"rule"`
+	Labels          map[string]string `json:"labels"`
+	Timestamp       time.Time         `json:"timestamp"`
+	IntervalSeconds int32             `json:"interval_seconds"`
+	Count           int64             `json:"count"`
+	// Matching lines reported with the sample; stored separately, see
+	// LogLine.
+	Lines []string `json:"lines,omitempty"`
+}
+
+// LogLine is a recent line matching a log rule.
+type LogLine struct {
+	Timestamp time.Time `json:"timestamp"`
+	Line      string    `json:"line"`
+}
+
+// LogRuleSummary describes the recent activity of a log rule on a host.
+type LogRuleSummary struct {
+	Rule          string            `json:"rule"`
+	Labels        map[string]string `json:"labels"`
+	LastCount     int64             `json:"last_count"`
+	LastSampleAt  time.Time         `json:"last_sample_at"`
+	CountLastHour int64             `json:"count_last_hour"`
+	Lines         []LogLine         `json:"lines"`
+}
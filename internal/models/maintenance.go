@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// MaintenanceWindow silences a host, or every host matching the selector
+// Tag, between StartsAt and EndsAt: no new alerts are opened for it and its
+// presence changes aren't announced.
+type MaintenanceWindow struct {
+	ID        int64     `json:"id"`
+	Hostname  string    `json:"hostname,omitempty"`
+	Tag       string    `json:"tag,omitempty"`
+	Reason    string    `json:"reason"`
+	Author    string    `json:"author"`
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	CreatedAt time.Time `json:"created_at"`
+	// Active is computed by the API from the current time
+	Active bool `json:"active"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Host presence states
+const (
+	// HostConnecting hosts have opened a stream but not sent metrics yet.
+	HostConnecting = "connecting"
+	HostOnline     = "online"
+	// HostStale hosts stopped sending metrics or lost their stream and may
+	// still come back.
+	HostStale   = "stale"
+	HostOffline = "offline"
+	// HostDecommissioned hosts are retired and no longer tracked.
+	HostDecommissioned = "decommissioned"
+)
+
+// StatusTransition is a change of a host's presence state with the reason
+// the controller made it.
+type StatusTransition struct {
+	ID         int64     `json:"id"`
+	HostID     int64     `json:"host_id"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	Reason     string    `json:"reason"`
+	Timestamp  time.Time `json:"timestamp"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// AgentProfile is agent configuration assigned to the hosts matching Selector.
+// When several profiles match a host, the one with the highest Priority wins.
+type AgentProfile struct {
+	ID                    int64     `json:"id"`
+	Name                  string    `json:"name"`
+	Selector              string    `json:"selector"`
+	Priority              int       `json:"priority"`
+	ReportIntervalSeconds int32     `json:"report_interval_seconds"`
+	EnabledCollectors     []string  `json:"enabled_collectors"`
+	MountInclude          []string  `json:"mount_include"`
+	MountExclude          []string  `json:"mount_exclude"`
+	Version               int64     `json:"version"`
+	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
+}
@@ -6,4 +6,16 @@ type Tag struct {
 	ID        int64     `json:"id"`
 	Name      string    `json:"name"`
 	CreatedAt time.Time `json:"created_at"`
+	// Number of hosts with the tag
+	Hosts int `json:"hosts"`
+}
+
+// HostTagResult is what a bulk tag operation did to one host: the tags it
+// added and removed, leaving out those the host already had or didn't have,
+// or why the host was skipped.
+type HostTagResult struct {
+	Hostname string   `json:"hostname"`
+	Added    []string `json:"added"`
+	Removed  []string `json:"removed"`
+	Error    string   `json:"error,omitempty"`
 }
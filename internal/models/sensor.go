@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Sensor is a hardware temperature or fan sensor of a host with its latest
+// reading.
+type Sensor struct {
+	ID       int64   `json:"id"`
+	HostID   int64   `json:"host_id"`
+	Chip     string  `json:"chip"`
+	Sensor   string  `json:"sensor"`
+	Label    string  `json:"label"`
+	Kind     string  `json:"kind"`
+	Value    float64 `json:"value"`
+	Critical float64 `json:"critical"`
+	Max      float64 `json:"max"`
+	// OverCritical is set when a temperature is at or above its critical
+	// threshold.
+	OverCritical bool      `json:"over_critical"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type SensorReading struct {
+	SensorID  int64     `json:"sensor_id"`
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// TagRule adds Tag to every host matching all of its conditions that are set.
+// A tag added by a rule is removed again once the rule stops matching the
+// host; tags the host already had are left alone.
+type TagRule struct {
+	ID            int64     `json:"id"`
+	Name          string    `json:"name"`
+	Tag           string    `json:"tag"`
+	HostnameRegex string    `json:"hostname_regex,omitempty"`
+	CIDR          string    `json:"cidr,omitempty"`
+	MinCPUCores   int32     `json:"min_cpu_cores,omitempty"`
+	MaxCPUCores   int32     `json:"max_cpu_cores,omitempty"`
+	OS            string    `json:"os,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// TagRulePreview is what saving a tagging rule would do: the hosts it
+// matches, and those it would add its tag to or remove it from.
+type TagRulePreview struct {
+	Rule    TagRule  `json:"rule"`
+	Matches []string `json:"matches"`
+	Add     []string `json:"add"`
+	Remove  []string `json:"remove"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Unit is the latest known state of a systemd unit watched on a host.
+type Unit struct {
+	HostID      int64     `json:"host_id"`
+	Hostname    string    `json:"hostname"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	LoadState   string    `json:"load_state"`
+	ActiveState string    `json:"active_state"`
+	SubState    string    `json:"sub_state"`
+	Since       time.Time `json:"since"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// UnitTransition records a unit changing state.
+type UnitTransition struct {
+	ID              int64     `json:"id"`
+	HostID          int64     `json:"host_id"`
+	Unit            string    `json:"unit"`
+	FromActiveState string    `json:"from_active_state"`
+	FromSubState    string    `json:"from_sub_state"`
+	ToActiveState   string    `json:"to_active_state"`
+	ToSubState      string    `json:"to_sub_state"`
+	Timestamp       time.Time `json:"timestamp"`
+}
@@ -0,0 +1,122 @@
+package version
+
+import (
+	"cmp"
+	"strconv"
+	"strings"
+)
+
+// Version is the release version of this build, set at build time with
+// -ldflags "-X github.com/metorial/sentinel/internal/version.Version=v1.2.3".
+var Version = "dev"
+
+const (
+	// Protocol is the agent/controller stream protocol version of this build.
+	Protocol int32 = 2
+
+	// MinProtocol is the oldest agent protocol the controller accepts.
+	// Protocol 1 agents predate the Hello handshake and register with their
+	// first metrics message.
+	MinProtocol int32 = 1
+)
+
+// Compare compares two semantic versions such as v1.2.3 or 1.2.3-rc.1,
+// returning -1, 0 or 1 as a is older than, the same as or newer than b. ok is
+// false if either is not a semantic version, as with dev builds.
+func Compare(a, b string) (result int, ok bool) {
+	va, okA := parse(a)
+	vb, okB := parse(b)
+	if !okA || !okB {
+		return 0, false
+	}
+
+	for i := range va.core {
+		if va.core[i] != vb.core[i] {
+			return cmp.Compare(va.core[i], vb.core[i]), true
+		}
+	}
+
+	// A pre-release is older than its release
+	switch {
+	case va.pre == "" && vb.pre == "":
+		return 0, true
+	case va.pre == "":
+		return 1, true
+	case vb.pre == "":
+		return -1, true
+	}
+
+	ida, idb := strings.Split(va.pre, "."), strings.Split(vb.pre, ".")
+	for i := 0; i < len(ida) && i < len(idb); i++ {
+		if ida[i] == idb[i] {
+			continue
+		}
+		numA, numB := numeric(ida[i]), numeric(idb[i])
+		switch {
+		case numA && numB:
+			na, _ := strconv.Atoi(ida[i])
+			nb, _ := strconv.Atoi(idb[i])
+			return cmp.Compare(na, nb), true
+		case numA:
+			// Numeric identifiers are older than alphanumeric ones
+			return -1, true
+		case numB:
+			return 1, true
+		case ida[i] < idb[i]:
+			return -1, true
+		default:
+			return 1, true
+		}
+	}
+	return cmp.Compare(len(ida), len(idb)), true
+}
+
+type semver struct {
+	core [3]int
+	pre  string
+}
+
+func parse(s string) (semver, bool) {
+	var v semver
+	s = strings.TrimPrefix(s, "v")
+	s, _, _ = strings.Cut(s, "+")
+	s, pre, hasPre := strings.Cut(s, "-")
+	if hasPre {
+		for _, id := range strings.Split(pre, ".") {
+			if id == "" {
+				return v, false
+			}
+		}
+		v.pre = pre
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return v, false
+	}
+	for i, p := range parts {
+		if !numeric(p) {
+			return v, false
+		}
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return v, false
+		}
+		v.core[i] = n
+	}
+	return v, true
+}
+
+// numeric reports whether s is a non-empty string of digits. strconv.Atoi
+// alone also accepts a sign.
+func numeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+package version
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+		ok   bool
+	}{
+		{"1.2.3", "1.2.3", 0, true},
+		{"v1.2.3", "1.2.3", 0, true},
+		{"v1.2.3", "v1.2.4", -1, true},
+		{"v1.10.0", "v1.9.0", 1, true},
+		{"v2.0.0", "v1.99.99", 1, true},
+		{"v1.2.3+build.5", "v1.2.3", 0, true},
+
+		// A pre-release is older than its release
+		{"v1.2.3-rc.1", "v1.2.3", -1, true},
+		{"v1.2.3", "v1.2.3-rc.1", 1, true},
+		{"v1.2.3-rc.1", "v1.2.2", 1, true},
+
+		// Pre-release identifiers compare numerically or lexically
+		{"v1.2.3-rc.2", "v1.2.3-rc.10", -1, true},
+		{"v1.2.3-alpha", "v1.2.3-beta", -1, true},
+		{"v1.2.3-1", "v1.2.3-alpha", -1, true},
+		{"v1.2.3-alpha", "v1.2.3-1", 1, true},
+		{"v1.2.3-alpha", "v1.2.3-alpha.1", -1, true},
+		{"v1.2.3-alpha.beta", "v1.2.3-alpha.1", 1, true},
+		{"v1.2.3-rc.1+build.2", "v1.2.3-rc.1", 0, true},
+
+		// Not semantic versions
+		{"dev", "v1.2.3", 0, false},
+		{"v1.2.3", "", 0, false},
+		{"v1.2", "v1.2.0", 0, false},
+		{"v1.2.3.4", "v1.2.3", 0, false},
+		{"v1.x.3", "v1.2.3", 0, false},
+		{"v1.+2.3", "v1.2.3", 0, false},
+		{"v1..3", "v1.2.3", 0, false},
+		{"v1.2.3-", "v1.2.3", 0, false},
+		{"v1.2.3-rc..1", "v1.2.3", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+" vs "+tt.b, func(t *testing.T) {
+			got, ok := Compare(tt.a, tt.b)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("Compare(%q, %q) = %d, %v, want %d, %v", tt.a, tt.b, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
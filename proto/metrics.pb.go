@@ -226,28 +226,1989 @@ func (x *ResourceUsage) GetUsedStorageBytes() int64 {
 }
 
 type Acknowledgment struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Success bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Message string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	// Name of the agent collector whose data couldn't be stored, for collectors
+	// that only send changes: the agent sends it again on its next run.
+	Resend        string `protobuf:"bytes,3,opt,name=resend,proto3" json:"resend,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Acknowledgment) Reset() {
+	*x = Acknowledgment{}
+	mi := &file_proto_metrics_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Acknowledgment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Acknowledgment) ProtoMessage() {}
+
+func (x *Acknowledgment) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Acknowledgment.ProtoReflect.Descriptor instead.
+func (*Acknowledgment) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Acknowledgment) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *Acknowledgment) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *Acknowledgment) GetResend() string {
+	if x != nil {
+		return x.Resend
+	}
+	return ""
+}
+
+// Command sent from the collector to a connected agent. The id is echoed back
+// in the matching CommandResult so the collector can correlate responses.
+type Command struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Types that are valid to be assigned to Action:
+	//
+	//	*Command_CollectNow
+	//	*Command_SetInterval
+	//	*Command_RunDiagnostic
+	Action        isCommand_Action `protobuf_oneof:"action"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Command) Reset() {
+	*x = Command{}
+	mi := &file_proto_metrics_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Command) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Command) ProtoMessage() {}
+
+func (x *Command) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Command.ProtoReflect.Descriptor instead.
+func (*Command) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Command) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Command) GetAction() isCommand_Action {
+	if x != nil {
+		return x.Action
+	}
+	return nil
+}
+
+func (x *Command) GetCollectNow() *CollectNow {
+	if x != nil {
+		if x, ok := x.Action.(*Command_CollectNow); ok {
+			return x.CollectNow
+		}
+	}
+	return nil
+}
+
+func (x *Command) GetSetInterval() *SetInterval {
+	if x != nil {
+		if x, ok := x.Action.(*Command_SetInterval); ok {
+			return x.SetInterval
+		}
+	}
+	return nil
+}
+
+func (x *Command) GetRunDiagnostic() *RunDiagnostic {
+	if x != nil {
+		if x, ok := x.Action.(*Command_RunDiagnostic); ok {
+			return x.RunDiagnostic
+		}
+	}
+	return nil
+}
+
+type isCommand_Action interface {
+	isCommand_Action()
+}
+
+type Command_CollectNow struct {
+	CollectNow *CollectNow `protobuf:"bytes,2,opt,name=collect_now,json=collectNow,proto3,oneof"`
+}
+
+type Command_SetInterval struct {
+	SetInterval *SetInterval `protobuf:"bytes,3,opt,name=set_interval,json=setInterval,proto3,oneof"`
+}
+
+type Command_RunDiagnostic struct {
+	RunDiagnostic *RunDiagnostic `protobuf:"bytes,4,opt,name=run_diagnostic,json=runDiagnostic,proto3,oneof"`
+}
+
+func (*Command_CollectNow) isCommand_Action() {}
+
+func (*Command_SetInterval) isCommand_Action() {}
+
+func (*Command_RunDiagnostic) isCommand_Action() {}
+
+// Collect and send metrics immediately instead of waiting for the next tick.
+type CollectNow struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CollectNow) Reset() {
+	*x = CollectNow{}
+	mi := &file_proto_metrics_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CollectNow) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CollectNow) ProtoMessage() {}
+
+func (x *CollectNow) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CollectNow.ProtoReflect.Descriptor instead.
+func (*CollectNow) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{5}
+}
+
+// Change the metrics reporting interval of the agent.
+type SetInterval struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	IntervalSeconds int32                  `protobuf:"varint,1,opt,name=interval_seconds,json=intervalSeconds,proto3" json:"interval_seconds,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *SetInterval) Reset() {
+	*x = SetInterval{}
+	mi := &file_proto_metrics_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetInterval) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetInterval) ProtoMessage() {}
+
+func (x *SetInterval) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetInterval.ProtoReflect.Descriptor instead.
+func (*SetInterval) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *SetInterval) GetIntervalSeconds() int32 {
+	if x != nil {
+		return x.IntervalSeconds
+	}
+	return 0
+}
+
+// Run a named, built-in diagnostic on the agent and return its output.
+type RunDiagnostic struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RunDiagnostic) Reset() {
+	*x = RunDiagnostic{}
+	mi := &file_proto_metrics_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RunDiagnostic) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RunDiagnostic) ProtoMessage() {}
+
+func (x *RunDiagnostic) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RunDiagnostic.ProtoReflect.Descriptor instead.
+func (*RunDiagnostic) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *RunDiagnostic) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type CommandResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Success       bool                   `protobuf:"varint,2,opt,name=success,proto3" json:"success,omitempty"`
+	Output        string                 `protobuf:"bytes,3,opt,name=output,proto3" json:"output,omitempty"`
+	Error         string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CommandResult) Reset() {
+	*x = CommandResult{}
+	mi := &file_proto_metrics_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CommandResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CommandResult) ProtoMessage() {}
+
+func (x *CommandResult) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CommandResult.ProtoReflect.Descriptor instead.
+func (*CommandResult) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *CommandResult) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *CommandResult) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *CommandResult) GetOutput() string {
+	if x != nil {
+		return x.Output
+	}
+	return ""
+}
+
+func (x *CommandResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+// Configuration pushed from the collector to an agent. A version of 0 means no
+// profile matches the host and the agent should fall back to its defaults.
+type AgentConfig struct {
+	state                 protoimpl.MessageState `protogen:"open.v1"`
+	Version               int64                  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	Profile               string                 `protobuf:"bytes,2,opt,name=profile,proto3" json:"profile,omitempty"`
+	ReportIntervalSeconds int32                  `protobuf:"varint,3,opt,name=report_interval_seconds,json=reportIntervalSeconds,proto3" json:"report_interval_seconds,omitempty"`
+	// Optional collectors to run; empty enables every collector the agent has.
+	EnabledCollectors []string `protobuf:"bytes,4,rep,name=enabled_collectors,json=enabledCollectors,proto3" json:"enabled_collectors,omitempty"`
+	// Mountpoint globs counted towards storage; empty means "/" only.
+	MountInclude  []string `protobuf:"bytes,5,rep,name=mount_include,json=mountInclude,proto3" json:"mount_include,omitempty"`
+	MountExclude  []string `protobuf:"bytes,6,rep,name=mount_exclude,json=mountExclude,proto3" json:"mount_exclude,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AgentConfig) Reset() {
+	*x = AgentConfig{}
+	mi := &file_proto_metrics_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AgentConfig) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentConfig) ProtoMessage() {}
+
+func (x *AgentConfig) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentConfig.ProtoReflect.Descriptor instead.
+func (*AgentConfig) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *AgentConfig) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *AgentConfig) GetProfile() string {
+	if x != nil {
+		return x.Profile
+	}
+	return ""
+}
+
+func (x *AgentConfig) GetReportIntervalSeconds() int32 {
+	if x != nil {
+		return x.ReportIntervalSeconds
+	}
+	return 0
+}
+
+func (x *AgentConfig) GetEnabledCollectors() []string {
+	if x != nil {
+		return x.EnabledCollectors
+	}
+	return nil
+}
+
+func (x *AgentConfig) GetMountInclude() []string {
+	if x != nil {
+		return x.MountInclude
+	}
+	return nil
+}
+
+func (x *AgentConfig) GetMountExclude() []string {
+	if x != nil {
+		return x.MountExclude
+	}
+	return nil
+}
+
+// Sent by the agent after it has applied (or failed to apply) an AgentConfig.
+type ConfigApplied struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Version       int64                  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	Success       bool                   `protobuf:"varint,2,opt,name=success,proto3" json:"success,omitempty"`
+	Error         string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConfigApplied) Reset() {
+	*x = ConfigApplied{}
+	mi := &file_proto_metrics_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConfigApplied) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConfigApplied) ProtoMessage() {}
+
+func (x *ConfigApplied) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConfigApplied.ProtoReflect.Descriptor instead.
+func (*ConfigApplied) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ConfigApplied) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *ConfigApplied) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *ConfigApplied) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+// First message sent by an agent on a new stream. Collectors reject streams
+// whose protocol version they don't support.
+type Hello struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Hostname        string                 `protobuf:"bytes,1,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	AgentVersion    string                 `protobuf:"bytes,2,opt,name=agent_version,json=agentVersion,proto3" json:"agent_version,omitempty"`
+	ProtocolVersion int32                  `protobuf:"varint,3,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"`
+	Os              string                 `protobuf:"bytes,4,opt,name=os,proto3" json:"os,omitempty"`
+	Arch            string                 `protobuf:"bytes,5,opt,name=arch,proto3" json:"arch,omitempty"`
+	Capabilities    []string               `protobuf:"bytes,6,rep,name=capabilities,proto3" json:"capabilities,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Hello) Reset() {
+	*x = Hello{}
+	mi := &file_proto_metrics_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Hello) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Hello) ProtoMessage() {}
+
+func (x *Hello) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Hello.ProtoReflect.Descriptor instead.
+func (*Hello) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Hello) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *Hello) GetAgentVersion() string {
+	if x != nil {
+		return x.AgentVersion
+	}
+	return ""
+}
+
+func (x *Hello) GetProtocolVersion() int32 {
+	if x != nil {
+		return x.ProtocolVersion
+	}
+	return 0
+}
+
+func (x *Hello) GetOs() string {
+	if x != nil {
+		return x.Os
+	}
+	return ""
+}
+
+func (x *Hello) GetArch() string {
+	if x != nil {
+		return x.Arch
+	}
+	return ""
+}
+
+func (x *Hello) GetCapabilities() []string {
+	if x != nil {
+		return x.Capabilities
+	}
+	return nil
+}
+
+// Reply to Hello listing the agent capabilities the collector will use.
+type Welcome struct {
+	state                protoimpl.MessageState `protogen:"open.v1"`
+	ControllerVersion    string                 `protobuf:"bytes,1,opt,name=controller_version,json=controllerVersion,proto3" json:"controller_version,omitempty"`
+	ProtocolVersion      int32                  `protobuf:"varint,2,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"`
+	AcceptedCapabilities []string               `protobuf:"bytes,3,rep,name=accepted_capabilities,json=acceptedCapabilities,proto3" json:"accepted_capabilities,omitempty"`
+	unknownFields        protoimpl.UnknownFields
+	sizeCache            protoimpl.SizeCache
+}
+
+func (x *Welcome) Reset() {
+	*x = Welcome{}
+	mi := &file_proto_metrics_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Welcome) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Welcome) ProtoMessage() {}
+
+func (x *Welcome) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Welcome.ProtoReflect.Descriptor instead.
+func (*Welcome) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *Welcome) GetControllerVersion() string {
+	if x != nil {
+		return x.ControllerVersion
+	}
+	return ""
+}
+
+func (x *Welcome) GetProtocolVersion() int32 {
+	if x != nil {
+		return x.ProtocolVersion
+	}
+	return 0
+}
+
+func (x *Welcome) GetAcceptedCapabilities() []string {
+	if x != nil {
+		return x.AcceptedCapabilities
+	}
+	return nil
+}
+
+// OS and hardware inventory of the host. Agents send it on startup and
+// whenever it changes.
+type Inventory struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Hostname      string                 `protobuf:"bytes,1,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	OsName        string                 `protobuf:"bytes,3,opt,name=os_name,json=osName,proto3" json:"os_name,omitempty"`
+	OsVersion     string                 `protobuf:"bytes,4,opt,name=os_version,json=osVersion,proto3" json:"os_version,omitempty"`
+	KernelVersion string                 `protobuf:"bytes,5,opt,name=kernel_version,json=kernelVersion,proto3" json:"kernel_version,omitempty"`
+	Arch          string                 `protobuf:"bytes,6,opt,name=arch,proto3" json:"arch,omitempty"`
+	CpuModel      string                 `protobuf:"bytes,7,opt,name=cpu_model,json=cpuModel,proto3" json:"cpu_model,omitempty"`
+	// Hypervisor the host runs under (e.g. "kvm"), empty on bare metal.
+	Virtualization string `protobuf:"bytes,8,opt,name=virtualization,proto3" json:"virtualization,omitempty"`
+	// Container runtime the agent runs in (e.g. "docker"), empty if none.
+	Container     string              `protobuf:"bytes,9,opt,name=container,proto3" json:"container,omitempty"`
+	BootTime      int64               `protobuf:"varint,10,opt,name=boot_time,json=bootTime,proto3" json:"boot_time,omitempty"`
+	Timezone      string              `protobuf:"bytes,11,opt,name=timezone,proto3" json:"timezone,omitempty"`
+	Interfaces    []*NetworkInterface `protobuf:"bytes,12,rep,name=interfaces,proto3" json:"interfaces,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Inventory) Reset() {
+	*x = Inventory{}
+	mi := &file_proto_metrics_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Inventory) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Inventory) ProtoMessage() {}
+
+func (x *Inventory) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Inventory.ProtoReflect.Descriptor instead.
+func (*Inventory) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *Inventory) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *Inventory) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *Inventory) GetOsName() string {
+	if x != nil {
+		return x.OsName
+	}
+	return ""
+}
+
+func (x *Inventory) GetOsVersion() string {
+	if x != nil {
+		return x.OsVersion
+	}
+	return ""
+}
+
+func (x *Inventory) GetKernelVersion() string {
+	if x != nil {
+		return x.KernelVersion
+	}
+	return ""
+}
+
+func (x *Inventory) GetArch() string {
+	if x != nil {
+		return x.Arch
+	}
+	return ""
+}
+
+func (x *Inventory) GetCpuModel() string {
+	if x != nil {
+		return x.CpuModel
+	}
+	return ""
+}
+
+func (x *Inventory) GetVirtualization() string {
+	if x != nil {
+		return x.Virtualization
+	}
+	return ""
+}
+
+func (x *Inventory) GetContainer() string {
+	if x != nil {
+		return x.Container
+	}
+	return ""
+}
+
+func (x *Inventory) GetBootTime() int64 {
+	if x != nil {
+		return x.BootTime
+	}
+	return 0
+}
+
+func (x *Inventory) GetTimezone() string {
+	if x != nil {
+		return x.Timezone
+	}
+	return ""
+}
+
+func (x *Inventory) GetInterfaces() []*NetworkInterface {
+	if x != nil {
+		return x.Interfaces
+	}
+	return nil
+}
+
+type NetworkInterface struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Mac   string                 `protobuf:"bytes,2,opt,name=mac,proto3" json:"mac,omitempty"`
+	// IPv4 and IPv6 addresses in CIDR notation.
+	Addresses     []string `protobuf:"bytes,3,rep,name=addresses,proto3" json:"addresses,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NetworkInterface) Reset() {
+	*x = NetworkInterface{}
+	mi := &file_proto_metrics_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NetworkInterface) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NetworkInterface) ProtoMessage() {}
+
+func (x *NetworkInterface) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NetworkInterface.ProtoReflect.Descriptor instead.
+func (*NetworkInterface) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *NetworkInterface) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *NetworkInterface) GetMac() string {
+	if x != nil {
+		return x.Mac
+	}
+	return ""
+}
+
+func (x *NetworkInterface) GetAddresses() []string {
+	if x != nil {
+		return x.Addresses
+	}
+	return nil
+}
+
+// Resource usage of the container cgroups (v2) running on a host. Every
+// report is a full snapshot; cgroups missing from it have stopped.
+type CgroupMetrics struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Hostname      string                 `protobuf:"bytes,1,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Cgroups       []*CgroupUsage         `protobuf:"bytes,3,rep,name=cgroups,proto3" json:"cgroups,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CgroupMetrics) Reset() {
+	*x = CgroupMetrics{}
+	mi := &file_proto_metrics_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CgroupMetrics) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CgroupMetrics) ProtoMessage() {}
+
+func (x *CgroupMetrics) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CgroupMetrics.ProtoReflect.Descriptor instead.
+func (*CgroupMetrics) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *CgroupMetrics) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *CgroupMetrics) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *CgroupMetrics) GetCgroups() []*CgroupUsage {
+	if x != nil {
+		return x.Cgroups
+	}
+	return nil
+}
+
+type CgroupUsage struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Path relative to the cgroup2 mount, e.g. "system.slice/docker-<id>.scope".
+	Path string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	// Workload runtime inferred from the path ("docker", "nomad", ...).
+	Runtime string `protobuf:"bytes,2,opt,name=runtime,proto3" json:"runtime,omitempty"`
+	// Container, task or pod id inferred from the path.
+	Id string `protobuf:"bytes,3,opt,name=id,proto3" json:"id,omitempty"`
+	// CPU usage since the previous report; 100 is one fully used core.
+	CpuPercent  float64 `protobuf:"fixed64,4,opt,name=cpu_percent,json=cpuPercent,proto3" json:"cpu_percent,omitempty"`
+	MemoryBytes int64   `protobuf:"varint,5,opt,name=memory_bytes,json=memoryBytes,proto3" json:"memory_bytes,omitempty"`
+	// 0 if the cgroup has no memory limit.
+	MemoryLimitBytes int64 `protobuf:"varint,6,opt,name=memory_limit_bytes,json=memoryLimitBytes,proto3" json:"memory_limit_bytes,omitempty"`
+	// Cumulative bytes read and written since the cgroup was created.
+	IoReadBytes   int64 `protobuf:"varint,7,opt,name=io_read_bytes,json=ioReadBytes,proto3" json:"io_read_bytes,omitempty"`
+	IoWriteBytes  int64 `protobuf:"varint,8,opt,name=io_write_bytes,json=ioWriteBytes,proto3" json:"io_write_bytes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CgroupUsage) Reset() {
+	*x = CgroupUsage{}
+	mi := &file_proto_metrics_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CgroupUsage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CgroupUsage) ProtoMessage() {}
+
+func (x *CgroupUsage) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CgroupUsage.ProtoReflect.Descriptor instead.
+func (*CgroupUsage) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *CgroupUsage) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *CgroupUsage) GetRuntime() string {
+	if x != nil {
+		return x.Runtime
+	}
+	return ""
+}
+
+func (x *CgroupUsage) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *CgroupUsage) GetCpuPercent() float64 {
+	if x != nil {
+		return x.CpuPercent
+	}
+	return 0
+}
+
+func (x *CgroupUsage) GetMemoryBytes() int64 {
+	if x != nil {
+		return x.MemoryBytes
+	}
+	return 0
+}
+
+func (x *CgroupUsage) GetMemoryLimitBytes() int64 {
+	if x != nil {
+		return x.MemoryLimitBytes
+	}
+	return 0
+}
+
+func (x *CgroupUsage) GetIoReadBytes() int64 {
+	if x != nil {
+		return x.IoReadBytes
+	}
+	return 0
+}
+
+func (x *CgroupUsage) GetIoWriteBytes() int64 {
+	if x != nil {
+		return x.IoWriteBytes
+	}
+	return 0
+}
+
+// Containers known to the Docker Engine on a host. Every report is a full
+// list replacing the previous one.
+type ContainerList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Hostname      string                 `protobuf:"bytes,1,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Containers    []*Container           `protobuf:"bytes,3,rep,name=containers,proto3" json:"containers,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ContainerList) Reset() {
+	*x = ContainerList{}
+	mi := &file_proto_metrics_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ContainerList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ContainerList) ProtoMessage() {}
+
+func (x *ContainerList) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ContainerList.ProtoReflect.Descriptor instead.
+func (*ContainerList) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *ContainerList) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *ContainerList) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *ContainerList) GetContainers() []*Container {
+	if x != nil {
+		return x.Containers
+	}
+	return nil
+}
+
+type Container struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name  string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Image string                 `protobuf:"bytes,3,opt,name=image,proto3" json:"image,omitempty"`
+	// Docker state: created, running, paused, restarting, exited, dead, ...
+	State string `protobuf:"bytes,4,opt,name=state,proto3" json:"state,omitempty"`
+	// Human readable status, e.g. "Up 2 hours".
+	Status       string `protobuf:"bytes,5,opt,name=status,proto3" json:"status,omitempty"`
+	RestartCount int32  `protobuf:"varint,6,opt,name=restart_count,json=restartCount,proto3" json:"restart_count,omitempty"`
+	Created      int64  `protobuf:"varint,7,opt,name=created,proto3" json:"created,omitempty"`
+	// Resource usage; only set for running containers. 100% is one full core.
+	CpuPercent       float64 `protobuf:"fixed64,8,opt,name=cpu_percent,json=cpuPercent,proto3" json:"cpu_percent,omitempty"`
+	MemoryBytes      int64   `protobuf:"varint,9,opt,name=memory_bytes,json=memoryBytes,proto3" json:"memory_bytes,omitempty"`
+	MemoryLimitBytes int64   `protobuf:"varint,10,opt,name=memory_limit_bytes,json=memoryLimitBytes,proto3" json:"memory_limit_bytes,omitempty"`
+	NetworkRxBytes   int64   `protobuf:"varint,11,opt,name=network_rx_bytes,json=networkRxBytes,proto3" json:"network_rx_bytes,omitempty"`
+	NetworkTxBytes   int64   `protobuf:"varint,12,opt,name=network_tx_bytes,json=networkTxBytes,proto3" json:"network_tx_bytes,omitempty"`
+	BlockReadBytes   int64   `protobuf:"varint,13,opt,name=block_read_bytes,json=blockReadBytes,proto3" json:"block_read_bytes,omitempty"`
+	BlockWriteBytes  int64   `protobuf:"varint,14,opt,name=block_write_bytes,json=blockWriteBytes,proto3" json:"block_write_bytes,omitempty"`
+	// Why the details or usage of the container couldn't be read this tick, in
+	// which case they are missing or from an earlier tick.
+	Error         string `protobuf:"bytes,15,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Container) Reset() {
+	*x = Container{}
+	mi := &file_proto_metrics_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Container) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Container) ProtoMessage() {}
+
+func (x *Container) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Container.ProtoReflect.Descriptor instead.
+func (*Container) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *Container) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Container) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Container) GetImage() string {
+	if x != nil {
+		return x.Image
+	}
+	return ""
+}
+
+func (x *Container) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *Container) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Container) GetRestartCount() int32 {
+	if x != nil {
+		return x.RestartCount
+	}
+	return 0
+}
+
+func (x *Container) GetCreated() int64 {
+	if x != nil {
+		return x.Created
+	}
+	return 0
+}
+
+func (x *Container) GetCpuPercent() float64 {
+	if x != nil {
+		return x.CpuPercent
+	}
+	return 0
+}
+
+func (x *Container) GetMemoryBytes() int64 {
+	if x != nil {
+		return x.MemoryBytes
+	}
+	return 0
+}
+
+func (x *Container) GetMemoryLimitBytes() int64 {
+	if x != nil {
+		return x.MemoryLimitBytes
+	}
+	return 0
+}
+
+func (x *Container) GetNetworkRxBytes() int64 {
+	if x != nil {
+		return x.NetworkRxBytes
+	}
+	return 0
+}
+
+func (x *Container) GetNetworkTxBytes() int64 {
+	if x != nil {
+		return x.NetworkTxBytes
+	}
+	return 0
+}
+
+func (x *Container) GetBlockReadBytes() int64 {
+	if x != nil {
+		return x.BlockReadBytes
+	}
+	return 0
+}
+
+func (x *Container) GetBlockWriteBytes() int64 {
+	if x != nil {
+		return x.BlockWriteBytes
+	}
+	return 0
+}
+
+func (x *Container) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+// A synthetic check the agent runs against target every interval.
+type CheckDefinition struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name  string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	// One of "http", "tcp", "tls", "dns".
+	Type string `protobuf:"bytes,3,opt,name=type,proto3" json:"type,omitempty"`
+	// URL for http, host:port for tcp and tls, hostname for dns.
+	Target          string `protobuf:"bytes,4,opt,name=target,proto3" json:"target,omitempty"`
+	IntervalSeconds int32  `protobuf:"varint,5,opt,name=interval_seconds,json=intervalSeconds,proto3" json:"interval_seconds,omitempty"`
+	TimeoutSeconds  int32  `protobuf:"varint,6,opt,name=timeout_seconds,json=timeoutSeconds,proto3" json:"timeout_seconds,omitempty"`
+	// http: expected status code; 0 accepts any 2xx or 3xx status.
+	ExpectedStatus int32 `protobuf:"varint,7,opt,name=expected_status,json=expectedStatus,proto3" json:"expected_status,omitempty"`
+	// http: regular expression the response body must match, if set.
+	BodyRegex string `protobuf:"bytes,8,opt,name=body_regex,json=bodyRegex,proto3" json:"body_regex,omitempty"`
+	// tls: minimum number of days the certificate must remain valid.
+	MinDaysValid int32 `protobuf:"varint,9,opt,name=min_days_valid,json=minDaysValid,proto3" json:"min_days_valid,omitempty"`
+	// dns: address that must be among the answers, if set.
+	ExpectedAddress string `protobuf:"bytes,10,opt,name=expected_address,json=expectedAddress,proto3" json:"expected_address,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *CheckDefinition) Reset() {
+	*x = CheckDefinition{}
+	mi := &file_proto_metrics_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CheckDefinition) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CheckDefinition) ProtoMessage() {}
+
+func (x *CheckDefinition) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CheckDefinition.ProtoReflect.Descriptor instead.
+func (*CheckDefinition) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *CheckDefinition) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *CheckDefinition) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *CheckDefinition) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *CheckDefinition) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
+func (x *CheckDefinition) GetIntervalSeconds() int32 {
+	if x != nil {
+		return x.IntervalSeconds
+	}
+	return 0
+}
+
+func (x *CheckDefinition) GetTimeoutSeconds() int32 {
+	if x != nil {
+		return x.TimeoutSeconds
+	}
+	return 0
+}
+
+func (x *CheckDefinition) GetExpectedStatus() int32 {
+	if x != nil {
+		return x.ExpectedStatus
+	}
+	return 0
+}
+
+func (x *CheckDefinition) GetBodyRegex() string {
+	if x != nil {
+		return x.BodyRegex
+	}
+	return ""
+}
+
+func (x *CheckDefinition) GetMinDaysValid() int32 {
+	if x != nil {
+		return x.MinDaysValid
+	}
+	return 0
+}
+
+func (x *CheckDefinition) GetExpectedAddress() string {
+	if x != nil {
+		return x.ExpectedAddress
+	}
+	return ""
+}
+
+// The full set of checks assigned to an agent, replacing any earlier set.
+type CheckAssignment struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Checks        []*CheckDefinition     `protobuf:"bytes,1,rep,name=checks,proto3" json:"checks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CheckAssignment) Reset() {
+	*x = CheckAssignment{}
+	mi := &file_proto_metrics_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CheckAssignment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CheckAssignment) ProtoMessage() {}
+
+func (x *CheckAssignment) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CheckAssignment.ProtoReflect.Descriptor instead.
+func (*CheckAssignment) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *CheckAssignment) GetChecks() []*CheckDefinition {
+	if x != nil {
+		return x.Checks
+	}
+	return nil
+}
+
+type CheckResult struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	CheckId   int64                  `protobuf:"varint,1,opt,name=check_id,json=checkId,proto3" json:"check_id,omitempty"`
+	Name      string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Timestamp int64                  `protobuf:"varint,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Success   bool                   `protobuf:"varint,4,opt,name=success,proto3" json:"success,omitempty"`
+	LatencyMs float64                `protobuf:"fixed64,5,opt,name=latency_ms,json=latencyMs,proto3" json:"latency_ms,omitempty"`
+	// Failure reason, or details such as the status code on success.
+	Message       string `protobuf:"bytes,6,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CheckResult) Reset() {
+	*x = CheckResult{}
+	mi := &file_proto_metrics_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CheckResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CheckResult) ProtoMessage() {}
+
+func (x *CheckResult) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CheckResult.ProtoReflect.Descriptor instead.
+func (*CheckResult) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *CheckResult) GetCheckId() int64 {
+	if x != nil {
+		return x.CheckId
+	}
+	return 0
+}
+
+func (x *CheckResult) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *CheckResult) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *CheckResult) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *CheckResult) GetLatencyMs() float64 {
+	if x != nil {
+		return x.LatencyMs
+	}
+	return 0
+}
+
+func (x *CheckResult) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+// TLS certificates found on a host. Every report is a full list replacing
+// the previous one.
+type CertificateList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Hostname      string                 `protobuf:"bytes,1,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Certificates  []*Certificate         `protobuf:"bytes,3,rep,name=certificates,proto3" json:"certificates,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CertificateList) Reset() {
+	*x = CertificateList{}
+	mi := &file_proto_metrics_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CertificateList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CertificateList) ProtoMessage() {}
+
+func (x *CertificateList) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CertificateList.ProtoReflect.Descriptor instead.
+func (*CertificateList) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *CertificateList) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *CertificateList) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *CertificateList) GetCertificates() []*Certificate {
+	if x != nil {
+		return x.Certificates
+	}
+	return nil
+}
+
+type Certificate struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// File path, or "tcp://address:port" for certificates served on a local
+	// listening port.
+	Source  string `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`
+	Subject string `protobuf:"bytes,2,opt,name=subject,proto3" json:"subject,omitempty"`
+	Issuer  string `protobuf:"bytes,3,opt,name=issuer,proto3" json:"issuer,omitempty"`
+	// DNS names, IP addresses and email addresses.
+	Sans      []string `protobuf:"bytes,4,rep,name=sans,proto3" json:"sans,omitempty"`
+	NotBefore int64    `protobuf:"varint,5,opt,name=not_before,json=notBefore,proto3" json:"not_before,omitempty"`
+	NotAfter  int64    `protobuf:"varint,6,opt,name=not_after,json=notAfter,proto3" json:"not_after,omitempty"`
+	Serial    string   `protobuf:"bytes,7,opt,name=serial,proto3" json:"serial,omitempty"`
+	// Hex encoded SHA-256 of the DER certificate.
+	Fingerprint   string `protobuf:"bytes,8,opt,name=fingerprint,proto3" json:"fingerprint,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Certificate) Reset() {
+	*x = Certificate{}
+	mi := &file_proto_metrics_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Certificate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Certificate) ProtoMessage() {}
+
+func (x *Certificate) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Certificate.ProtoReflect.Descriptor instead.
+func (*Certificate) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *Certificate) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *Certificate) GetSubject() string {
+	if x != nil {
+		return x.Subject
+	}
+	return ""
+}
+
+func (x *Certificate) GetIssuer() string {
+	if x != nil {
+		return x.Issuer
+	}
+	return ""
+}
+
+func (x *Certificate) GetSans() []string {
+	if x != nil {
+		return x.Sans
+	}
+	return nil
+}
+
+func (x *Certificate) GetNotBefore() int64 {
+	if x != nil {
+		return x.NotBefore
+	}
+	return 0
+}
+
+func (x *Certificate) GetNotAfter() int64 {
+	if x != nil {
+		return x.NotAfter
+	}
+	return 0
+}
+
+func (x *Certificate) GetSerial() string {
+	if x != nil {
+		return x.Serial
+	}
+	return ""
+}
+
+func (x *Certificate) GetFingerprint() string {
+	if x != nil {
+		return x.Fingerprint
+	}
+	return ""
+}
+
+// State of the systemd units an agent is configured to watch. Sent when any
+// unit changes state, as a full list.
+type UnitStatusList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Hostname      string                 `protobuf:"bytes,1,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Units         []*UnitStatus          `protobuf:"bytes,3,rep,name=units,proto3" json:"units,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UnitStatusList) Reset() {
+	*x = UnitStatusList{}
+	mi := &file_proto_metrics_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UnitStatusList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnitStatusList) ProtoMessage() {}
+
+func (x *UnitStatusList) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnitStatusList.ProtoReflect.Descriptor instead.
+func (*UnitStatusList) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *UnitStatusList) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *UnitStatusList) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *UnitStatusList) GetUnits() []*UnitStatus {
+	if x != nil {
+		return x.Units
+	}
+	return nil
+}
+
+type UnitStatus struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Unit name, e.g. "nginx.service".
+	Name        string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Description string `protobuf:"bytes,2,opt,name=description,proto3" json:"description,omitempty"`
+	// loaded, not-found, masked, ...
+	LoadState string `protobuf:"bytes,3,opt,name=load_state,json=loadState,proto3" json:"load_state,omitempty"`
+	// active, inactive, failed, activating, deactivating, reloading
+	ActiveState string `protobuf:"bytes,4,opt,name=active_state,json=activeState,proto3" json:"active_state,omitempty"`
+	// Unit type specific state, e.g. running, exited, dead.
+	SubState string `protobuf:"bytes,5,opt,name=sub_state,json=subState,proto3" json:"sub_state,omitempty"`
+	// When the unit last changed state, 0 if unknown.
+	Since         int64 `protobuf:"varint,6,opt,name=since,proto3" json:"since,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UnitStatus) Reset() {
+	*x = UnitStatus{}
+	mi := &file_proto_metrics_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UnitStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnitStatus) ProtoMessage() {}
+
+func (x *UnitStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnitStatus.ProtoReflect.Descriptor instead.
+func (*UnitStatus) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *UnitStatus) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *UnitStatus) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *UnitStatus) GetLoadState() string {
+	if x != nil {
+		return x.LoadState
+	}
+	return ""
+}
+
+func (x *UnitStatus) GetActiveState() string {
+	if x != nil {
+		return x.ActiveState
+	}
+	return ""
+}
+
+func (x *UnitStatus) GetSubState() string {
+	if x != nil {
+		return x.SubState
+	}
+	return ""
+}
+
+func (x *UnitStatus) GetSince() int64 {
+	if x != nil {
+		return x.Since
+	}
+	return 0
+}
+
+// Matches of the agent's log rules over one report interval.
+type LogCounts struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Hostname        string                 `protobuf:"bytes,1,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	Timestamp       int64                  `protobuf:"varint,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	IntervalSeconds int32                  `protobuf:"varint,3,opt,name=interval_seconds,json=intervalSeconds,proto3" json:"interval_seconds,omitempty"`
+	Counts          []*LogRuleCount        `protobuf:"bytes,4,rep,name=counts,proto3" json:"counts,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *LogCounts) Reset() {
+	*x = LogCounts{}
+	mi := &file_proto_metrics_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogCounts) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogCounts) ProtoMessage() {}
+
+func (x *LogCounts) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogCounts.ProtoReflect.Descriptor instead.
+func (*LogCounts) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *LogCounts) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *LogCounts) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *LogCounts) GetIntervalSeconds() int32 {
+	if x != nil {
+		return x.IntervalSeconds
+	}
+	return 0
+}
+
+func (x *LogCounts) GetCounts() []*LogRuleCount {
+	if x != nil {
+		return x.Counts
+	}
+	return nil
+}
+
+type LogRuleCount struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Rule  string                 `protobuf:"bytes,1,opt,name=rule,proto3" json:"rule,omitempty"`
+	// Labels identifying the sample besides the rule, e.g. the file path.
+	Labels map[string]string `protobuf:"bytes,2,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Lines matched during the interval.
+	Count int64 `protobuf:"varint,3,opt,name=count,proto3" json:"count,omitempty"`
+	// The last matching lines of the interval, oldest first.
+	Lines         []string `protobuf:"bytes,4,rep,name=lines,proto3" json:"lines,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LogRuleCount) Reset() {
+	*x = LogRuleCount{}
+	mi := &file_proto_metrics_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogRuleCount) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogRuleCount) ProtoMessage() {}
+
+func (x *LogRuleCount) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogRuleCount.ProtoReflect.Descriptor instead.
+func (*LogRuleCount) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *LogRuleCount) GetRule() string {
+	if x != nil {
+		return x.Rule
+	}
+	return ""
+}
+
+func (x *LogRuleCount) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *LogRuleCount) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *LogRuleCount) GetLines() []string {
+	if x != nil {
+		return x.Lines
+	}
+	return nil
+}
+
+// Hardware sensor readings from hwmon.
+type SensorReadings struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
-	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	Hostname      string                 `protobuf:"bytes,1,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Sensors       []*SensorReading       `protobuf:"bytes,3,rep,name=sensors,proto3" json:"sensors,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Acknowledgment) Reset() {
-	*x = Acknowledgment{}
-	mi := &file_proto_metrics_proto_msgTypes[3]
+func (x *SensorReadings) Reset() {
+	*x = SensorReadings{}
+	mi := &file_proto_metrics_proto_msgTypes[28]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Acknowledgment) String() string {
+func (x *SensorReadings) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Acknowledgment) ProtoMessage() {}
+func (*SensorReadings) ProtoMessage() {}
 
-func (x *Acknowledgment) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_metrics_proto_msgTypes[3]
+func (x *SensorReadings) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[28]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -258,31 +2219,147 @@ func (x *Acknowledgment) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Acknowledgment.ProtoReflect.Descriptor instead.
-func (*Acknowledgment) Descriptor() ([]byte, []int) {
-	return file_proto_metrics_proto_rawDescGZIP(), []int{3}
+// Deprecated: Use SensorReadings.ProtoReflect.Descriptor instead.
+func (*SensorReadings) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{28}
 }
 
-func (x *Acknowledgment) GetSuccess() bool {
+func (x *SensorReadings) GetHostname() string {
 	if x != nil {
-		return x.Success
+		return x.Hostname
 	}
-	return false
+	return ""
 }
 
-func (x *Acknowledgment) GetMessage() string {
+func (x *SensorReadings) GetTimestamp() int64 {
 	if x != nil {
-		return x.Message
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *SensorReadings) GetSensors() []*SensorReading {
+	if x != nil {
+		return x.Sensors
+	}
+	return nil
+}
+
+type SensorReading struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// hwmon chip name, e.g. "coretemp" or "nvme", suffixed with "-1", "-2",
+	// ... when several chips share a name.
+	Chip string `protobuf:"bytes,1,opt,name=chip,proto3" json:"chip,omitempty"`
+	// Sensor within the chip, e.g. "temp1" or "fan2".
+	Sensor string `protobuf:"bytes,2,opt,name=sensor,proto3" json:"sensor,omitempty"`
+	// Human readable label, e.g. "Package id 0"; may be empty.
+	Label string `protobuf:"bytes,3,opt,name=label,proto3" json:"label,omitempty"`
+	// "temperature" (degrees Celsius) or "fan" (RPM).
+	Kind  string  `protobuf:"bytes,4,opt,name=kind,proto3" json:"kind,omitempty"`
+	Value float64 `protobuf:"fixed64,5,opt,name=value,proto3" json:"value,omitempty"`
+	// Thresholds reported by the chip, 0 if unknown.
+	Critical      float64 `protobuf:"fixed64,6,opt,name=critical,proto3" json:"critical,omitempty"`
+	Max           float64 `protobuf:"fixed64,7,opt,name=max,proto3" json:"max,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SensorReading) Reset() {
+	*x = SensorReading{}
+	mi := &file_proto_metrics_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SensorReading) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SensorReading) ProtoMessage() {}
+
+func (x *SensorReading) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SensorReading.ProtoReflect.Descriptor instead.
+func (*SensorReading) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *SensorReading) GetChip() string {
+	if x != nil {
+		return x.Chip
+	}
+	return ""
+}
+
+func (x *SensorReading) GetSensor() string {
+	if x != nil {
+		return x.Sensor
+	}
+	return ""
+}
+
+func (x *SensorReading) GetLabel() string {
+	if x != nil {
+		return x.Label
+	}
+	return ""
+}
+
+func (x *SensorReading) GetKind() string {
+	if x != nil {
+		return x.Kind
 	}
 	return ""
 }
 
+func (x *SensorReading) GetValue() float64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+func (x *SensorReading) GetCritical() float64 {
+	if x != nil {
+		return x.Critical
+	}
+	return 0
+}
+
+func (x *SensorReading) GetMax() float64 {
+	if x != nil {
+		return x.Max
+	}
+	return 0
+}
+
 // Wrapper for messages from agent to collector
 type AgentMessage struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// Types that are valid to be assigned to Payload:
 	//
 	//	*AgentMessage_Metrics
+	//	*AgentMessage_CommandResult
+	//	*AgentMessage_ConfigApplied
+	//	*AgentMessage_Hello
+	//	*AgentMessage_Inventory
+	//	*AgentMessage_Cgroups
+	//	*AgentMessage_Containers
+	//	*AgentMessage_CheckResult
+	//	*AgentMessage_Certificates
+	//	*AgentMessage_Units
+	//	*AgentMessage_LogCounts
+	//	*AgentMessage_Sensors
 	Payload       isAgentMessage_Payload `protobuf_oneof:"payload"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
@@ -290,7 +2367,7 @@ type AgentMessage struct {
 
 func (x *AgentMessage) Reset() {
 	*x = AgentMessage{}
-	mi := &file_proto_metrics_proto_msgTypes[4]
+	mi := &file_proto_metrics_proto_msgTypes[30]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -302,7 +2379,7 @@ func (x *AgentMessage) String() string {
 func (*AgentMessage) ProtoMessage() {}
 
 func (x *AgentMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_metrics_proto_msgTypes[4]
+	mi := &file_proto_metrics_proto_msgTypes[30]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -315,7 +2392,7 @@ func (x *AgentMessage) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use AgentMessage.ProtoReflect.Descriptor instead.
 func (*AgentMessage) Descriptor() ([]byte, []int) {
-	return file_proto_metrics_proto_rawDescGZIP(), []int{4}
+	return file_proto_metrics_proto_rawDescGZIP(), []int{30}
 }
 
 func (x *AgentMessage) GetPayload() isAgentMessage_Payload {
@@ -334,6 +2411,105 @@ func (x *AgentMessage) GetMetrics() *HostMetrics {
 	return nil
 }
 
+func (x *AgentMessage) GetCommandResult() *CommandResult {
+	if x != nil {
+		if x, ok := x.Payload.(*AgentMessage_CommandResult); ok {
+			return x.CommandResult
+		}
+	}
+	return nil
+}
+
+func (x *AgentMessage) GetConfigApplied() *ConfigApplied {
+	if x != nil {
+		if x, ok := x.Payload.(*AgentMessage_ConfigApplied); ok {
+			return x.ConfigApplied
+		}
+	}
+	return nil
+}
+
+func (x *AgentMessage) GetHello() *Hello {
+	if x != nil {
+		if x, ok := x.Payload.(*AgentMessage_Hello); ok {
+			return x.Hello
+		}
+	}
+	return nil
+}
+
+func (x *AgentMessage) GetInventory() *Inventory {
+	if x != nil {
+		if x, ok := x.Payload.(*AgentMessage_Inventory); ok {
+			return x.Inventory
+		}
+	}
+	return nil
+}
+
+func (x *AgentMessage) GetCgroups() *CgroupMetrics {
+	if x != nil {
+		if x, ok := x.Payload.(*AgentMessage_Cgroups); ok {
+			return x.Cgroups
+		}
+	}
+	return nil
+}
+
+func (x *AgentMessage) GetContainers() *ContainerList {
+	if x != nil {
+		if x, ok := x.Payload.(*AgentMessage_Containers); ok {
+			return x.Containers
+		}
+	}
+	return nil
+}
+
+func (x *AgentMessage) GetCheckResult() *CheckResult {
+	if x != nil {
+		if x, ok := x.Payload.(*AgentMessage_CheckResult); ok {
+			return x.CheckResult
+		}
+	}
+	return nil
+}
+
+func (x *AgentMessage) GetCertificates() *CertificateList {
+	if x != nil {
+		if x, ok := x.Payload.(*AgentMessage_Certificates); ok {
+			return x.Certificates
+		}
+	}
+	return nil
+}
+
+func (x *AgentMessage) GetUnits() *UnitStatusList {
+	if x != nil {
+		if x, ok := x.Payload.(*AgentMessage_Units); ok {
+			return x.Units
+		}
+	}
+	return nil
+}
+
+func (x *AgentMessage) GetLogCounts() *LogCounts {
+	if x != nil {
+		if x, ok := x.Payload.(*AgentMessage_LogCounts); ok {
+			return x.LogCounts
+		}
+	}
+	return nil
+}
+
+func (x *AgentMessage) GetSensors() *SensorReadings {
+	if x != nil {
+		if x, ok := x.Payload.(*AgentMessage_Sensors); ok {
+			return x.Sensors
+		}
+	}
+	return nil
+}
+
 type isAgentMessage_Payload interface {
 	isAgentMessage_Payload()
 }
@@ -342,14 +2518,84 @@ type AgentMessage_Metrics struct {
 	Metrics *HostMetrics `protobuf:"bytes,1,opt,name=metrics,proto3,oneof"`
 }
 
+type AgentMessage_CommandResult struct {
+	CommandResult *CommandResult `protobuf:"bytes,2,opt,name=command_result,json=commandResult,proto3,oneof"`
+}
+
+type AgentMessage_ConfigApplied struct {
+	ConfigApplied *ConfigApplied `protobuf:"bytes,3,opt,name=config_applied,json=configApplied,proto3,oneof"`
+}
+
+type AgentMessage_Hello struct {
+	Hello *Hello `protobuf:"bytes,4,opt,name=hello,proto3,oneof"`
+}
+
+type AgentMessage_Inventory struct {
+	Inventory *Inventory `protobuf:"bytes,5,opt,name=inventory,proto3,oneof"`
+}
+
+type AgentMessage_Cgroups struct {
+	Cgroups *CgroupMetrics `protobuf:"bytes,6,opt,name=cgroups,proto3,oneof"`
+}
+
+type AgentMessage_Containers struct {
+	Containers *ContainerList `protobuf:"bytes,7,opt,name=containers,proto3,oneof"`
+}
+
+type AgentMessage_CheckResult struct {
+	CheckResult *CheckResult `protobuf:"bytes,8,opt,name=check_result,json=checkResult,proto3,oneof"`
+}
+
+type AgentMessage_Certificates struct {
+	Certificates *CertificateList `protobuf:"bytes,9,opt,name=certificates,proto3,oneof"`
+}
+
+type AgentMessage_Units struct {
+	Units *UnitStatusList `protobuf:"bytes,10,opt,name=units,proto3,oneof"`
+}
+
+type AgentMessage_LogCounts struct {
+	LogCounts *LogCounts `protobuf:"bytes,11,opt,name=log_counts,json=logCounts,proto3,oneof"`
+}
+
+type AgentMessage_Sensors struct {
+	Sensors *SensorReadings `protobuf:"bytes,12,opt,name=sensors,proto3,oneof"`
+}
+
 func (*AgentMessage_Metrics) isAgentMessage_Payload() {}
 
+func (*AgentMessage_CommandResult) isAgentMessage_Payload() {}
+
+func (*AgentMessage_ConfigApplied) isAgentMessage_Payload() {}
+
+func (*AgentMessage_Hello) isAgentMessage_Payload() {}
+
+func (*AgentMessage_Inventory) isAgentMessage_Payload() {}
+
+func (*AgentMessage_Cgroups) isAgentMessage_Payload() {}
+
+func (*AgentMessage_Containers) isAgentMessage_Payload() {}
+
+func (*AgentMessage_CheckResult) isAgentMessage_Payload() {}
+
+func (*AgentMessage_Certificates) isAgentMessage_Payload() {}
+
+func (*AgentMessage_Units) isAgentMessage_Payload() {}
+
+func (*AgentMessage_LogCounts) isAgentMessage_Payload() {}
+
+func (*AgentMessage_Sensors) isAgentMessage_Payload() {}
+
 // Wrapper for messages from collector to agent
 type CollectorMessage struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// Types that are valid to be assigned to Payload:
 	//
 	//	*CollectorMessage_Ack
+	//	*CollectorMessage_Command
+	//	*CollectorMessage_Config
+	//	*CollectorMessage_Welcome
+	//	*CollectorMessage_Checks
 	Payload       isCollectorMessage_Payload `protobuf_oneof:"payload"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
@@ -357,7 +2603,7 @@ type CollectorMessage struct {
 
 func (x *CollectorMessage) Reset() {
 	*x = CollectorMessage{}
-	mi := &file_proto_metrics_proto_msgTypes[5]
+	mi := &file_proto_metrics_proto_msgTypes[31]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -369,7 +2615,7 @@ func (x *CollectorMessage) String() string {
 func (*CollectorMessage) ProtoMessage() {}
 
 func (x *CollectorMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_metrics_proto_msgTypes[5]
+	mi := &file_proto_metrics_proto_msgTypes[31]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -382,7 +2628,7 @@ func (x *CollectorMessage) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CollectorMessage.ProtoReflect.Descriptor instead.
 func (*CollectorMessage) Descriptor() ([]byte, []int) {
-	return file_proto_metrics_proto_rawDescGZIP(), []int{5}
+	return file_proto_metrics_proto_rawDescGZIP(), []int{31}
 }
 
 func (x *CollectorMessage) GetPayload() isCollectorMessage_Payload {
@@ -401,6 +2647,42 @@ func (x *CollectorMessage) GetAck() *Acknowledgment {
 	return nil
 }
 
+func (x *CollectorMessage) GetCommand() *Command {
+	if x != nil {
+		if x, ok := x.Payload.(*CollectorMessage_Command); ok {
+			return x.Command
+		}
+	}
+	return nil
+}
+
+func (x *CollectorMessage) GetConfig() *AgentConfig {
+	if x != nil {
+		if x, ok := x.Payload.(*CollectorMessage_Config); ok {
+			return x.Config
+		}
+	}
+	return nil
+}
+
+func (x *CollectorMessage) GetWelcome() *Welcome {
+	if x != nil {
+		if x, ok := x.Payload.(*CollectorMessage_Welcome); ok {
+			return x.Welcome
+		}
+	}
+	return nil
+}
+
+func (x *CollectorMessage) GetChecks() *CheckAssignment {
+	if x != nil {
+		if x, ok := x.Payload.(*CollectorMessage_Checks); ok {
+			return x.Checks
+		}
+	}
+	return nil
+}
+
 type isCollectorMessage_Payload interface {
 	isCollectorMessage_Payload()
 }
@@ -409,8 +2691,32 @@ type CollectorMessage_Ack struct {
 	Ack *Acknowledgment `protobuf:"bytes,1,opt,name=ack,proto3,oneof"`
 }
 
+type CollectorMessage_Command struct {
+	Command *Command `protobuf:"bytes,2,opt,name=command,proto3,oneof"`
+}
+
+type CollectorMessage_Config struct {
+	Config *AgentConfig `protobuf:"bytes,3,opt,name=config,proto3,oneof"`
+}
+
+type CollectorMessage_Welcome struct {
+	Welcome *Welcome `protobuf:"bytes,4,opt,name=welcome,proto3,oneof"`
+}
+
+type CollectorMessage_Checks struct {
+	Checks *CheckAssignment `protobuf:"bytes,5,opt,name=checks,proto3,oneof"`
+}
+
 func (*CollectorMessage_Ack) isCollectorMessage_Payload() {}
 
+func (*CollectorMessage_Command) isCollectorMessage_Payload() {}
+
+func (*CollectorMessage_Config) isCollectorMessage_Payload() {}
+
+func (*CollectorMessage_Welcome) isCollectorMessage_Payload() {}
+
+func (*CollectorMessage_Checks) isCollectorMessage_Payload() {}
+
 var File_proto_metrics_proto protoreflect.FileDescriptor
 
 const file_proto_metrics_proto_rawDesc = "" +
@@ -431,15 +2737,209 @@ const file_proto_metrics_proto_rawDesc = "" +
 	"\vcpu_percent\x18\x01 \x01(\x01R\n" +
 	"cpuPercent\x12*\n" +
 	"\x11used_memory_bytes\x18\x02 \x01(\x03R\x0fusedMemoryBytes\x12,\n" +
-	"\x12used_storage_bytes\x18\x03 \x01(\x03R\x10usedStorageBytes\"D\n" +
+	"\x12used_storage_bytes\x18\x03 \x01(\x03R\x10usedStorageBytes\"\\\n" +
 	"\x0eAcknowledgment\x12\x18\n" +
 	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
-	"\amessage\x18\x02 \x01(\tR\amessage\"K\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x16\n" +
+	"\x06resend\x18\x03 \x01(\tR\x06resend\"\xd7\x01\n" +
+	"\aCommand\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x126\n" +
+	"\vcollect_now\x18\x02 \x01(\v2\x13.metrics.CollectNowH\x00R\n" +
+	"collectNow\x129\n" +
+	"\fset_interval\x18\x03 \x01(\v2\x14.metrics.SetIntervalH\x00R\vsetInterval\x12?\n" +
+	"\x0erun_diagnostic\x18\x04 \x01(\v2\x16.metrics.RunDiagnosticH\x00R\rrunDiagnosticB\b\n" +
+	"\x06action\"\f\n" +
+	"\n" +
+	"CollectNow\"8\n" +
+	"\vSetInterval\x12)\n" +
+	"\x10interval_seconds\x18\x01 \x01(\x05R\x0fintervalSeconds\"#\n" +
+	"\rRunDiagnostic\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"g\n" +
+	"\rCommandResult\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x18\n" +
+	"\asuccess\x18\x02 \x01(\bR\asuccess\x12\x16\n" +
+	"\x06output\x18\x03 \x01(\tR\x06output\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\"\xf2\x01\n" +
+	"\vAgentConfig\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\x03R\aversion\x12\x18\n" +
+	"\aprofile\x18\x02 \x01(\tR\aprofile\x126\n" +
+	"\x17report_interval_seconds\x18\x03 \x01(\x05R\x15reportIntervalSeconds\x12-\n" +
+	"\x12enabled_collectors\x18\x04 \x03(\tR\x11enabledCollectors\x12#\n" +
+	"\rmount_include\x18\x05 \x03(\tR\fmountInclude\x12#\n" +
+	"\rmount_exclude\x18\x06 \x03(\tR\fmountExclude\"Y\n" +
+	"\rConfigApplied\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\x03R\aversion\x12\x18\n" +
+	"\asuccess\x18\x02 \x01(\bR\asuccess\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\"\xbb\x01\n" +
+	"\x05Hello\x12\x1a\n" +
+	"\bhostname\x18\x01 \x01(\tR\bhostname\x12#\n" +
+	"\ragent_version\x18\x02 \x01(\tR\fagentVersion\x12)\n" +
+	"\x10protocol_version\x18\x03 \x01(\x05R\x0fprotocolVersion\x12\x0e\n" +
+	"\x02os\x18\x04 \x01(\tR\x02os\x12\x12\n" +
+	"\x04arch\x18\x05 \x01(\tR\x04arch\x12\"\n" +
+	"\fcapabilities\x18\x06 \x03(\tR\fcapabilities\"\x98\x01\n" +
+	"\aWelcome\x12-\n" +
+	"\x12controller_version\x18\x01 \x01(\tR\x11controllerVersion\x12)\n" +
+	"\x10protocol_version\x18\x02 \x01(\x05R\x0fprotocolVersion\x123\n" +
+	"\x15accepted_capabilities\x18\x03 \x03(\tR\x14acceptedCapabilities\"\x8f\x03\n" +
+	"\tInventory\x12\x1a\n" +
+	"\bhostname\x18\x01 \x01(\tR\bhostname\x12\x1c\n" +
+	"\ttimestamp\x18\x02 \x01(\x03R\ttimestamp\x12\x17\n" +
+	"\aos_name\x18\x03 \x01(\tR\x06osName\x12\x1d\n" +
+	"\n" +
+	"os_version\x18\x04 \x01(\tR\tosVersion\x12%\n" +
+	"\x0ekernel_version\x18\x05 \x01(\tR\rkernelVersion\x12\x12\n" +
+	"\x04arch\x18\x06 \x01(\tR\x04arch\x12\x1b\n" +
+	"\tcpu_model\x18\a \x01(\tR\bcpuModel\x12&\n" +
+	"\x0evirtualization\x18\b \x01(\tR\x0evirtualization\x12\x1c\n" +
+	"\tcontainer\x18\t \x01(\tR\tcontainer\x12\x1b\n" +
+	"\tboot_time\x18\n" +
+	" \x01(\x03R\bbootTime\x12\x1a\n" +
+	"\btimezone\x18\v \x01(\tR\btimezone\x129\n" +
+	"\n" +
+	"interfaces\x18\f \x03(\v2\x19.metrics.NetworkInterfaceR\n" +
+	"interfaces\"V\n" +
+	"\x10NetworkInterface\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x10\n" +
+	"\x03mac\x18\x02 \x01(\tR\x03mac\x12\x1c\n" +
+	"\taddresses\x18\x03 \x03(\tR\taddresses\"y\n" +
+	"\rCgroupMetrics\x12\x1a\n" +
+	"\bhostname\x18\x01 \x01(\tR\bhostname\x12\x1c\n" +
+	"\ttimestamp\x18\x02 \x01(\x03R\ttimestamp\x12.\n" +
+	"\acgroups\x18\x03 \x03(\v2\x14.metrics.CgroupUsageR\acgroups\"\x87\x02\n" +
+	"\vCgroupUsage\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x18\n" +
+	"\aruntime\x18\x02 \x01(\tR\aruntime\x12\x0e\n" +
+	"\x02id\x18\x03 \x01(\tR\x02id\x12\x1f\n" +
+	"\vcpu_percent\x18\x04 \x01(\x01R\n" +
+	"cpuPercent\x12!\n" +
+	"\fmemory_bytes\x18\x05 \x01(\x03R\vmemoryBytes\x12,\n" +
+	"\x12memory_limit_bytes\x18\x06 \x01(\x03R\x10memoryLimitBytes\x12\"\n" +
+	"\rio_read_bytes\x18\a \x01(\x03R\vioReadBytes\x12$\n" +
+	"\x0eio_write_bytes\x18\b \x01(\x03R\fioWriteBytes\"}\n" +
+	"\rContainerList\x12\x1a\n" +
+	"\bhostname\x18\x01 \x01(\tR\bhostname\x12\x1c\n" +
+	"\ttimestamp\x18\x02 \x01(\x03R\ttimestamp\x122\n" +
+	"\n" +
+	"containers\x18\x03 \x03(\v2\x12.metrics.ContainerR\n" +
+	"containers\"\xe4\x03\n" +
+	"\tContainer\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x14\n" +
+	"\x05image\x18\x03 \x01(\tR\x05image\x12\x14\n" +
+	"\x05state\x18\x04 \x01(\tR\x05state\x12\x16\n" +
+	"\x06status\x18\x05 \x01(\tR\x06status\x12#\n" +
+	"\rrestart_count\x18\x06 \x01(\x05R\frestartCount\x12\x18\n" +
+	"\acreated\x18\a \x01(\x03R\acreated\x12\x1f\n" +
+	"\vcpu_percent\x18\b \x01(\x01R\n" +
+	"cpuPercent\x12!\n" +
+	"\fmemory_bytes\x18\t \x01(\x03R\vmemoryBytes\x12,\n" +
+	"\x12memory_limit_bytes\x18\n" +
+	" \x01(\x03R\x10memoryLimitBytes\x12(\n" +
+	"\x10network_rx_bytes\x18\v \x01(\x03R\x0enetworkRxBytes\x12(\n" +
+	"\x10network_tx_bytes\x18\f \x01(\x03R\x0enetworkTxBytes\x12(\n" +
+	"\x10block_read_bytes\x18\r \x01(\x03R\x0eblockReadBytes\x12*\n" +
+	"\x11block_write_bytes\x18\x0e \x01(\x03R\x0fblockWriteBytes\x12\x14\n" +
+	"\x05error\x18\x0f \x01(\tR\x05error\"\xce\x02\n" +
+	"\x0fCheckDefinition\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x12\n" +
+	"\x04type\x18\x03 \x01(\tR\x04type\x12\x16\n" +
+	"\x06target\x18\x04 \x01(\tR\x06target\x12)\n" +
+	"\x10interval_seconds\x18\x05 \x01(\x05R\x0fintervalSeconds\x12'\n" +
+	"\x0ftimeout_seconds\x18\x06 \x01(\x05R\x0etimeoutSeconds\x12'\n" +
+	"\x0fexpected_status\x18\a \x01(\x05R\x0eexpectedStatus\x12\x1d\n" +
+	"\n" +
+	"body_regex\x18\b \x01(\tR\tbodyRegex\x12$\n" +
+	"\x0emin_days_valid\x18\t \x01(\x05R\fminDaysValid\x12)\n" +
+	"\x10expected_address\x18\n" +
+	" \x01(\tR\x0fexpectedAddress\"C\n" +
+	"\x0fCheckAssignment\x120\n" +
+	"\x06checks\x18\x01 \x03(\v2\x18.metrics.CheckDefinitionR\x06checks\"\xad\x01\n" +
+	"\vCheckResult\x12\x19\n" +
+	"\bcheck_id\x18\x01 \x01(\x03R\acheckId\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x1c\n" +
+	"\ttimestamp\x18\x03 \x01(\x03R\ttimestamp\x12\x18\n" +
+	"\asuccess\x18\x04 \x01(\bR\asuccess\x12\x1d\n" +
+	"\n" +
+	"latency_ms\x18\x05 \x01(\x01R\tlatencyMs\x12\x18\n" +
+	"\amessage\x18\x06 \x01(\tR\amessage\"\x85\x01\n" +
+	"\x0fCertificateList\x12\x1a\n" +
+	"\bhostname\x18\x01 \x01(\tR\bhostname\x12\x1c\n" +
+	"\ttimestamp\x18\x02 \x01(\x03R\ttimestamp\x128\n" +
+	"\fcertificates\x18\x03 \x03(\v2\x14.metrics.CertificateR\fcertificates\"\xe1\x01\n" +
+	"\vCertificate\x12\x16\n" +
+	"\x06source\x18\x01 \x01(\tR\x06source\x12\x18\n" +
+	"\asubject\x18\x02 \x01(\tR\asubject\x12\x16\n" +
+	"\x06issuer\x18\x03 \x01(\tR\x06issuer\x12\x12\n" +
+	"\x04sans\x18\x04 \x03(\tR\x04sans\x12\x1d\n" +
+	"\n" +
+	"not_before\x18\x05 \x01(\x03R\tnotBefore\x12\x1b\n" +
+	"\tnot_after\x18\x06 \x01(\x03R\bnotAfter\x12\x16\n" +
+	"\x06serial\x18\a \x01(\tR\x06serial\x12 \n" +
+	"\vfingerprint\x18\b \x01(\tR\vfingerprint\"u\n" +
+	"\x0eUnitStatusList\x12\x1a\n" +
+	"\bhostname\x18\x01 \x01(\tR\bhostname\x12\x1c\n" +
+	"\ttimestamp\x18\x02 \x01(\x03R\ttimestamp\x12)\n" +
+	"\x05units\x18\x03 \x03(\v2\x13.metrics.UnitStatusR\x05units\"\xb7\x01\n" +
+	"\n" +
+	"UnitStatus\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12 \n" +
+	"\vdescription\x18\x02 \x01(\tR\vdescription\x12\x1d\n" +
+	"\n" +
+	"load_state\x18\x03 \x01(\tR\tloadState\x12!\n" +
+	"\factive_state\x18\x04 \x01(\tR\vactiveState\x12\x1b\n" +
+	"\tsub_state\x18\x05 \x01(\tR\bsubState\x12\x14\n" +
+	"\x05since\x18\x06 \x01(\x03R\x05since\"\x9f\x01\n" +
+	"\tLogCounts\x12\x1a\n" +
+	"\bhostname\x18\x01 \x01(\tR\bhostname\x12\x1c\n" +
+	"\ttimestamp\x18\x02 \x01(\x03R\ttimestamp\x12)\n" +
+	"\x10interval_seconds\x18\x03 \x01(\x05R\x0fintervalSeconds\x12-\n" +
+	"\x06counts\x18\x04 \x03(\v2\x15.metrics.LogRuleCountR\x06counts\"\xc4\x01\n" +
+	"\fLogRuleCount\x12\x12\n" +
+	"\x04rule\x18\x01 \x01(\tR\x04rule\x129\n" +
+	"\x06labels\x18\x02 \x03(\v2!.metrics.LogRuleCount.LabelsEntryR\x06labels\x12\x14\n" +
+	"\x05count\x18\x03 \x01(\x03R\x05count\x12\x14\n" +
+	"\x05lines\x18\x04 \x03(\tR\x05lines\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"|\n" +
+	"\x0eSensorReadings\x12\x1a\n" +
+	"\bhostname\x18\x01 \x01(\tR\bhostname\x12\x1c\n" +
+	"\ttimestamp\x18\x02 \x01(\x03R\ttimestamp\x120\n" +
+	"\asensors\x18\x03 \x03(\v2\x16.metrics.SensorReadingR\asensors\"\xa9\x01\n" +
+	"\rSensorReading\x12\x12\n" +
+	"\x04chip\x18\x01 \x01(\tR\x04chip\x12\x16\n" +
+	"\x06sensor\x18\x02 \x01(\tR\x06sensor\x12\x14\n" +
+	"\x05label\x18\x03 \x01(\tR\x05label\x12\x12\n" +
+	"\x04kind\x18\x04 \x01(\tR\x04kind\x12\x14\n" +
+	"\x05value\x18\x05 \x01(\x01R\x05value\x12\x1a\n" +
+	"\bcritical\x18\x06 \x01(\x01R\bcritical\x12\x10\n" +
+	"\x03max\x18\a \x01(\x01R\x03max\"\xad\x05\n" +
 	"\fAgentMessage\x120\n" +
-	"\ametrics\x18\x01 \x01(\v2\x14.metrics.HostMetricsH\x00R\ametricsB\t\n" +
-	"\apayload\"J\n" +
+	"\ametrics\x18\x01 \x01(\v2\x14.metrics.HostMetricsH\x00R\ametrics\x12?\n" +
+	"\x0ecommand_result\x18\x02 \x01(\v2\x16.metrics.CommandResultH\x00R\rcommandResult\x12?\n" +
+	"\x0econfig_applied\x18\x03 \x01(\v2\x16.metrics.ConfigAppliedH\x00R\rconfigApplied\x12&\n" +
+	"\x05hello\x18\x04 \x01(\v2\x0e.metrics.HelloH\x00R\x05hello\x122\n" +
+	"\tinventory\x18\x05 \x01(\v2\x12.metrics.InventoryH\x00R\tinventory\x122\n" +
+	"\acgroups\x18\x06 \x01(\v2\x16.metrics.CgroupMetricsH\x00R\acgroups\x128\n" +
+	"\n" +
+	"containers\x18\a \x01(\v2\x16.metrics.ContainerListH\x00R\n" +
+	"containers\x129\n" +
+	"\fcheck_result\x18\b \x01(\v2\x14.metrics.CheckResultH\x00R\vcheckResult\x12>\n" +
+	"\fcertificates\x18\t \x01(\v2\x18.metrics.CertificateListH\x00R\fcertificates\x12/\n" +
+	"\x05units\x18\n" +
+	" \x01(\v2\x17.metrics.UnitStatusListH\x00R\x05units\x123\n" +
+	"\n" +
+	"log_counts\x18\v \x01(\v2\x12.metrics.LogCountsH\x00R\tlogCounts\x123\n" +
+	"\asensors\x18\f \x01(\v2\x17.metrics.SensorReadingsH\x00R\asensorsB\t\n" +
+	"\apayload\"\x8a\x02\n" +
 	"\x10CollectorMessage\x12+\n" +
-	"\x03ack\x18\x01 \x01(\v2\x17.metrics.AcknowledgmentH\x00R\x03ackB\t\n" +
+	"\x03ack\x18\x01 \x01(\v2\x17.metrics.AcknowledgmentH\x00R\x03ack\x12,\n" +
+	"\acommand\x18\x02 \x01(\v2\x10.metrics.CommandH\x00R\acommand\x12.\n" +
+	"\x06config\x18\x03 \x01(\v2\x14.metrics.AgentConfigH\x00R\x06config\x12,\n" +
+	"\awelcome\x18\x04 \x01(\v2\x10.metrics.WelcomeH\x00R\awelcome\x122\n" +
+	"\x06checks\x18\x05 \x01(\v2\x18.metrics.CheckAssignmentH\x00R\x06checksB\t\n" +
 	"\apayload2Y\n" +
 	"\x10MetricsCollector\x12E\n" +
 	"\rStreamMetrics\x12\x15.metrics.AgentMessage\x1a\x19.metrics.CollectorMessage(\x010\x01B$Z\"github.com/metorial/sentinel/protob\x06proto3"
@@ -456,27 +2956,81 @@ func file_proto_metrics_proto_rawDescGZIP() []byte {
 	return file_proto_metrics_proto_rawDescData
 }
 
-var file_proto_metrics_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_proto_metrics_proto_msgTypes = make([]protoimpl.MessageInfo, 33)
 var file_proto_metrics_proto_goTypes = []any{
 	(*HostMetrics)(nil),      // 0: metrics.HostMetrics
 	(*HostInfo)(nil),         // 1: metrics.HostInfo
 	(*ResourceUsage)(nil),    // 2: metrics.ResourceUsage
 	(*Acknowledgment)(nil),   // 3: metrics.Acknowledgment
-	(*AgentMessage)(nil),     // 4: metrics.AgentMessage
-	(*CollectorMessage)(nil), // 5: metrics.CollectorMessage
+	(*Command)(nil),          // 4: metrics.Command
+	(*CollectNow)(nil),       // 5: metrics.CollectNow
+	(*SetInterval)(nil),      // 6: metrics.SetInterval
+	(*RunDiagnostic)(nil),    // 7: metrics.RunDiagnostic
+	(*CommandResult)(nil),    // 8: metrics.CommandResult
+	(*AgentConfig)(nil),      // 9: metrics.AgentConfig
+	(*ConfigApplied)(nil),    // 10: metrics.ConfigApplied
+	(*Hello)(nil),            // 11: metrics.Hello
+	(*Welcome)(nil),          // 12: metrics.Welcome
+	(*Inventory)(nil),        // 13: metrics.Inventory
+	(*NetworkInterface)(nil), // 14: metrics.NetworkInterface
+	(*CgroupMetrics)(nil),    // 15: metrics.CgroupMetrics
+	(*CgroupUsage)(nil),      // 16: metrics.CgroupUsage
+	(*ContainerList)(nil),    // 17: metrics.ContainerList
+	(*Container)(nil),        // 18: metrics.Container
+	(*CheckDefinition)(nil),  // 19: metrics.CheckDefinition
+	(*CheckAssignment)(nil),  // 20: metrics.CheckAssignment
+	(*CheckResult)(nil),      // 21: metrics.CheckResult
+	(*CertificateList)(nil),  // 22: metrics.CertificateList
+	(*Certificate)(nil),      // 23: metrics.Certificate
+	(*UnitStatusList)(nil),   // 24: metrics.UnitStatusList
+	(*UnitStatus)(nil),       // 25: metrics.UnitStatus
+	(*LogCounts)(nil),        // 26: metrics.LogCounts
+	(*LogRuleCount)(nil),     // 27: metrics.LogRuleCount
+	(*SensorReadings)(nil),   // 28: metrics.SensorReadings
+	(*SensorReading)(nil),    // 29: metrics.SensorReading
+	(*AgentMessage)(nil),     // 30: metrics.AgentMessage
+	(*CollectorMessage)(nil), // 31: metrics.CollectorMessage
+	nil,                      // 32: metrics.LogRuleCount.LabelsEntry
 }
 var file_proto_metrics_proto_depIdxs = []int32{
-	1, // 0: metrics.HostMetrics.info:type_name -> metrics.HostInfo
-	2, // 1: metrics.HostMetrics.usage:type_name -> metrics.ResourceUsage
-	0, // 2: metrics.AgentMessage.metrics:type_name -> metrics.HostMetrics
-	3, // 3: metrics.CollectorMessage.ack:type_name -> metrics.Acknowledgment
-	4, // 4: metrics.MetricsCollector.StreamMetrics:input_type -> metrics.AgentMessage
-	5, // 5: metrics.MetricsCollector.StreamMetrics:output_type -> metrics.CollectorMessage
-	5, // [5:6] is the sub-list for method output_type
-	4, // [4:5] is the sub-list for method input_type
-	4, // [4:4] is the sub-list for extension type_name
-	4, // [4:4] is the sub-list for extension extendee
-	0, // [0:4] is the sub-list for field type_name
+	1,  // 0: metrics.HostMetrics.info:type_name -> metrics.HostInfo
+	2,  // 1: metrics.HostMetrics.usage:type_name -> metrics.ResourceUsage
+	5,  // 2: metrics.Command.collect_now:type_name -> metrics.CollectNow
+	6,  // 3: metrics.Command.set_interval:type_name -> metrics.SetInterval
+	7,  // 4: metrics.Command.run_diagnostic:type_name -> metrics.RunDiagnostic
+	14, // 5: metrics.Inventory.interfaces:type_name -> metrics.NetworkInterface
+	16, // 6: metrics.CgroupMetrics.cgroups:type_name -> metrics.CgroupUsage
+	18, // 7: metrics.ContainerList.containers:type_name -> metrics.Container
+	19, // 8: metrics.CheckAssignment.checks:type_name -> metrics.CheckDefinition
+	23, // 9: metrics.CertificateList.certificates:type_name -> metrics.Certificate
+	25, // 10: metrics.UnitStatusList.units:type_name -> metrics.UnitStatus
+	27, // 11: metrics.LogCounts.counts:type_name -> metrics.LogRuleCount
+	32, // 12: metrics.LogRuleCount.labels:type_name -> metrics.LogRuleCount.LabelsEntry
+	29, // 13: metrics.SensorReadings.sensors:type_name -> metrics.SensorReading
+	0,  // 14: metrics.AgentMessage.metrics:type_name -> metrics.HostMetrics
+	8,  // 15: metrics.AgentMessage.command_result:type_name -> metrics.CommandResult
+	10, // 16: metrics.AgentMessage.config_applied:type_name -> metrics.ConfigApplied
+	11, // 17: metrics.AgentMessage.hello:type_name -> metrics.Hello
+	13, // 18: metrics.AgentMessage.inventory:type_name -> metrics.Inventory
+	15, // 19: metrics.AgentMessage.cgroups:type_name -> metrics.CgroupMetrics
+	17, // 20: metrics.AgentMessage.containers:type_name -> metrics.ContainerList
+	21, // 21: metrics.AgentMessage.check_result:type_name -> metrics.CheckResult
+	22, // 22: metrics.AgentMessage.certificates:type_name -> metrics.CertificateList
+	24, // 23: metrics.AgentMessage.units:type_name -> metrics.UnitStatusList
+	26, // 24: metrics.AgentMessage.log_counts:type_name -> metrics.LogCounts
+	28, // 25: metrics.AgentMessage.sensors:type_name -> metrics.SensorReadings
+	3,  // 26: metrics.CollectorMessage.ack:type_name -> metrics.Acknowledgment
+	4,  // 27: metrics.CollectorMessage.command:type_name -> metrics.Command
+	9,  // 28: metrics.CollectorMessage.config:type_name -> metrics.AgentConfig
+	12, // 29: metrics.CollectorMessage.welcome:type_name -> metrics.Welcome
+	20, // 30: metrics.CollectorMessage.checks:type_name -> metrics.CheckAssignment
+	30, // 31: metrics.MetricsCollector.StreamMetrics:input_type -> metrics.AgentMessage
+	31, // 32: metrics.MetricsCollector.StreamMetrics:output_type -> metrics.CollectorMessage
+	32, // [32:33] is the sub-list for method output_type
+	31, // [31:32] is the sub-list for method input_type
+	31, // [31:31] is the sub-list for extension type_name
+	31, // [31:31] is the sub-list for extension extendee
+	0,  // [0:31] is the sub-list for field type_name
 }
 
 func init() { file_proto_metrics_proto_init() }
@@ -485,10 +3039,30 @@ func file_proto_metrics_proto_init() {
 		return
 	}
 	file_proto_metrics_proto_msgTypes[4].OneofWrappers = []any{
+		(*Command_CollectNow)(nil),
+		(*Command_SetInterval)(nil),
+		(*Command_RunDiagnostic)(nil),
+	}
+	file_proto_metrics_proto_msgTypes[30].OneofWrappers = []any{
 		(*AgentMessage_Metrics)(nil),
+		(*AgentMessage_CommandResult)(nil),
+		(*AgentMessage_ConfigApplied)(nil),
+		(*AgentMessage_Hello)(nil),
+		(*AgentMessage_Inventory)(nil),
+		(*AgentMessage_Cgroups)(nil),
+		(*AgentMessage_Containers)(nil),
+		(*AgentMessage_CheckResult)(nil),
+		(*AgentMessage_Certificates)(nil),
+		(*AgentMessage_Units)(nil),
+		(*AgentMessage_LogCounts)(nil),
+		(*AgentMessage_Sensors)(nil),
 	}
-	file_proto_metrics_proto_msgTypes[5].OneofWrappers = []any{
+	file_proto_metrics_proto_msgTypes[31].OneofWrappers = []any{
 		(*CollectorMessage_Ack)(nil),
+		(*CollectorMessage_Command)(nil),
+		(*CollectorMessage_Config)(nil),
+		(*CollectorMessage_Welcome)(nil),
+		(*CollectorMessage_Checks)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -496,7 +3070,7 @@ func file_proto_metrics_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_metrics_proto_rawDesc), len(file_proto_metrics_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   6,
+			NumMessages:   33,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
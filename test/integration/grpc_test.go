@@ -268,8 +268,9 @@ func TestInactiveHostMarking(t *testing.T) {
 	}
 	defer listener.Close()
 
+	server := commander.NewServer(db)
 	grpcServer := grpc.NewServer()
-	pb.RegisterMetricsCollectorServer(grpcServer, commander.NewServer(db))
+	pb.RegisterMetricsCollectorServer(grpcServer, server)
 
 	go func() {
 		if err := grpcServer.Serve(listener); err != nil {
@@ -330,24 +331,33 @@ func TestInactiveHostMarking(t *testing.T) {
 		t.Error("Expected host to be online")
 	}
 
-	if err := db.MarkInactive(1 * time.Second); err != nil {
-		t.Fatalf("Failed to mark inactive: %v", err)
-	}
-
-	time.Sleep(1100 * time.Millisecond)
-
-	if err := db.MarkInactive(1 * time.Second); err != nil {
-		t.Fatalf("Failed to mark inactive: %v", err)
+	// A host with an open stream stays online however long the sweep waits
+	if err := server.SweepPresence(time.Now().Add(10 * time.Second)); err != nil {
+		t.Fatalf("Failed to sweep presence: %v", err)
 	}
-
 	err = db.QueryRow("SELECT online FROM hosts WHERE hostname = ?", "test-host").Scan(&online)
 	if err != nil {
 		t.Fatalf("Failed to query host: %v", err)
 	}
+	if !online {
+		t.Error("Expected host to stay online while its stream is open")
+	}
+
+	// Once the stream is gone, the host goes offline after the timeout
+	stream.CloseSend()
+	deadline := time.Now().Add(2 * time.Second)
+	for online && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		if err := server.SweepPresence(time.Now().Add(2 * time.Minute)); err != nil {
+			t.Fatalf("Failed to sweep presence: %v", err)
+		}
+		err = db.QueryRow("SELECT online FROM hosts WHERE hostname = ?", "test-host").Scan(&online)
+		if err != nil {
+			t.Fatalf("Failed to query host: %v", err)
+		}
+	}
 
 	if online {
 		t.Error("Expected host to be offline after timeout")
 	}
-
-	stream.CloseSend()
 }